@@ -0,0 +1,656 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/astro"
+	"github.com/mithilarun/limelight/internal/automation"
+	"github.com/mithilarun/limelight/internal/bridge"
+	"github.com/mithilarun/limelight/internal/calendar"
+	"github.com/mithilarun/limelight/internal/credentials"
+	"github.com/mithilarun/limelight/internal/db"
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// NewAutomationsCommand creates the automations command for managing rules
+func NewAutomationsCommand(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "automations",
+		Aliases: []string{"automation"},
+		Short:   "Manage automation rules",
+		Long: `Manage automation rules, which are written as JSON files and imported.
+
+Conditions listed on an automation must all hold. Combine them differently with
+groups, which nest conditions in their config:
+
+  {"type": "any", "config": {"conditions": [{"type": "weekend"}, {"type": "date_range", "config": {"start": "12-24", "end": "12-26"}}]}}
+
+An all group holds when every nested condition does, an any group when at least
+one does, and a not group when its conditions don't all hold.`,
+	}
+
+	cmd.AddCommand(newAutomationsListCommand())
+	cmd.AddCommand(newAutomationsShowCommand())
+	cmd.AddCommand(newAutomationsImportCommand())
+	cmd.AddCommand(newAutomationsExportCommand())
+	cmd.AddCommand(newAutomationsEnableCommand(true))
+	cmd.AddCommand(newAutomationsEnableCommand(false))
+	cmd.AddCommand(newAutomationsDeleteCommand())
+	cmd.AddCommand(newAutomationsCheckCommand(logger))
+	cmd.AddCommand(newAutomationsRunCommand(logger))
+	cmd.AddCommand(newAutomationsHistoryCommand())
+
+	return cmd
+}
+
+func newAutomationsListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List automations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			store, closeStore, err := openStore()
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+
+			automations, err := store.ListAutomations(ctx)
+			if err != nil {
+				return err
+			}
+			if len(automations) == 0 {
+				fmt.Println("No automations, add one with 'limelight automations import'")
+				return nil
+			}
+
+			for _, a := range automations {
+				state := "enabled"
+				if !a.Enabled {
+					state = "disabled"
+				}
+				fmt.Printf("%-4d %-32s %-8s %s\n", a.ID, a.Name, state, a.Description)
+			}
+			return nil
+		},
+	}
+}
+
+func newAutomationsShowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <name|id>",
+		Short: "Show an automation's triggers, conditions and actions",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			store, closeStore, err := openStore()
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+
+			spec, err := findAutomationSpec(ctx, store, args[0])
+			if err != nil {
+				return err
+			}
+
+			printAutomationSpec(spec)
+			return nil
+		},
+	}
+}
+
+func newAutomationsImportCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import <file>",
+		Short: "Create or replace automations from a JSON file",
+		Long: `Create automations from a JSON file holding one automation or a list of them.
+An automation with the same name as an existing one replaces it. Use - to read
+from standard input. Nothing is saved unless every automation in the file is valid.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			data, err := readFileOrStdin(args[0])
+			if err != nil {
+				return err
+			}
+
+			specs, err := automation.ParseFile(data)
+			if err != nil {
+				return err
+			}
+
+			engine := automation.NewEngine()
+			for _, spec := range specs {
+				if err := engine.ValidateSpec(spec); err != nil {
+					return errors.Wrapf(err, "automation %q", spec.Name)
+				}
+			}
+
+			store, closeStore, err := openStore()
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+
+			err = store.WithTx(ctx, func(tx models.Store) error {
+				existing, err := tx.ListAutomations(ctx)
+				if err != nil {
+					return err
+				}
+				for _, spec := range specs {
+					for _, a := range existing {
+						if a.Name == spec.Name {
+							spec.ID = a.ID
+						}
+					}
+					if err := tx.SaveAutomationSpec(ctx, spec); err != nil {
+						return errors.Wrapf(err, "saving automation %q", spec.Name)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, spec := range specs {
+				fmt.Printf("Imported %s (%d)\n", spec.Name, spec.ID)
+			}
+			return nil
+		},
+	}
+}
+
+func newAutomationsExportCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export [name|id...]",
+		Short: "Write automations as JSON for editing or backup",
+		Long:  "Write the named automations, or all of them, in the format 'limelight automations import' reads.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			store, closeStore, err := openStore()
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+
+			var specs []*models.AutomationSpec
+			if len(args) == 0 {
+				automations, err := store.ListAutomations(ctx)
+				if err != nil {
+					return err
+				}
+				// Oldest first, so a re-import keeps the original order
+				for i := len(automations) - 1; i >= 0; i-- {
+					spec, err := store.LoadAutomationSpec(ctx, automations[i].ID)
+					if err != nil {
+						return err
+					}
+					specs = append(specs, spec)
+				}
+			} else {
+				for _, ref := range args {
+					spec, err := findAutomationSpec(ctx, store, ref)
+					if err != nil {
+						return err
+					}
+					specs = append(specs, spec)
+				}
+			}
+
+			data, err := automation.MarshalFiles(specs)
+			if err != nil {
+				return err
+			}
+
+			if output == "" || output == "-" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if err := os.WriteFile(output, data, 0644); err != nil {
+				return errors.Wrap(err, "writing automations")
+			}
+			fmt.Printf("Exported %d automations to %s\n", len(specs), output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write instead of standard output")
+
+	return cmd
+}
+
+func newAutomationsEnableCommand(enable bool) *cobra.Command {
+	use, short, done := "enable", "Enable an automation", "Enabled"
+	if !enable {
+		use, short, done = "disable", "Disable an automation without deleting it", "Disabled"
+	}
+
+	return &cobra.Command{
+		Use:   use + " <name|id>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			store, closeStore, err := openStore()
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+
+			a, err := findAutomation(ctx, store, args[0])
+			if err != nil {
+				return err
+			}
+			if err := store.SetEnabled(ctx, a.ID, enable); err != nil {
+				return err
+			}
+
+			fmt.Printf("%s %s\n", done, a.Name)
+			return nil
+		},
+	}
+}
+
+func newAutomationsDeleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <name|id>",
+		Short: "Delete an automation",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			store, closeStore, err := openStore()
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+
+			a, err := findAutomation(ctx, store, args[0])
+			if err != nil {
+				return err
+			}
+			if err := store.DeleteAutomation(ctx, a.ID); err != nil {
+				return err
+			}
+
+			fmt.Printf("Deleted %s\n", a.Name)
+			return nil
+		},
+	}
+}
+
+func newAutomationsCheckCommand(logger *zap.Logger) *cobra.Command {
+	var at string
+
+	cmd := &cobra.Command{
+		Use:   "check <name|id>",
+		Short: "Evaluate an automation's conditions now or at another time",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			database, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			spec, err := findAutomationSpec(ctx, models.NewSQLStore(database), args[0])
+			if err != nil {
+				return err
+			}
+
+			env, err := newAutomationEnv(ctx, database, logger)
+			if err != nil {
+				return err
+			}
+			if at != "" {
+				env.Now, err = time.ParseInLocation("2006-01-02T15:04", at, env.Now.Location())
+				if err != nil {
+					return errors.Wrapf(err, "parsing --at %q (expected YYYY-MM-DDTHH:MM)", at)
+				}
+			}
+
+			engine := automation.NewEngine()
+			passed := true
+			for _, c := range spec.Conditions {
+				ok, err := engine.CheckCondition(ctx, env, c.Type, c.Config)
+				if err != nil {
+					return errors.Wrapf(err, "checking %s condition", c.Type)
+				}
+				passed = passed && ok
+				description := strings.ReplaceAll(describeCondition(c.Type, c.Config), "\n", "\n      ")
+				fmt.Printf("%-5s %s\n", passFail(ok), description)
+			}
+
+			fmt.Printf("\n%s at %s: conditions %s\n", spec.Name, env.Now.Format("2006-01-02 15:04 MST"), passFail(passed))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&at, "at", "", "Evaluate at this time in the home time zone (YYYY-MM-DDTHH:MM)")
+
+	return cmd
+}
+
+func newAutomationsRunCommand(logger *zap.Logger) *cobra.Command {
+	var skipConditions bool
+
+	cmd := &cobra.Command{
+		Use:   "run <name|id>",
+		Short: "Run an automation's actions now if its conditions hold",
+		Long: `Run an automation's actions now, regardless of its triggers, if its
+conditions hold. Delays and waits run in the foreground; press Ctrl-C to cancel.
+The run is recorded in the automation's history.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			database, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			store := models.NewSQLStore(database)
+			spec, err := findAutomationSpec(ctx, store, args[0])
+			if err != nil {
+				return err
+			}
+
+			env, err := newAutomationEnv(ctx, database, logger)
+			if err != nil {
+				return err
+			}
+
+			engine := automation.NewEngine()
+			if err := engine.ValidateSpec(spec); err != nil {
+				return errors.Wrapf(err, "automation %q", spec.Name)
+			}
+			if !skipConditions {
+				ok, err := engine.CheckConditions(ctx, env, spec.Conditions)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Printf("%s: conditions don't hold, nothing to do\n", spec.Name)
+					return nil
+				}
+			}
+
+			run, err := engine.RunAutomation(ctx, env, store, spec)
+			if errors.Is(err, automation.ErrStopped) {
+				fmt.Printf("%s: %v (run %d)\n", spec.Name, err, run.ID)
+				return nil
+			}
+			if err != nil {
+				if run != nil {
+					return errors.Wrapf(err, "run %d", run.ID)
+				}
+				return err
+			}
+
+			fmt.Printf("%s: done (run %d)\n", spec.Name, run.ID)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&skipConditions, "skip-conditions", false, "Run the actions even if the conditions don't hold")
+
+	return cmd
+}
+
+func newAutomationsHistoryCommand() *cobra.Command {
+	var limit int
+	var steps bool
+
+	cmd := &cobra.Command{
+		Use:   "history [name|id]",
+		Short: "Show recent runs of one or every automation",
+		Long: `Show recent runs of one or every automation, newest first. With --steps,
+each bridge, webhook, exec and notify action a run performed is listed under it
+with what it did or why it failed.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			store, closeStore, err := openStore()
+			if err != nil {
+				return err
+			}
+			defer closeStore()
+
+			automations, err := store.ListAutomations(ctx)
+			if err != nil {
+				return err
+			}
+			names := make(map[int64]string, len(automations))
+			for _, a := range automations {
+				names[a.ID] = a.Name
+			}
+
+			var automationID int64
+			if len(args) == 1 {
+				a, err := findAutomation(ctx, store, args[0])
+				if err != nil {
+					return err
+				}
+				automationID = a.ID
+			}
+
+			runs, err := store.ListRuns(ctx, automationID, limit)
+			if err != nil {
+				return err
+			}
+			if len(runs) == 0 {
+				fmt.Println("No runs yet")
+				return nil
+			}
+
+			for _, run := range runs {
+				fmt.Printf("%-5d %-24s %s  %-9s %-8s %s\n", run.ID, names[run.AutomationID],
+					run.StartedAt.Local().Format("2006-01-02 15:04:05"), run.Status, runDuration(run), run.Error)
+				if !steps {
+					continue
+				}
+
+				runSteps, err := store.GetRunSteps(ctx, run.ID)
+				if err != nil {
+					return err
+				}
+				for _, step := range runSteps {
+					text := step.Detail
+					if step.Error != "" {
+						text = step.Error
+					}
+					fmt.Printf("      %-7s %-9s %s\n", step.ActionType, step.Status,
+						strings.ReplaceAll(text, "\n", "\n"+strings.Repeat(" ", 24)))
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Show at most this many runs, 0 for all")
+	cmd.Flags().BoolVar(&steps, "steps", false, "List the actions each run performed")
+
+	return cmd
+}
+
+// runDuration formats how long a run took, or "-" if it hasn't finished
+func runDuration(run *models.Run) string {
+	if run.FinishedAt == nil {
+		return "-"
+	}
+	return run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond).String()
+}
+
+// newAutomationEnv builds the environment automations are checked and run in:
+// the home location, the configured bridges and cached calendars
+func newAutomationEnv(ctx context.Context, database *sql.DB, logger *zap.Logger) (*automation.Env, error) {
+	home, err := astro.GetHomeFromConfig()
+	if err != nil {
+		logger.Debug("no home location, using local time", zap.Error(err))
+	}
+	env := automation.NewEnv(home)
+
+	config, err := credentials.LoadConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading config")
+	}
+	if config != nil {
+		pool := bridge.NewClientPool(config, credentialStoreOptions(config, logger), logger)
+		env.Bridge = func(ctx context.Context, profileOrBridgeID string) (automation.Bridge, error) {
+			return pool.Client(ctx, profileOrBridgeID)
+		}
+	}
+
+	ttl, err := db.NewSettings(database).GetDuration(ctx, settingCalendarTTL, calendar.DefaultCacheTTL)
+	logSettingError(logger, settingCalendarTTL, err)
+	env.Calendars = calendar.NewLoader(database, ttl)
+
+	return env, nil
+}
+
+// openStore opens the migrated database as an automation store
+func openStore() (models.Store, func(), error) {
+	database, err := openDatabase()
+	if err != nil {
+		return nil, nil, err
+	}
+	return models.NewSQLStore(database), func() { database.Close() }, nil
+}
+
+// findAutomation looks up an automation by ID or name
+func findAutomation(ctx context.Context, store models.Store, ref string) (*models.Automation, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return store.GetAutomation(ctx, id)
+	}
+
+	automations, err := store.ListAutomations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range automations {
+		if strings.EqualFold(a.Name, ref) {
+			return a, nil
+		}
+	}
+	return nil, errors.Mark(errors.Newf("no automation named %q", ref), models.ErrNotFound)
+}
+
+func findAutomationSpec(ctx context.Context, store models.Store, ref string) (*models.AutomationSpec, error) {
+	a, err := findAutomation(ctx, store, ref)
+	if err != nil {
+		return nil, err
+	}
+	return store.LoadAutomationSpec(ctx, a.ID)
+}
+
+func printAutomationSpec(spec *models.AutomationSpec) {
+	state := "enabled"
+	if !spec.Enabled {
+		state = "disabled"
+	}
+	fmt.Printf("%s (%d, %s)\n", spec.Name, spec.ID, state)
+	if spec.Description != "" {
+		fmt.Printf("  %s\n", spec.Description)
+	}
+
+	fmt.Println("\nTriggers:")
+	for _, t := range spec.Triggers {
+		fmt.Printf("  %s\n", describeNode(string(t.Type), t.Config))
+	}
+
+	fmt.Println("\nConditions (all must hold):")
+	if len(spec.Conditions) == 0 {
+		fmt.Println("  none")
+	}
+	for _, c := range spec.Conditions {
+		fmt.Println(indentLines(describeCondition(c.Type, c.Config), "  "))
+	}
+
+	fmt.Println("\nActions:")
+	for i, a := range spec.Actions {
+		fmt.Printf("  %d. %s\n", i+1, describeNode(string(a.Type), a.Config))
+	}
+}
+
+// describeCondition renders a condition, with groups as an indented tree
+func describeCondition(conditionType models.ConditionType, config json.RawMessage) string {
+	var group automation.GroupConfig
+	switch conditionType {
+	case models.ConditionTypeAll, models.ConditionTypeAny, models.ConditionTypeNot:
+		if err := json.Unmarshal(config, &group); err != nil {
+			return describeNode(string(conditionType), config)
+		}
+	default:
+		return describeNode(string(conditionType), config)
+	}
+
+	label := map[models.ConditionType]string{
+		models.ConditionTypeAll: "all of:",
+		models.ConditionTypeAny: "any of:",
+		models.ConditionTypeNot: "not all of:",
+	}[conditionType]
+	if conditionType == models.ConditionTypeNot && len(group.Conditions) == 1 {
+		label = "not:"
+	}
+
+	lines := []string{label}
+	for _, node := range group.Conditions {
+		lines = append(lines, indentLines(describeCondition(node.Type, node.Config), "  "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// describeNode renders a trigger, condition or action as its type and compact config
+func describeNode(nodeType string, config json.RawMessage) string {
+	trimmed := strings.TrimSpace(string(config))
+	if trimmed == "" || trimmed == "{}" || trimmed == "null" {
+		return nodeType
+	}
+	return nodeType + " " + trimmed
+}
+
+func indentLines(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+func passFail(ok bool) string {
+	if ok {
+		return "pass"
+	}
+	return "fail"
+}
+
+func readFileOrStdin(path string) ([]byte, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return data, errors.Wrap(err, "reading standard input")
+	}
+	data, err := os.ReadFile(path)
+	return data, errors.Wrapf(err, "reading %s", path)
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
+	"github.com/mithilarun/limelight/internal/credentials"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// keyDateLayout formats application key dates in local time
+const keyDateLayout = "2006-01-02 15:04"
+
+func NewBridgeCommand(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bridge",
+		Short: "Manage the Hue bridge",
+	}
+
+	cmd.AddCommand(newBridgeKeysCommand(logger))
+
+	return cmd
+}
+
+func newBridgeKeysCommand(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage application keys registered on the bridge",
+		Long: `List, revoke and rotate the application keys registered on the bridge.
+
+Every pairing registers a new key, and the bridge keeps them until revoked.`,
+	}
+
+	cmd.AddCommand(newListBridgeKeysCommand(logger))
+	cmd.AddCommand(newRevokeBridgeKeyCommand(logger))
+	cmd.AddCommand(newRotateBridgeKeyCommand(logger))
+
+	return cmd
+}
+
+func newListBridgeKeysCommand(logger *zap.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List application keys",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client, err := getAuthenticatedClient(ctx, logger)
+			if err != nil {
+				return err
+			}
+
+			keys, err := client.ListApplicationKeys(ctx)
+			if err != nil {
+				return errors.Wrap(err, "listing application keys")
+			}
+
+			fmt.Printf("Found %d application keys:\n\n", len(keys))
+			for _, key := range keys {
+				current := ""
+				if key.Username == client.APIKey() {
+					current = " (this key)"
+				}
+
+				fmt.Printf("  %s%s\n", key.Name, current)
+				fmt.Printf("    Key: %s\n", key.Username)
+				fmt.Printf("    Created: %s\n", formatKeyDate(key.CreateDate))
+				fmt.Printf("    Last used: %s\n", formatKeyDate(key.LastUseDate))
+				fmt.Println()
+			}
+
+			return nil
+		},
+	}
+}
+
+func newRevokeBridgeKeyCommand(logger *zap.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <key>",
+		Short: "Revoke an application key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client, err := getAuthenticatedClient(ctx, logger)
+			if err != nil {
+				return err
+			}
+
+			if args[0] == client.APIKey() {
+				return errors.New("that is the key limelight uses, run 'limelight bridge keys rotate' to replace it")
+			}
+
+			if err := client.RevokeApplicationKey(ctx, args[0]); err != nil {
+				return errors.WithHint(err, "if the bridge refuses, revoke the key from the Hue app or account.meethue.com")
+			}
+
+			fmt.Printf("Application key %s revoked\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newRotateBridgeKeyCommand(logger *zap.Logger) *cobra.Command {
+	var (
+		authTimeout time.Duration
+		keepOld     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Pair a new application key and revoke the old one",
+		Long: `Pair a new application key and client key with the bridge, save them to the
+configured credential store, then revoke the previous key.
+
+The bridge's link button must be pressed, as during setup.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rotateBridgeKey(logger, authTimeout, keepOld)
+		},
+	}
+
+	cmd.Flags().DurationVar(&authTimeout, "auth-timeout", 60*time.Second, "Timeout for bridge button press")
+	cmd.Flags().BoolVar(&keepOld, "keep-old", false, "Leave the previous key registered on the bridge")
+
+	return cmd
+}
+
+func rotateBridgeKey(logger *zap.Logger, authTimeout time.Duration, keepOld bool) error {
+	ctx := context.Background()
+
+	config, err := loadConfigWithProfiles()
+	if err != nil {
+		return err
+	}
+	if config.BridgeIP == "" {
+		return errors.Newf("profile %s has no bridge configured, run 'limelight setup' first", config.ProfileName)
+	}
+
+	store, err := credentials.NewStoreFromConfig(config, credentialStoreOptions(config, logger))
+	if err != nil {
+		return err
+	}
+
+	ref := config.CredentialItem()
+	oldKey, err := store.Get(ctx, ref, credentials.FieldAPIKey)
+	if err != nil {
+		return errors.Wrapf(err, "getting api key from %s credential store", store.Name())
+	}
+
+	devType, err := deviceType("")
+	if err != nil {
+		return err
+	}
+	if keys, err := bridge.NewClient(config.BridgeIP, oldKey, logger).ListApplicationKeys(ctx); err == nil {
+		for _, key := range keys {
+			if key.Username == oldKey && key.Name != "" {
+				devType = key.Name
+			}
+		}
+	}
+
+	fmt.Println("Press the link button on your Hue Bridge now...")
+	fmt.Printf("Waiting for button press (timeout: %v)...\n", authTimeout)
+
+	authCtx, cancel := context.WithTimeout(ctx, authTimeout)
+	defer cancel()
+
+	auth, err := bridge.NewClient(config.BridgeIP, "", logger).Authenticate(authCtx, devType)
+	if err != nil {
+		return errors.Wrap(err, "authenticating with bridge")
+	}
+
+	if err := saveBridgeCredentials(ctx, store, ref, auth); err != nil {
+		return errors.WithHintf(err, "the new key %s is registered on the bridge but was not saved", auth.Username)
+	}
+
+	if store.Name() == credentials.StoreEnv {
+		fmt.Println()
+		fmt.Println("The previous key was kept. Once the environment is updated, revoke it with:")
+		fmt.Printf("  limelight bridge keys revoke %s\n", oldKey)
+		return nil
+	}
+
+	if keepOld {
+		fmt.Printf("Previous key %s kept on the bridge\n", oldKey)
+		return nil
+	}
+
+	client := bridge.NewClient(config.BridgeIP, auth.Username, logger)
+	if err := client.RevokeApplicationKey(ctx, oldKey); err != nil {
+		logger.Warn("failed to revoke previous application key", zap.Error(err))
+		fmt.Printf("Previous key %s could not be revoked, remove it from the Hue app or account.meethue.com\n", oldKey)
+		return nil
+	}
+
+	fmt.Println("Previous key revoked")
+	return nil
+}
+
+func formatKeyDate(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format(keyDateLayout)
+}
@@ -0,0 +1,508 @@
+package commands
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/astro"
+	"github.com/mithilarun/limelight/internal/calendar"
+	"github.com/mithilarun/limelight/internal/credentials"
+	"github.com/mithilarun/limelight/internal/db"
+	"github.com/mithilarun/limelight/internal/paths"
+	"github.com/spf13/cobra"
+)
+
+// Settings stored in the database config table
+const (
+	settingNominatimURL  = "geocoder.nominatim_url"
+	settingUserAgent     = "geocoder.user_agent"
+	settingGeocodeTTL    = "geocoder.cache_ttl"
+	settingCalendarTTL   = "calendar.cache_ttl"
+	settingBackupDir     = "backup.dir"
+	settingBackupKeep    = "backup.keep"
+	configSourceDatabase = "database"
+	configSourceDefault  = "default"
+)
+
+// configKey describes a setting managed by 'limelight config'. Profile settings
+// are stored in the selected profile of the config file, the rest in the database.
+type configKey struct {
+	name        string
+	description string
+	profile     bool
+	// defaultValue applies to database settings that are unset
+	defaultValue string
+	// get reads a profile setting
+	get func(config *credentials.Config) string
+	// set validates a value and, for profile settings, applies it to config
+	set func(config *credentials.Config, value string) error
+}
+
+var configKeys = []configKey{
+	{
+		name:        "bridge_ip",
+		description: "Bridge IP address",
+		profile:     true,
+		get:         func(c *credentials.Config) string { return c.BridgeIP },
+		set: func(c *credentials.Config, value string) error {
+			c.BridgeIP = value
+			return nil
+		},
+	},
+	{
+		name:        "bridge_id",
+		description: "Bridge ID, used to select the profile with --profile",
+		profile:     true,
+		get:         func(c *credentials.Config) string { return c.BridgeID },
+		set: func(c *credentials.Config, value string) error {
+			c.BridgeID = strings.ToLower(value)
+			return nil
+		},
+	},
+	{
+		name:        "credential_store",
+		description: "Credential store holding the bridge keys",
+		profile:     true,
+		get:         func(c *credentials.Config) string { return c.CredentialStoreName() },
+		set: func(c *credentials.Config, value string) error {
+			for _, info := range credentials.Stores() {
+				if info.Name == value {
+					c.CredentialStore = value
+					return nil
+				}
+			}
+			return errors.Newf("unknown credential store: %s", value)
+		},
+	},
+	{
+		name:        "credential_ref",
+		description: "Item name or 1Password op:// reference of the bridge keys",
+		profile:     true,
+		get:         func(c *credentials.Config) string { return c.CredentialItem() },
+		set: func(c *credentials.Config, value string) error {
+			c.CredentialRef = value
+			c.OnePasswordItemName = ""
+			return nil
+		},
+	},
+	{
+		name:        "onepassword_vault",
+		description: "1Password vault for refs that don't name one",
+		profile:     true,
+		get:         func(c *credentials.Config) string { return c.OnePasswordVault },
+		set: func(c *credentials.Config, value string) error {
+			c.OnePasswordVault = value
+			return nil
+		},
+	},
+	{
+		name:        "latitude",
+		description: "Home latitude, the time zone is derived again when set",
+		profile:     true,
+		get:         func(c *credentials.Config) string { return formatCoordinate(c.Latitude) },
+		set: func(c *credentials.Config, value string) error {
+			latitude, err := strconv.ParseFloat(value, 64)
+			if err != nil || latitude < -90 || latitude > 90 {
+				return errors.Newf("invalid latitude: %s (must be between -90 and 90)", value)
+			}
+			c.Latitude = &latitude
+			return deriveTimeZone(c)
+		},
+	},
+	{
+		name:        "longitude",
+		description: "Home longitude, the time zone is derived again when set",
+		profile:     true,
+		get:         func(c *credentials.Config) string { return formatCoordinate(c.Longitude) },
+		set: func(c *credentials.Config, value string) error {
+			longitude, err := strconv.ParseFloat(value, 64)
+			if err != nil || longitude < -180 || longitude > 180 {
+				return errors.Newf("invalid longitude: %s (must be between -180 and 180)", value)
+			}
+			c.Longitude = &longitude
+			return deriveTimeZone(c)
+		},
+	},
+	{
+		name:        "timezone",
+		description: "IANA time zone of the home location",
+		profile:     true,
+		get:         func(c *credentials.Config) string { return c.TimeZone },
+		set: func(c *credentials.Config, value string) error {
+			if _, err := astro.LoadTimeZone(value); err != nil {
+				return err
+			}
+			c.TimeZone = value
+			return nil
+		},
+	},
+	{
+		name:         settingNominatimURL,
+		description:  "Nominatim server base URL",
+		defaultValue: astro.DefaultNominatimURL,
+		set: func(_ *credentials.Config, value string) error {
+			if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+				return errors.Newf("invalid url: %s", value)
+			}
+			return nil
+		},
+	},
+	{
+		name:         settingUserAgent,
+		description:  "User agent sent to the geocoding server",
+		defaultValue: astro.DefaultUserAgent,
+		set:          func(_ *credentials.Config, value string) error { return nil },
+	},
+	{
+		name:         settingGeocodeTTL,
+		description:  "How long geocoding results are cached",
+		defaultValue: astro.DefaultGeocodeCacheTTL.String(),
+		set: func(_ *credentials.Config, value string) error {
+			ttl, err := time.ParseDuration(value)
+			if err != nil || ttl <= 0 {
+				return errors.Newf("invalid duration: %s", value)
+			}
+			return nil
+		},
+	},
+	{
+		name:         settingCalendarTTL,
+		description:  "How long downloaded calendars are used before they are fetched again",
+		defaultValue: calendar.DefaultCacheTTL.String(),
+		set: func(_ *credentials.Config, value string) error {
+			ttl, err := time.ParseDuration(value)
+			if err != nil || ttl <= 0 {
+				return errors.Newf("invalid duration: %s", value)
+			}
+			return nil
+		},
+	},
+	{
+		name:        settingBackupDir,
+		description: "Directory for 'db backup --rotate', backups in the state directory if unset",
+		set: func(_ *credentials.Config, value string) error {
+			if !filepath.IsAbs(value) {
+				return errors.Newf("backup directory must be an absolute path: %s", value)
+			}
+			return nil
+		},
+	},
+	{
+		name:         settingBackupKeep,
+		description:  "Number of rotating backups to keep",
+		defaultValue: strconv.Itoa(defaultBackupKeep),
+		set: func(_ *credentials.Config, value string) error {
+			keep, err := strconv.Atoi(value)
+			if err != nil || keep < 1 {
+				return errors.Newf("invalid number of backups: %s (must be at least 1)", value)
+			}
+			return nil
+		},
+	},
+}
+
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show and change settings",
+		Long: `Show and change settings without editing the config file by hand.
+
+Bridge, credential and location settings belong to the selected profile and are
+kept in the config file. Other settings are shared by all profiles and kept in the database.`,
+	}
+
+	cmd.AddCommand(newConfigListCommand())
+	cmd.AddCommand(newConfigGetCommand())
+	cmd.AddCommand(newConfigSetCommand())
+	cmd.AddCommand(newConfigUnsetCommand())
+	cmd.AddCommand(newConfigPathCommand())
+
+	return cmd
+}
+
+func newConfigListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all settings and where they come from",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			config, err := loadOrNewConfig()
+			if err != nil {
+				return err
+			}
+
+			settings, closeSettings, err := openSettings()
+			if err != nil {
+				return err
+			}
+			defer closeSettings()
+
+			stored, err := settings.List(ctx)
+			if err != nil {
+				return err
+			}
+			known := make(map[string]bool)
+
+			for _, key := range configKeys {
+				known[key.name] = true
+				value, source, err := configValue(ctx, config, settings, key)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("%-24s %-40s %s\n", key.name, valueOrNone(value), source)
+			}
+
+			var unknown []string
+			for _, setting := range stored {
+				if !known[setting.Key] {
+					unknown = append(unknown, fmt.Sprintf("%-24s %-40s %s", setting.Key, setting.Value, configSourceDatabase))
+				}
+			}
+			sort.Strings(unknown)
+			for _, line := range unknown {
+				fmt.Println(line)
+			}
+
+			return nil
+		},
+	}
+}
+
+func newConfigGetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print the value of a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			key, err := findConfigKey(args[0])
+			if err != nil {
+				return err
+			}
+
+			config, err := loadOrNewConfig()
+			if err != nil {
+				return err
+			}
+
+			var settings *db.Settings
+			if !key.profile {
+				var closeSettings func()
+				settings, closeSettings, err = openSettings()
+				if err != nil {
+					return err
+				}
+				defer closeSettings()
+			}
+
+			value, _, err := configValue(ctx, config, settings, key)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(value)
+			return nil
+		},
+	}
+}
+
+func newConfigSetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Change a setting",
+		Example: `  limelight config set geocoder.cache_ttl 168h
+  limelight config set longitude -- -122.4194`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := findConfigKey(args[0])
+			if err != nil {
+				return err
+			}
+			return setConfigValue(key, args[1])
+		},
+	}
+}
+
+func newConfigUnsetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unset <key>",
+		Short: "Reset a setting to its default",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := findConfigKey(args[0])
+			if err != nil {
+				return err
+			}
+
+			if key.profile {
+				return errors.Newf("%s belongs to the profile, use 'limelight config set' or 'limelight setup' to change it", key.name)
+			}
+
+			settings, closeSettings, err := openSettings()
+			if err != nil {
+				return err
+			}
+			defer closeSettings()
+
+			if err := settings.Delete(context.Background(), key.name); err != nil {
+				return err
+			}
+
+			fmt.Printf("%s reset to %s\n", key.name, key.defaultValue)
+			return nil
+		},
+	}
+}
+
+func newConfigPathCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "path",
+		Short: "Print the config file, database and state locations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configPath, err := credentials.GetConfigPath()
+			if err != nil {
+				return err
+			}
+			dbPath, err := db.DefaultPath()
+			if err != nil {
+				return err
+			}
+
+			stateDir, err := paths.StateDir()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Config file: %s\n", configPath)
+			fmt.Printf("Database: %s\n", dbPath)
+			fmt.Printf("State: %s\n", stateDir)
+			return nil
+		},
+	}
+}
+
+func findConfigKey(name string) (configKey, error) {
+	for _, key := range configKeys {
+		if key.name == name {
+			return key, nil
+		}
+	}
+
+	names := make([]string, len(configKeys))
+	for i, key := range configKeys {
+		names[i] = key.name
+	}
+	return configKey{}, errors.WithHintf(errors.Newf("unknown setting: %s", name), "settings are: %s", strings.Join(names, ", "))
+}
+
+// configValue returns a setting's value and where it came from
+func configValue(ctx context.Context, config *credentials.Config, settings *db.Settings, key configKey) (string, string, error) {
+	if key.profile {
+		return key.get(config), "profile " + config.ProfileName, nil
+	}
+
+	value, ok, err := settings.Get(ctx, key.name)
+	if err != nil {
+		return "", "", err
+	}
+	if !ok {
+		return key.defaultValue, configSourceDefault, nil
+	}
+	return value, configSourceDatabase, nil
+}
+
+func setConfigValue(key configKey, value string) error {
+	config, err := loadOrNewConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := key.set(config, value); err != nil {
+		return err
+	}
+
+	if key.profile {
+		if err := credentials.SaveConfig(config); err != nil {
+			return errors.Wrap(err, "saving config")
+		}
+		fmt.Printf("%s set to %s in profile %s\n", key.name, key.get(config), config.ProfileName)
+		return nil
+	}
+
+	settings, closeSettings, err := openSettings()
+	if err != nil {
+		return err
+	}
+	defer closeSettings()
+
+	if err := settings.Set(context.Background(), key.name, value); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s set to %s\n", key.name, value)
+	return nil
+}
+
+func loadOrNewConfig() (*credentials.Config, error) {
+	config, err := credentials.LoadConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading config")
+	}
+	if config == nil {
+		config = credentials.NewConfig()
+	}
+	return config, nil
+}
+
+// openDatabase opens the database and applies pending migrations
+func openDatabase() (*sql.DB, error) {
+	database, err := db.Open("")
+	if err != nil {
+		return nil, err
+	}
+	if err := db.RunMigrations(database); err != nil {
+		database.Close()
+		return nil, err
+	}
+	return database, nil
+}
+
+// openSettings opens the database for reading and writing settings
+func openSettings() (*db.Settings, func(), error) {
+	database, err := openDatabase()
+	if err != nil {
+		return nil, nil, err
+	}
+	return db.NewSettings(database), func() { database.Close() }, nil
+}
+
+// deriveTimeZone updates the time zone after the coordinates change,
+// once both are set
+func deriveTimeZone(c *credentials.Config) error {
+	latitude, longitude, ok := c.Location()
+	if !ok {
+		return nil
+	}
+	timeZone, err := astro.LookupTimeZone(latitude, longitude)
+	if err != nil {
+		return errors.Wrap(err, "deriving time zone from location")
+	}
+	c.TimeZone = timeZone
+	return nil
+}
+
+func formatCoordinate(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
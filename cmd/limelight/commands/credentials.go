@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
+	"github.com/mithilarun/limelight/internal/credentials"
+	"go.uber.org/zap"
+	"golang.org/x/term"
+)
+
+func credentialStoreOptions(config *credentials.Config, logger *zap.Logger) credentials.StoreOptions {
+	return credentials.StoreOptions{
+		Logger:     logger,
+		Config:     config,
+		Passphrase: readPassphrase,
+	}
+}
+
+// readPassphrase reads the encrypted file store passphrase from the terminal without echo,
+// asking twice when a new file will be created
+func readPassphrase(newFile bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.Newf("stdin is not a terminal, set %s", credentials.PassphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, "Passphrase for encrypted credentials: ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	if newFile {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		confirmation, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(confirmation) != string(passphrase) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+
+	return string(passphrase), nil
+}
+
+// Device types are "<application>#<device>", with the device part limited to 19 characters
+const (
+	deviceTypeApplication = "limelight"
+	maxDeviceNameLength   = 19
+)
+
+// deviceType returns the name limelight registers under in the bridge's list of
+// application keys, "limelight#<hostname>" unless a device name is given
+func deviceType(deviceName string) (string, error) {
+	if deviceName == "" {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			hostname = "cli"
+		}
+		deviceName, _, _ = strings.Cut(hostname, ".")
+		if len(deviceName) > maxDeviceNameLength {
+			deviceName = deviceName[:maxDeviceNameLength]
+		}
+	}
+
+	if strings.Contains(deviceName, "#") {
+		return "", errors.Newf("invalid device name %q: must not contain '#'", deviceName)
+	}
+	if len(deviceName) > maxDeviceNameLength {
+		return "", errors.Newf("invalid device name %q: must be at most %d characters", deviceName, maxDeviceNameLength)
+	}
+
+	return deviceTypeApplication + "#" + deviceName, nil
+}
+
+// chooseCredentialStore picks where the API key should be stored. A store named
+// on the command line is used as-is; otherwise the user is asked, defaulting to
+// the configured store or else the first secure store available on this machine.
+func chooseCredentialStore(ctx context.Context, prompter *setupPrompter, config *credentials.Config, logger *zap.Logger, name string) (credentials.Store, error) {
+	if name != "" {
+		store, err := credentials.NewStore(name, credentialStoreOptions(config, logger))
+		if err != nil {
+			return nil, err
+		}
+		if !store.IsAvailable(ctx) {
+			return nil, errors.Newf("%s credential store is not available", name)
+		}
+		if name == credentials.StorePlaintext {
+			fmt.Println("WARNING: the API key will be stored unencrypted in the config file.")
+		}
+		return store, nil
+	}
+
+	infos := credentials.Stores()
+	stores := make([]credentials.Store, len(infos))
+	available := make([]bool, len(infos))
+	defaultIndex := -1
+
+	for i, info := range infos {
+		store, err := credentials.NewStore(info.Name, credentialStoreOptions(config, logger))
+		if err != nil {
+			return nil, err
+		}
+		stores[i] = store
+		available[i] = store.IsAvailable(ctx)
+
+		if !available[i] {
+			continue
+		}
+		if info.Name == config.CredentialStoreName() || (defaultIndex == -1 && info.Secure) {
+			defaultIndex = i
+		}
+	}
+
+	if defaultIndex == -1 && prompter.assumeYes {
+		return nil, errors.New("no secure credential store available, pass --credential-store")
+	}
+
+	fmt.Println()
+	fmt.Println("Where should the bridge API key be stored?")
+	for i, info := range infos {
+		status := ""
+		if !available[i] {
+			status = " (not available)"
+		}
+		fmt.Printf("  %d. %-15s %s%s\n", i+1, info.Name, info.Description, status)
+	}
+
+	defaultChoice := ""
+	if defaultIndex != -1 {
+		defaultChoice = strconv.Itoa(defaultIndex + 1)
+	}
+
+	choice, err := prompter.ask("Choose a credential store", defaultChoice)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading credential store")
+	}
+	if choice == "" {
+		return nil, errors.New("no credential store available")
+	}
+
+	index := -1
+	for i, info := range infos {
+		if choice == info.Name || choice == strconv.Itoa(i+1) {
+			index = i
+		}
+	}
+	if index == -1 {
+		return nil, errors.Newf("invalid choice: %s", choice)
+	}
+	if !available[index] {
+		return nil, errors.Newf("%s credential store is not available", infos[index].Name)
+	}
+
+	if infos[index].Name == credentials.StorePlaintext {
+		fmt.Println()
+		fmt.Println("WARNING: the API key will be stored unencrypted in the config file.")
+
+		confirmed, err := prompter.confirm("Continue?")
+		if err != nil {
+			return nil, errors.Wrap(err, "reading confirmation")
+		}
+		if !confirmed {
+			return nil, errors.New("setup cancelled")
+		}
+	}
+
+	return stores[index], nil
+}
+
+// saveBridgeCredentials stores the keys issued when pairing. A client key left
+// from an earlier pairing is removed if the bridge issued none, since it
+// wouldn't match the new application key. The environment store can't be
+// written, so the variables to set are printed instead.
+func saveBridgeCredentials(ctx context.Context, store credentials.Store, ref string, auth *bridge.AuthResult) error {
+	secrets := []struct {
+		field string
+		value string
+	}{
+		{credentials.FieldAPIKey, auth.Username},
+		{credentials.FieldClientKey, auth.ClientKey},
+	}
+
+	fmt.Println()
+	if envStore, ok := store.(*credentials.EnvStore); ok {
+		fmt.Println("Set these in the environment to use the bridge:")
+		for _, secret := range secrets {
+			if secret.value != "" {
+				fmt.Printf("  export %s=%s\n", envStore.VarName(secret.field), secret.value)
+			} else if secret.field == credentials.FieldClientKey {
+				fmt.Printf("  unset %s\n", envStore.VarName(secret.field))
+			}
+		}
+		return nil
+	}
+
+	for _, secret := range secrets {
+		if secret.value == "" {
+			continue
+		}
+		if err := store.Set(ctx, ref, secret.field, secret.value); err != nil {
+			return errors.Wrapf(err, "saving %s to %s credential store", secret.field, store.Name())
+		}
+	}
+
+	if auth.ClientKey == "" {
+		if err := store.Delete(ctx, ref, credentials.FieldClientKey); err != nil {
+			return errors.Wrapf(err, "removing stale %s from %s credential store", credentials.FieldClientKey, store.Name())
+		}
+	}
+
+	fmt.Printf("API key saved to %s credential store: %s\n", store.Name(), ref)
+	if auth.ClientKey == "" {
+		fmt.Println("The bridge issued no client key, so entertainment streaming won't work until it is paired again")
+	}
+	return nil
+}
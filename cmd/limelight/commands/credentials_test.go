@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
+	"github.com/mithilarun/limelight/internal/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is a credential store kept in a map
+type memoryStore map[string]string
+
+func (m memoryStore) Name() string                     { return "memory" }
+func (m memoryStore) IsAvailable(context.Context) bool { return true }
+
+func (m memoryStore) Get(_ context.Context, ref, field string) (string, error) {
+	value, ok := m[ref+"/"+field]
+	if !ok {
+		return "", errors.Wrapf(credentials.ErrSecretNotFound, "%s field of %s", field, ref)
+	}
+	return value, nil
+}
+
+func (m memoryStore) Set(_ context.Context, ref, field, value string) error {
+	m[ref+"/"+field] = value
+	return nil
+}
+
+func (m memoryStore) Delete(_ context.Context, ref, field string) error {
+	delete(m, ref+"/"+field)
+	return nil
+}
+
+func TestSaveBridgeCredentials(t *testing.T) {
+	ctx := context.Background()
+	store := memoryStore{}
+
+	require.NoError(t, saveBridgeCredentials(ctx, store, "hue", &bridge.AuthResult{Username: "old-key", ClientKey: "0123"}))
+	assert.Equal(t, memoryStore{"hue/api_key": "old-key", "hue/client_key": "0123"}, store)
+
+	// A pairing without a client key doesn't leave the old one beside the new application key
+	require.NoError(t, saveBridgeCredentials(ctx, store, "hue", &bridge.AuthResult{Username: "new-key"}))
+	assert.Equal(t, memoryStore{"hue/api_key": "new-key"}, store)
+	_, err := store.Get(ctx, "hue", credentials.FieldClientKey)
+	assert.ErrorIs(t, err, credentials.ErrSecretNotFound)
+}
@@ -0,0 +1,339 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// defaultBackupKeep is how many rotating backups are kept unless backup.keep is set
+const defaultBackupKeep = 7
+
+// NewDBCommand creates the db command for maintaining the database
+func NewDBCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Manage the limelight database",
+		Long: `Inspect, migrate, back up and restore the database.
+
+Other commands migrate the database automatically. Before migrating or rolling
+back a database that already has a schema, limelight copies it to
+limelight.db.pre-<migration>.bak next to the database file.`,
+	}
+
+	cmd.AddCommand(newDBStatusCommand())
+	cmd.AddCommand(newDBMigrateCommand())
+	cmd.AddCommand(newDBRollbackCommand())
+	cmd.AddCommand(newDBBackupCommand())
+	cmd.AddCommand(newDBRestoreCommand())
+	cmd.AddCommand(newDBCheckCommand())
+
+	return cmd
+}
+
+func newDBStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show which migrations have been applied",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := db.DefaultPath()
+			if err != nil {
+				return err
+			}
+
+			database, err := db.Open("")
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			statuses, err := db.Status(database)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Database: %s\n\n", path)
+			for _, status := range statuses {
+				state := "pending"
+				switch {
+				case status.Unknown:
+					state = "unknown"
+				case status.Modified:
+					state = "modified"
+				case status.Applied:
+					state = "applied"
+				}
+
+				appliedAt := ""
+				if status.Applied {
+					appliedAt = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+				}
+				fmt.Printf("%-32s %-9s %s\n", status.Version, state, appliedAt)
+			}
+
+			return nil
+		},
+	}
+}
+
+func newDBMigrateCommand() *cobra.Command {
+	var target string
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open("")
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			report, err := db.Migrate(database, target)
+			printMigrationReport(report)
+			if err != nil {
+				return err
+			}
+			if len(report.Applied) == 0 {
+				fmt.Println("Database is up to date")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&target, "to", "", "Stop after applying this migration")
+
+	return cmd
+}
+
+func newDBRollbackCommand() *cobra.Command {
+	var steps int
+
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Revert the most recent migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open("")
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			report, err := db.Rollback(database, steps)
+			printMigrationReport(report)
+			return err
+		},
+	}
+
+	cmd.Flags().IntVar(&steps, "steps", 1, "Number of migrations to revert")
+
+	return cmd
+}
+
+func printMigrationReport(report *db.MigrationReport) {
+	if report == nil {
+		return
+	}
+	if report.Backup != "" {
+		fmt.Printf("Backed up database to %s\n", report.Backup)
+	}
+	for _, version := range report.Applied {
+		fmt.Printf("Applied %s\n", version)
+	}
+	for _, version := range report.RolledBack {
+		fmt.Printf("Rolled back %s\n", version)
+	}
+}
+
+func newDBBackupCommand() *cobra.Command {
+	var (
+		rotate bool
+		force  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "backup [file]",
+		Short: "Copy the database to a file while it is in use",
+		Long: `Copy the database to a file with SQLite's online backup API, which is safe
+while other limelight processes are using the database.
+
+With --rotate, the backup is written to backup.dir (backups in the state
+directory by default) and only the newest backup.keep of them are kept. Run it
+from cron or a systemd timer for scheduled backups.`,
+		Example: `  limelight db backup ~/limelight-before-upgrade.db
+  limelight db backup --rotate`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			if rotate == (len(args) == 1) {
+				return errors.New("give either a backup file or --rotate")
+			}
+
+			if rotate {
+				database, err := openDatabase()
+				if err != nil {
+					return err
+				}
+				defer database.Close()
+
+				dir, keep, err := rotatingBackupSettings(ctx, db.NewSettings(database))
+				if err != nil {
+					return err
+				}
+
+				path, err := db.RotateBackup(ctx, database, dir, keep, time.Now())
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Backed up database to %s\n", path)
+				return nil
+			}
+
+			dest := args[0]
+			if _, err := os.Stat(dest); err == nil && !force {
+				return errors.WithHint(
+					errors.Newf("%s already exists", dest),
+					"Use --force to replace it.",
+				)
+			}
+
+			database, err := db.Open("")
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			if err := db.Backup(ctx, database, dest); err != nil {
+				return err
+			}
+			fmt.Printf("Backed up database to %s\n", dest)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&rotate, "rotate", false, "Write a timestamped backup to backup.dir and remove the oldest")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing backup file")
+
+	return cmd
+}
+
+func newDBRestoreCommand() *cobra.Command {
+	var assumeYes bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Replace the database with a backup",
+		Long: `Replace the database with a backup made by 'limelight db backup'.
+
+The backup is checked before anything changes, and the current database is
+copied to limelight.db.pre-restore.bak first. A backup from an older version is
+migrated after it is restored. Stop other limelight processes before restoring.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			src := args[0]
+
+			path, err := db.DefaultPath()
+			if err != nil {
+				return err
+			}
+
+			prompter := &setupPrompter{reader: bufio.NewReader(os.Stdin), assumeYes: assumeYes}
+			confirmed, err := prompter.confirm(fmt.Sprintf("Replace %s with %s?", path, src))
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Println("Restore cancelled")
+				return nil
+			}
+
+			database, err := db.Open("")
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			safety, err := db.Restore(ctx, database, src)
+			if err != nil {
+				return err
+			}
+			if safety != "" {
+				fmt.Printf("Saved the previous database to %s\n", safety)
+			}
+
+			report, err := db.Migrate(database, "")
+			printMigrationReport(report)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Restored database from %s\n", src)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Restore without asking for confirmation")
+
+	return cmd
+}
+
+func newDBCheckCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check",
+		Short: "Check the database for corruption and broken references",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open("")
+			if err != nil {
+				return err
+			}
+			defer database.Close()
+
+			problems, err := db.Check(context.Background(), database)
+			if err != nil {
+				return err
+			}
+			if len(problems) == 0 {
+				fmt.Println("Database is healthy")
+				return nil
+			}
+
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+			return errors.WithHint(
+				errors.Newf("found %d problems", len(problems)),
+				"Restore a recent backup with 'limelight db restore'.",
+			)
+		},
+	}
+}
+
+// rotatingBackupSettings returns the backup directory and how many backups to keep
+func rotatingBackupSettings(ctx context.Context, settings *db.Settings) (string, int, error) {
+	dir, err := settings.GetString(ctx, settingBackupDir, "")
+	if err != nil {
+		return "", 0, err
+	}
+	if strings.TrimSpace(dir) == "" {
+		dir, err = db.DefaultBackupDir()
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	keep, err := settings.GetInt(ctx, settingBackupKeep, defaultBackupKeep)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return dir, keep, nil
+}
@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func NewEntertainmentCommand(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "entertainment",
+		Short: "Stream colors to entertainment areas",
+		Long: `List entertainment areas and stream colors to their lights with the
+Entertainment API, far faster than changing lights one request at a time.
+Entertainment areas are set up in the Hue app.`,
+	}
+
+	cmd.AddCommand(newListEntertainmentCommand(logger))
+	cmd.AddCommand(newStreamEntertainmentCommand(logger))
+	cmd.AddCommand(newStopEntertainmentCommand(logger))
+
+	return cmd
+}
+
+func newListEntertainmentCommand(logger *zap.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List entertainment areas",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client, err := getAuthenticatedClient(ctx, logger)
+			if err != nil {
+				return err
+			}
+
+			configs, err := client.GetEntertainmentConfigurations(ctx)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Found %d entertainment areas:\n\n", len(configs))
+			for _, config := range configs {
+				status := "idle"
+				if config.IsActive() {
+					status = "streaming"
+				}
+				fmt.Printf("  %s\n", config.Metadata.Name)
+				fmt.Printf("    ID: %s\n", config.ID)
+				fmt.Printf("    Type: %s\n", config.ConfigurationType)
+				fmt.Printf("    Channels: %d, Lights: %d\n", len(config.Channels), len(config.LightServices))
+				fmt.Printf("    Status: %s\n", status)
+				fmt.Println()
+			}
+
+			return nil
+		},
+	}
+}
+
+func newStreamEntertainmentCommand(logger *zap.Logger) *cobra.Command {
+	var (
+		source     string
+		rate       int
+		duration   time.Duration
+		period     time.Duration
+		spread     bool
+		brightness float64
+		gain       float64
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stream <name|id>",
+		Short: "Stream generated colors to an entertainment area",
+		Long: `Stream colors to an entertainment area until --duration passes or Ctrl-C
+is pressed, then return its lights to how they were.
+
+The cycle source turns the lights around the color wheel. The audio source
+reads signed 16-bit little-endian mono audio from stdin and shows its
+loudness as a level meter across the area's channels.`,
+		Example: `  limelight entertainment stream TV --source cycle --period 20s --spread
+  parec --format=s16le --channels=1 | limelight entertainment stream TV --source audio`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if rate < 1 || rate > bridge.MaxStreamRate {
+				return errors.Newf("--rate must be from 1 to %d", bridge.MaxStreamRate)
+			}
+
+			var frames bridge.FrameSource
+			switch source {
+			case "cycle":
+				if brightness < 0 || brightness > 100 {
+					return errors.New("--brightness must be from 0 to 100")
+				}
+				level := brightness / 100
+				frames = bridge.ColorCycle{Period: period, Spread: spread, Brightness: &level}
+			case "audio":
+				audio := bridge.NewAudioLevel(os.Stdin)
+				audio.Gain = gain
+				frames = audio
+			default:
+				return errors.Newf("invalid source %q (expected cycle or audio)", source)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			if duration > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, duration)
+				defer cancel()
+			}
+
+			pool, err := getClientPool(logger)
+			if err != nil {
+				return err
+			}
+			client, err := pool.Client(ctx, "")
+			if err != nil {
+				return err
+			}
+			clientKey, err := pool.ClientKey(ctx, "")
+			if err != nil {
+				return err
+			}
+
+			config, err := findEntertainmentConfiguration(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+
+			stream, err := client.OpenStream(ctx, config.ID, clientKey)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Streaming to %s, press Ctrl-C to stop\n", config.Metadata.Name)
+			runErr := stream.Run(ctx, frames, rate)
+			if err := errors.CombineErrors(runErr, stream.Close()); err != nil {
+				return err
+			}
+			fmt.Printf("Streaming to %s stopped\n", config.Metadata.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&source, "source", "cycle", "Frame source: cycle or audio")
+	cmd.Flags().IntVar(&rate, "rate", bridge.DefaultStreamRate, fmt.Sprintf("Frames per second to send (1-%d)", bridge.MaxStreamRate))
+	cmd.Flags().DurationVar(&duration, "duration", 0, "Stop streaming after this long, such as 5m")
+	cmd.Flags().DurationVar(&period, "period", bridge.DefaultCyclePeriod, "How long the cycle source takes around the color wheel")
+	cmd.Flags().BoolVar(&spread, "spread", false, "Spread the cycle source's colors across the channels")
+	cmd.Flags().Float64Var(&brightness, "brightness", 100, "Brightness of the cycle source (0-100)")
+	cmd.Flags().Float64Var(&gain, "gain", 1, "Gain of the audio source, raise it for quiet audio")
+
+	return cmd
+}
+
+func newStopEntertainmentCommand(logger *zap.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stop <name|id>",
+		Short: "Stop streaming to an entertainment area",
+		Long: `Stop streaming to an entertainment area, such as one left streaming by a
+client that exited without stopping it.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client, err := getAuthenticatedClient(ctx, logger)
+			if err != nil {
+				return err
+			}
+
+			config, err := findEntertainmentConfiguration(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+			if err := client.StopStreaming(ctx, config.ID); err != nil {
+				return err
+			}
+
+			fmt.Printf("Streaming to %s stopped\n", config.Metadata.Name)
+			return nil
+		},
+	}
+}
+
+// findEntertainmentConfiguration finds an entertainment area by ID or, ignoring case, name
+func findEntertainmentConfiguration(ctx context.Context, client *bridge.Client, nameOrID string) (*bridge.EntertainmentConfiguration, error) {
+	configs, err := client.GetEntertainmentConfigurations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range configs {
+		if configs[i].ID == nameOrID || strings.EqualFold(configs[i].Metadata.Name, nameOrID) {
+			return &configs[i], nil
+		}
+	}
+	return nil, errors.Newf("no entertainment area is named or has ID %s; set one up in the Hue app", nameOrID)
+}
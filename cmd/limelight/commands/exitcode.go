@@ -0,0 +1,29 @@
+package commands
+
+import (
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
+)
+
+// Exit codes returned by limelight, so provisioning scripts can tell
+// a missed button press (retry) from a bridge that can't be reached (fix the network)
+const (
+	ExitOK                = 0
+	ExitError             = 1
+	ExitLinkButtonTimeout = 2
+	ExitBridgeUnreachable = 3
+)
+
+// ExitCode maps a command error to the process exit code
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, bridge.ErrLinkButtonNotPressed):
+		return ExitLinkButtonTimeout
+	case errors.Is(err, bridge.ErrBridgeUnreachable):
+		return ExitBridgeUnreachable
+	default:
+		return ExitError
+	}
+}
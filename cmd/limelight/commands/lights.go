@@ -3,6 +3,9 @@ package commands
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/mithilarun/limelight/internal/bridge"
@@ -20,6 +23,9 @@ func NewLightsCommand(logger *zap.Logger) *cobra.Command {
 
 	cmd.AddCommand(newListLightsCommand(logger))
 	cmd.AddCommand(newSetLightCommand(logger))
+	cmd.AddCommand(newLightEffectCommand(logger))
+	cmd.AddCommand(newLightAlertCommand(logger))
+	cmd.AddCommand(newLightIdentifyCommand(logger))
 
 	return cmd
 }
@@ -56,6 +62,9 @@ func newListLightsCommand(logger *zap.Logger) *cobra.Command {
 				fmt.Printf("    ID: %s\n", light.ID)
 				fmt.Printf("    Status: %s%s\n", status, brightness)
 				fmt.Printf("    Type: %s\n", light.Metadata.Archetype)
+				if effect := light.ActiveEffect(); effect != "" {
+					fmt.Printf("    Effect: %s\n", effect)
+				}
 				fmt.Println()
 			}
 
@@ -66,15 +75,19 @@ func newListLightsCommand(logger *zap.Logger) *cobra.Command {
 
 func newSetLightCommand(logger *zap.Logger) *cobra.Command {
 	var (
-		on         bool
-		off        bool
-		brightness float64
+		on           bool
+		off          bool
+		brightness   float64
+		gradient     []string
+		gradientMode string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "set <light-id>",
 		Short: "Set light state",
-		Args:  cobra.ExactArgs(1),
+		Example: `  limelight lights set <light-id> --on --brightness 60
+  limelight lights set <light-id> --gradient red,orange,yellow --gradient-mode mirrored`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if on && off {
 				return errors.New("cannot specify both --on and --off")
@@ -88,6 +101,13 @@ func newSetLightCommand(logger *zap.Logger) *cobra.Command {
 
 			lightID := args[0]
 
+			if len(gradient) > 0 {
+				return setLightGradient(ctx, client, lightID, gradient, gradientMode, on, off, brightness)
+			}
+			if gradientMode != "" {
+				return errors.New("--gradient-mode needs a --gradient")
+			}
+
 			targetOn := on
 			if off {
 				targetOn = false
@@ -110,35 +130,217 @@ func newSetLightCommand(logger *zap.Logger) *cobra.Command {
 	cmd.Flags().BoolVar(&on, "on", false, "Turn light on")
 	cmd.Flags().BoolVar(&off, "off", false, "Turn light off")
 	cmd.Flags().Float64Var(&brightness, "brightness", 0, "Set brightness (0-100)")
+	cmd.Flags().StringSliceVar(&gradient, "gradient", nil, "Set a gradient light's colors, 2 to 5 names or hex colors such as red,orange,#ffff00")
+	cmd.Flags().StringVar(&gradientMode, "gradient-mode", "", "Gradient mode: interpolated, mirrored or pixelated")
 
 	return cmd
 }
 
-func getAuthenticatedClient(ctx context.Context, logger *zap.Logger) (*bridge.Client, error) {
-	config, err := credentials.LoadConfig()
+// setLightGradient sets a gradient light's colors, turning it on or off and
+// dimming it in the same request if asked
+func setLightGradient(ctx context.Context, client *bridge.Client, lightID string, colors []string, mode string, on, off bool, brightness float64) error {
+	g, err := bridge.ParseGradient(colors, mode)
 	if err != nil {
-		return nil, errors.Wrap(err, "loading config")
+		return err
 	}
 
-	if config == nil {
-		return nil, errors.New("no configuration found, run 'limelight setup' first")
+	light, err := client.GetLight(ctx, lightID)
+	if err != nil {
+		return err
+	}
+	if err := light.CheckGradient(g); err != nil {
+		return err
 	}
 
-	var apiKey string
+	req := bridge.LightUpdateRequest{Gradient: g}
+	if on || off {
+		req.On = &bridge.LightOnState{On: on}
+	}
+	if brightness > 0 {
+		req.Dimming = &bridge.LightDimmingState{Brightness: brightness}
+	}
+	if err := client.UpdateLight(ctx, light.ID, req); err != nil {
+		return errors.Wrap(err, "setting light gradient")
+	}
 
-	if config.OnePasswordItemName != "" {
-		credManager := credentials.NewManager(logger)
-		if !credManager.IsAvailable(ctx) {
-			return nil, errors.New("1password cli not available")
-		}
+	fmt.Printf("Gradient set on %s\n", light.Metadata.Name)
+	return nil
+}
+
+func newLightEffectCommand(logger *zap.Logger) *cobra.Command {
+	var duration time.Duration
 
-		apiKey, err = credManager.GetAPIKey(ctx, config.OnePasswordItemName)
-		if err != nil {
-			return nil, errors.Wrap(err, "getting api key from 1password")
+	cmd := &cobra.Command{
+		Use:   "effect <light-id> [effect]",
+		Short: "Start or end a light effect, or list the effects a light supports",
+		Long: `Start an effect such as candle or fire, which runs until another effect
+replaces it or the light is changed, or a timed effect, sunrise or sunset, which
+runs for --duration. "none" ends whichever is running. Without an effect, list
+the effects the light supports.`,
+		Example: `  limelight lights effect <light-id> candle
+  limelight lights effect <light-id> sunrise --duration 30m
+  limelight lights effect <light-id> none`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client, err := getAuthenticatedClient(ctx, logger)
+			if err != nil {
+				return err
+			}
+
+			light, err := client.GetLight(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 1 {
+				printLightEffects(light)
+				return nil
+			}
+
+			effect := args[1]
+			var req bridge.LightUpdateRequest
+			switch {
+			case effect == "none":
+				if light.Effects == nil && light.TimedEffects == nil {
+					return errors.Newf("light %q has no effects", light.Metadata.Name)
+				}
+				if light.Effects != nil {
+					req.Effects = &bridge.LightEffectState{Effect: bridge.EffectNone}
+				}
+				if light.TimedEffects != nil {
+					req.TimedEffects = &bridge.LightTimedEffectState{Effect: bridge.EffectNone}
+				}
+			case effect == bridge.TimedEffectSunrise || effect == bridge.TimedEffectSunset:
+				if !light.SupportsTimedEffect(effect) {
+					return unsupportedEffect(light, effect)
+				}
+				if duration <= 0 {
+					return errors.Newf("%s needs a --duration", effect)
+				}
+				req.TimedEffects = &bridge.LightTimedEffectState{Effect: effect, Duration: int(duration.Milliseconds())}
+			default:
+				if !light.SupportsEffect(effect) {
+					return unsupportedEffect(light, effect)
+				}
+				req.On = &bridge.LightOnState{On: true}
+				req.Effects = &bridge.LightEffectState{Effect: effect}
+			}
+
+			if err := client.UpdateLight(ctx, light.ID, req); err != nil {
+				return errors.Wrap(err, "setting light effect")
+			}
+
+			if effect == "none" {
+				fmt.Printf("Effects on %s ended\n", light.Metadata.Name)
+			} else {
+				fmt.Printf("%s started on %s\n", effect, light.Metadata.Name)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&duration, "duration", 0, "How long a sunrise or sunset takes, such as 30m")
+
+	return cmd
+}
+
+// printLightEffects lists the effects and timed effects a light supports
+func printLightEffects(light *bridge.Light) {
+	list := func(values []string) string {
+		values = slices.DeleteFunc(slices.Clone(values), func(v string) bool { return v == bridge.EffectNone })
+		if len(values) == 0 {
+			return "none"
 		}
+		return strings.Join(values, ", ")
+	}
+
+	fmt.Printf("%s\n", light.Metadata.Name)
+	if light.Effects != nil {
+		fmt.Printf("  Effects: %s\n", list(light.Effects.EffectValues))
 	} else {
-		return nil, errors.New("no credential storage configured")
+		fmt.Printf("  Effects: none\n")
+	}
+	if light.TimedEffects != nil {
+		fmt.Printf("  Timed effects: %s\n", list(light.TimedEffects.EffectValues))
+	} else {
+		fmt.Printf("  Timed effects: none\n")
+	}
+	if effect := light.ActiveEffect(); effect != "" {
+		fmt.Printf("  Running: %s\n", effect)
+	}
+}
+
+func unsupportedEffect(light *bridge.Light, effect string) error {
+	return errors.WithHint(
+		errors.Newf("light %q doesn't support the %s effect", light.Metadata.Name, effect),
+		fmt.Sprintf("Run 'limelight lights effect %s' to list the effects it supports.", light.ID),
+	)
+}
+
+func newLightAlertCommand(logger *zap.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "alert <light-id>",
+		Short: "Make a light breathe once",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client, err := getAuthenticatedClient(ctx, logger)
+			if err != nil {
+				return err
+			}
+
+			req := bridge.LightUpdateRequest{Alert: &bridge.LightAlertState{Action: bridge.AlertBreathe}}
+			if err := client.UpdateLight(ctx, args[0], req); err != nil {
+				return errors.Wrap(err, "alerting light")
+			}
+
+			fmt.Printf("Light %s alerted\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newLightIdentifyCommand(logger *zap.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "identify <light-id>",
+		Short: "Make a light blink so you can find it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			client, err := getAuthenticatedClient(ctx, logger)
+			if err != nil {
+				return err
+			}
+
+			if err := client.IdentifyLight(ctx, args[0]); err != nil {
+				return errors.Wrap(err, "identifying light")
+			}
+
+			fmt.Printf("Light %s identified\n", args[0])
+			return nil
+		},
+	}
+}
+
+func getAuthenticatedClient(ctx context.Context, logger *zap.Logger) (*bridge.Client, error) {
+	pool, err := getClientPool(logger)
+	if err != nil {
+		return nil, err
+	}
+	return pool.Client(ctx, "")
+}
+
+// getClientPool returns a pool for the bridges of the configured profiles
+func getClientPool(logger *zap.Logger) (*bridge.ClientPool, error) {
+	config, err := credentials.LoadConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading config")
+	}
+
+	if config == nil {
+		return nil, errors.New("no configuration found, run 'limelight setup' first")
 	}
 
-	return bridge.NewClient(config.BridgeIP, apiKey, logger), nil
+	return bridge.NewClientPool(config, credentialStoreOptions(config, logger), logger), nil
 }
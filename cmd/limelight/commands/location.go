@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/astro"
+	"github.com/mithilarun/limelight/internal/db"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const maxGeocodingResults = 5
+
+type geocoderOptions struct {
+	nominatimURL string
+	userAgent    string
+}
+
+func NewLocationCommand(logger *zap.Logger) *cobra.Command {
+	var opts geocoderOptions
+
+	cmd := &cobra.Command{
+		Use:   "location",
+		Short: "Manage the home location",
+		Long:  "Show and set the location used for sunrise and sunset calculations",
+	}
+
+	cmd.PersistentFlags().StringVar(&opts.nominatimURL, "nominatim-url", os.Getenv("LIMELIGHT_NOMINATIM_URL"), "Nominatim server base URL (env LIMELIGHT_NOMINATIM_URL, setting geocoder.nominatim_url)")
+	cmd.PersistentFlags().StringVar(&opts.userAgent, "user-agent", os.Getenv("LIMELIGHT_USER_AGENT"), "User agent sent to the geocoding server (env LIMELIGHT_USER_AGENT, setting geocoder.user_agent)")
+
+	cmd.AddCommand(newShowLocationCommand(logger, &opts))
+	cmd.AddCommand(newSetLocationCommand(logger, &opts))
+
+	return cmd
+}
+
+func newShowLocationCommand(logger *zap.Logger, opts *geocoderOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Show the configured location",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+
+			home, err := astro.GetHomeFromConfig()
+			if err != nil {
+				return errors.Wrap(err, "getting location")
+			}
+
+			fmt.Printf("Latitude: %.4f\n", home.Latitude)
+			fmt.Printf("Longitude: %.4f\n", home.Longitude)
+			fmt.Printf("Time zone: %s\n", home.TimeZone)
+
+			geocoder, closeGeocoder := newGeocoder(logger, opts)
+			defer closeGeocoder()
+
+			place, err := geocoder.Reverse(ctx, home.Latitude, home.Longitude)
+			if err != nil {
+				logger.Warn("failed to look up place name", zap.Error(err))
+				return nil
+			}
+
+			fmt.Printf("Place: %s\n", place.DisplayName)
+			return nil
+		},
+	}
+}
+
+func newSetLocationCommand(logger *zap.Logger, opts *geocoderOptions) *cobra.Command {
+	var (
+		city      string
+		pick      int
+		latitude  float64
+		longitude float64
+		timeZone  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Set the location by city name or coordinates, and its time zone",
+		Example: `  limelight location set --city "San Francisco"
+  limelight location set --city Portland --pick 2
+  limelight location set --lat 37.7749 --lon -122.4194
+  limelight location set --timezone America/Los_Angeles`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			latSet := cmd.Flags().Changed("lat")
+			lonSet := cmd.Flags().Changed("lon")
+
+			if timeZone != "" {
+				if _, err := astro.LoadTimeZone(timeZone); err != nil {
+					return err
+				}
+			}
+
+			switch {
+			case city != "":
+				if latSet || lonSet {
+					return errors.New("cannot specify both --city and --lat/--lon")
+				}
+
+				geocoder, closeGeocoder := newGeocoder(logger, opts)
+				defer closeGeocoder()
+
+				results, err := geocoder.Search(ctx, city, maxGeocodingResults)
+				if err != nil {
+					return errors.Wrapf(err, "looking up city %s", city)
+				}
+				if len(results) == 0 {
+					return errors.Newf("no results found for city: %s", city)
+				}
+
+				result, err := chooseGeocodingResult(results, pick)
+				if err != nil {
+					return err
+				}
+
+				if err := astro.SetLocationInConfig(result.Latitude, result.Longitude); err != nil {
+					return errors.Wrap(err, "setting location")
+				}
+
+				fmt.Printf("Location set to %s (%.4f, %.4f)\n", result.DisplayName, result.Latitude, result.Longitude)
+			case latSet || lonSet:
+				if !latSet || !lonSet {
+					return errors.New("both --lat and --lon are required")
+				}
+
+				if err := astro.SetLocationInConfig(latitude, longitude); err != nil {
+					return errors.Wrap(err, "setting location")
+				}
+
+				fmt.Printf("Location set to %.4f, %.4f\n", latitude, longitude)
+			case timeZone == "":
+				return errors.New("specify --city, --lat and --lon, or --timezone")
+			}
+
+			if timeZone != "" {
+				if err := astro.SetTimeZoneInConfig(timeZone); err != nil {
+					return errors.Wrap(err, "setting time zone")
+				}
+			}
+
+			home, err := astro.GetHomeFromConfig()
+			if err != nil {
+				return errors.Wrap(err, "getting location")
+			}
+
+			fmt.Printf("Time zone: %s\n", home.TimeZone)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&city, "city", "", "City name to look up")
+	cmd.Flags().IntVar(&pick, "pick", 0, "Choose the Nth match when a city name is ambiguous")
+	cmd.Flags().Float64Var(&latitude, "lat", 0, "Latitude (-90 to 90)")
+	cmd.Flags().Float64Var(&longitude, "lon", 0, "Longitude (-180 to 180)")
+	cmd.Flags().StringVar(&timeZone, "timezone", "", "IANA time zone, overriding the one derived from the coordinates")
+
+	return cmd
+}
+
+// chooseGeocodingResult picks a result by 1-based index, prompting when there is more than one
+func chooseGeocodingResult(results []astro.GeocodingResult, pick int) (*astro.GeocodingResult, error) {
+	if pick > 0 {
+		if pick > len(results) {
+			return nil, errors.Newf("--pick %d is out of range, found %d matches", pick, len(results))
+		}
+		return &results[pick-1], nil
+	}
+
+	if len(results) == 1 {
+		return &results[0], nil
+	}
+
+	fmt.Printf("Found %d matches:\n\n", len(results))
+	for i, result := range results {
+		fmt.Printf("  %d. %s (%.4f, %.4f)\n", i+1, result.DisplayName, result.Latitude, result.Longitude)
+	}
+	fmt.Println()
+	fmt.Print("Choose a location [1]: ")
+
+	reader := bufio.NewReader(os.Stdin)
+	choice, err := reader.ReadString('\n')
+	if err != nil && choice == "" {
+		return nil, errors.Wrap(err, "reading choice, use --pick to choose non-interactively")
+	}
+	choice = strings.TrimSpace(choice)
+
+	if choice == "" {
+		return &results[0], nil
+	}
+
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > len(results) {
+		return nil, errors.Newf("invalid choice: %s", choice)
+	}
+
+	return &results[index-1], nil
+}
+
+// newGeocoder builds the CLI geocoder: Nominatim with a SQLite cache, falling back
+// to the bundled city database. The cache and stored settings are skipped if the
+// database can't be opened.
+func newGeocoder(logger *zap.Logger, opts *geocoderOptions) (astro.Geocoder, func()) {
+	ctx := context.Background()
+	config := astro.NominatimConfig{
+		BaseURL:   opts.nominatimURL,
+		UserAgent: opts.userAgent,
+	}
+	ttl := astro.DefaultGeocodeCacheTTL
+
+	closer := func() {}
+
+	database, err := openDatabase()
+	if err != nil {
+		logger.Warn("geocoding cache unavailable", zap.Error(err))
+	} else {
+		closer = func() { database.Close() }
+
+		settings := db.NewSettings(database)
+		if config.BaseURL == "" {
+			config.BaseURL, err = settings.GetString(ctx, settingNominatimURL, "")
+			logSettingError(logger, settingNominatimURL, err)
+		}
+		if config.UserAgent == "" {
+			config.UserAgent, err = settings.GetString(ctx, settingUserAgent, "")
+			logSettingError(logger, settingUserAgent, err)
+		}
+		ttl, err = settings.GetDuration(ctx, settingGeocodeTTL, astro.DefaultGeocodeCacheTTL)
+		logSettingError(logger, settingGeocodeTTL, err)
+	}
+
+	var online astro.Geocoder = astro.NewNominatimGeocoder(config)
+	if database != nil {
+		online = astro.NewCachingGeocoder(online, database, ttl)
+	}
+
+	return astro.NewFallbackGeocoder(online, astro.NewOfflineGeocoder()), closer
+}
+
+func logSettingError(logger *zap.Logger, key string, err error) {
+	if err != nil {
+		logger.Warn("ignoring stored setting", zap.String("key", key), zap.Error(err))
+	}
+}
@@ -0,0 +1,199 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/credentials"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func NewProfilesCommand(logger *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profiles",
+		Short: "Manage bridge profiles",
+		Long: `List, switch between and remove bridge profiles.
+
+Each profile holds one bridge's address, credentials, location and time zone.
+Create a profile by running setup with --profile, and select one for a single
+command with --profile or LIMELIGHT_PROFILE.`,
+	}
+
+	cmd.AddCommand(newListProfilesCommand())
+	cmd.AddCommand(newUseProfileCommand())
+	cmd.AddCommand(newRemoveProfileCommand(logger))
+
+	return cmd
+}
+
+func newListProfilesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List all profiles",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfigWithProfiles()
+			if err != nil {
+				return err
+			}
+
+			names := config.ProfileNames()
+			fmt.Printf("Found %d profiles:\n\n", len(names))
+			for _, name := range names {
+				profile, _ := config.GetProfile(name)
+
+				var marks string
+				if name == config.CurrentProfile {
+					marks += " (current)"
+				}
+				if name == config.ProfileName && name != config.CurrentProfile {
+					marks += " (selected)"
+				}
+
+				fmt.Printf("  %s%s\n", name, marks)
+				fmt.Printf("    Bridge IP: %s\n", valueOrNone(profile.BridgeIP))
+				fmt.Printf("    Bridge ID: %s\n", valueOrNone(profile.BridgeID))
+				if store := profile.CredentialStoreName(); store != "" {
+					fmt.Printf("    Credentials: %s (%s)\n", store, profile.CredentialItem(name))
+				} else {
+					fmt.Printf("    Credentials: none\n")
+				}
+				if latitude, longitude, ok := profile.Location(); ok {
+					fmt.Printf("    Location: %.4f, %.4f\n", latitude, longitude)
+				}
+				if profile.TimeZone != "" {
+					fmt.Printf("    Time zone: %s\n", profile.TimeZone)
+				}
+				fmt.Println()
+			}
+
+			return nil
+		},
+	}
+}
+
+func newUseProfileCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use <name>",
+		Short: "Make a profile the current one",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfigWithProfiles()
+			if err != nil {
+				return err
+			}
+
+			if err := config.UseProfile(args[0]); err != nil {
+				return err
+			}
+
+			if err := credentials.SaveConfig(config); err != nil {
+				return errors.Wrap(err, "saving config")
+			}
+
+			fmt.Printf("Current profile is now %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newRemoveProfileCommand(logger *zap.Logger) *cobra.Command {
+	var keepCredentials bool
+
+	cmd := &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a profile and its stored API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			name := args[0]
+
+			config, err := loadConfigWithProfiles()
+			if err != nil {
+				return err
+			}
+
+			profileConfig, err := config.WithProfile(name)
+			if err != nil {
+				return err
+			}
+
+			if !keepCredentials {
+				deleteProfileCredentials(ctx, logger, config, profileConfig)
+			}
+
+			if err := config.RemoveProfile(name); err != nil {
+				return err
+			}
+
+			if err := credentials.SaveConfig(config); err != nil {
+				return errors.Wrap(err, "saving config")
+			}
+
+			fmt.Printf("Profile %s removed\n", name)
+			if config.CurrentProfile != "" {
+				fmt.Printf("Current profile is %s\n", config.CurrentProfile)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&keepCredentials, "keep-credentials", false, "Leave the API key in the credential store")
+
+	return cmd
+}
+
+// deleteProfileCredentials removes a profile's API key from its credential store,
+// unless another profile shares the same store and item. Failures are only logged.
+func deleteProfileCredentials(ctx context.Context, logger *zap.Logger, config, profileConfig *credentials.Config) {
+	storeName := profileConfig.CredentialStoreName()
+	ref := profileConfig.CredentialItem()
+	if storeName == "" || storeName == credentials.StoreEnv {
+		return
+	}
+
+	for _, other := range config.ProfileNames() {
+		if other == profileConfig.ProfileName {
+			continue
+		}
+		profile, _ := config.GetProfile(other)
+		if profile.CredentialStoreName() == storeName && profile.CredentialItem(other) == ref {
+			logger.Info("keeping api key shared with another profile", zap.String("profile", other))
+			return
+		}
+	}
+
+	store, err := credentials.NewStoreFromConfig(profileConfig, credentialStoreOptions(config, logger))
+	if err == nil {
+		err = store.Delete(ctx, ref, credentials.FieldAPIKey)
+	}
+	if err != nil {
+		logger.Warn("failed to delete api key from credential store",
+			zap.String("store", storeName),
+			zap.String("item", ref),
+			zap.Error(err),
+		)
+	}
+}
+
+// loadConfigWithProfiles loads the config, failing if there is none yet
+func loadConfigWithProfiles() (*credentials.Config, error) {
+	config, err := credentials.LoadConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading config")
+	}
+
+	if config == nil {
+		return nil, errors.New("no configuration found, run 'limelight setup' first")
+	}
+
+	return config, nil
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -13,11 +14,12 @@ func NewScenesCommand(logger *zap.Logger) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "scenes",
 		Short: "Manage Hue scenes",
-		Long:  "List and activate Hue scenes",
+		Long:  "List and activate Hue scenes, and set the gradients they recall",
 	}
 
 	cmd.AddCommand(newListScenesCommand(logger))
 	cmd.AddCommand(newActivateSceneCommand(logger))
+	cmd.AddCommand(newSetSceneGradientCommand(logger))
 
 	return cmd
 }
@@ -74,3 +76,51 @@ func newActivateSceneCommand(logger *zap.Logger) *cobra.Command {
 		},
 	}
 }
+
+func newSetSceneGradientCommand(logger *zap.Logger) *cobra.Command {
+	var (
+		gradient     []string
+		gradientMode string
+	)
+
+	cmd := &cobra.Command{
+		Use:     "set-gradient <scene-id> <light-id>",
+		Short:   "Set the gradient a scene recalls on one of its lights",
+		Example: "  limelight scenes set-gradient <scene-id> <light-id> --gradient red,orange,yellow --gradient-mode mirrored",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sceneID, lightID := args[0], args[1]
+
+			g, err := bridge.ParseGradient(gradient, gradientMode)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			client, err := getAuthenticatedClient(ctx, logger)
+			if err != nil {
+				return err
+			}
+
+			light, err := client.GetLight(ctx, lightID)
+			if err != nil {
+				return err
+			}
+			if err := light.CheckGradient(g); err != nil {
+				return err
+			}
+
+			if err := client.SetSceneGradient(ctx, sceneID, light.ID, g); err != nil {
+				return errors.Wrap(err, "setting scene gradient")
+			}
+
+			fmt.Printf("Scene %s now sets a gradient on %s\n", sceneID, light.Metadata.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&gradient, "gradient", nil, "The light's colors, 2 to 5 names or hex colors such as red,orange,#ffff00")
+	cmd.Flags().StringVar(&gradientMode, "gradient-mode", "", "Gradient mode: interpolated, mirrored or pixelated")
+
+	return cmd
+}
@@ -5,36 +5,81 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/astro"
 	"github.com/mithilarun/limelight/internal/bridge"
 	"github.com/mithilarun/limelight/internal/credentials"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
+// setupOptions holds the answers given on the command line or in the environment.
+// Anything left empty is asked for, or defaulted when running with --yes.
+type setupOptions struct {
+	authTimeout     time.Duration
+	bridgeIP        string
+	bridgeID        string
+	credentialStore string
+	item            string
+	latitude        string
+	longitude       string
+	deviceName      string
+	assumeYes       bool
+}
+
 func NewSetupCommand(logger *zap.Logger) *cobra.Command {
-	var authTimeout time.Duration
+	opts := &setupOptions{}
 
 	cmd := &cobra.Command{
 		Use:   "setup",
 		Short: "Initial setup wizard for Hue bridge pairing",
-		Long:  "Guides you through the process of connecting to your Hue bridge and storing credentials",
+		Long: `Guides you through the process of connecting to your Hue bridge and storing credentials.
+
+Every answer can be given as a flag or environment variable. With --yes, setup
+runs unattended: nothing is prompted for and unanswered questions take their defaults.
+
+Exit codes: 0 on success, 2 if the link button was not pressed in time,
+3 if the bridge could not be reached, 1 for any other error.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runSetup(logger, authTimeout)
+			return runSetup(logger, opts)
 		},
 	}
 
-	cmd.Flags().DurationVar(&authTimeout, "auth-timeout", 60*time.Second, "Timeout for bridge button press")
+	assumeYes, _ := strconv.ParseBool(os.Getenv("LIMELIGHT_ASSUME_YES"))
+
+	cmd.Flags().DurationVar(&opts.authTimeout, "auth-timeout", 60*time.Second, "Timeout for bridge button press")
+	cmd.Flags().StringVar(&opts.bridgeIP, "bridge-ip", envOrDefault("LIMELIGHT_BRIDGE_IP", ""), "Bridge IP address (env LIMELIGHT_BRIDGE_IP)")
+	cmd.Flags().StringVar(&opts.bridgeID, "bridge-id", envOrDefault("LIMELIGHT_BRIDGE_ID", ""), "Expected bridge ID, used to find the bridge when no IP is given (env LIMELIGHT_BRIDGE_ID)")
+	cmd.Flags().StringVar(&opts.credentialStore, "credential-store", envOrDefault("LIMELIGHT_CREDENTIAL_STORE", ""), "Credential store to save the API key in (env LIMELIGHT_CREDENTIAL_STORE)")
+	cmd.Flags().StringVar(&opts.item, "item", envOrDefault("LIMELIGHT_CREDENTIAL_ITEM", ""), "Credential item name or 1Password op:// reference (env LIMELIGHT_CREDENTIAL_ITEM)")
+	cmd.Flags().StringVar(&opts.latitude, "lat", envOrDefault("LIMELIGHT_LATITUDE", ""), "Home latitude (env LIMELIGHT_LATITUDE)")
+	cmd.Flags().StringVar(&opts.longitude, "lon", envOrDefault("LIMELIGHT_LONGITUDE", ""), "Home longitude (env LIMELIGHT_LONGITUDE)")
+	cmd.Flags().StringVar(&opts.deviceName, "device-name", envOrDefault("LIMELIGHT_DEVICE_NAME", ""), "Device name registered on the bridge, defaults to the hostname (env LIMELIGHT_DEVICE_NAME)")
+	cmd.Flags().BoolVarP(&opts.assumeYes, "yes", "y", assumeYes, "Run unattended, accepting defaults for anything not given (env LIMELIGHT_ASSUME_YES)")
 
 	return cmd
 }
 
-func runSetup(logger *zap.Logger, authTimeout time.Duration) error {
+func runSetup(logger *zap.Logger, opts *setupOptions) error {
 	ctx := context.Background()
-	reader := bufio.NewReader(os.Stdin)
+	prompter := &setupPrompter{
+		reader:    bufio.NewReader(os.Stdin),
+		assumeYes: opts.assumeYes,
+	}
+
+	devType, err := deviceType(opts.deviceName)
+	if err != nil {
+		return err
+	}
+
+	hasLocation, latitude, longitude, err := parseSetupLocation(opts.latitude, opts.longitude)
+	if err != nil {
+		return err
+	}
 
 	fmt.Println("=== Limelight Setup Wizard ===")
 	fmt.Println()
@@ -45,84 +90,115 @@ func runSetup(logger *zap.Logger, authTimeout time.Duration) error {
 	}
 
 	if config == nil {
-		config = &credentials.Config{}
+		config = credentials.NewConfig()
 	}
 
-	fmt.Print("Enter your Hue Bridge IP address: ")
-	if config.BridgeIP != "" {
-		fmt.Printf("[%s] ", config.BridgeIP)
-	}
+	fmt.Printf("Profile: %s\n", config.ProfileName)
+	fmt.Println()
 
-	bridgeIP, err := reader.ReadString('\n')
-	if err != nil {
-		return errors.Wrap(err, "reading bridge IP")
+	bridgeIP := opts.bridgeIP
+	if bridgeIP == "" && opts.bridgeID != "" {
+		discoveryURL := envOrDefault("LIMELIGHT_DISCOVERY_URL", bridge.DefaultDiscoveryURL)
+		found, err := bridge.DiscoverByID(ctx, discoveryURL, opts.bridgeID)
+		if err != nil {
+			return errors.Wrapf(err, "discovering bridge %s", opts.bridgeID)
+		}
+		bridgeIP = found.IPAddress
+		fmt.Printf("Discovered bridge %s at %s\n", found.ID, bridgeIP)
 	}
-	bridgeIP = strings.TrimSpace(bridgeIP)
-
-	if bridgeIP == "" && config.BridgeIP != "" {
-		bridgeIP = config.BridgeIP
+	if bridgeIP == "" {
+		bridgeIP, err = prompter.ask("Enter your Hue Bridge IP address", config.BridgeIP)
+		if err != nil {
+			return errors.Wrap(err, "reading bridge IP")
+		}
 	}
 
 	if bridgeIP == "" {
-		return errors.New("bridge IP is required")
+		return errors.New("bridge IP is required, pass --bridge-ip or --bridge-id")
 	}
 	config.BridgeIP = bridgeIP
 
-	credManager := credentials.NewManager(logger)
+	info, err := bridge.NewClient(bridgeIP, "", logger).GetBridgeInfo(ctx)
+	switch {
+	case err != nil && opts.bridgeID != "":
+		return errors.Wrapf(err, "verifying bridge %s", opts.bridgeID)
+	case err != nil:
+		logger.Warn("failed to read bridge id", zap.Error(err))
+	case opts.bridgeID != "" && !strings.EqualFold(info.BridgeID, opts.bridgeID):
+		return errors.Newf("bridge at %s is %s, expected %s", bridgeIP, info.BridgeID, strings.ToLower(opts.bridgeID))
+	default:
+		config.BridgeID = info.BridgeID
+		fmt.Printf("Found bridge %s (%s)\n", info.Name, info.BridgeID)
+	}
 
-	var onePasswordItemName string
-	if credManager.IsAvailable(ctx) {
-		fmt.Println()
-		fmt.Println("1Password CLI detected. API key will be stored securely in 1Password.")
-		fmt.Print("Enter 1Password item name [limelight-hue]: ")
+	store, err := chooseCredentialStore(ctx, prompter, config, logger, opts.credentialStore)
+	if err != nil {
+		return err
+	}
 
-		itemName, err := reader.ReadString('\n')
-		if err != nil {
-			return errors.Wrap(err, "reading item name")
+	credentialRef := opts.item
+	if credentialRef == "" {
+		credentialRef = config.CredentialItem()
+		switch store.Name() {
+		case credentials.StoreOnePassword, credentials.StorePass, credentials.StoreSecretService:
+			credentialRef, err = prompter.ask("Enter credential item name", credentialRef)
+			if err != nil {
+				return errors.Wrap(err, "reading item name")
+			}
 		}
-		itemName = strings.TrimSpace(itemName)
+	}
 
-		if itemName == "" {
-			itemName = "limelight-hue"
+	if store.Name() == credentials.StoreOnePassword {
+		vault, err := prompter.ask("Enter 1Password vault (blank for the account default)", config.OnePasswordVault)
+		if err != nil {
+			return errors.Wrap(err, "reading vault")
 		}
-		onePasswordItemName = itemName
-		config.OnePasswordItemName = itemName
-	} else {
-		fmt.Println()
-		fmt.Println("WARNING: 1Password CLI not available. API key will be stored in plaintext config.")
-		fmt.Println("Install 1Password CLI for secure credential storage.")
+		config.OnePasswordVault = vault
+
+		store = credentials.NewOnePasswordStore(logger, credentials.OnePasswordConfig{Vault: config.OnePasswordVault})
 	}
 
+	config.CredentialStore = store.Name()
+	config.CredentialRef = credentialRef
+	config.OnePasswordItemName = ""
+
 	fmt.Println()
 	fmt.Println("Press the link button on your Hue Bridge now...")
-	fmt.Printf("Waiting for button press (timeout: %v)...\n", authTimeout)
+	fmt.Printf("Waiting for button press (timeout: %v)...\n", opts.authTimeout)
 	fmt.Println()
 
 	client := bridge.NewClient(bridgeIP, "", logger)
 
-	authCtx, cancel := context.WithTimeout(ctx, authTimeout)
+	authCtx, cancel := context.WithTimeout(ctx, opts.authTimeout)
 	defer cancel()
 
-	apiKey, err := client.Authenticate(authCtx, "limelight#macOS")
+	auth, err := client.Authenticate(authCtx, devType)
 	if err != nil {
 		return errors.Wrap(err, "authenticating with bridge")
 	}
+	apiKey := auth.Username
 
-	if onePasswordItemName != "" {
-		if err := credManager.SaveAPIKey(ctx, onePasswordItemName, apiKey); err != nil {
-			return errors.Wrap(err, "saving api key to 1password")
-		}
-		fmt.Println()
-		fmt.Printf("API key saved to 1Password item: %s\n", onePasswordItemName)
+	if err := saveBridgeCredentials(ctx, store, credentialRef, auth); err != nil {
+		return err
 	}
 
 	if err := credentials.SaveConfig(config); err != nil {
 		return errors.Wrap(err, "saving config")
 	}
 
+	if hasLocation {
+		if err := astro.SetLocationInConfig(latitude, longitude); err != nil {
+			return errors.Wrap(err, "saving location")
+		}
+	}
+
 	fmt.Println()
 	fmt.Println("Setup complete!")
+	fmt.Printf("Profile: %s\n", config.ProfileName)
 	fmt.Printf("Bridge IP: %s\n", config.BridgeIP)
+	if hasLocation {
+		fmt.Printf("Location: %.6f, %.6f\n", latitude, longitude)
+	}
 
 	authenticatedClient := bridge.NewClient(bridgeIP, apiKey, logger)
 	lights, err := authenticatedClient.GetLights(ctx)
@@ -134,3 +210,78 @@ func runSetup(logger *zap.Logger, authTimeout time.Duration) error {
 
 	return nil
 }
+
+// parseSetupLocation validates --lat and --lon before pairing, so a typo
+// doesn't cost a button press. Both or neither must be given.
+func parseSetupLocation(lat, lon string) (bool, float64, float64, error) {
+	if lat == "" && lon == "" {
+		return false, 0, 0, nil
+	}
+	if lat == "" || lon == "" {
+		return false, 0, 0, errors.New("--lat and --lon must be given together")
+	}
+
+	latitude, err := strconv.ParseFloat(lat, 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return false, 0, 0, errors.Newf("invalid latitude: %s (must be between -90 and 90)", lat)
+	}
+	longitude, err := strconv.ParseFloat(lon, 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return false, 0, 0, errors.Newf("invalid longitude: %s (must be between -180 and 180)", lon)
+	}
+
+	return true, latitude, longitude, nil
+}
+
+// setupPrompter reads answers from stdin, or takes the defaults when setup runs unattended
+type setupPrompter struct {
+	reader    *bufio.Reader
+	assumeYes bool
+}
+
+// ask prompts for a value, returning defaultValue for an empty answer
+func (p *setupPrompter) ask(prompt, defaultValue string) (string, error) {
+	if p.assumeYes {
+		return defaultValue, nil
+	}
+
+	fmt.Print(prompt)
+	if defaultValue != "" {
+		fmt.Printf(" [%s]", defaultValue)
+	}
+	fmt.Print(": ")
+
+	answer, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer, nil
+	}
+	return defaultValue, nil
+}
+
+// confirm asks a yes/no question that defaults to no
+func (p *setupPrompter) confirm(prompt string) (bool, error) {
+	if p.assumeYes {
+		return true, nil
+	}
+
+	fmt.Printf("%s [y/N]: ", prompt)
+
+	answer, err := p.reader.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(strings.TrimSpace(answer), "y"), nil
+}
+
+// envOrDefault returns the value of an environment variable, or defaultValue if it is unset or empty
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
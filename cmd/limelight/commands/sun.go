@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/astro"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+const dateLayout = "2006-01-02"
+
+type sunDayOutput struct {
+	Date              string     `json:"date"`
+	Latitude          float64    `json:"latitude"`
+	Longitude         float64    `json:"longitude"`
+	TimeZone          string     `json:"timezone"`
+	AstronomicalDawn  *time.Time `json:"astronomical_dawn,omitempty"`
+	NauticalDawn      *time.Time `json:"nautical_dawn,omitempty"`
+	CivilDawn         *time.Time `json:"civil_dawn,omitempty"`
+	Sunrise           *time.Time `json:"sunrise,omitempty"`
+	SolarNoon         *time.Time `json:"solar_noon,omitempty"`
+	Sunset            *time.Time `json:"sunset,omitempty"`
+	CivilDusk         *time.Time `json:"civil_dusk,omitempty"`
+	NauticalDusk      *time.Time `json:"nautical_dusk,omitempty"`
+	AstronomicalDusk  *time.Time `json:"astronomical_dusk,omitempty"`
+	GoldenHourMorning *timeRange `json:"golden_hour_morning,omitempty"`
+	GoldenHourEvening *timeRange `json:"golden_hour_evening,omitempty"`
+	BlueHourMorning   *timeRange `json:"blue_hour_morning,omitempty"`
+	BlueHourEvening   *timeRange `json:"blue_hour_evening,omitempty"`
+	DayLengthSeconds  float64    `json:"day_length_seconds"`
+}
+
+type timeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func NewSunCommand(logger *zap.Logger) *cobra.Command {
+	var (
+		dateStr    string
+		days       int
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sun",
+		Short: "Show sunrise, sunset and twilight times",
+		Long:  "Show sunrise, sunset, twilight, golden hour, day length and solar noon for the configured location",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days < 1 {
+				return errors.New("--days must be at least 1")
+			}
+
+			home, err := astro.GetHomeFromConfig()
+			if err != nil {
+				return errors.Wrap(err, "getting location, run 'limelight location set' first")
+			}
+
+			date := home.Now()
+			if dateStr != "" {
+				date, err = time.ParseInLocation(dateLayout, dateStr, home.TimeZone)
+				if err != nil {
+					return errors.Wrapf(err, "parsing date %q (expected YYYY-MM-DD)", dateStr)
+				}
+			}
+
+			var results []sunDayOutput
+			for i := 0; i < days; i++ {
+				day := date.AddDate(0, 0, i)
+				times, err := home.SunTimes(day)
+				if err != nil {
+					return errors.Wrapf(err, "calculating sun times for %s", day.Format(dateLayout))
+				}
+				results = append(results, newSunDayOutput(day, home.Latitude, home.Longitude, times))
+			}
+
+			if jsonOutput {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(results)
+			}
+
+			for i, result := range results {
+				if i > 0 {
+					fmt.Println()
+				}
+				printSunDay(result)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dateStr, "date", "", "Date to show (YYYY-MM-DD, default today)")
+	cmd.Flags().IntVar(&days, "days", 1, "Number of consecutive days to show")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+func newSunDayOutput(day time.Time, latitude, longitude float64, times *astro.SunTimes) sunDayOutput {
+	return sunDayOutput{
+		Date:              day.Format(dateLayout),
+		Latitude:          latitude,
+		Longitude:         longitude,
+		TimeZone:          day.Location().String(),
+		AstronomicalDawn:  optionalTime(times.AstronomicalDawn),
+		NauticalDawn:      optionalTime(times.NauticalDawn),
+		CivilDawn:         optionalTime(times.CivilDawn),
+		Sunrise:           optionalTime(times.Sunrise),
+		SolarNoon:         optionalTime(times.SolarNoon),
+		Sunset:            optionalTime(times.Sunset),
+		CivilDusk:         optionalTime(times.CivilDusk),
+		NauticalDusk:      optionalTime(times.NauticalDusk),
+		AstronomicalDusk:  optionalTime(times.AstronomicalDusk),
+		GoldenHourMorning: optionalRange(times.GoldenHourMorning),
+		GoldenHourEvening: optionalRange(times.GoldenHourEvening),
+		BlueHourMorning:   optionalRange(times.BlueHourMorning),
+		BlueHourEvening:   optionalRange(times.BlueHourEvening),
+		DayLengthSeconds:  times.DayLength.Seconds(),
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func optionalRange(r astro.TimeRange) *timeRange {
+	if r.Start.IsZero() || r.End.IsZero() {
+		return nil
+	}
+	return &timeRange{Start: r.Start, End: r.End}
+}
+
+func printSunDay(day sunDayOutput) {
+	fmt.Printf("Sun times for %s at %.4f, %.4f (%s):\n\n", day.Date, day.Latitude, day.Longitude, day.TimeZone)
+
+	rows := []struct {
+		label string
+		value *time.Time
+	}{
+		{"Astronomical dawn", day.AstronomicalDawn},
+		{"Nautical dawn", day.NauticalDawn},
+		{"Civil dawn", day.CivilDawn},
+		{"Sunrise", day.Sunrise},
+		{"Solar noon", day.SolarNoon},
+		{"Sunset", day.Sunset},
+		{"Civil dusk", day.CivilDusk},
+		{"Nautical dusk", day.NauticalDusk},
+		{"Astronomical dusk", day.AstronomicalDusk},
+	}
+	for _, row := range rows {
+		fmt.Printf("  %-18s %s\n", row.label, formatClock(row.value))
+	}
+
+	fmt.Printf("  %-18s %s, %s\n", "Golden hour", formatRange(day.GoldenHourMorning), formatRange(day.GoldenHourEvening))
+	fmt.Printf("  %-18s %s, %s\n", "Blue hour", formatRange(day.BlueHourMorning), formatRange(day.BlueHourEvening))
+	fmt.Printf("  %-18s %s\n", "Day length", formatDayLength(day.DayLengthSeconds))
+}
+
+func formatDayLength(seconds float64) string {
+	minutes := int(seconds/60 + 0.5)
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+func formatClock(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format("15:04:05")
+}
+
+func formatRange(r *timeRange) string {
+	if r == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s-%s", r.Start.Format("15:04"), r.End.Format("15:04"))
+}
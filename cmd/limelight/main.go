@@ -5,6 +5,8 @@ import (
 	"os"
 
 	"github.com/mithilarun/limelight/cmd/limelight/commands"
+	"github.com/mithilarun/limelight/internal/credentials"
+	"github.com/mithilarun/limelight/internal/db"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -17,17 +19,40 @@ func main() {
 	}
 	defer logger.Sync()
 
+	var (
+		profile    string
+		configPath string
+		dbPath     string
+	)
+
 	rootCmd := &cobra.Command{
 		Use:   "limelight",
 		Short: "Philips Hue automation tool",
 		Long:  "A CLI tool for proactive automation of Philips Hue lights and scenes",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			credentials.SelectProfile(profile)
+			credentials.SetConfigPath(configPath)
+			db.SetPath(dbPath)
+		},
 	}
 
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Bridge profile to use instead of the current one (env LIMELIGHT_PROFILE)")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file path (env LIMELIGHT_CONFIG)")
+	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Database path or SQLite DSN (env LIMELIGHT_DB)")
+
 	rootCmd.AddCommand(commands.NewSetupCommand(logger))
 	rootCmd.AddCommand(commands.NewLightsCommand(logger))
 	rootCmd.AddCommand(commands.NewScenesCommand(logger))
+	rootCmd.AddCommand(commands.NewEntertainmentCommand(logger))
+	rootCmd.AddCommand(commands.NewSunCommand(logger))
+	rootCmd.AddCommand(commands.NewLocationCommand(logger))
+	rootCmd.AddCommand(commands.NewProfilesCommand(logger))
+	rootCmd.AddCommand(commands.NewBridgeCommand(logger))
+	rootCmd.AddCommand(commands.NewConfigCommand())
+	rootCmd.AddCommand(commands.NewDBCommand())
+	rootCmd.AddCommand(commands.NewAutomationsCommand(logger))
 
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(commands.ExitCode(err))
 	}
 }
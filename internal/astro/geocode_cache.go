@@ -0,0 +1,117 @@
+package astro
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DefaultGeocodeCacheTTL is how long cached lookups are reused
+	DefaultGeocodeCacheTTL = 30 * 24 * time.Hour
+
+	cacheKindSearch  = "search"
+	cacheKindReverse = "reverse"
+)
+
+// CachingGeocoder stores results from another geocoder in the geocode_cache table.
+// Cache read and write failures are not fatal; the wrapped geocoder is used instead.
+type CachingGeocoder struct {
+	next Geocoder
+	db   *sql.DB
+	ttl  time.Duration
+}
+
+// NewCachingGeocoder creates a geocoder that caches lookups made through next
+func NewCachingGeocoder(next Geocoder, db *sql.DB, ttl time.Duration) *CachingGeocoder {
+	if ttl <= 0 {
+		ttl = DefaultGeocodeCacheTTL
+	}
+
+	return &CachingGeocoder{
+		next: next,
+		db:   db,
+		ttl:  ttl,
+	}
+}
+
+// Search returns cached results for query, looking them up on a cache miss
+func (g *CachingGeocoder) Search(ctx context.Context, query string, limit int) ([]GeocodingResult, error) {
+	if query == "" {
+		return nil, errors.New("search query cannot be empty")
+	}
+
+	key := fmt.Sprintf("%s|%d", strings.ToLower(strings.TrimSpace(query)), limit)
+
+	var cached []GeocodingResult
+	if g.lookup(ctx, cacheKindSearch, key, &cached) {
+		return cached, nil
+	}
+
+	results, err := g.next.Search(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) > 0 {
+		g.store(ctx, cacheKindSearch, key, results)
+	}
+
+	return results, nil
+}
+
+// Reverse returns the cached place for the coordinates, looking it up on a cache miss
+func (g *CachingGeocoder) Reverse(ctx context.Context, latitude, longitude float64) (*GeocodingResult, error) {
+	key := fmt.Sprintf("%.4f,%.4f", latitude, longitude)
+
+	var cached GeocodingResult
+	if g.lookup(ctx, cacheKindReverse, key, &cached) {
+		return &cached, nil
+	}
+
+	result, err := g.next.Reverse(ctx, latitude, longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	g.store(ctx, cacheKindReverse, key, result)
+
+	return result, nil
+}
+
+// lookup decodes an unexpired cache entry into out, reporting whether one was found
+func (g *CachingGeocoder) lookup(ctx context.Context, kind, key string, out interface{}) bool {
+	var results string
+	var createdAt time.Time
+	err := g.db.QueryRowContext(ctx,
+		"SELECT results, created_at FROM geocode_cache WHERE kind = ? AND query = ?",
+		kind, key,
+	).Scan(&results, &createdAt)
+	if err != nil {
+		return false
+	}
+
+	if time.Since(createdAt) > g.ttl {
+		return false
+	}
+
+	return json.Unmarshal([]byte(results), out) == nil
+}
+
+// store saves value as the cache entry for key
+func (g *CachingGeocoder) store(ctx context.Context, kind, key string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	_, _ = g.db.ExecContext(ctx, `
+		INSERT INTO geocode_cache (kind, query, results) VALUES (?, ?, ?)
+		ON CONFLICT (kind, query) DO UPDATE SET results = excluded.results, created_at = CURRENT_TIMESTAMP
+	`, kind, key, string(data))
+}
@@ -1,25 +1,70 @@
 package astro
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cockroachdb/errors"
 )
 
 const (
-	nominatimURL = "https://nominatim.openstreetmap.org/search"
-	userAgent    = "limelight/1.0"
+	DefaultNominatimURL = "https://nominatim.openstreetmap.org"
+	DefaultUserAgent    = "limelight/1.0"
+
+	// nominatimMinInterval follows the public Nominatim usage policy of at most one request per second
+	nominatimMinInterval = time.Second
+	geocodingTimeout     = 10 * time.Second
 )
 
+// ErrNotFound is returned, wrapped, when no place is near the coordinates given to Reverse
+var ErrNotFound = errors.New("no place found")
+
 // GeocodingResult represents a location result from geocoding
 type GeocodingResult struct {
-	DisplayName string
-	Latitude    float64
-	Longitude   float64
+	DisplayName string  `json:"display_name"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+}
+
+// Geocoder looks up coordinates for place names and place names for coordinates
+type Geocoder interface {
+	// Search returns up to limit places matching query, best match first.
+	// An empty slice means nothing matched.
+	Search(ctx context.Context, query string, limit int) ([]GeocodingResult, error)
+	// Reverse returns the place nearest to the given coordinates
+	Reverse(ctx context.Context, latitude, longitude float64) (*GeocodingResult, error)
+}
+
+// NominatimConfig configures a NominatimGeocoder
+type NominatimConfig struct {
+	// BaseURL of the Nominatim server, defaults to DefaultNominatimURL
+	BaseURL string
+	// UserAgent identifies the application, as required by the Nominatim usage policy
+	UserAgent string
+	// MinInterval is the minimum time between requests, defaults to one second
+	MinInterval time.Duration
+	// HTTPClient is used for requests, defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// NominatimGeocoder geocodes using a Nominatim (OpenStreetMap) server
+type NominatimGeocoder struct {
+	baseURL     string
+	userAgent   string
+	minInterval time.Duration
+	httpClient  *http.Client
+
+	// slot lets one request run at a time, from the rate limit wait until
+	// the response arrives, and guards lastRequest
+	slot        chan struct{}
+	lastRequest time.Time
 }
 
 // nominatimResponse represents the JSON response from Nominatim API
@@ -30,65 +75,252 @@ type nominatimResponse struct {
 	DisplayName string  `json:"display_name"`
 	Type        string  `json:"type"`
 	Importance  float64 `json:"importance"`
+	Error       string  `json:"error,omitempty"`
 }
 
-// GeocodeCity looks up the coordinates for a city using Nominatim (OpenStreetMap)
-func GeocodeCity(cityName string) (*GeocodingResult, error) {
-	if cityName == "" {
-		return nil, errors.New("city name cannot be empty")
+// NewNominatimGeocoder creates a Nominatim geocoder, filling in defaults for unset config fields
+func NewNominatimGeocoder(config NominatimConfig) *NominatimGeocoder {
+	g := &NominatimGeocoder{
+		baseURL:     strings.TrimRight(config.BaseURL, "/"),
+		userAgent:   config.UserAgent,
+		minInterval: config.MinInterval,
+		httpClient:  config.HTTPClient,
+		slot:        make(chan struct{}, 1),
+	}
+
+	if g.baseURL == "" {
+		g.baseURL = DefaultNominatimURL
+	}
+	if g.userAgent == "" {
+		g.userAgent = DefaultUserAgent
+	}
+	if g.minInterval == 0 {
+		g.minInterval = nominatimMinInterval
+	}
+	if g.httpClient == nil {
+		g.httpClient = &http.Client{Timeout: geocodingTimeout}
+	}
+
+	return g
+}
+
+// Search looks up places matching query
+func (g *NominatimGeocoder) Search(ctx context.Context, query string, limit int) ([]GeocodingResult, error) {
+	if query == "" {
+		return nil, errors.New("search query cannot be empty")
+	}
+	if limit < 1 {
+		limit = 1
 	}
 
 	params := url.Values{}
-	params.Set("q", cityName)
+	params.Set("q", query)
 	params.Set("format", "json")
-	params.Set("limit", "1")
+	params.Set("limit", strconv.Itoa(limit))
 	params.Set("addressdetails", "0")
 
-	reqURL := fmt.Sprintf("%s?%s", nominatimURL, params.Encode())
+	var responses []nominatimResponse
+	if err := g.get(ctx, "/search", params, &responses); err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest("GET", reqURL, nil)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create request")
+	results := make([]GeocodingResult, 0, len(responses))
+	for _, response := range responses {
+		result, err := response.toResult()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
+
+// Reverse looks up the place nearest to the given coordinates
+func (g *NominatimGeocoder) Reverse(ctx context.Context, latitude, longitude float64) (*GeocodingResult, error) {
+	if err := validateCoordinates(latitude, longitude); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("lat", strconv.FormatFloat(latitude, 'f', -1, 64))
+	params.Set("lon", strconv.FormatFloat(longitude, 'f', -1, 64))
+	params.Set("format", "json")
+	params.Set("zoom", "10")
+
+	var response nominatimResponse
+	if err := g.get(ctx, "/reverse", params, &response); err != nil {
+		return nil, err
 	}
 
-	req.Header.Set("User-Agent", userAgent)
+	if response.Error != "" {
+		return nil, errors.Newf("reverse geocoding failed: %s", response.Error)
+	}
+
+	return response.toResult()
+}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+// get performs a rate-limited GET request and decodes the JSON response into out
+func (g *NominatimGeocoder) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	if err := g.wait(ctx); err != nil {
+		return err
 	}
+	defer g.release()
 
-	resp, err := client.Do(req)
+	reqURL := fmt.Sprintf("%s%s?%s", g.baseURL, path, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to make geocoding request")
+		return errors.Wrap(err, "failed to create request")
+	}
+
+	req.Header.Set("User-Agent", g.userAgent)
+
+	resp, err := g.httpClient.Do(req)
+
+	// Space the next request from when this one finished, so that a slow
+	// request cannot bring two closer than minInterval at the server
+	g.lastRequest = time.Now()
+
+	if err != nil {
+		return errors.Wrap(err, "failed to make geocoding request")
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return errors.New("geocoding request was rate limited by the server")
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Newf("geocoding request failed with status %d", resp.StatusCode)
+		return errors.Newf("geocoding request failed with status %d", resp.StatusCode)
 	}
 
-	var results []nominatimResponse
-	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
-		return nil, errors.Wrap(err, "failed to decode geocoding response")
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Wrap(err, "failed to decode geocoding response")
 	}
 
-	if len(results) == 0 {
-		return nil, errors.Newf("no results found for city: %s", cityName)
+	return nil
+}
+
+// wait takes the request slot and blocks until the minimum interval since the
+// previous request finished has passed. The caller must release the slot once
+// its request has finished.
+func (g *NominatimGeocoder) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "waiting for geocoding rate limit")
+	case g.slot <- struct{}{}:
+	}
+
+	if delay := g.minInterval - time.Since(g.lastRequest); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			g.release()
+			return errors.Wrap(ctx.Err(), "waiting for geocoding rate limit")
+		case <-timer.C:
+		}
 	}
 
-	result := results[0]
+	return nil
+}
+
+// release frees the request slot taken by wait
+func (g *NominatimGeocoder) release() {
+	<-g.slot
+}
 
-	var lat, lon float64
-	if _, err := fmt.Sscanf(result.Lat, "%f", &lat); err != nil {
+// toResult parses the string coordinates returned by Nominatim
+func (r nominatimResponse) toResult() (*GeocodingResult, error) {
+	lat, err := strconv.ParseFloat(r.Lat, 64)
+	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse latitude")
 	}
-	if _, err := fmt.Sscanf(result.Lon, "%f", &lon); err != nil {
+	lon, err := strconv.ParseFloat(r.Lon, 64)
+	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse longitude")
 	}
 
 	return &GeocodingResult{
-		DisplayName: result.DisplayName,
+		DisplayName: r.DisplayName,
 		Latitude:    lat,
 		Longitude:   lon,
 	}, nil
 }
+
+// FallbackGeocoder tries a primary geocoder and falls back to a secondary one when it fails
+type FallbackGeocoder struct {
+	primary  Geocoder
+	fallback Geocoder
+}
+
+// NewFallbackGeocoder creates a geocoder that uses fallback when primary returns an error
+func NewFallbackGeocoder(primary, fallback Geocoder) *FallbackGeocoder {
+	return &FallbackGeocoder{
+		primary:  primary,
+		fallback: fallback,
+	}
+}
+
+// Search tries the primary geocoder, then the fallback
+func (g *FallbackGeocoder) Search(ctx context.Context, query string, limit int) ([]GeocodingResult, error) {
+	results, err := g.primary.Search(ctx, query, limit)
+	if err == nil {
+		return results, nil
+	}
+
+	results, fallbackErr := g.fallback.Search(ctx, query, limit)
+	if fallbackErr != nil {
+		return nil, errors.CombineErrors(err, fallbackErr)
+	}
+	return results, nil
+}
+
+// Reverse tries the primary geocoder, then the fallback
+func (g *FallbackGeocoder) Reverse(ctx context.Context, latitude, longitude float64) (*GeocodingResult, error) {
+	result, err := g.primary.Reverse(ctx, latitude, longitude)
+	if err == nil {
+		return result, nil
+	}
+
+	result, fallbackErr := g.fallback.Reverse(ctx, latitude, longitude)
+	if fallbackErr != nil {
+		return nil, errors.CombineErrors(err, fallbackErr)
+	}
+	return result, nil
+}
+
+var (
+	defaultGeocoderOnce sync.Once
+	defaultGeocoder     Geocoder
+)
+
+// DefaultGeocoder returns the public Nominatim server backed by the bundled offline city database
+func DefaultGeocoder() Geocoder {
+	defaultGeocoderOnce.Do(func() {
+		defaultGeocoder = NewFallbackGeocoder(
+			NewNominatimGeocoder(NominatimConfig{}),
+			NewOfflineGeocoder(),
+		)
+	})
+	return defaultGeocoder
+}
+
+// GeocodeCity looks up the coordinates for a city using the default geocoder
+func GeocodeCity(cityName string) (*GeocodingResult, error) {
+	if cityName == "" {
+		return nil, errors.New("city name cannot be empty")
+	}
+
+	results, err := DefaultGeocoder().Search(context.Background(), cityName, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, errors.Newf("no results found for city: %s", cityName)
+	}
+
+	return &results[0], nil
+}
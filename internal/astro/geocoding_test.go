@@ -1,9 +1,17 @@
 package astro
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/db"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -48,3 +56,297 @@ func TestGeocodeCityMultipleWords(t *testing.T) {
 	assert.Less(t, result.Latitude, 41.0)
 	assert.Contains(t, result.DisplayName, "New York")
 }
+
+func newNominatimStub(t *testing.T, handler http.HandlerFunc) *NominatimGeocoder {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return NewNominatimGeocoder(NominatimConfig{
+		BaseURL:     server.URL,
+		UserAgent:   "limelight-test/1.0",
+		MinInterval: time.Millisecond,
+	})
+}
+
+func TestNominatimGeocoderSearch(t *testing.T) {
+	geocoder := newNominatimStub(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/search", r.URL.Path)
+		assert.Equal(t, "Portland", r.URL.Query().Get("q"))
+		assert.Equal(t, "3", r.URL.Query().Get("limit"))
+		assert.Equal(t, "limelight-test/1.0", r.Header.Get("User-Agent"))
+
+		fmt.Fprint(w, `[
+			{"place_id": 1, "lat": "45.5152", "lon": "-122.6784", "display_name": "Portland, Oregon, United States"},
+			{"place_id": 2, "lat": "43.6591", "lon": "-70.2568", "display_name": "Portland, Maine, United States"}
+		]`)
+	})
+
+	results, err := geocoder.Search(context.Background(), "Portland", 3)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "Portland, Oregon, United States", results[0].DisplayName)
+	assert.Equal(t, 45.5152, results[0].Latitude)
+	assert.Equal(t, -70.2568, results[1].Longitude)
+}
+
+func TestNominatimGeocoderSearchNoResults(t *testing.T) {
+	geocoder := newNominatimStub(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+
+	results, err := geocoder.Search(context.Background(), "Nowhere", 1)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestNominatimGeocoderErrorStatus(t *testing.T) {
+	geocoder := newNominatimStub(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	_, err := geocoder.Search(context.Background(), "London", 1)
+	assert.Error(t, err)
+}
+
+func TestNominatimGeocoderReverse(t *testing.T) {
+	geocoder := newNominatimStub(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/reverse", r.URL.Path)
+		assert.Equal(t, "51.5074", r.URL.Query().Get("lat"))
+		assert.Equal(t, "-0.1278", r.URL.Query().Get("lon"))
+
+		fmt.Fprint(w, `{"place_id": 1, "lat": "51.5073", "lon": "-0.1276", "display_name": "London, Greater London, England"}`)
+	})
+
+	result, err := geocoder.Reverse(context.Background(), 51.5074, -0.1278)
+	require.NoError(t, err)
+	assert.Equal(t, "London, Greater London, England", result.DisplayName)
+}
+
+func TestNominatimGeocoderReverseError(t *testing.T) {
+	geocoder := newNominatimStub(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error": "Unable to geocode"}`)
+	})
+
+	_, err := geocoder.Reverse(context.Background(), 0, 0)
+	assert.Error(t, err)
+}
+
+func TestNominatimGeocoderRateLimit(t *testing.T) {
+	var requests []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		fmt.Fprint(w, `[]`)
+	}))
+	t.Cleanup(server.Close)
+
+	geocoder := NewNominatimGeocoder(NominatimConfig{
+		BaseURL:     server.URL,
+		MinInterval: 100 * time.Millisecond,
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := geocoder.Search(context.Background(), "London", 1)
+		require.NoError(t, err)
+	}
+
+	require.Len(t, requests, 3)
+	for i := 1; i < len(requests); i++ {
+		assert.GreaterOrEqual(t, requests[i].Sub(requests[i-1]), 100*time.Millisecond)
+	}
+}
+
+func TestNominatimGeocoderRateLimitConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	var starts, ends []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+
+		time.Sleep(150 * time.Millisecond)
+		fmt.Fprint(w, `[]`)
+
+		mu.Lock()
+		ends = append(ends, time.Now())
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	geocoder := NewNominatimGeocoder(NominatimConfig{
+		BaseURL:     server.URL,
+		MinInterval: 100 * time.Millisecond,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := geocoder.Search(context.Background(), "London", 1)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, starts, 2)
+	require.Len(t, ends, 2)
+	assert.GreaterOrEqual(t, starts[1].Sub(ends[0]), 100*time.Millisecond,
+		"the second request should wait for the first to finish")
+}
+
+func TestNominatimGeocoderRateLimitCancelled(t *testing.T) {
+	geocoder := newNominatimStub(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	geocoder.minInterval = time.Hour
+
+	_, err := geocoder.Search(context.Background(), "London", 1)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = geocoder.Search(ctx, "London", 1)
+	assert.Error(t, err)
+}
+
+func TestOfflineGeocoderSearch(t *testing.T) {
+	geocoder := NewOfflineGeocoder()
+
+	results, err := geocoder.Search(context.Background(), "san francisco", 5)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "San Francisco, United States", results[0].DisplayName)
+	assert.InDelta(t, 37.7749, results[0].Latitude, 0.01)
+}
+
+func TestOfflineGeocoderSearchCountry(t *testing.T) {
+	geocoder := NewOfflineGeocoder()
+
+	results, err := geocoder.Search(context.Background(), "Portland, United States", 5)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.InDelta(t, 45.5152, results[0].Latitude, 0.01)
+
+	results, err = geocoder.Search(context.Background(), "Portland, France", 5)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestOfflineGeocoderSearchRanking(t *testing.T) {
+	geocoder := NewOfflineGeocoder()
+
+	results, err := geocoder.Search(context.Background(), "San", 10)
+	require.NoError(t, err)
+	require.Greater(t, len(results), 1)
+	assert.Equal(t, "Santiago, Chile", results[0].DisplayName)
+}
+
+func TestOfflineGeocoderReverse(t *testing.T) {
+	geocoder := NewOfflineGeocoder()
+
+	result, err := geocoder.Reverse(context.Background(), 51.50, -0.12)
+	require.NoError(t, err)
+	assert.Equal(t, "London, United Kingdom", result.DisplayName)
+
+	_, err = geocoder.Reverse(context.Background(), -40, -20)
+	assert.True(t, errors.Is(err, ErrNotFound), "mid-Atlantic is far from any city")
+}
+
+type failingGeocoder struct{}
+
+func (failingGeocoder) Search(ctx context.Context, query string, limit int) ([]GeocodingResult, error) {
+	return nil, errors.New("network unavailable")
+}
+
+func (failingGeocoder) Reverse(ctx context.Context, latitude, longitude float64) (*GeocodingResult, error) {
+	return nil, errors.New("network unavailable")
+}
+
+func TestFallbackGeocoder(t *testing.T) {
+	geocoder := NewFallbackGeocoder(failingGeocoder{}, NewOfflineGeocoder())
+
+	results, err := geocoder.Search(context.Background(), "Berlin", 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Berlin, Germany", results[0].DisplayName)
+
+	result, err := geocoder.Reverse(context.Background(), 52.52, 13.40)
+	require.NoError(t, err)
+	assert.Equal(t, "Berlin, Germany", result.DisplayName)
+}
+
+func TestFallbackGeocoderBothFail(t *testing.T) {
+	geocoder := NewFallbackGeocoder(failingGeocoder{}, failingGeocoder{})
+
+	_, err := geocoder.Search(context.Background(), "Berlin", 1)
+	assert.Error(t, err)
+}
+
+func TestCachingGeocoder(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "limelight.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		database.Close()
+	})
+	require.NoError(t, db.RunMigrations(database))
+
+	var searches, reverses int
+	stub := newNominatimStub(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reverse" {
+			reverses++
+			fmt.Fprint(w, `{"lat": "48.8566", "lon": "2.3522", "display_name": "Paris, France"}`)
+			return
+		}
+		searches++
+		fmt.Fprint(w, `[{"lat": "48.8566", "lon": "2.3522", "display_name": "Paris, France"}]`)
+	})
+
+	geocoder := NewCachingGeocoder(stub, database, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		results, err := geocoder.Search(context.Background(), " Paris ", 1)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "Paris, France", results[0].DisplayName)
+
+		result, err := geocoder.Reverse(context.Background(), 48.8566, 2.3522)
+		require.NoError(t, err)
+		assert.Equal(t, "Paris, France", result.DisplayName)
+	}
+
+	assert.Equal(t, 1, searches)
+	assert.Equal(t, 1, reverses)
+
+	_, err = geocoder.Search(context.Background(), "paris", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, searches, "lookups should be case-insensitive")
+}
+
+func TestCachingGeocoderExpired(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "limelight.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		database.Close()
+	})
+	require.NoError(t, db.RunMigrations(database))
+
+	var searches int
+	stub := newNominatimStub(t, func(w http.ResponseWriter, r *http.Request) {
+		searches++
+		fmt.Fprint(w, `[{"lat": "48.8566", "lon": "2.3522", "display_name": "Paris, France"}]`)
+	})
+
+	geocoder := NewCachingGeocoder(stub, database, time.Hour)
+
+	_, err = geocoder.Search(context.Background(), "Paris", 1)
+	require.NoError(t, err)
+
+	_, err = database.Exec("UPDATE geocode_cache SET created_at = datetime('now', '-2 hours')")
+	require.NoError(t, err)
+
+	_, err = geocoder.Search(context.Background(), "Paris", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, searches)
+}
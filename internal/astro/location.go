@@ -1,10 +1,40 @@
 package astro
 
 import (
+	"time"
+
 	"github.com/cockroachdb/errors"
 	"github.com/mithilarun/limelight/internal/credentials"
 )
 
+// Home is the configured location of the house and its time zone.
+// Schedules and sun times should be expressed in the home's zone, not the host's.
+type Home struct {
+	Latitude  float64
+	Longitude float64
+	TimeZone  *time.Location
+}
+
+// Now returns the current time in the home's time zone
+func (h *Home) Now() time.Time {
+	return time.Now().In(h.TimeZone)
+}
+
+// Date returns midnight of the given calendar date in the home's time zone
+func (h *Home) Date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, h.TimeZone)
+}
+
+// SunTimes calculates sun events for the home on the calendar day of t in the home's time zone
+func (h *Home) SunTimes(t time.Time) (*SunTimes, error) {
+	return CalculateSunTimes(h.Latitude, h.Longitude, t.In(h.TimeZone))
+}
+
+// SunEvent calculates a sun event for the home on the calendar day of t in the home's time zone
+func (h *Home) SunEvent(t time.Time, event SunEvent) (time.Time, error) {
+	return CalculateSunEvent(h.Latitude, h.Longitude, t.In(h.TimeZone), event)
+}
+
 // GetLocationFromConfig retrieves the latitude and longitude from the config file
 func GetLocationFromConfig() (latitude, longitude float64, err error) {
 	config, err := credentials.LoadConfig()
@@ -12,25 +42,65 @@ func GetLocationFromConfig() (latitude, longitude float64, err error) {
 		return 0, 0, errors.Wrap(err, "failed to load config")
 	}
 
+	return locationFromConfig(config)
+}
+
+// locationFromConfig validates and returns the coordinates stored in config
+func locationFromConfig(config *credentials.Config) (latitude, longitude float64, err error) {
 	if config == nil {
 		return 0, 0, errors.New("config file does not exist")
 	}
 
-	if config.Latitude == 0 && config.Longitude == 0 {
+	latitude, longitude, ok := config.Location()
+	if !ok {
 		return 0, 0, errors.New("latitude and longitude not set in config")
 	}
 
-	if config.Latitude < -90 || config.Latitude > 90 {
-		return 0, 0, errors.Newf("invalid latitude in config: %f (must be between -90 and 90)", config.Latitude)
+	if latitude < -90 || latitude > 90 {
+		return 0, 0, errors.Newf("invalid latitude in config: %f (must be between -90 and 90)", latitude)
 	}
-	if config.Longitude < -180 || config.Longitude > 180 {
-		return 0, 0, errors.Newf("invalid longitude in config: %f (must be between -180 and 180)", config.Longitude)
+	if longitude < -180 || longitude > 180 {
+		return 0, 0, errors.Newf("invalid longitude in config: %f (must be between -180 and 180)", longitude)
 	}
 
-	return config.Latitude, config.Longitude, nil
+	return latitude, longitude, nil
 }
 
-// SetLocationInConfig updates the latitude and longitude in the config file
+// GetHomeFromConfig retrieves the home location and time zone from the config file.
+// If no time zone is stored it is derived from the coordinates.
+func GetHomeFromConfig() (*Home, error) {
+	config, err := credentials.LoadConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load config")
+	}
+
+	latitude, longitude, err := locationFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	zoneName := config.TimeZone
+	if zoneName == "" {
+		zoneName, err = LookupTimeZone(latitude, longitude)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to derive time zone from location")
+		}
+	}
+
+	timeZone, err := LoadTimeZone(zoneName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load time zone from config")
+	}
+
+	return &Home{
+		Latitude:  latitude,
+		Longitude: longitude,
+		TimeZone:  timeZone,
+	}, nil
+}
+
+// SetLocationInConfig updates the latitude and longitude in the config file,
+// along with the time zone derived from them
 func SetLocationInConfig(latitude, longitude float64) error {
 	if latitude < -90 || latitude > 90 {
 		return errors.Newf("invalid latitude: %f (must be between -90 and 90)", latitude)
@@ -39,6 +109,37 @@ func SetLocationInConfig(latitude, longitude float64) error {
 		return errors.Newf("invalid longitude: %f (must be between -180 and 180)", longitude)
 	}
 
+	timeZone, err := LookupTimeZone(latitude, longitude)
+	if err != nil {
+		return errors.Wrap(err, "failed to derive time zone from location")
+	}
+
+	config, err := credentials.LoadConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	if config == nil {
+		config = &credentials.Config{}
+	}
+
+	config.Latitude = &latitude
+	config.Longitude = &longitude
+	config.TimeZone = timeZone
+
+	if err := credentials.SaveConfig(config); err != nil {
+		return errors.Wrap(err, "failed to save config")
+	}
+
+	return nil
+}
+
+// SetTimeZoneInConfig overrides the derived time zone with an IANA zone name
+func SetTimeZoneInConfig(name string) error {
+	if _, err := LoadTimeZone(name); err != nil {
+		return err
+	}
+
 	config, err := credentials.LoadConfig()
 	if err != nil {
 		return errors.Wrap(err, "failed to load config")
@@ -48,8 +149,7 @@ func SetLocationInConfig(latitude, longitude float64) error {
 		config = &credentials.Config{}
 	}
 
-	config.Latitude = latitude
-	config.Longitude = longitude
+	config.TimeZone = name
 
 	if err := credentials.SaveConfig(config); err != nil {
 		return errors.Wrap(err, "failed to save config")
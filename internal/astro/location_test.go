@@ -1,8 +1,9 @@
 package astro
 
 import (
-	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/mithilarun/limelight/internal/credentials"
 	"github.com/stretchr/testify/assert"
@@ -10,12 +11,11 @@ import (
 )
 
 func setupTestEnv(t *testing.T) {
-	tmpDir := t.TempDir()
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	t.Cleanup(func() {
-		os.Setenv("HOME", oldHome)
-	})
+	t.Setenv(credentials.ConfigPathEnv, filepath.Join(t.TempDir(), "config.json"))
+}
+
+func coordinate(value float64) *float64 {
+	return &value
 }
 
 func TestGetLocationFromConfig(t *testing.T) {
@@ -23,8 +23,8 @@ func TestGetLocationFromConfig(t *testing.T) {
 
 	config := &credentials.Config{
 		BridgeIP:  "192.168.1.100",
-		Latitude:  37.7749,
-		Longitude: -122.4194,
+		Latitude:  coordinate(37.7749),
+		Longitude: coordinate(-122.4194),
 	}
 	err := credentials.SaveConfig(config)
 	require.NoError(t, err)
@@ -63,8 +63,8 @@ func TestSetLocationInConfig(t *testing.T) {
 
 	config, err := credentials.LoadConfig()
 	require.NoError(t, err)
-	assert.Equal(t, 37.7749, config.Latitude)
-	assert.Equal(t, -122.4194, config.Longitude)
+	assert.Equal(t, coordinate(37.7749), config.Latitude)
+	assert.Equal(t, coordinate(-122.4194), config.Longitude)
 }
 
 func TestSetLocationInConfigExistingConfig(t *testing.T) {
@@ -82,8 +82,20 @@ func TestSetLocationInConfigExistingConfig(t *testing.T) {
 	updated, err := credentials.LoadConfig()
 	require.NoError(t, err)
 	assert.Equal(t, "192.168.1.100", updated.BridgeIP)
-	assert.Equal(t, 40.7128, updated.Latitude)
-	assert.Equal(t, -74.0060, updated.Longitude)
+	assert.Equal(t, coordinate(40.7128), updated.Latitude)
+	assert.Equal(t, coordinate(-74.0060), updated.Longitude)
+}
+
+func TestGetLocationFromConfigZeroCoordinates(t *testing.T) {
+	setupTestEnv(t)
+
+	err := SetLocationInConfig(0, 0)
+	require.NoError(t, err)
+
+	lat, lon, err := GetLocationFromConfig()
+	require.NoError(t, err, "0, 0 is a place, not a missing location")
+	assert.Equal(t, 0.0, lat)
+	assert.Equal(t, 0.0, lon)
 }
 
 func TestSetLocationInConfigInvalidLatitude(t *testing.T) {
@@ -141,8 +153,8 @@ func TestGetLocationFromConfigInvalidLatitude(t *testing.T) {
 
 	config := &credentials.Config{
 		BridgeIP:  "192.168.1.100",
-		Latitude:  200.0,
-		Longitude: 0,
+		Latitude:  coordinate(200.0),
+		Longitude: coordinate(0),
 	}
 	err := credentials.SaveConfig(config)
 	require.NoError(t, err)
@@ -156,8 +168,8 @@ func TestGetLocationFromConfigInvalidLongitude(t *testing.T) {
 
 	config := &credentials.Config{
 		BridgeIP:  "192.168.1.100",
-		Latitude:  0,
-		Longitude: 500.0,
+		Latitude:  coordinate(0),
+		Longitude: coordinate(500.0),
 	}
 	err := credentials.SaveConfig(config)
 	require.NoError(t, err)
@@ -184,3 +196,72 @@ func TestSetLocationByCity(t *testing.T) {
 	assert.Equal(t, result.Latitude, lat)
 	assert.Equal(t, result.Longitude, lon)
 }
+
+func TestSetLocationInConfigDerivesTimeZone(t *testing.T) {
+	setupTestEnv(t)
+
+	err := SetLocationInConfig(51.5074, -0.1278)
+	require.NoError(t, err)
+
+	config, err := credentials.LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/London", config.TimeZone)
+}
+
+func TestSetTimeZoneInConfig(t *testing.T) {
+	setupTestEnv(t)
+
+	err := SetLocationInConfig(37.7749, -122.4194)
+	require.NoError(t, err)
+
+	err = SetTimeZoneInConfig("America/Denver")
+	require.NoError(t, err)
+
+	home, err := GetHomeFromConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "America/Denver", home.TimeZone.String())
+	assert.Equal(t, 37.7749, home.Latitude)
+}
+
+func TestSetTimeZoneInConfigInvalid(t *testing.T) {
+	setupTestEnv(t)
+
+	err := SetTimeZoneInConfig("Not/AZone")
+	assert.Error(t, err)
+}
+
+func TestGetHomeFromConfigDerivesMissingTimeZone(t *testing.T) {
+	setupTestEnv(t)
+
+	config := &credentials.Config{
+		BridgeIP:  "192.168.1.100",
+		Latitude:  coordinate(35.6895),
+		Longitude: coordinate(139.6917),
+	}
+	err := credentials.SaveConfig(config)
+	require.NoError(t, err)
+
+	home, err := GetHomeFromConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "Asia/Tokyo", home.TimeZone.String())
+}
+
+func TestHomeSunTimesUseHomeTimeZone(t *testing.T) {
+	location, err := LoadTimeZone("America/Los_Angeles")
+	require.NoError(t, err)
+
+	home := &Home{
+		Latitude:  37.7749,
+		Longitude: -122.4194,
+		TimeZone:  location,
+	}
+
+	// Midnight UTC on the 22nd is still the 21st at home
+	times, err := home.SunTimes(time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	assert.Equal(t, location, times.Sunset.Location())
+	assert.Equal(t, 21, times.Sunset.Day())
+	assert.Equal(t, 16, times.Sunset.Hour())
+	assert.Equal(t, 21, home.Date(2024, 12, 21).Day())
+}
@@ -0,0 +1,202 @@
+package astro
+
+import (
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	earthRadiusKm = 6371.0
+
+	// offlineReverseMaxKm is how far a bundled city may be from the coordinates
+	// given to Reverse, beyond which the city would mislabel the place
+	offlineReverseMaxKm = 50.0
+)
+
+//go:embed data/cities.csv
+var citiesCSV string
+
+// city is an entry in the bundled city database
+type city struct {
+	name       string
+	country    string
+	latitude   float64
+	longitude  float64
+	population int
+}
+
+var (
+	citiesOnce sync.Once
+	cities     []city
+	citiesErr  error
+)
+
+// loadCities parses the bundled city database once
+func loadCities() ([]city, error) {
+	citiesOnce.Do(func() {
+		records, err := csv.NewReader(strings.NewReader(citiesCSV)).ReadAll()
+		if err != nil {
+			citiesErr = errors.Wrap(err, "failed to parse bundled city database")
+			return
+		}
+
+		for i, record := range records {
+			if i == 0 {
+				continue
+			}
+
+			lat, err := strconv.ParseFloat(record[2], 64)
+			if err != nil {
+				citiesErr = errors.Wrapf(err, "invalid latitude for %s", record[0])
+				return
+			}
+			lon, err := strconv.ParseFloat(record[3], 64)
+			if err != nil {
+				citiesErr = errors.Wrapf(err, "invalid longitude for %s", record[0])
+				return
+			}
+			population, err := strconv.Atoi(record[4])
+			if err != nil {
+				citiesErr = errors.Wrapf(err, "invalid population for %s", record[0])
+				return
+			}
+
+			cities = append(cities, city{
+				name:       record[0],
+				country:    record[1],
+				latitude:   lat,
+				longitude:  lon,
+				population: population,
+			})
+		}
+	})
+
+	return cities, citiesErr
+}
+
+// OfflineGeocoder geocodes against a small bundled database of major cities.
+// It needs no network access and is used as a fallback when online lookups fail.
+type OfflineGeocoder struct{}
+
+// NewOfflineGeocoder creates a geocoder backed by the bundled city database
+func NewOfflineGeocoder() *OfflineGeocoder {
+	return &OfflineGeocoder{}
+}
+
+// Search matches query against city names, optionally followed by ", <country>".
+// Exact name matches rank before prefix matches, then larger cities first.
+func (g *OfflineGeocoder) Search(ctx context.Context, query string, limit int) ([]GeocodingResult, error) {
+	if query == "" {
+		return nil, errors.New("search query cannot be empty")
+	}
+
+	all, err := loadCities()
+	if err != nil {
+		return nil, err
+	}
+
+	name, country, _ := strings.Cut(strings.ToLower(query), ",")
+	name = strings.TrimSpace(name)
+	country = strings.TrimSpace(country)
+
+	type match struct {
+		city  city
+		exact bool
+	}
+
+	var matches []match
+	for _, c := range all {
+		cityName := strings.ToLower(c.name)
+		if !strings.HasPrefix(cityName, name) {
+			continue
+		}
+		if country != "" && !strings.HasPrefix(strings.ToLower(c.country), country) {
+			continue
+		}
+		matches = append(matches, match{city: c, exact: cityName == name})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].exact != matches[j].exact {
+			return matches[i].exact
+		}
+		return matches[i].city.population > matches[j].city.population
+	})
+
+	if limit < 1 {
+		limit = 1
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	results := make([]GeocodingResult, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, m.city.toResult())
+	}
+
+	return results, nil
+}
+
+// Reverse returns the bundled city nearest to the given coordinates, or an error
+// wrapping ErrNotFound when none is within offlineReverseMaxKm
+func (g *OfflineGeocoder) Reverse(ctx context.Context, latitude, longitude float64) (*GeocodingResult, error) {
+	if err := validateCoordinates(latitude, longitude); err != nil {
+		return nil, err
+	}
+
+	all, err := loadCities()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(all) == 0 {
+		return nil, errors.New("bundled city database is empty")
+	}
+
+	nearest := all[0]
+	nearestDistance := math.Inf(1)
+	for _, c := range all {
+		distance := distanceKm(latitude, longitude, c.latitude, c.longitude)
+		if distance < nearestDistance {
+			nearest = c
+			nearestDistance = distance
+		}
+	}
+
+	if nearestDistance > offlineReverseMaxKm {
+		return nil, errors.Mark(errors.Newf("no bundled city within %.0f km of %.4f, %.4f",
+			offlineReverseMaxKm, latitude, longitude), ErrNotFound)
+	}
+
+	result := nearest.toResult()
+	return &result, nil
+}
+
+// toResult converts a bundled city to a geocoding result
+func (c city) toResult() GeocodingResult {
+	return GeocodingResult{
+		DisplayName: c.name + ", " + c.country,
+		Latitude:    c.latitude,
+		Longitude:   c.longitude,
+	}
+}
+
+// distanceKm calculates the great-circle distance between two points using the haversine formula
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := (lat2 - lat1) * degreesToRadians
+	dLon := (lon2 - lon1) * degreesToRadians
+
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		cosDeg(lat1)*cosDeg(lat2)*math.Pow(math.Sin(dLon/2), 2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,430 @@
+package astro
+
+import (
+	"math"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// SunEvent identifies a point in the sun's daily path
+type SunEvent string
+
+const (
+	SunEventAstronomicalDawn       SunEvent = "astronomical_dawn"
+	SunEventNauticalDawn           SunEvent = "nautical_dawn"
+	SunEventCivilDawn              SunEvent = "civil_dawn"
+	SunEventBlueHourMorningStart   SunEvent = "blue_hour_morning_start"
+	SunEventBlueHourMorningEnd     SunEvent = "blue_hour_morning_end"
+	SunEventGoldenHourMorningStart SunEvent = "golden_hour_morning_start"
+	SunEventSunrise                SunEvent = "sunrise"
+	SunEventGoldenHourMorningEnd   SunEvent = "golden_hour_morning_end"
+	SunEventSolarNoon              SunEvent = "solar_noon"
+	SunEventGoldenHourEveningStart SunEvent = "golden_hour_evening_start"
+	SunEventSunset                 SunEvent = "sunset"
+	SunEventGoldenHourEveningEnd   SunEvent = "golden_hour_evening_end"
+	SunEventBlueHourEveningStart   SunEvent = "blue_hour_evening_start"
+	SunEventBlueHourEveningEnd     SunEvent = "blue_hour_evening_end"
+	SunEventCivilDusk              SunEvent = "civil_dusk"
+	SunEventNauticalDusk           SunEvent = "nautical_dusk"
+	SunEventAstronomicalDusk       SunEvent = "astronomical_dusk"
+)
+
+// Solar elevation angles (degrees) that define the sun events.
+// Sunrise and sunset use the apparent upper limb at the horizon, which accounts
+// for atmospheric refraction and the sun's radius.
+const (
+	elevationSunrise      = -0.833
+	elevationCivil        = -6.0
+	elevationNautical     = -12.0
+	elevationAstronomical = -18.0
+	elevationGoldenLow    = -4.0
+	elevationGoldenHigh   = 6.0
+)
+
+// sunEventDefinition describes an event as the sun crossing an elevation
+type sunEventDefinition struct {
+	elevation float64
+	rising    bool
+}
+
+var sunEventDefinitions = map[SunEvent]sunEventDefinition{
+	SunEventAstronomicalDawn:       {elevationAstronomical, true},
+	SunEventNauticalDawn:           {elevationNautical, true},
+	SunEventCivilDawn:              {elevationCivil, true},
+	SunEventBlueHourMorningStart:   {elevationCivil, true},
+	SunEventBlueHourMorningEnd:     {elevationGoldenLow, true},
+	SunEventGoldenHourMorningStart: {elevationGoldenLow, true},
+	SunEventSunrise:                {elevationSunrise, true},
+	SunEventGoldenHourMorningEnd:   {elevationGoldenHigh, true},
+	SunEventGoldenHourEveningStart: {elevationGoldenHigh, false},
+	SunEventSunset:                 {elevationSunrise, false},
+	SunEventGoldenHourEveningEnd:   {elevationGoldenLow, false},
+	SunEventBlueHourEveningStart:   {elevationGoldenLow, false},
+	SunEventBlueHourEveningEnd:     {elevationCivil, false},
+	SunEventCivilDusk:              {elevationCivil, false},
+	SunEventNauticalDusk:           {elevationNautical, false},
+	SunEventAstronomicalDusk:       {elevationAstronomical, false},
+}
+
+// SunEvents returns all supported sun events in chronological order
+func SunEvents() []SunEvent {
+	return []SunEvent{
+		SunEventAstronomicalDawn,
+		SunEventNauticalDawn,
+		SunEventCivilDawn,
+		SunEventBlueHourMorningStart,
+		SunEventBlueHourMorningEnd,
+		SunEventGoldenHourMorningStart,
+		SunEventSunrise,
+		SunEventGoldenHourMorningEnd,
+		SunEventSolarNoon,
+		SunEventGoldenHourEveningStart,
+		SunEventSunset,
+		SunEventGoldenHourEveningEnd,
+		SunEventBlueHourEveningStart,
+		SunEventBlueHourEveningEnd,
+		SunEventCivilDusk,
+		SunEventNauticalDusk,
+		SunEventAstronomicalDusk,
+	}
+}
+
+// ParseSunEvent validates a sun event name
+func ParseSunEvent(name string) (SunEvent, error) {
+	event := SunEvent(name)
+	if event == SunEventSolarNoon {
+		return event, nil
+	}
+	if _, ok := sunEventDefinitions[event]; !ok {
+		return "", errors.Newf("unknown sun event: %s", name)
+	}
+	return event, nil
+}
+
+// SolarPosition is the apparent position of the sun as seen from a location
+type SolarPosition struct {
+	// Elevation above the horizon in degrees, corrected for atmospheric refraction
+	Elevation float64 `json:"elevation"`
+	// Azimuth in degrees clockwise from north
+	Azimuth float64 `json:"azimuth"`
+}
+
+// TimeRange is a span of time between two sun events
+type TimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// SunTimes holds all sun events for a single date at a location.
+// Events that do not occur on the date (e.g. during polar day or night) are zero.
+type SunTimes struct {
+	AstronomicalDawn  time.Time     `json:"astronomical_dawn"`
+	NauticalDawn      time.Time     `json:"nautical_dawn"`
+	CivilDawn         time.Time     `json:"civil_dawn"`
+	Sunrise           time.Time     `json:"sunrise"`
+	SolarNoon         time.Time     `json:"solar_noon"`
+	Sunset            time.Time     `json:"sunset"`
+	CivilDusk         time.Time     `json:"civil_dusk"`
+	NauticalDusk      time.Time     `json:"nautical_dusk"`
+	AstronomicalDusk  time.Time     `json:"astronomical_dusk"`
+	BlueHourMorning   TimeRange     `json:"blue_hour_morning"`
+	GoldenHourMorning TimeRange     `json:"golden_hour_morning"`
+	GoldenHourEvening TimeRange     `json:"golden_hour_evening"`
+	BlueHourEvening   TimeRange     `json:"blue_hour_evening"`
+	DayLength         time.Duration `json:"day_length"`
+}
+
+// CalculateSolarPosition calculates the sun's elevation and azimuth at an instant
+// using the NOAA solar position algorithm
+func CalculateSolarPosition(latitude, longitude float64, t time.Time) (SolarPosition, error) {
+	if err := validateCoordinates(latitude, longitude); err != nil {
+		return SolarPosition{}, err
+	}
+
+	utc := t.UTC()
+	sun := solarParametersAt(utc)
+
+	midnight := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC)
+	minutes := utc.Sub(midnight).Minutes()
+
+	trueSolarTime := math.Mod(minutes+sun.equationOfTime+4*longitude, 1440)
+	if trueSolarTime < 0 {
+		trueSolarTime += 1440
+	}
+
+	hourAngle := trueSolarTime/4 - 180
+	if hourAngle < -180 {
+		hourAngle += 360
+	}
+
+	cosZenith := sinDeg(latitude)*sinDeg(sun.declination) +
+		cosDeg(latitude)*cosDeg(sun.declination)*cosDeg(hourAngle)
+	zenith := radiansToDegrees * math.Acos(clamp(cosZenith, -1, 1))
+	elevation := 90 - zenith
+
+	var azimuth float64
+	denominator := cosDeg(latitude) * sinDeg(zenith)
+	if math.Abs(denominator) < 1e-9 {
+		// Sun at the zenith or observer at a pole; azimuth is undefined, pick due south/north
+		if latitude > 0 {
+			azimuth = 180
+		}
+	} else {
+		cosAzimuth := (sinDeg(latitude)*cosDeg(zenith) - sinDeg(sun.declination)) / denominator
+		angle := radiansToDegrees * math.Acos(clamp(cosAzimuth, -1, 1))
+		if hourAngle > 0 {
+			azimuth = normalizeDegrees(angle + 180)
+		} else {
+			azimuth = normalizeDegrees(540 - angle)
+		}
+	}
+
+	return SolarPosition{
+		Elevation: elevation + atmosphericRefraction(elevation),
+		Azimuth:   azimuth,
+	}, nil
+}
+
+// CalculateSunEvent calculates the time of a sun event on the calendar day of date,
+// in date's location. Returns an error if the event does not occur on that day.
+func CalculateSunEvent(latitude, longitude float64, date time.Time, event SunEvent) (time.Time, error) {
+	if err := validateCoordinates(latitude, longitude); err != nil {
+		return time.Time{}, err
+	}
+
+	location := date.Location()
+	year, month, day := date.Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, location)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	var definition sunEventDefinition
+	if event != SunEventSolarNoon {
+		var ok bool
+		definition, ok = sunEventDefinitions[event]
+		if !ok {
+			return time.Time{}, errors.Newf("unknown sun event: %s", event)
+		}
+	}
+
+	// The event nearest a given UTC day's transit may fall on the previous or next
+	// local calendar day, so check the transits surrounding the requested day.
+	var lastErr error
+	utcStart := dayStart.UTC()
+	for offset := -1; offset <= 1; offset++ {
+		utcDay := time.Date(utcStart.Year(), utcStart.Month(), utcStart.Day()+offset, 0, 0, 0, 0, time.UTC)
+		noon := solarNoon(longitude, utcDay)
+
+		var eventTime time.Time
+		if event == SunEventSolarNoon {
+			eventTime = noon
+		} else {
+			var err error
+			eventTime, err = elevationCrossing(latitude, longitude, noon, definition)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+		}
+
+		if !eventTime.Before(dayStart) && eventTime.Before(dayEnd) {
+			return eventTime.In(location), nil
+		}
+	}
+
+	if lastErr != nil {
+		return time.Time{}, lastErr
+	}
+	return time.Time{}, errors.Newf("%s does not occur on %s at this location", event, dayStart.Format("2006-01-02"))
+}
+
+// CalculateSunTimes calculates all sun events for the calendar day of date, in date's location
+func CalculateSunTimes(latitude, longitude float64, date time.Time) (*SunTimes, error) {
+	if err := validateCoordinates(latitude, longitude); err != nil {
+		return nil, err
+	}
+
+	at := func(event SunEvent) time.Time {
+		t, err := CalculateSunEvent(latitude, longitude, date, event)
+		if err != nil {
+			return time.Time{}
+		}
+		return t
+	}
+
+	times := &SunTimes{
+		AstronomicalDawn: at(SunEventAstronomicalDawn),
+		NauticalDawn:     at(SunEventNauticalDawn),
+		CivilDawn:        at(SunEventCivilDawn),
+		Sunrise:          at(SunEventSunrise),
+		SolarNoon:        at(SunEventSolarNoon),
+		Sunset:           at(SunEventSunset),
+		CivilDusk:        at(SunEventCivilDusk),
+		NauticalDusk:     at(SunEventNauticalDusk),
+		AstronomicalDusk: at(SunEventAstronomicalDusk),
+		BlueHourMorning: TimeRange{
+			Start: at(SunEventBlueHourMorningStart),
+			End:   at(SunEventBlueHourMorningEnd),
+		},
+		GoldenHourMorning: TimeRange{
+			Start: at(SunEventGoldenHourMorningStart),
+			End:   at(SunEventGoldenHourMorningEnd),
+		},
+		GoldenHourEvening: TimeRange{
+			Start: at(SunEventGoldenHourEveningStart),
+			End:   at(SunEventGoldenHourEveningEnd),
+		},
+		BlueHourEvening: TimeRange{
+			Start: at(SunEventBlueHourEveningStart),
+			End:   at(SunEventBlueHourEveningEnd),
+		},
+	}
+
+	// Measure daylight around the day's transit so the result does not depend on
+	// whether sunrise and sunset fall on the same calendar day in date's location
+	sunrise, riseErr := elevationCrossing(latitude, longitude, times.SolarNoon, sunEventDefinitions[SunEventSunrise])
+	sunset, setErr := elevationCrossing(latitude, longitude, times.SolarNoon, sunEventDefinitions[SunEventSunset])
+	if riseErr == nil && setErr == nil {
+		times.DayLength = sunset.Sub(sunrise)
+	} else {
+		// Polar day or night: use the sun's elevation at noon to tell them apart
+		position, err := CalculateSolarPosition(latitude, longitude, times.SolarNoon)
+		if err != nil {
+			return nil, err
+		}
+		if position.Elevation > elevationSunrise {
+			times.DayLength = 24 * time.Hour
+		}
+	}
+
+	return times, nil
+}
+
+// solarParameters are the sun's orbital quantities needed by the NOAA algorithm
+type solarParameters struct {
+	// declination in degrees
+	declination float64
+	// equationOfTime in minutes
+	equationOfTime float64
+}
+
+// solarParametersAt calculates the sun's declination and the equation of time at an instant
+func solarParametersAt(t time.Time) solarParameters {
+	T := julianCentury(t)
+
+	meanLongitude := normalizeDegrees(280.46646 + T*(36000.76983+T*0.0003032))
+	meanAnomaly := 357.52911 + T*(35999.05029-0.0001537*T)
+	eccentricity := 0.016708634 - T*(0.000042037+0.0000001267*T)
+
+	equationOfCenter := sinDeg(meanAnomaly)*(1.914602-T*(0.004817+0.000014*T)) +
+		sinDeg(2*meanAnomaly)*(0.019993-0.000101*T) +
+		sinDeg(3*meanAnomaly)*0.000289
+
+	trueLongitude := meanLongitude + equationOfCenter
+	omega := 125.04 - 1934.136*T
+	apparentLongitude := trueLongitude - 0.00569 - 0.00478*sinDeg(omega)
+
+	meanObliquity := 23 + (26+(21.448-T*(46.815+T*(0.00059-T*0.001813)))/60)/60
+	obliquity := meanObliquity + 0.00256*cosDeg(omega)
+
+	declination := radiansToDegrees * math.Asin(sinDeg(obliquity)*sinDeg(apparentLongitude))
+
+	y := math.Pow(tanDeg(obliquity/2), 2)
+	equationOfTime := 4 * radiansToDegrees * (y*sinDeg(2*meanLongitude) -
+		2*eccentricity*sinDeg(meanAnomaly) +
+		4*eccentricity*y*sinDeg(meanAnomaly)*cosDeg(2*meanLongitude) -
+		0.5*y*y*sinDeg(4*meanLongitude) -
+		1.25*eccentricity*eccentricity*sinDeg(2*meanAnomaly))
+
+	return solarParameters{
+		declination:    declination,
+		equationOfTime: equationOfTime,
+	}
+}
+
+// solarNoon calculates the time of the sun's transit on a UTC day
+func solarNoon(longitude float64, utcDay time.Time) time.Time {
+	noon := utcDay.Add(12 * time.Hour)
+	for i := 0; i < 3; i++ {
+		sun := solarParametersAt(noon)
+		minutes := 720 - 4*longitude - sun.equationOfTime
+		noon = utcDay.Add(minutesToDuration(minutes))
+	}
+	return noon
+}
+
+// elevationCrossing calculates when the sun crosses an elevation on the rising or
+// setting side of a transit, refining the estimate with the sun's position at the crossing
+func elevationCrossing(latitude, longitude float64, noon time.Time, definition sunEventDefinition) (time.Time, error) {
+	noon = noon.UTC()
+	utcDay := time.Date(noon.Year(), noon.Month(), noon.Day(), 0, 0, 0, 0, time.UTC)
+
+	estimate := noon
+	for i := 0; i < 4; i++ {
+		sun := solarParametersAt(estimate)
+
+		cosHourAngle := (sinDeg(definition.elevation) - sinDeg(latitude)*sinDeg(sun.declination)) /
+			(cosDeg(latitude) * cosDeg(sun.declination))
+		if cosHourAngle > 1 {
+			return time.Time{}, errors.Newf("sun never rises above %.3f° at this location on this date", definition.elevation)
+		}
+		if cosHourAngle < -1 {
+			return time.Time{}, errors.Newf("sun never sets below %.3f° at this location on this date", definition.elevation)
+		}
+
+		hourAngle := radiansToDegrees * math.Acos(cosHourAngle)
+		if definition.rising {
+			hourAngle = -hourAngle
+		}
+
+		// Transit time using the equation of time at the current estimate
+		transit := 720 - 4*longitude - sun.equationOfTime
+		estimate = utcDay.Add(minutesToDuration(transit + 4*hourAngle))
+	}
+
+	return estimate, nil
+}
+
+// atmosphericRefraction approximates refraction (degrees) for a geometric elevation
+func atmosphericRefraction(elevation float64) float64 {
+	var arcSeconds float64
+	switch {
+	case elevation > 85:
+		arcSeconds = 0
+	case elevation > 5:
+		te := tanDeg(elevation)
+		arcSeconds = 58.1/te - 0.07/math.Pow(te, 3) + 0.000086/math.Pow(te, 5)
+	case elevation > -0.575:
+		arcSeconds = 1735 + elevation*(-518.2+elevation*(103.4+elevation*(-12.79+elevation*0.711)))
+	default:
+		arcSeconds = -20.772 / tanDeg(elevation)
+	}
+	return arcSeconds / 3600
+}
+
+// julianCentury returns Julian centuries since J2000.0
+func julianCentury(t time.Time) float64 {
+	julianDay := float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5
+	return (julianDay - 2451545.0) / 36525.0
+}
+
+// minutesToDuration converts fractional minutes to a duration
+func minutesToDuration(minutes float64) time.Duration {
+	return time.Duration(minutes * float64(time.Minute))
+}
+
+// validateCoordinates checks latitude and longitude ranges
+func validateCoordinates(latitude, longitude float64) error {
+	if latitude < -90 || latitude > 90 {
+		return errors.Newf("invalid latitude: %f (must be between -90 and 90)", latitude)
+	}
+	if longitude < -180 || longitude > 180 {
+		return errors.Newf("invalid longitude: %f (must be between -180 and 180)", longitude)
+	}
+	return nil
+}
+
+// clamp limits v to the range [low, high]
+func clamp(v, low, high float64) float64 {
+	return math.Max(low, math.Min(high, v))
+}
@@ -0,0 +1,238 @@
+package astro
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateSolarPosition(t *testing.T) {
+	testCases := []struct {
+		name          string
+		latitude      float64
+		longitude     float64
+		time          time.Time
+		wantElevation float64
+		wantAzimuth   float64
+		tolerance     float64
+	}{
+		{
+			name:          "equator at march equinox noon",
+			latitude:      0,
+			longitude:     0,
+			time:          time.Date(2024, 3, 20, 12, 7, 0, 0, time.UTC),
+			wantElevation: 90,
+			tolerance:     1,
+		},
+		{
+			name:          "london summer solstice noon",
+			latitude:      51.5074,
+			longitude:     -0.1278,
+			time:          time.Date(2024, 6, 21, 12, 2, 0, 0, time.UTC),
+			wantElevation: 61.95,
+			wantAzimuth:   180,
+			tolerance:     0.5,
+		},
+		{
+			name:          "san francisco winter solstice afternoon",
+			latitude:      37.7749,
+			longitude:     -122.4194,
+			time:          time.Date(2024, 12, 21, 23, 0, 0, 0, time.UTC),
+			wantElevation: 16.7,
+			wantAzimuth:   220.7,
+			tolerance:     0.5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			position, err := CalculateSolarPosition(tc.latitude, tc.longitude, tc.time)
+			require.NoError(t, err)
+
+			assert.InDelta(t, tc.wantElevation, position.Elevation, tc.tolerance)
+			if tc.wantAzimuth != 0 {
+				assert.InDelta(t, tc.wantAzimuth, position.Azimuth, tc.tolerance)
+			}
+		})
+	}
+}
+
+func TestCalculateSolarPositionInvalidCoordinates(t *testing.T) {
+	_, err := CalculateSolarPosition(91, 0, time.Now())
+	assert.Error(t, err)
+
+	_, err = CalculateSolarPosition(0, 181, time.Now())
+	assert.Error(t, err)
+}
+
+func TestCalculateSunEvent(t *testing.T) {
+	testCases := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		date      time.Time
+		event     SunEvent
+		wantHour  int
+		wantMin   int
+		tolerance int
+	}{
+		{
+			name:      "london solar noon summer solstice",
+			latitude:  51.5074,
+			longitude: -0.1278,
+			date:      time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+			event:     SunEventSolarNoon,
+			wantHour:  12,
+			wantMin:   2,
+			tolerance: 1,
+		},
+		{
+			name:      "london civil dusk summer solstice",
+			latitude:  51.5074,
+			longitude: -0.1278,
+			date:      time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+			event:     SunEventCivilDusk,
+			wantHour:  21,
+			wantMin:   9,
+			tolerance: 2,
+		},
+		{
+			name:      "new york nautical dawn winter solstice",
+			latitude:  40.7128,
+			longitude: -74.0060,
+			date:      time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC),
+			event:     SunEventNauticalDawn,
+			wantHour:  11,
+			wantMin:   11,
+			tolerance: 2,
+		},
+		{
+			name:      "new york astronomical dusk winter solstice",
+			latitude:  40.7128,
+			longitude: -74.0060,
+			date:      time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC),
+			event:     SunEventAstronomicalDusk,
+			wantHour:  23,
+			wantMin:   11,
+			tolerance: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eventTime, err := CalculateSunEvent(tc.latitude, tc.longitude, tc.date, tc.event)
+			require.NoError(t, err)
+
+			wantTime := time.Date(tc.date.Year(), tc.date.Month(), tc.date.Day(), tc.wantHour, tc.wantMin, 0, 0, time.UTC)
+			diffMinutes := abs(int(eventTime.Sub(wantTime).Minutes()))
+
+			assert.LessOrEqual(t, diffMinutes, tc.tolerance, "time should match within %d minutes", tc.tolerance)
+		})
+	}
+}
+
+func TestCalculateSunEventMatchesElevation(t *testing.T) {
+	latitude, longitude := 47.6062, -122.3321
+	date := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	for event, definition := range sunEventDefinitions {
+		t.Run(string(event), func(t *testing.T) {
+			eventTime, err := CalculateSunEvent(latitude, longitude, date, event)
+			require.NoError(t, err)
+
+			position, err := CalculateSolarPosition(latitude, longitude, eventTime)
+			require.NoError(t, err)
+
+			geometric := position.Elevation - atmosphericRefraction(definition.elevation)
+			assert.InDelta(t, definition.elevation, geometric, 0.05)
+		})
+	}
+}
+
+func TestCalculateSunEventKeepsSubSecondPrecision(t *testing.T) {
+	sunrise, err := CalculateSunEvent(37.7749, -122.4194, time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), SunEventSunrise)
+	require.NoError(t, err)
+	assert.NotZero(t, sunrise.Nanosecond())
+}
+
+func TestCalculateSunEventUsesDateLocation(t *testing.T) {
+	location, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+
+	date := time.Date(2024, 12, 21, 0, 0, 0, 0, location)
+	sunset, err := CalculateSunEvent(37.7749, -122.4194, date, SunEventSunset)
+	require.NoError(t, err)
+
+	assert.Equal(t, location, sunset.Location())
+	assert.Equal(t, 21, sunset.Day())
+	assert.Equal(t, 16, sunset.Hour())
+}
+
+func TestCalculateSunEventUnknown(t *testing.T) {
+	_, err := CalculateSunEvent(0, 0, time.Now(), SunEvent("moonrise"))
+	assert.Error(t, err)
+}
+
+func TestCalculateSunEventNoAstronomicalDusk(t *testing.T) {
+	_, err := CalculateSunEvent(51.5074, -0.1278, time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), SunEventAstronomicalDusk)
+	assert.Error(t, err)
+}
+
+func TestCalculateSunTimes(t *testing.T) {
+	times, err := CalculateSunTimes(40.7128, -74.0060, time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	ordered := []time.Time{
+		times.AstronomicalDawn,
+		times.NauticalDawn,
+		times.CivilDawn,
+		times.Sunrise,
+		times.SolarNoon,
+		times.Sunset,
+		times.CivilDusk,
+		times.NauticalDusk,
+		times.AstronomicalDusk,
+	}
+	for i := 1; i < len(ordered); i++ {
+		assert.True(t, ordered[i].After(ordered[i-1]), "event %d should follow event %d", i, i-1)
+	}
+
+	assert.Equal(t, times.CivilDawn, times.BlueHourMorning.Start)
+	assert.Equal(t, times.BlueHourMorning.End, times.GoldenHourMorning.Start)
+	assert.Equal(t, times.GoldenHourEvening.End, times.BlueHourEvening.Start)
+	assert.Equal(t, times.CivilDusk, times.BlueHourEvening.End)
+
+	assert.InDelta(t, (9*time.Hour + 15*time.Minute).Minutes(), times.DayLength.Minutes(), 2)
+}
+
+func TestCalculateSunTimesPolar(t *testing.T) {
+	night, err := CalculateSunTimes(85.0, 0, time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.True(t, night.Sunrise.IsZero())
+	assert.Equal(t, time.Duration(0), night.DayLength)
+
+	day, err := CalculateSunTimes(85.0, 0, time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.True(t, day.Sunset.IsZero())
+	assert.Equal(t, 24*time.Hour, day.DayLength)
+}
+
+func TestParseSunEvent(t *testing.T) {
+	for _, event := range SunEvents() {
+		parsed, err := ParseSunEvent(string(event))
+		require.NoError(t, err)
+		assert.Equal(t, event, parsed)
+	}
+
+	_, err := ParseSunEvent("moonrise")
+	assert.Error(t, err)
+}
+
+func TestAtmosphericRefraction(t *testing.T) {
+	assert.Equal(t, 0.0, atmosphericRefraction(89))
+	assert.InDelta(t, 0.482, atmosphericRefraction(0), 0.001)
+	assert.False(t, math.IsNaN(atmosphericRefraction(-10)))
+}
@@ -0,0 +1,220 @@
+package astro
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// Embed the IANA time zone database so zones resolve on hosts without zoneinfo
+	_ "time/tzdata"
+
+	"github.com/cockroachdb/errors"
+)
+
+// maxCoastDistanceKm is how far a location outside every zone may be from the
+// nearest zone's boundary and still be given that zone. It covers coastal
+// towns, small islands and near-shore waters that the simplified coastlines
+// leave out; locations farther out are open ocean and get a fixed-offset zone.
+const maxCoastDistanceKm = 50.0
+
+// timezones.txt holds simplified boundaries of the IANA time zones, drawn from
+// country and zone borders. Each line is a zone name followed by one ring of
+// latitude,longitude vertices; a zone may have several rings, and a ring
+// inside another, such as an enclave, takes precedence over it.
+//
+//go:embed data/timezones.txt
+var zoneBoundaries string
+
+// zoneRing is one ring of a time zone's boundary
+type zoneRing struct {
+	name string
+	// points are latitude, longitude pairs; the last joins the first
+	points [][2]float64
+	// minLat, maxLat, minLon and maxLon bound the ring
+	minLat, maxLat, minLon, maxLon float64
+	// area is in square degrees, only used to prefer the inner of nested rings
+	area float64
+}
+
+var (
+	zoneRingsOnce sync.Once
+	zoneRings     []zoneRing
+	zoneRingsErr  error
+)
+
+// loadZoneRings parses the bundled boundaries once
+func loadZoneRings() ([]zoneRing, error) {
+	zoneRingsOnce.Do(func() {
+		for _, line := range strings.Split(zoneBoundaries, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			ring, err := parseZoneRing(line)
+			if err != nil {
+				zoneRingsErr = err
+				return
+			}
+			zoneRings = append(zoneRings, ring)
+		}
+	})
+
+	return zoneRings, zoneRingsErr
+}
+
+// parseZoneRing parses a zone name followed by latitude,longitude vertices
+func parseZoneRing(line string) (zoneRing, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return zoneRing{}, errors.Newf("malformed time zone boundary: %.40q", line)
+	}
+
+	ring := zoneRing{
+		name:   fields[0],
+		minLat: math.Inf(1), maxLat: math.Inf(-1),
+		minLon: math.Inf(1), maxLon: math.Inf(-1),
+	}
+	for _, field := range fields[1:] {
+		latText, lonText, ok := strings.Cut(field, ",")
+		lat, latErr := strconv.ParseFloat(latText, 64)
+		lon, lonErr := strconv.ParseFloat(lonText, 64)
+		if !ok || latErr != nil || lonErr != nil {
+			return zoneRing{}, errors.Newf("invalid vertex %q in the boundary of %s", field, ring.name)
+		}
+
+		ring.points = append(ring.points, [2]float64{lat, lon})
+		ring.minLat, ring.maxLat = min(ring.minLat, lat), max(ring.maxLat, lat)
+		ring.minLon, ring.maxLon = min(ring.minLon, lon), max(ring.maxLon, lon)
+	}
+
+	// Shoelace formula
+	for i, p := range ring.points {
+		q := ring.points[(i+1)%len(ring.points)]
+		ring.area += p[1]*q[0] - q[1]*p[0]
+	}
+	ring.area = math.Abs(ring.area) / 2
+
+	return ring, nil
+}
+
+// contains reports whether a point is inside the ring, by counting the edges
+// a ray from it crosses
+func (r *zoneRing) contains(latitude, longitude float64) bool {
+	if latitude < r.minLat || latitude > r.maxLat || longitude < r.minLon || longitude > r.maxLon {
+		return false
+	}
+
+	inside := false
+	for i, p := range r.points {
+		q := r.points[(i+1)%len(r.points)]
+		if (p[0] > latitude) != (q[0] > latitude) &&
+			longitude < p[1]+(latitude-p[0])/(q[0]-p[0])*(q[1]-p[1]) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// distanceKm returns roughly how far a point is from the ring's boundary,
+// treating the area around the point as flat
+func (r *zoneRing) distanceKm(latitude, longitude float64) float64 {
+	kmPerDegreeLat := earthRadiusKm * degreesToRadians
+	kmPerDegreeLon := kmPerDegreeLat * cosDeg(latitude)
+	project := func(p [2]float64) (x, y float64) {
+		return (p[1] - longitude) * kmPerDegreeLon, (p[0] - latitude) * kmPerDegreeLat
+	}
+
+	nearest := math.Inf(1)
+	for i, p := range r.points {
+		ax, ay := project(p)
+		bx, by := project(r.points[(i+1)%len(r.points)])
+
+		// The closest point of the edge to the origin
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if length := dx*dx + dy*dy; length > 0 {
+			t = min(max(-(ax*dx+ay*dy)/length, 0), 1)
+		}
+		nearest = min(nearest, math.Hypot(ax+t*dx, ay+t*dy))
+	}
+	return nearest
+}
+
+// LookupTimeZone returns the IANA time zone for coordinates.
+// The zone whose boundary contains the location is used, the innermost if
+// boundaries nest. A location just outside every boundary, such as on a
+// coast, gets the nearest zone; one farther out is open ocean and gets a fixed
+// Etc/GMT offset zone.
+func LookupTimeZone(latitude, longitude float64) (string, error) {
+	if err := validateCoordinates(latitude, longitude); err != nil {
+		return "", err
+	}
+
+	rings, err := loadZoneRings()
+	if err != nil {
+		return "", err
+	}
+
+	var found *zoneRing
+	for i := range rings {
+		if rings[i].contains(latitude, longitude) && (found == nil || rings[i].area < found.area) {
+			found = &rings[i]
+		}
+	}
+	if found != nil {
+		return found.name, nil
+	}
+
+	// Only rings whose bounds come within maxCoastDistanceKm can be near enough
+	marginLat := maxCoastDistanceKm / (earthRadiusKm * degreesToRadians)
+	marginLon := marginLat / max(cosDeg(latitude), 0.01)
+	nearestDistance := maxCoastDistanceKm
+	for i := range rings {
+		r := &rings[i]
+		if latitude < r.minLat-marginLat || latitude > r.maxLat+marginLat ||
+			longitude < r.minLon-marginLon || longitude > r.maxLon+marginLon {
+			continue
+		}
+		if distance := r.distanceKm(latitude, longitude); distance <= nearestDistance {
+			found = r
+			nearestDistance = distance
+		}
+	}
+	if found != nil {
+		return found.name, nil
+	}
+
+	return nauticalTimeZone(longitude), nil
+}
+
+// LoadTimeZone loads a time zone by IANA name
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, errors.New("time zone name cannot be empty")
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unknown time zone: %s", name)
+	}
+
+	return location, nil
+}
+
+// nauticalTimeZone returns the Etc/GMT zone for a longitude.
+// Etc zone signs are inverted: Etc/GMT+5 is five hours behind UTC.
+func nauticalTimeZone(longitude float64) string {
+	offset := int(math.Round(longitude / 15))
+	switch {
+	case offset == 0:
+		return "Etc/GMT"
+	case offset > 0:
+		return fmt.Sprintf("Etc/GMT-%d", offset)
+	default:
+		return fmt.Sprintf("Etc/GMT+%d", -offset)
+	}
+}
@@ -0,0 +1,171 @@
+package astro
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupTimeZone(t *testing.T) {
+	testCases := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		want      string
+	}{
+		{
+			name:      "san francisco",
+			latitude:  37.7749,
+			longitude: -122.4194,
+			want:      "America/Los_Angeles",
+		},
+		{
+			name:      "new york",
+			latitude:  40.7128,
+			longitude: -74.0060,
+			want:      "America/New_York",
+		},
+		{
+			name:      "london",
+			latitude:  51.5074,
+			longitude: -0.1278,
+			want:      "Europe/London",
+		},
+		{
+			name:      "tokyo",
+			latitude:  35.6895,
+			longitude: 139.6917,
+			want:      "Asia/Tokyo",
+		},
+		{
+			name:      "sydney",
+			latitude:  -33.8688,
+			longitude: 151.2093,
+			want:      "Australia/Sydney",
+		},
+		{
+			name:      "vigo",
+			latitude:  42.2406,
+			longitude: -8.7207,
+			want:      "Europe/Madrid",
+		},
+		{
+			name:      "badajoz",
+			latitude:  38.8794,
+			longitude: -6.9707,
+			want:      "Europe/Madrid",
+		},
+		{
+			name:      "lisbon",
+			latitude:  38.7223,
+			longitude: -9.1393,
+			want:      "Europe/Lisbon",
+		},
+		{
+			name:      "gdansk",
+			latitude:  54.352,
+			longitude: 18.6466,
+			want:      "Europe/Warsaw",
+		},
+		{
+			name:      "kaliningrad",
+			latitude:  54.7104,
+			longitude: 20.4522,
+			want:      "Europe/Kaliningrad",
+		},
+		{
+			name:      "el paso",
+			latitude:  31.7619,
+			longitude: -106.485,
+			want:      "America/Denver",
+		},
+		{
+			name:      "ciudad juarez",
+			latitude:  31.6904,
+			longitude: -106.4245,
+			want:      "America/Ciudad_Juarez",
+		},
+		{
+			name:      "seattle",
+			latitude:  47.6062,
+			longitude: -122.3321,
+			want:      "America/Los_Angeles",
+		},
+		{
+			name:      "vancouver",
+			latitude:  49.2827,
+			longitude: -123.1207,
+			want:      "America/Vancouver",
+		},
+		{
+			name:      "san diego",
+			latitude:  32.7157,
+			longitude: -117.1611,
+			want:      "America/Los_Angeles",
+		},
+		{
+			name:      "tijuana",
+			latitude:  32.5027,
+			longitude: -117.0037,
+			want:      "America/Tijuana",
+		},
+		{
+			name:      "strasbourg",
+			latitude:  48.5734,
+			longitude: 7.7521,
+			want:      "Europe/Paris",
+		},
+		{
+			name:      "busingen enclave",
+			latitude:  47.697,
+			longitude: 8.69,
+			want:      "Europe/Busingen",
+		},
+		{
+			name:      "coastal island",
+			latitude:  39.0,
+			longitude: 1.45,
+			want:      "Europe/Madrid",
+		},
+		{
+			name:      "south atlantic ocean",
+			latitude:  -40,
+			longitude: -20,
+			want:      "Etc/GMT+1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone, err := LookupTimeZone(tc.latitude, tc.longitude)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, zone)
+
+			_, err = LoadTimeZone(zone)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLookupTimeZoneInvalidCoordinates(t *testing.T) {
+	_, err := LookupTimeZone(91, 0)
+	assert.Error(t, err)
+
+	_, err = LookupTimeZone(0, -181)
+	assert.Error(t, err)
+}
+
+func TestLoadTimeZoneInvalid(t *testing.T) {
+	_, err := LoadTimeZone("")
+	assert.Error(t, err)
+
+	_, err = LoadTimeZone("Mars/Olympus_Mons")
+	assert.Error(t, err)
+}
+
+func TestNauticalTimeZone(t *testing.T) {
+	assert.Equal(t, "Etc/GMT", nauticalTimeZone(3))
+	assert.Equal(t, "Etc/GMT-12", nauticalTimeZone(179))
+	assert.Equal(t, "Etc/GMT+10", nauticalTimeZone(-150))
+}
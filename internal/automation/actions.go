@@ -0,0 +1,640 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
+	"github.com/mithilarun/limelight/internal/db/models"
+)
+
+const (
+	// maxActionDepth bounds how deeply repeat and parallel actions can be nested
+	maxActionDepth = 16
+	// maxRepeat bounds how many times a repeat action runs its actions
+	maxRepeat = 1000
+	// defaultWaitInterval is how often wait_for checks its conditions
+	defaultWaitInterval = 10 * time.Second
+)
+
+// ErrStopped is returned when a stop_if action, or a wait_for that timed out
+// with on_timeout "stop", ends a run early
+var ErrStopped = errors.New("stopped")
+
+// BridgeControl is the part of a bridge actions change. *bridge.Client satisfies it.
+type BridgeControl interface {
+	UpdateLight(ctx context.Context, lightID string, req bridge.LightUpdateRequest) error
+	UpdateGroupedLight(ctx context.Context, groupedLightID string, req bridge.LightUpdateRequest) error
+	ActivateScene(ctx context.Context, sceneID string) error
+	IdentifyLight(ctx context.Context, lightID string) error
+}
+
+// Bridge is a bridge that conditions read and actions change
+type Bridge interface {
+	BridgeState
+	BridgeControl
+}
+
+// WithState returns a bridge that reads its state from state, such as a
+// *bridge.StateCache, and changes it through control
+func WithState(control BridgeControl, state BridgeState) Bridge {
+	return stateBridge{BridgeState: state, BridgeControl: control}
+}
+
+type stateBridge struct {
+	BridgeState
+	BridgeControl
+}
+
+// ActionHandler validates and runs one type of action
+type ActionHandler struct {
+	// Validate rejects a config that Run can't use
+	Validate func(config json.RawMessage) error
+	// Run performs the action, returning once it is done, and describes what
+	// it did for the run history, such as a webhook's response
+	Run func(ctx context.Context, env *Env, config json.RawMessage) (string, error)
+}
+
+// Duration is a duration in a config, written like "90s" or "1h30m"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Newf("invalid duration %s (expected a string such as \"5m\")", data)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil || parsed < 0 {
+		return errors.Newf("invalid duration %q (expected a value such as \"90s\" or \"1h30m\")", s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LightActionConfig is the config of light and group actions. With a
+// transition, the lights fade to the new state and the next action starts once
+// they are done.
+type LightActionConfig struct {
+	// LightID is the light of a light action
+	LightID string `json:"light_id,omitempty"`
+	// GroupID is the grouped light of a group action, a room's or zone's lights
+	GroupID string `json:"group_id,omitempty"`
+	// Bridge is a profile name or bridge ID, the current profile's bridge if empty
+	Bridge string `json:"bridge,omitempty"`
+
+	On *bool `json:"on,omitempty"`
+	// Brightness is a percentage
+	Brightness *float64 `json:"brightness,omitempty"`
+	// Gradient sets the colors along a gradient light; light actions only
+	Gradient   *GradientConfig `json:"gradient,omitempty"`
+	Transition Duration        `json:"transition,omitempty"`
+
+	// gradient is Gradient as the bridge takes it
+	gradient *bridge.LightGradientState
+}
+
+// GradientConfig lists a gradient's colors in order from the start of the
+// light, as names such as "orange" or hex colors such as "#ff8800"
+type GradientConfig struct {
+	Colors []string `json:"colors"`
+	// Mode is interpolated, mirrored or pixelated, the light's current mode if empty
+	Mode string `json:"mode,omitempty"`
+}
+
+// SceneActionConfig is the config of scene actions
+type SceneActionConfig struct {
+	SceneID string `json:"scene_id"`
+	Bridge  string `json:"bridge,omitempty"`
+}
+
+// DelayConfig is the config of delay actions, which wait for Duration or, for a
+// random delay, for a time between Min and Max
+type DelayConfig struct {
+	Duration Duration `json:"duration,omitempty"`
+	Min      Duration `json:"min,omitempty"`
+	Max      Duration `json:"max,omitempty"`
+}
+
+// WaitForConfig is the config of wait_for actions, which wait until all of
+// their conditions hold or Timeout passes. After a timeout the run continues
+// unless OnTimeout is "stop".
+type WaitForConfig struct {
+	Conditions []ConditionNode `json:"conditions"`
+	Timeout    Duration        `json:"timeout"`
+	// Interval is how often the conditions are checked, every 10 seconds by default
+	Interval  Duration `json:"interval,omitempty"`
+	OnTimeout string   `json:"on_timeout,omitempty"`
+}
+
+// RepeatConfig is the config of repeat actions, which run their actions in order Times times
+type RepeatConfig struct {
+	Times   int          `json:"times"`
+	Actions []ActionNode `json:"actions"`
+}
+
+// ParallelConfig is the config of parallel actions. Each branch runs its
+// actions in order, alongside the other branches, and the action is done when
+// every branch is. If a branch fails or stops, the others are cancelled.
+type ParallelConfig struct {
+	Branches [][]ActionNode `json:"branches"`
+}
+
+// StopIfConfig is the config of stop_if actions, which end the run when all of
+// their conditions hold
+type StopIfConfig struct {
+	Conditions []ConditionNode `json:"conditions"`
+}
+
+// RegisterAction adds or replaces the handler for an action type. Flow actions
+// such as delay and repeat are built in and can't be replaced.
+func (e *Engine) RegisterAction(actionType models.ActionType, handler ActionHandler) {
+	e.actions[actionType] = handler
+}
+
+// ValidateAction checks that an action, and any nested in it, can be run
+func (e *Engine) ValidateAction(actionType models.ActionType, config json.RawMessage) error {
+	return e.validateAction(ActionNode{Type: actionType, Config: config}, 0)
+}
+
+// RunActions runs actions in order, returning ErrStopped if a stop_if or
+// wait_for ended the run early
+func (e *Engine) RunActions(ctx context.Context, env *Env, actions []*models.Action) error {
+	nodes := make([]ActionNode, 0, len(actions))
+	for _, a := range actions {
+		nodes = append(nodes, ActionNode{Type: a.Type, Config: a.Config})
+	}
+	return e.runSequence(ctx, env, nodes, 0)
+}
+
+func (e *Engine) runSequence(ctx context.Context, env *Env, nodes []ActionNode, depth int) error {
+	for i, node := range nodes {
+		if err := e.runAction(ctx, env, node, depth); err != nil {
+			if errors.Is(err, ErrStopped) {
+				return err
+			}
+			return errors.Wrapf(err, "action %d (%s)", i+1, node.Type)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) runAction(ctx context.Context, env *Env, node ActionNode, depth int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch node.Type {
+	case models.ActionTypeDelay:
+		c, err := parseDelay(node.Config)
+		if err != nil {
+			return err
+		}
+		return sleep(ctx, c.pick())
+
+	case models.ActionTypeWaitFor:
+		c, err := parseWaitFor(node.Config)
+		if err != nil {
+			return err
+		}
+		return e.waitFor(ctx, env, c)
+
+	case models.ActionTypeRepeat:
+		c, err := parseRepeat(node.Config, depth)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < c.Times; i++ {
+			if err := e.runSequence(ctx, env, c.Actions, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case models.ActionTypeParallel:
+		c, err := parseParallel(node.Config, depth)
+		if err != nil {
+			return err
+		}
+		return e.runParallel(ctx, env, c, depth)
+
+	case models.ActionTypeStopIf:
+		c, err := parseStopIf(node.Config)
+		if err != nil {
+			return err
+		}
+		ok, err := e.checkNodes(ctx, env.refreshed(), c.Conditions)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return errors.Wrap(ErrStopped, "stop_if conditions hold")
+		}
+		return nil
+	}
+
+	handler, ok := e.actions[node.Type]
+	if !ok {
+		return errors.Newf("unsupported action type: %s", node.Type)
+	}
+	started := time.Now()
+	detail, err := handler.Run(ctx, env, node.Config)
+	env.recordStep(node.Type, started, detail, err)
+	return err
+}
+
+func (e *Engine) waitFor(ctx context.Context, env *Env, c *WaitForConfig) error {
+	deadline := time.Now().Add(time.Duration(c.Timeout))
+	for {
+		ok, err := e.checkNodes(ctx, env.refreshed(), c.Conditions)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if c.OnTimeout == "stop" {
+				return errors.Wrapf(ErrStopped, "wait_for timed out after %s", time.Duration(c.Timeout))
+			}
+			return nil
+		}
+		if err := sleep(ctx, min(time.Duration(c.Interval), remaining)); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *Engine) runParallel(ctx context.Context, env *Env, c *ParallelConfig, depth int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i, branch := range c.Branches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := e.runSequence(ctx, env, branch, depth+1); err != nil {
+				once.Do(func() {
+					if !errors.Is(err, ErrStopped) {
+						err = errors.Wrapf(err, "branch %d", i+1)
+					}
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// checkNodes reports whether all conditions hold
+func (e *Engine) checkNodes(ctx context.Context, env *Env, nodes []ConditionNode) (bool, error) {
+	for _, node := range nodes {
+		ok, err := e.CheckCondition(ctx, env, node.Type, node.Config)
+		if err != nil {
+			return false, errors.Wrapf(err, "checking %s condition", node.Type)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (e *Engine) validateAction(node ActionNode, depth int) error {
+	switch node.Type {
+	case models.ActionTypeDelay:
+		_, err := parseDelay(node.Config)
+		return err
+
+	case models.ActionTypeWaitFor:
+		c, err := parseWaitFor(node.Config)
+		if err != nil {
+			return err
+		}
+		return e.validateNodes(c.Conditions)
+
+	case models.ActionTypeRepeat:
+		c, err := parseRepeat(node.Config, depth)
+		if err != nil {
+			return err
+		}
+		return e.validateActions(c.Actions, depth+1)
+
+	case models.ActionTypeParallel:
+		c, err := parseParallel(node.Config, depth)
+		if err != nil {
+			return err
+		}
+		for i, branch := range c.Branches {
+			if err := e.validateActions(branch, depth+1); err != nil {
+				return errors.Wrapf(err, "branch %d", i+1)
+			}
+		}
+		return nil
+
+	case models.ActionTypeStopIf:
+		c, err := parseStopIf(node.Config)
+		if err != nil {
+			return err
+		}
+		return e.validateNodes(c.Conditions)
+	}
+
+	handler, ok := e.actions[node.Type]
+	if !ok {
+		return errors.Newf("unsupported action type: %s", node.Type)
+	}
+	if handler.Validate == nil {
+		return nil
+	}
+	return handler.Validate(node.Config)
+}
+
+func (e *Engine) validateActions(nodes []ActionNode, depth int) error {
+	for i, node := range nodes {
+		if err := e.validateAction(node, depth); err != nil {
+			return errors.Wrapf(err, "action %d (%s)", i+1, node.Type)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) validateNodes(nodes []ConditionNode) error {
+	for i, node := range nodes {
+		if err := e.ValidateCondition(node.Type, node.Config); err != nil {
+			return errors.Wrapf(err, "condition %d (%s)", i+1, node.Type)
+		}
+	}
+	return nil
+}
+
+func parseDelay(config json.RawMessage) (*DelayConfig, error) {
+	var c DelayConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	random := c.Min != 0 || c.Max != 0
+	switch {
+	case c.Duration != 0 && random:
+		return nil, errors.New("delay takes a duration or a min and max, not both")
+	case c.Duration == 0 && !random:
+		return nil, errors.New("delay needs a duration, or a min and max")
+	case random && c.Max <= c.Min:
+		return nil, errors.Newf("delay max %s must be after min %s", time.Duration(c.Max), time.Duration(c.Min))
+	}
+	return &c, nil
+}
+
+// pick returns the fixed delay or a random one in [Min, Max)
+func (c *DelayConfig) pick() time.Duration {
+	if c.Duration != 0 {
+		return time.Duration(c.Duration)
+	}
+	return time.Duration(c.Min) + rand.N(time.Duration(c.Max-c.Min))
+}
+
+func parseWaitFor(config json.RawMessage) (*WaitForConfig, error) {
+	var c WaitForConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Conditions) == 0 {
+		return nil, errors.New("wait_for needs at least one condition")
+	}
+	if c.Timeout == 0 {
+		return nil, errors.New("wait_for needs a timeout")
+	}
+	if c.Interval == 0 {
+		c.Interval = Duration(defaultWaitInterval)
+	}
+	if c.OnTimeout != "" && c.OnTimeout != "continue" && c.OnTimeout != "stop" {
+		return nil, errors.Newf("invalid on_timeout %q (expected continue or stop)", c.OnTimeout)
+	}
+	return &c, nil
+}
+
+func parseRepeat(config json.RawMessage, depth int) (*RepeatConfig, error) {
+	if depth >= maxActionDepth {
+		return nil, errors.Newf("actions are nested more than %d deep", maxActionDepth)
+	}
+	var c RepeatConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.Times < 1 || c.Times > maxRepeat {
+		return nil, errors.Newf("repeat times must be between 1 and %d, got %d", maxRepeat, c.Times)
+	}
+	if len(c.Actions) == 0 {
+		return nil, errors.New("repeat needs at least one action")
+	}
+	return &c, nil
+}
+
+func parseParallel(config json.RawMessage, depth int) (*ParallelConfig, error) {
+	if depth >= maxActionDepth {
+		return nil, errors.Newf("actions are nested more than %d deep", maxActionDepth)
+	}
+	var c ParallelConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Branches) == 0 {
+		return nil, errors.New("parallel needs at least one branch")
+	}
+	for i, branch := range c.Branches {
+		if len(branch) == 0 {
+			return nil, errors.Newf("parallel branch %d has no actions", i+1)
+		}
+	}
+	return &c, nil
+}
+
+func parseStopIf(config json.RawMessage) (*StopIfConfig, error) {
+	var c StopIfConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Conditions) == 0 {
+		return nil, errors.New("stop_if needs at least one condition")
+	}
+	return &c, nil
+}
+
+func (e *Engine) registerBuiltinActions() {
+	e.RegisterAction(models.ActionTypeLight, ActionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseLightAction(config, models.ActionTypeLight)
+			return err
+		},
+		Run: func(ctx context.Context, env *Env, config json.RawMessage) (string, error) {
+			c, err := parseLightAction(config, models.ActionTypeLight)
+			if err != nil {
+				return "", err
+			}
+			b, err := env.bridge(ctx, c.Bridge)
+			if err != nil {
+				return "", err
+			}
+			if c.gradient != nil {
+				if err := checkGradient(ctx, b, c.LightID, c.gradient); err != nil {
+					return "", err
+				}
+			}
+			if err := b.UpdateLight(ctx, c.LightID, c.request()); err != nil {
+				return "", err
+			}
+			return "updated light " + c.LightID, sleep(ctx, time.Duration(c.Transition))
+		},
+	})
+	e.RegisterAction(models.ActionTypeGroup, ActionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseLightAction(config, models.ActionTypeGroup)
+			return err
+		},
+		Run: func(ctx context.Context, env *Env, config json.RawMessage) (string, error) {
+			c, err := parseLightAction(config, models.ActionTypeGroup)
+			if err != nil {
+				return "", err
+			}
+			b, err := env.bridge(ctx, c.Bridge)
+			if err != nil {
+				return "", err
+			}
+			if err := b.UpdateGroupedLight(ctx, c.GroupID, c.request()); err != nil {
+				return "", err
+			}
+			return "updated grouped light " + c.GroupID, sleep(ctx, time.Duration(c.Transition))
+		},
+	})
+	e.RegisterAction(models.ActionTypeScene, ActionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseSceneAction(config)
+			return err
+		},
+		Run: func(ctx context.Context, env *Env, config json.RawMessage) (string, error) {
+			c, err := parseSceneAction(config)
+			if err != nil {
+				return "", err
+			}
+			b, err := env.bridge(ctx, c.Bridge)
+			if err != nil {
+				return "", err
+			}
+			if err := b.ActivateScene(ctx, c.SceneID); err != nil {
+				return "", err
+			}
+			return "activated scene " + c.SceneID, nil
+		},
+	})
+	e.registerEffectActions()
+	e.registerExternalActions()
+}
+
+func parseLightAction(config json.RawMessage, actionType models.ActionType) (*LightActionConfig, error) {
+	var c LightActionConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+
+	target, other := c.LightID, c.GroupID
+	field, otherField := "light_id", "group_id"
+	if actionType == models.ActionTypeGroup {
+		target, other = c.GroupID, c.LightID
+		field, otherField = "group_id", "light_id"
+	}
+	if target == "" {
+		return nil, errors.Newf("%s action needs a %s", actionType, field)
+	}
+	if other != "" {
+		return nil, errors.Newf("%s action doesn't take a %s", actionType, otherField)
+	}
+
+	if c.On == nil && c.Brightness == nil && c.Gradient == nil {
+		return nil, errors.Newf("%s action needs on, brightness or a gradient", actionType)
+	}
+	if c.Gradient != nil {
+		if actionType == models.ActionTypeGroup {
+			return nil, errors.New("group action doesn't take a gradient; set it on each gradient light")
+		}
+		g, err := bridge.ParseGradient(c.Gradient.Colors, c.Gradient.Mode)
+		if err != nil {
+			return nil, err
+		}
+		c.gradient = g
+	}
+	if c.Brightness != nil && (*c.Brightness < 0 || *c.Brightness > 100) {
+		return nil, errors.Newf("brightness %g is not between 0 and 100", *c.Brightness)
+	}
+	return &c, nil
+}
+
+// checkGradient returns an error if the light can't show g, so a gradient
+// isn't sent to a light that would ignore it or drop some of its colors
+func checkGradient(ctx context.Context, b Bridge, lightID string, g *bridge.LightGradientState) error {
+	lights, err := b.GetLights(ctx)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(lights, func(l bridge.Light) bool { return l.ID == lightID })
+	if i < 0 {
+		return errors.Newf("no light has ID %s", lightID)
+	}
+	return lights[i].CheckGradient(g)
+}
+
+func (c *LightActionConfig) request() bridge.LightUpdateRequest {
+	var req bridge.LightUpdateRequest
+	if c.On != nil {
+		req.On = &bridge.LightOnState{On: *c.On}
+	}
+	if c.Brightness != nil {
+		req.Dimming = &bridge.LightDimmingState{Brightness: *c.Brightness}
+	}
+	if c.gradient != nil {
+		req.Gradient = c.gradient
+	}
+	if c.Transition != 0 {
+		req.Dynamics = &bridge.LightDynamicsState{Duration: int(time.Duration(c.Transition).Milliseconds())}
+	}
+	return req
+}
+
+func parseSceneAction(config json.RawMessage) (*SceneActionConfig, error) {
+	var c SceneActionConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.SceneID == "" {
+		return nil, errors.New("scene action needs a scene_id")
+	}
+	return &c, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,253 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseActions reads a list of actions in file form
+func parseActions(t *testing.T, data string) []*models.Action {
+	var nodes []ActionNode
+	require.NoError(t, json.Unmarshal([]byte(data), &nodes))
+
+	engine := NewEngine()
+	actions := make([]*models.Action, 0, len(nodes))
+	for i, node := range nodes {
+		require.NoError(t, engine.ValidateAction(node.Type, node.Config), "action %d", i+1)
+		actions = append(actions, &models.Action{Type: node.Type, Config: node.Config, OrderIndex: i})
+	}
+	return actions
+}
+
+func TestRunActionsInOrder(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+
+	actions := parseActions(t, `[
+		{"type": "group", "config": {"group_id": "g1", "brightness": 10, "transition": "20ms"}},
+		{"type": "delay", "config": {"duration": "10ms"}},
+		{"type": "light", "config": {"light_id": "l1", "on": false}},
+		{"type": "scene", "config": {"scene_id": "s1", "bridge": "home"}}
+	]`)
+
+	start := time.Now()
+	require.NoError(t, NewEngine().RunActions(context.Background(), env, actions))
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond, "the transition and delay are waited for")
+
+	assert.Equal(t, []string{
+		`group g1 {"dimming":{"brightness":10},"dynamics":{"duration":20}}`,
+		`light l1 {"on":{"on":false}}`,
+		"scene s1",
+	}, f.recorded())
+}
+
+func TestGradientLightAction(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+
+	actions := parseActions(t, `[
+		{"type": "light", "config": {"light_id": "l4", "gradient": {"colors": ["red", "#0000ff"], "mode": "mirrored"}}}
+	]`)
+	require.NoError(t, NewEngine().RunActions(context.Background(), env, actions))
+	assert.Equal(t, []string{
+		`light l4 {"gradient":{"points":[{"color":{"xy":{"x":0.7006,"y":0.2993}}},{"color":{"xy":{"x":0.1355,"y":0.0399}}}],"mode":"interpolated_palette_mirrored"}}`,
+	}, f.recorded())
+
+	unsupported := map[string]string{
+		"not a gradient light": `{"light_id": "l1", "gradient": {"colors": ["red", "blue"]}}`,
+		"too many colors":      `{"light_id": "l4", "gradient": {"colors": ["red", "green", "blue", "white"]}}`,
+		"unsupported mode":     `{"light_id": "l4", "gradient": {"colors": ["red", "blue"], "mode": "pixelated"}}`,
+		"unknown light":        `{"light_id": "l9", "gradient": {"colors": ["red", "blue"]}}`,
+	}
+	for name, config := range unsupported {
+		t.Run(name, func(t *testing.T) {
+			env, f := bridgeEnvWithFake(t)
+			_, err := runAction(t, env, models.ActionTypeLight, config)
+			assert.Error(t, err)
+			assert.Empty(t, f.recorded(), "nothing is sent to the bridge")
+		})
+	}
+}
+
+func TestRandomDelay(t *testing.T) {
+	c, err := parseDelay(json.RawMessage(`{"min": "1m", "max": "2m"}`))
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		d := c.pick()
+		assert.GreaterOrEqual(t, d, time.Minute)
+		assert.Less(t, d, 2*time.Minute)
+	}
+}
+
+func TestRepeatAction(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+
+	actions := parseActions(t, `[
+		{"type": "repeat", "config": {"times": 3, "actions": [
+			{"type": "light", "config": {"light_id": "l1", "on": true}},
+			{"type": "light", "config": {"light_id": "l1", "on": false}}
+		]}}
+	]`)
+	require.NoError(t, NewEngine().RunActions(context.Background(), env, actions))
+	assert.Len(t, f.recorded(), 6)
+}
+
+func TestParallelAction(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+
+	actions := parseActions(t, `[
+		{"type": "parallel", "config": {"branches": [
+			[{"type": "delay", "config": {"duration": "20ms"}}, {"type": "light", "config": {"light_id": "l1", "on": true}}],
+			[{"type": "light", "config": {"light_id": "l2", "on": true}}]
+		]}},
+		{"type": "scene", "config": {"scene_id": "s1"}}
+	]`)
+	require.NoError(t, NewEngine().RunActions(context.Background(), env, actions))
+	assert.Equal(t, []string{
+		`light l2 {"on":{"on":true}}`,
+		`light l1 {"on":{"on":true}}`,
+		"scene s1",
+	}, f.recorded(), "the next action waits for every branch")
+}
+
+func TestStopIfAction(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+
+	actions := parseActions(t, `[
+		{"type": "scene", "config": {"scene_id": "s1"}},
+		{"type": "stop_if", "config": {"conditions": [{"type": "light_state", "config": {"light": "Hallway", "on": true}}]}},
+		{"type": "scene", "config": {"scene_id": "s2"}},
+		{"type": "stop_if", "config": {"conditions": [{"type": "light_state", "config": {"light": "Desk", "on": true}}]}},
+		{"type": "scene", "config": {"scene_id": "s3"}}
+	]`)
+	err := NewEngine().RunActions(context.Background(), env, actions)
+	assert.True(t, errors.Is(err, ErrStopped))
+	assert.Equal(t, []string{"scene s1", "scene s2"}, f.recorded())
+}
+
+func TestStopIfCancelsParallelBranches(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+
+	actions := parseActions(t, `[
+		{"type": "parallel", "config": {"branches": [
+			[{"type": "delay", "config": {"duration": "10s"}}, {"type": "light", "config": {"light_id": "l1", "on": false}}],
+			[{"type": "stop_if", "config": {"conditions": [{"type": "light_state", "config": {"light": "Desk", "on": true}}]}}]
+		]}}
+	]`)
+
+	start := time.Now()
+	err := NewEngine().RunActions(context.Background(), env, actions)
+	assert.True(t, errors.Is(err, ErrStopped))
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Empty(t, f.recorded())
+}
+
+func TestWaitForAction(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+	engine := NewEngine()
+	ctx := context.Background()
+
+	// Holds already
+	actions := parseActions(t, `[
+		{"type": "wait_for", "config": {"conditions": [{"type": "light_state", "config": {"light": "Desk", "on": true}}], "timeout": "1h"}},
+		{"type": "scene", "config": {"scene_id": "s1"}}
+	]`)
+	require.NoError(t, engine.RunActions(ctx, env, actions))
+	assert.Equal(t, []string{"scene s1"}, f.recorded())
+
+	// Times out and carries on
+	actions = parseActions(t, `[
+		{"type": "wait_for", "config": {"conditions": [{"type": "light_state", "config": {"light": "Hallway", "on": true}}], "timeout": "30ms", "interval": "5ms"}},
+		{"type": "scene", "config": {"scene_id": "s2"}}
+	]`)
+	start := time.Now()
+	require.NoError(t, engine.RunActions(ctx, env, actions))
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	assert.Equal(t, []string{"scene s1", "scene s2"}, f.recorded())
+
+	// Times out and stops
+	actions = parseActions(t, `[
+		{"type": "wait_for", "config": {"conditions": [{"type": "light_state", "config": {"light": "Hallway", "on": true}}], "timeout": "10ms", "interval": "5ms", "on_timeout": "stop"}},
+		{"type": "scene", "config": {"scene_id": "s3"}}
+	]`)
+	err := engine.RunActions(ctx, env, actions)
+	assert.True(t, errors.Is(err, ErrStopped))
+	assert.Equal(t, []string{"scene s1", "scene s2"}, f.recorded())
+}
+
+func TestRunActionsIsCancellable(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+
+	actions := parseActions(t, `[
+		{"type": "delay", "config": {"duration": "10s"}},
+		{"type": "scene", "config": {"scene_id": "s1"}}
+	]`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := NewEngine().RunActions(ctx, env, actions)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Empty(t, f.recorded())
+}
+
+func TestValidateActions(t *testing.T) {
+	engine := NewEngine()
+
+	invalid := map[models.ActionType][]string{
+		models.ActionTypeLight: {
+			`{"on": true}`,
+			`{"light_id": "l1"}`,
+			`{"light_id": "l1", "group_id": "g1", "on": true}`,
+			`{"light_id": "l1", "brightness": 150}`,
+			`{"light_id": "l1", "on": true, "transition": "soon"}`,
+			`{"light_id": "l1", "gradient": {"colors": ["red"]}}`,
+			`{"light_id": "l1", "gradient": {"colors": ["red", "blue"], "mode": "spiral"}}`,
+		},
+		models.ActionTypeGroup: {
+			`{"light_id": "l1", "on": true}`,
+			`{"group_id": "g1", "gradient": {"colors": ["red", "blue"]}}`,
+		},
+		models.ActionTypeScene: {`{}`},
+		models.ActionTypeDelay: {
+			`{}`,
+			`{"duration": 5}`,
+			`{"duration": "5m", "min": "1m", "max": "2m"}`,
+			`{"min": "2m", "max": "1m"}`,
+		},
+		models.ActionTypeWaitFor: {
+			`{"timeout": "5m"}`,
+			`{"conditions": [{"type": "weekday"}]}`,
+			`{"conditions": [{"type": "weekday"}], "timeout": "5m", "on_timeout": "fail"}`,
+			`{"conditions": [{"type": "sometimes"}], "timeout": "5m"}`,
+		},
+		models.ActionTypeRepeat: {
+			`{"times": 0, "actions": [{"type": "scene", "config": {"scene_id": "s1"}}]}`,
+			`{"times": 2, "actions": []}`,
+			`{"times": 2, "actions": [{"type": "scene", "config": {}}]}`,
+		},
+		models.ActionTypeParallel: {
+			`{"branches": []}`,
+			`{"branches": [[]]}`,
+			`{"branches": [[{"type": "explode"}]]}`,
+		},
+		models.ActionTypeStopIf: {`{"conditions": []}`},
+	}
+	for actionType, configs := range invalid {
+		for _, config := range configs {
+			assert.Error(t, engine.ValidateAction(actionType, json.RawMessage(config)), "%s %s", actionType, config)
+		}
+	}
+
+	nested := `{"type": "scene", "config": {"scene_id": "s1"}}`
+	for i := 0; i < maxActionDepth+1; i++ {
+		nested = `{"type": "repeat", "config": {"times": 1, "actions": [` + nested + `]}}`
+	}
+	var node ActionNode
+	require.NoError(t, json.Unmarshal([]byte(nested), &node))
+	assert.ErrorContains(t, engine.ValidateAction(node.Type, node.Config), "nested more than")
+}
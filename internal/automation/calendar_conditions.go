@@ -0,0 +1,95 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/calendar"
+	"github.com/mithilarun/limelight/internal/db/models"
+)
+
+// CalendarEventConfig is the config of calendar_event conditions, which hold
+// while an event matching every filter set is in progress. Recurring events are
+// expanded and cancelled ones skipped. Put the condition in a not group for
+// "not during a meeting".
+type CalendarEventConfig struct {
+	// Calendar is an http, https or webcal URL or an absolute path to an .ics file
+	Calendar string `json:"calendar"`
+	// Summary matches events whose summary contains it, ignoring case
+	Summary string `json:"summary,omitempty"`
+	// Category matches events with this category, ignoring case
+	Category string `json:"category,omitempty"`
+	// Today also counts events that are over or yet to start today, for
+	// conditions such as "on a public holiday"
+	Today bool `json:"today,omitempty"`
+}
+
+func (e *Engine) registerCalendarConditions() {
+	e.RegisterCondition(models.ConditionTypeCalendarEvent, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseCalendarEvent(config)
+			return err
+		},
+		Check: func(ctx context.Context, env *Env, config json.RawMessage) (bool, error) {
+			c, err := parseCalendarEvent(config)
+			if err != nil {
+				return false, err
+			}
+
+			loader := env.Calendars
+			if loader == nil {
+				loader = calendar.NewLoader(nil, 0)
+			}
+			cal, err := loader.Load(ctx, c.Calendar)
+			if err != nil {
+				return false, err
+			}
+
+			from, to := env.Now, env.Now.Add(time.Nanosecond)
+			if c.Today {
+				from = time.Date(env.Now.Year(), env.Now.Month(), env.Now.Day(), 0, 0, 0, 0, env.Now.Location())
+				to = from.AddDate(0, 0, 1)
+			}
+			occurrences, err := cal.Occurrences(from, to)
+			if err != nil {
+				return false, err
+			}
+
+			for _, o := range occurrences {
+				if c.matches(o.Event) {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	})
+}
+
+func parseCalendarEvent(config json.RawMessage) (*CalendarEventConfig, error) {
+	var c CalendarEventConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if err := calendar.ValidateSource(c.Calendar); err != nil {
+		return nil, errors.Wrap(err, "calendar_event needs a calendar")
+	}
+	return &c, nil
+}
+
+func (c *CalendarEventConfig) matches(event *calendar.Event) bool {
+	if c.Summary != "" && !strings.Contains(strings.ToLower(event.Summary), strings.ToLower(c.Summary)) {
+		return false
+	}
+	if c.Category == "" {
+		return true
+	}
+	for _, category := range event.Categories {
+		if strings.EqualFold(category, c.Category) {
+			return true
+		}
+	}
+	return false
+}
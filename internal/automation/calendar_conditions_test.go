@@ -0,0 +1,64 @@
+package automation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCalendar(t *testing.T, events ...string) string {
+	path := filepath.Join(t.TempDir(), "calendar.ics")
+	data := "BEGIN:VCALENDAR\n" + strings.Join(events, "\n") + "\nEND:VCALENDAR\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
+
+func TestCalendarEventCondition(t *testing.T) {
+	path := writeCalendar(t,
+		"BEGIN:VEVENT\nDTSTART;VALUE=DATE:20261225\nSUMMARY:Christmas Day\nEND:VEVENT",
+		"BEGIN:VEVENT\nDTSTART:20261019T100000\nDTEND:20261019T110000\nRRULE:FREQ=WEEKLY\nSUMMARY:Team meeting\nEND:VEVENT",
+		"BEGIN:VEVENT\nDTSTART;VALUE=DATE:20261026\nDTEND;VALUE=DATE:20261031\nSUMMARY:Lisbon\nCATEGORIES:Travel,Away\nEND:VEVENT",
+	)
+	config := func(fields string) string {
+		return fmt.Sprintf(`{"calendar": %q%s}`, path, fields)
+	}
+
+	tests := []struct {
+		name   string
+		now    string
+		config string
+		want   bool
+	}{
+		{"holiday", "2026-12-25 06:30", config(""), true},
+		{"no event", "2026-12-26 06:30", config(""), false},
+		{"recurring meeting", "2026-11-02 10:15", config(`, "summary": "meeting"`), true},
+		{"after the meeting", "2026-11-02 11:00", config(`, "summary": "meeting"`), false},
+		{"meeting later today", "2026-11-02 07:00", config(`, "summary": "meeting", "today": true`), true},
+		{"summary doesn't match", "2026-11-02 10:15", config(`, "summary": "holiday"`), false},
+		{"category", "2026-10-28 21:00", config(`, "category": "away"`), true},
+		{"other category", "2026-10-28 21:00", config(`, "category": "work"`), false},
+		{"category after the trip", "2026-10-31 08:00", config(`, "category": "away"`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, check(t, envAt(t, tt.now), models.ConditionTypeCalendarEvent, tt.config))
+		})
+	}
+
+	// "not during a meeting"
+	notMeeting := fmt.Sprintf(`{"conditions": [{"type": "calendar_event", "config": %s}]}`, config(`, "summary": "meeting"`))
+	assert.False(t, check(t, envAt(t, "2026-11-09 10:30"), models.ConditionTypeNot, notMeeting))
+	assert.True(t, check(t, envAt(t, "2026-11-09 12:30"), models.ConditionTypeNot, notMeeting))
+
+	engine := NewEngine()
+	for _, c := range []string{`{}`, `{"calendar": "holidays.ics"}`, config(`, "tag": "away"`)} {
+		assert.Error(t, engine.ValidateCondition(models.ConditionTypeCalendarEvent, json.RawMessage(c)), c)
+	}
+}
@@ -0,0 +1,300 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/db/models"
+)
+
+// maxConditionDepth bounds how deeply groups can be nested
+const maxConditionDepth = 16
+
+// ConditionNode is a condition nested in a group's config
+type ConditionNode struct {
+	Type   models.ConditionType `json:"type"`
+	Config json.RawMessage      `json:"config,omitempty"`
+}
+
+// GroupConfig is the config of all, any and not conditions. An all group holds
+// when every nested condition does, an any group when at least one does, and a
+// not group when its conditions don't all hold, so a not of one condition negates it.
+type GroupConfig struct {
+	Conditions []ConditionNode `json:"conditions"`
+}
+
+// DayOfWeekConfig is the config of day_of_week conditions
+type DayOfWeekConfig struct {
+	// Days are weekday names such as "monday" or "mon"
+	Days []string `json:"days"`
+}
+
+// DateRangeConfig is the config of date_range conditions. Both ends are
+// inclusive and either full dates (2025-12-24) or, for a range that recurs
+// every year, month and day (12-24). A yearly range may wrap around New Year.
+type DateRangeConfig struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+const (
+	fullDateLayout   = "2006-01-02"
+	yearlyDateLayout = "01-02"
+)
+
+// CheckConditions reports whether all conditions hold, stopping at the first
+// that doesn't
+func (e *Engine) CheckConditions(ctx context.Context, env *Env, conditions []*models.Condition) (bool, error) {
+	for _, c := range conditions {
+		ok, err := e.CheckCondition(ctx, env, c.Type, c.Config)
+		if err != nil {
+			return false, errors.Wrapf(err, "checking %s condition", c.Type)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// CheckCondition reports whether a single condition, which may be a group, holds
+func (e *Engine) CheckCondition(ctx context.Context, env *Env, conditionType models.ConditionType, config json.RawMessage) (bool, error) {
+	return e.checkCondition(ctx, env, conditionType, config, 0)
+}
+
+// ValidateCondition checks that a condition, and any nested in it, can be evaluated
+func (e *Engine) ValidateCondition(conditionType models.ConditionType, config json.RawMessage) error {
+	return e.validateCondition(conditionType, config, 0)
+}
+
+func (e *Engine) checkCondition(ctx context.Context, env *Env, conditionType models.ConditionType, config json.RawMessage, depth int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	if isGroup(conditionType) {
+		group, err := parseGroup(config, depth)
+		if err != nil {
+			return false, err
+		}
+		return e.checkGroup(ctx, env, conditionType, group, depth)
+	}
+
+	handler, ok := e.conditions[conditionType]
+	if !ok {
+		return false, errors.Newf("unsupported condition type: %s", conditionType)
+	}
+	return handler.Check(ctx, env, config)
+}
+
+func (e *Engine) checkGroup(ctx context.Context, env *Env, conditionType models.ConditionType, group *GroupConfig, depth int) (bool, error) {
+	for i, node := range group.Conditions {
+		ok, err := e.checkCondition(ctx, env, node.Type, node.Config, depth+1)
+		if err != nil {
+			return false, errors.Wrapf(err, "%s condition %d (%s)", conditionType, i+1, node.Type)
+		}
+
+		if conditionType == models.ConditionTypeAny {
+			if ok {
+				return true, nil
+			}
+		} else if !ok {
+			// all fails and not holds at the first condition that doesn't
+			return conditionType == models.ConditionTypeNot, nil
+		}
+	}
+
+	return conditionType == models.ConditionTypeAll, nil
+}
+
+func (e *Engine) validateCondition(conditionType models.ConditionType, config json.RawMessage, depth int) error {
+	if isGroup(conditionType) {
+		group, err := parseGroup(config, depth)
+		if err != nil {
+			return err
+		}
+		for i, node := range group.Conditions {
+			if err := e.validateCondition(node.Type, node.Config, depth+1); err != nil {
+				return errors.Wrapf(err, "%s condition %d (%s)", conditionType, i+1, node.Type)
+			}
+		}
+		return nil
+	}
+
+	handler, ok := e.conditions[conditionType]
+	if !ok {
+		return errors.Newf("unsupported condition type: %s", conditionType)
+	}
+	if handler.Validate == nil {
+		return nil
+	}
+	return handler.Validate(config)
+}
+
+func isGroup(conditionType models.ConditionType) bool {
+	switch conditionType {
+	case models.ConditionTypeAll, models.ConditionTypeAny, models.ConditionTypeNot:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseGroup(config json.RawMessage, depth int) (*GroupConfig, error) {
+	if depth >= maxConditionDepth {
+		return nil, errors.Newf("conditions are nested more than %d deep", maxConditionDepth)
+	}
+
+	var group GroupConfig
+	if err := decodeConfig(config, &group); err != nil {
+		return nil, err
+	}
+	if len(group.Conditions) == 0 {
+		return nil, errors.New("condition group needs at least one condition")
+	}
+	return &group, nil
+}
+
+// decodeConfig unmarshals a condition or action config, rejecting unknown fields
+// so that typos don't silently change what a rule does
+func decodeConfig(config json.RawMessage, out interface{}) error {
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(config)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return errors.Wrap(err, "invalid config")
+	}
+	return nil
+}
+
+func (e *Engine) registerBuiltinConditions() {
+	e.RegisterCondition(models.ConditionTypeWeekday, ConditionHandler{
+		Check: func(_ context.Context, env *Env, _ json.RawMessage) (bool, error) {
+			return !isWeekend(env.Now.Weekday()), nil
+		},
+	})
+	e.RegisterCondition(models.ConditionTypeWeekend, ConditionHandler{
+		Check: func(_ context.Context, env *Env, _ json.RawMessage) (bool, error) {
+			return isWeekend(env.Now.Weekday()), nil
+		},
+	})
+	e.RegisterCondition(models.ConditionTypeDayOfWeek, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseDayOfWeek(config)
+			return err
+		},
+		Check: func(_ context.Context, env *Env, config json.RawMessage) (bool, error) {
+			days, err := parseDayOfWeek(config)
+			if err != nil {
+				return false, err
+			}
+			return days[env.Now.Weekday()], nil
+		},
+	})
+	e.RegisterCondition(models.ConditionTypeDateRange, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseDateRange(config)
+			return err
+		},
+		Check: func(_ context.Context, env *Env, config json.RawMessage) (bool, error) {
+			r, err := parseDateRange(config)
+			if err != nil {
+				return false, err
+			}
+			return r.contains(env.Now), nil
+		},
+	})
+
+	e.registerTimeConditions()
+	e.registerStateConditions()
+	e.registerCalendarConditions()
+}
+
+func isWeekend(day time.Weekday) bool {
+	return day == time.Saturday || day == time.Sunday
+}
+
+func parseDayOfWeek(config json.RawMessage) (map[time.Weekday]bool, error) {
+	var c DayOfWeekConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Days) == 0 {
+		return nil, errors.New("day_of_week needs at least one day")
+	}
+
+	days := make(map[time.Weekday]bool)
+	for _, name := range c.Days {
+		day, err := parseWeekday(name)
+		if err != nil {
+			return nil, err
+		}
+		days[day] = true
+	}
+	return days, nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if lower == full || lower == full[:3] {
+			return day, nil
+		}
+	}
+	return 0, errors.Newf("invalid day of week: %q", name)
+}
+
+// dateRange is a parsed DateRangeConfig
+type dateRange struct {
+	yearly     bool
+	start, end time.Time
+}
+
+func parseDateRange(config json.RawMessage) (*dateRange, error) {
+	var c DateRangeConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+
+	layout := fullDateLayout
+	yearly := len(c.Start) == len(yearlyDateLayout)
+	if yearly {
+		layout = yearlyDateLayout
+	}
+
+	start, err := time.Parse(layout, c.Start)
+	if err != nil {
+		return nil, errors.Newf("invalid start date %q (expected YYYY-MM-DD or MM-DD)", c.Start)
+	}
+	end, err := time.Parse(layout, c.End)
+	if err != nil {
+		return nil, errors.Newf("invalid end date %q (expected the same form as the start date)", c.End)
+	}
+	if !yearly && end.Before(start) {
+		return nil, errors.Newf("end date %s is before start date %s", c.End, c.Start)
+	}
+
+	return &dateRange{yearly: yearly, start: start, end: end}, nil
+}
+
+// contains reports whether the calendar date of t, in its own location, is in the range
+func (r *dateRange) contains(t time.Time) bool {
+	if !r.yearly {
+		day := t.Format(fullDateLayout)
+		return day >= r.start.Format(fullDateLayout) && day <= r.end.Format(fullDateLayout)
+	}
+
+	day := t.Format(yearlyDateLayout)
+	start, end := r.start.Format(yearlyDateLayout), r.end.Format(yearlyDateLayout)
+	if start <= end {
+		return day >= start && day <= end
+	}
+	// Wraps around New Year, e.g. 12-20 to 01-06
+	return day >= start || day <= end
+}
@@ -0,0 +1,136 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envAt returns an Env for a time in UTC
+func envAt(t *testing.T, value string) *Env {
+	now, err := time.Parse("2006-01-02 15:04", value)
+	require.NoError(t, err)
+	return &Env{Now: now}
+}
+
+func check(t *testing.T, env *Env, conditionType models.ConditionType, config string) bool {
+	engine := NewEngine()
+	require.NoError(t, engine.ValidateCondition(conditionType, json.RawMessage(config)))
+	ok, err := engine.CheckCondition(context.Background(), env, conditionType, json.RawMessage(config))
+	require.NoError(t, err)
+	return ok
+}
+
+func TestDayConditions(t *testing.T) {
+	saturday := envAt(t, "2026-10-17 09:00")
+	monday := envAt(t, "2026-10-19 09:00")
+
+	assert.True(t, check(t, saturday, models.ConditionTypeWeekend, ""))
+	assert.False(t, check(t, monday, models.ConditionTypeWeekend, ""))
+	assert.True(t, check(t, monday, models.ConditionTypeWeekday, ""))
+	assert.False(t, check(t, saturday, models.ConditionTypeWeekday, ""))
+
+	days := `{"days": ["Mon", "wednesday"]}`
+	assert.True(t, check(t, monday, models.ConditionTypeDayOfWeek, days))
+	assert.False(t, check(t, saturday, models.ConditionTypeDayOfWeek, days))
+}
+
+func TestDateRangeCondition(t *testing.T) {
+	tests := []struct {
+		name   string
+		now    string
+		config string
+		want   bool
+	}{
+		{"inside full range", "2026-12-25 10:00", `{"start": "2026-12-24", "end": "2026-12-26"}`, true},
+		{"last day is inclusive", "2026-12-26 23:59", `{"start": "2026-12-24", "end": "2026-12-26"}`, true},
+		{"full range in another year", "2027-12-25 10:00", `{"start": "2026-12-24", "end": "2026-12-26"}`, false},
+		{"yearly range", "2030-12-25 10:00", `{"start": "12-24", "end": "12-26"}`, true},
+		{"yearly range wrapping New Year", "2027-01-03 10:00", `{"start": "12-20", "end": "01-06"}`, true},
+		{"outside wrapping range", "2027-02-01 10:00", `{"start": "12-20", "end": "01-06"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, check(t, envAt(t, tt.now), models.ConditionTypeDateRange, tt.config))
+		})
+	}
+}
+
+func TestConditionGroups(t *testing.T) {
+	holidays := `{"type": "date_range", "config": {"start": "12-24", "end": "12-26"}}`
+	weekendOrHoliday := `{"conditions": [{"type": "weekend"}, ` + holidays + `]}`
+
+	christmasThursday := envAt(t, "2025-12-25 09:00")
+	saturday := envAt(t, "2026-10-17 09:00")
+	monday := envAt(t, "2026-10-19 09:00")
+
+	assert.True(t, check(t, christmasThursday, models.ConditionTypeAny, weekendOrHoliday))
+	assert.True(t, check(t, saturday, models.ConditionTypeAny, weekendOrHoliday))
+	assert.False(t, check(t, monday, models.ConditionTypeAny, weekendOrHoliday))
+
+	notHoliday := `{"conditions": [` + holidays + `]}`
+	assert.False(t, check(t, christmasThursday, models.ConditionTypeNot, notHoliday))
+	assert.True(t, check(t, monday, models.ConditionTypeNot, notHoliday))
+
+	weekdayNotHoliday := `{"conditions": [{"type": "weekday"}, {"type": "not", "config": ` + notHoliday + `}]}`
+	assert.False(t, check(t, christmasThursday, models.ConditionTypeAll, weekdayNotHoliday))
+	assert.True(t, check(t, monday, models.ConditionTypeAll, weekdayNotHoliday))
+	assert.False(t, check(t, saturday, models.ConditionTypeAll, weekdayNotHoliday))
+}
+
+func TestCheckConditionsRequiresAll(t *testing.T) {
+	engine := NewEngine()
+	conditions := []*models.Condition{
+		{Type: models.ConditionTypeWeekday},
+		{Type: models.ConditionTypeDayOfWeek, Config: json.RawMessage(`{"days": ["friday"]}`)},
+	}
+
+	ok, err := engine.CheckConditions(context.Background(), envAt(t, "2026-10-16 09:00"), conditions)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = engine.CheckConditions(context.Background(), envAt(t, "2026-10-19 09:00"), conditions)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = engine.CheckConditions(context.Background(), envAt(t, "2026-10-19 09:00"), nil)
+	require.NoError(t, err)
+	assert.True(t, ok, "no conditions always hold")
+}
+
+func TestValidateCondition(t *testing.T) {
+	engine := NewEngine()
+
+	invalid := []struct {
+		conditionType models.ConditionType
+		config        string
+	}{
+		{models.ConditionTypeDayOfWeek, `{"days": []}`},
+		{models.ConditionTypeDayOfWeek, `{"days": ["someday"]}`},
+		{models.ConditionTypeDayOfWeek, `{"day": ["monday"]}`},
+		{models.ConditionTypeDateRange, `{"start": "2026-12-26", "end": "2026-12-24"}`},
+		{models.ConditionTypeDateRange, `{"start": "12-24", "end": "2026-12-26"}`},
+		{models.ConditionTypeAny, `{"conditions": []}`},
+		{models.ConditionTypeAll, `{"conditions": [{"type": "full_moon"}]}`},
+		{models.ConditionTypeNot, `{"conditions": [{"type": "any", "config": {"conditions": [{"type": "day_of_week"}]}}]}`},
+		{models.ConditionType("full_moon"), `{}`},
+	}
+	for _, tt := range invalid {
+		assert.Error(t, engine.ValidateCondition(tt.conditionType, json.RawMessage(tt.config)), "%s %s", tt.conditionType, tt.config)
+	}
+
+	deep := `{"type": "weekend"}`
+	for i := 0; i < maxConditionDepth; i++ {
+		deep = `{"type": "all", "config": {"conditions": [` + deep + `]}}`
+	}
+	err := engine.ValidateCondition(models.ConditionTypeAll, json.RawMessage(`{"conditions": [`+deep+`]}`))
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "nested"))
+}
@@ -0,0 +1,194 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
+	"github.com/mithilarun/limelight/internal/db/models"
+)
+
+// EffectActionConfig is the config of effect and timed_effect actions. An
+// effect such as candle turns the light on and runs until another effect or
+// "no_effect" replaces it. A timed effect, sunrise or sunset, runs on the
+// bridge for Duration while the next action starts.
+type EffectActionConfig struct {
+	LightID string `json:"light_id"`
+	Bridge  string `json:"bridge,omitempty"`
+	Effect  string `json:"effect"`
+	// Duration is how long a timed effect takes
+	Duration Duration `json:"duration,omitempty"`
+}
+
+// SignalActionConfig is the config of alert actions, which make a light or a
+// room's or zone's lights breathe once, and identify actions, which make a
+// light's device blink so it can be found
+type SignalActionConfig struct {
+	LightID string `json:"light_id,omitempty"`
+	// GroupID is the grouped light of an alert, a room's or zone's lights
+	GroupID string `json:"group_id,omitempty"`
+	Bridge  string `json:"bridge,omitempty"`
+}
+
+func (e *Engine) registerEffectActions() {
+	for _, actionType := range []models.ActionType{models.ActionTypeEffect, models.ActionTypeTimedEffect} {
+		e.RegisterAction(actionType, ActionHandler{
+			Validate: func(config json.RawMessage) error {
+				_, err := parseEffectAction(config, actionType)
+				return err
+			},
+			Run: func(ctx context.Context, env *Env, config json.RawMessage) (string, error) {
+				c, err := parseEffectAction(config, actionType)
+				if err != nil {
+					return "", err
+				}
+				return env.runEffect(ctx, c, actionType)
+			},
+		})
+	}
+
+	e.RegisterAction(models.ActionTypeAlert, ActionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseSignalAction(config, models.ActionTypeAlert)
+			return err
+		},
+		Run: func(ctx context.Context, env *Env, config json.RawMessage) (string, error) {
+			c, err := parseSignalAction(config, models.ActionTypeAlert)
+			if err != nil {
+				return "", err
+			}
+			b, err := env.bridge(ctx, c.Bridge)
+			if err != nil {
+				return "", err
+			}
+			req := bridge.LightUpdateRequest{Alert: &bridge.LightAlertState{Action: bridge.AlertBreathe}}
+			if c.GroupID != "" {
+				if err := b.UpdateGroupedLight(ctx, c.GroupID, req); err != nil {
+					return "", err
+				}
+				return "alerted grouped light " + c.GroupID, nil
+			}
+			if err := b.UpdateLight(ctx, c.LightID, req); err != nil {
+				return "", err
+			}
+			return "alerted light " + c.LightID, nil
+		},
+	})
+	e.RegisterAction(models.ActionTypeIdentify, ActionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseSignalAction(config, models.ActionTypeIdentify)
+			return err
+		},
+		Run: func(ctx context.Context, env *Env, config json.RawMessage) (string, error) {
+			c, err := parseSignalAction(config, models.ActionTypeIdentify)
+			if err != nil {
+				return "", err
+			}
+			b, err := env.bridge(ctx, c.Bridge)
+			if err != nil {
+				return "", err
+			}
+			if err := b.IdentifyLight(ctx, c.LightID); err != nil {
+				return "", err
+			}
+			return "identified light " + c.LightID, nil
+		},
+	})
+}
+
+func parseEffectAction(config json.RawMessage, actionType models.ActionType) (*EffectActionConfig, error) {
+	var c EffectActionConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.LightID == "" {
+		return nil, errors.Newf("%s action needs a light_id", actionType)
+	}
+
+	known := bridge.Effects
+	if actionType == models.ActionTypeTimedEffect {
+		known = []string{bridge.TimedEffectSunrise, bridge.TimedEffectSunset}
+	}
+	if c.Effect != bridge.EffectNone && !slices.Contains(known, c.Effect) {
+		return nil, errors.Newf("unknown %s %q (expected %s or %s)", actionType, c.Effect, strings.Join(known, ", "), bridge.EffectNone)
+	}
+
+	switch {
+	case actionType == models.ActionTypeEffect && c.Duration != 0:
+		return nil, errors.New("effect action doesn't take a duration; use a timed_effect or a delay and no_effect")
+	case actionType == models.ActionTypeTimedEffect && c.Effect != bridge.EffectNone && c.Duration == 0:
+		return nil, errors.Newf("timed_effect %s needs a duration", c.Effect)
+	}
+	return &c, nil
+}
+
+// runEffect starts or ends an effect after checking that the light supports it,
+// since the bridge's own error doesn't say which effects it would take
+func (env *Env) runEffect(ctx context.Context, c *EffectActionConfig, actionType models.ActionType) (string, error) {
+	b, err := env.bridge(ctx, c.Bridge)
+	if err != nil {
+		return "", err
+	}
+	lights, err := b.GetLights(ctx)
+	if err != nil {
+		return "", err
+	}
+	i := slices.IndexFunc(lights, func(l bridge.Light) bool { return l.ID == c.LightID })
+	if i < 0 {
+		return "", errors.Newf("no light has ID %s", c.LightID)
+	}
+	light := &lights[i]
+
+	var req bridge.LightUpdateRequest
+	var supported bool
+	if actionType == models.ActionTypeTimedEffect {
+		supported = light.SupportsTimedEffect(c.Effect)
+		req.TimedEffects = &bridge.LightTimedEffectState{
+			Effect:   c.Effect,
+			Duration: int(time.Duration(c.Duration).Milliseconds()),
+		}
+	} else {
+		supported = light.SupportsEffect(c.Effect)
+		req.Effects = &bridge.LightEffectState{Effect: c.Effect}
+		if c.Effect != bridge.EffectNone {
+			req.On = &bridge.LightOnState{On: true}
+		}
+	}
+	if !supported {
+		return "", errors.Newf("light %q doesn't support the %s %s", light.Metadata.Name, c.Effect, actionType)
+	}
+
+	if err := b.UpdateLight(ctx, c.LightID, req); err != nil {
+		return "", err
+	}
+	if c.Effect == bridge.EffectNone {
+		return "ended the " + string(actionType) + " of light " + c.LightID, nil
+	}
+	return "started " + c.Effect + " on light " + c.LightID, nil
+}
+
+func parseSignalAction(config json.RawMessage, actionType models.ActionType) (*SignalActionConfig, error) {
+	var c SignalActionConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+
+	if actionType == models.ActionTypeIdentify {
+		if c.LightID == "" {
+			return nil, errors.New("identify action needs a light_id")
+		}
+		if c.GroupID != "" {
+			return nil, errors.New("identify action doesn't take a group_id; identify its lights one at a time")
+		}
+		return &c, nil
+	}
+
+	if (c.LightID == "") == (c.GroupID == "") {
+		return nil, errors.Newf("%s action needs a light_id or a group_id", actionType)
+	}
+	return &c, nil
+}
@@ -0,0 +1,81 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectActions(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+
+	actions := parseActions(t, `[
+		{"type": "effect", "config": {"light_id": "l1", "effect": "candle"}},
+		{"type": "effect", "config": {"light_id": "l1", "effect": "no_effect"}},
+		{"type": "timed_effect", "config": {"light_id": "l1", "effect": "sunrise", "duration": "30m"}},
+		{"type": "repeat", "config": {"times": 2, "actions": [
+			{"type": "alert", "config": {"light_id": "l2"}}
+		]}},
+		{"type": "alert", "config": {"group_id": "g1"}},
+		{"type": "identify", "config": {"light_id": "l3"}}
+	]`)
+	require.NoError(t, NewEngine().RunActions(context.Background(), env, actions))
+	assert.Equal(t, []string{
+		`light l1 {"on":{"on":true},"effects":{"effect":"candle"}}`,
+		`light l1 {"effects":{"effect":"no_effect"}}`,
+		`light l1 {"timed_effects":{"effect":"sunrise","duration":1800000}}`,
+		`light l2 {"alert":{"action":"breathe"}}`,
+		`light l2 {"alert":{"action":"breathe"}}`,
+		`group g1 {"alert":{"action":"breathe"}}`,
+		"identify l3",
+	}, f.recorded(), "the sunrise runs on the bridge while the next action starts")
+}
+
+func TestEffectActionChecksSupport(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+	engine := NewEngine()
+	ctx := context.Background()
+
+	err := engine.RunActions(ctx, env, parseActions(t, `[{"type": "effect", "config": {"light_id": "l1", "effect": "sparkle"}}]`))
+	assert.ErrorContains(t, err, `light "Desk" doesn't support the sparkle effect`)
+	err = engine.RunActions(ctx, env, parseActions(t, `[{"type": "effect", "config": {"light_id": "l3", "effect": "no_effect"}}]`))
+	assert.ErrorContains(t, err, `light "Plug" doesn't support`)
+	err = engine.RunActions(ctx, env, parseActions(t, `[{"type": "timed_effect", "config": {"light_id": "l9", "effect": "sunset", "duration": "1m"}}]`))
+	assert.ErrorContains(t, err, "no light has ID l9")
+	assert.Empty(t, f.recorded())
+}
+
+func TestValidateEffectActions(t *testing.T) {
+	engine := NewEngine()
+
+	invalid := map[models.ActionType][]string{
+		models.ActionTypeEffect: {
+			`{"effect": "candle"}`,
+			`{"light_id": "l1", "effect": "disco"}`,
+			`{"light_id": "l1", "effect": "sunrise"}`,
+			`{"light_id": "l1", "effect": "candle", "duration": "5m"}`,
+		},
+		models.ActionTypeTimedEffect: {
+			`{"light_id": "l1", "effect": "sunrise"}`,
+			`{"light_id": "l1", "effect": "candle", "duration": "5m"}`,
+		},
+		models.ActionTypeAlert: {
+			`{}`,
+			`{"light_id": "l1", "group_id": "g1"}`,
+		},
+		models.ActionTypeIdentify: {
+			`{}`,
+			`{"group_id": "g1"}`,
+		},
+	}
+	for actionType, configs := range invalid {
+		for _, config := range configs {
+			assert.Error(t, engine.ValidateAction(actionType, json.RawMessage(config)), "%s %s", actionType, config)
+		}
+	}
+	assert.NoError(t, engine.ValidateAction(models.ActionTypeTimedEffect, json.RawMessage(`{"light_id": "l1", "effect": "no_effect"}`)))
+}
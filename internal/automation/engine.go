@@ -0,0 +1,105 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/astro"
+	"github.com/mithilarun/limelight/internal/calendar"
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/mithilarun/limelight/internal/notify"
+)
+
+// Env is the state automations are evaluated against
+type Env struct {
+	// Now is the evaluation time in the home's time zone
+	Now time.Time
+	// Home is the configured location, nil if none is set
+	Home *astro.Home
+	// Bridge returns a bridge, given a profile name or bridge ID or "" for the
+	// current profile's; nil if no bridge is configured
+	Bridge func(ctx context.Context, profileOrBridgeID string) (Bridge, error)
+	// Calendars loads the calendars of calendar_event conditions, nil to read
+	// them without a cache
+	Calendars *calendar.Loader
+	// HTTPClient sends webhooks and ntfy and Gotify notifications, nil for http.DefaultClient
+	HTTPClient *http.Client
+	// Desktop shows desktop notifications, nil for the session bus's notification server
+	Desktop notify.Sender
+
+	// Automation and RunID identify the run for templates and commands, and
+	// are set by RunAutomation
+	Automation string
+	RunID      int64
+	// history records the actions a run performs, nil outside RunAutomation
+	history *runHistory
+}
+
+// NewEnv returns an Env for the current time at home, or in local time without a home
+func NewEnv(home *astro.Home) *Env {
+	now := time.Now()
+	if home != nil {
+		now = home.Now()
+	}
+	return &Env{Now: now, Home: home}
+}
+
+// refreshed returns a copy of env at the current time, for conditions checked
+// partway through a run
+func (env *Env) refreshed() *Env {
+	c := *env
+	c.Now = time.Now().In(env.Now.Location())
+	return &c
+}
+
+// ConditionHandler validates and checks one type of condition
+type ConditionHandler struct {
+	// Validate rejects a config that Check can't use
+	Validate func(config json.RawMessage) error
+	// Check reports whether the condition holds
+	Check func(ctx context.Context, env *Env, config json.RawMessage) (bool, error)
+}
+
+// Engine evaluates automations and runs their actions
+type Engine struct {
+	conditions map[models.ConditionType]ConditionHandler
+	actions    map[models.ActionType]ActionHandler
+}
+
+// NewEngine creates an engine with the built-in condition and action types
+func NewEngine() *Engine {
+	e := &Engine{
+		conditions: make(map[models.ConditionType]ConditionHandler),
+		actions:    make(map[models.ActionType]ActionHandler),
+	}
+	e.registerBuiltinConditions()
+	e.registerBuiltinActions()
+	return e
+}
+
+// RegisterCondition adds or replaces the handler for a condition type
+func (e *Engine) RegisterCondition(conditionType models.ConditionType, handler ConditionHandler) {
+	e.conditions[conditionType] = handler
+}
+
+// ValidateSpec checks that every condition of an automation can be evaluated
+// and every action run
+func (e *Engine) ValidateSpec(spec *models.AutomationSpec) error {
+	if spec.Name == "" {
+		return errors.New("automation name cannot be empty")
+	}
+	for i, c := range spec.Conditions {
+		if err := e.ValidateCondition(c.Type, c.Config); err != nil {
+			return errors.Wrapf(err, "condition %d", i+1)
+		}
+	}
+	for i, a := range spec.Actions {
+		if err := e.ValidateAction(a.Type, a.Config); err != nil {
+			return errors.Wrapf(err, "action %d", i+1)
+		}
+	}
+	return nil
+}
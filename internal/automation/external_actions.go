@@ -0,0 +1,579 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/mithilarun/limelight/internal/notify"
+)
+
+const (
+	defaultWebhookTimeout    = 10 * time.Second
+	defaultWebhookRetryDelay = time.Second
+	maxWebhookRetries        = 10
+	defaultExecTimeout       = time.Minute
+	notifyTimeout            = 30 * time.Second
+
+	// maxDetail bounds how much of a response or command output is kept in the run history
+	maxDetail = 500
+	// maxOutput bounds how much of a response or command output is read
+	maxOutput = 64 << 10
+)
+
+// WebhookConfig is the config of webhook actions, which send an HTTP request
+// and succeed on a 2xx response. Network errors, 429 and 5xx responses are
+// retried up to Retries times, waiting RetryDelay and then twice as long
+// before each further attempt. The URL, header values and body are templates.
+type WebhookConfig struct {
+	URL string `json:"url"`
+	// Method defaults to POST
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	// Timeout bounds each attempt, 10 seconds by default
+	Timeout    Duration `json:"timeout,omitempty"`
+	Retries    int      `json:"retries,omitempty"`
+	RetryDelay Duration `json:"retry_delay,omitempty"`
+}
+
+// ExecConfig is the config of exec actions, which run a local command and
+// succeed if it exits with status 0. The command isn't run through a shell;
+// its arguments and environment values are templates. The command also sees
+// LIMELIGHT_AUTOMATION and LIMELIGHT_RUN_ID.
+type ExecConfig struct {
+	// Command is the program and its arguments
+	Command []string          `json:"command"`
+	Env     map[string]string `json:"env,omitempty"`
+	// Dir is the working directory, limelight's if empty
+	Dir string `json:"dir,omitempty"`
+	// Timeout defaults to a minute, after which the command is killed
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// NotifyConfig is the config of notify actions. Via is desktop, the default,
+// ntfy, gotify or smtp. The title, message, URL, token and SMTP password are
+// templates, so secrets can be read from the environment with env.
+type NotifyConfig struct {
+	Via     string `json:"via,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+	// Priority runs from 1 to 5, 3 by default
+	Priority int `json:"priority,omitempty"`
+	// URL is the ntfy topic's URL or the Gotify server's
+	URL string `json:"url,omitempty"`
+	// Token is an ntfy access token or a Gotify application token
+	Token string      `json:"token,omitempty"`
+	SMTP  *SMTPConfig `json:"smtp,omitempty"`
+}
+
+// SMTPConfig is where notify actions with via smtp send email
+type SMTPConfig struct {
+	Host string `json:"host"`
+	// Port defaults to 587; on 465 the connection is TLS from the start
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// templateData is what templates in action configs can refer to
+type templateData struct {
+	Automation string
+	RunID      int64
+	Now        time.Time
+}
+
+var templateFuncs = template.FuncMap{
+	// env reads an environment variable, for secrets kept out of automation files
+	"env": os.Getenv,
+	// json quotes a value for a JSON body
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s template", name)
+	}
+	return t, nil
+}
+
+// render executes a template from an action config against the run
+func (env *Env) render(name, text string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	t, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	data := templateData{Automation: env.Automation, RunID: env.RunID, Now: env.Now}
+	if err := t.Execute(&b, data); err != nil {
+		return "", errors.Wrapf(err, "rendering %s", name)
+	}
+	return b.String(), nil
+}
+
+func (env *Env) httpClient() *http.Client {
+	if env.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return env.HTTPClient
+}
+
+func (e *Engine) registerExternalActions() {
+	e.RegisterAction(models.ActionTypeWebhook, ActionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseWebhook(config)
+			return err
+		},
+		Run: func(ctx context.Context, env *Env, config json.RawMessage) (string, error) {
+			c, err := parseWebhook(config)
+			if err != nil {
+				return "", err
+			}
+			return env.sendWebhook(ctx, c)
+		},
+	})
+	e.RegisterAction(models.ActionTypeExec, ActionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseExec(config)
+			return err
+		},
+		Run: func(ctx context.Context, env *Env, config json.RawMessage) (string, error) {
+			c, err := parseExec(config)
+			if err != nil {
+				return "", err
+			}
+			return env.runCommand(ctx, c)
+		},
+	})
+	e.RegisterAction(models.ActionTypeNotify, ActionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseNotify(config)
+			return err
+		},
+		Run: func(ctx context.Context, env *Env, config json.RawMessage) (string, error) {
+			c, err := parseNotify(config)
+			if err != nil {
+				return "", err
+			}
+			return env.notify(ctx, c)
+		},
+	})
+}
+
+func parseWebhook(config json.RawMessage) (*WebhookConfig, error) {
+	var c WebhookConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.URL == "" {
+		return nil, errors.New("webhook needs a url")
+	}
+	if !strings.Contains(c.URL, "{{") {
+		if err := validateHTTPURL(c.URL); err != nil {
+			return nil, err
+		}
+	}
+	if c.Method == "" {
+		c.Method = http.MethodPost
+	}
+	c.Method = strings.ToUpper(c.Method)
+	if c.Retries < 0 || c.Retries > maxWebhookRetries {
+		return nil, errors.Newf("webhook retries must be between 0 and %d, got %d", maxWebhookRetries, c.Retries)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = Duration(defaultWebhookTimeout)
+	}
+	if c.RetryDelay == 0 {
+		c.RetryDelay = Duration(defaultWebhookRetryDelay)
+	}
+
+	templates := map[string]string{"url": c.URL, "body": c.Body}
+	for key, value := range c.Headers {
+		templates["header "+key] = value
+	}
+	for name, text := range templates {
+		if _, err := parseTemplate(name, text); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+func validateHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Newf("invalid url %q (expected an http or https URL)", raw)
+	}
+	return nil
+}
+
+// sendWebhook sends the request, retrying failures that may be temporary
+func (env *Env) sendWebhook(ctx context.Context, c *WebhookConfig) (string, error) {
+	target, err := env.render("url", c.URL)
+	if err != nil {
+		return "", err
+	}
+	if err := validateHTTPURL(target); err != nil {
+		return "", err
+	}
+	body, err := env.render("body", c.Body)
+	if err != nil {
+		return "", err
+	}
+	headers := make(map[string]string, len(c.Headers))
+	for key, value := range c.Headers {
+		if headers[key], err = env.render("header "+key, value); err != nil {
+			return "", err
+		}
+	}
+
+	// Query strings often carry keys, so they're left out of the history
+	shown := target
+	if u, err := url.Parse(target); err == nil {
+		u.RawQuery = ""
+		shown = u.Redacted()
+	}
+
+	delay := time.Duration(c.RetryDelay)
+	attempts := c.Retries + 1
+	for attempt := 1; ; attempt++ {
+		status, response, err := env.webhookAttempt(ctx, c, target, headers, body)
+		if err == nil {
+			detail := fmt.Sprintf("%s %s: %s", c.Method, shown, status)
+			if attempt > 1 {
+				detail += fmt.Sprintf(" after %d attempts", attempt)
+			}
+			if response != "" {
+				detail += "\n" + response
+			}
+			return detail, nil
+		}
+		if attempt == attempts || !isRetryable(err) || ctx.Err() != nil {
+			if attempts > 1 {
+				return "", errors.Wrapf(err, "%s %s failed after %d attempts", c.Method, shown, attempt)
+			}
+			return "", errors.Wrapf(err, "%s %s", c.Method, shown)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return "", err
+		}
+		delay *= 2
+	}
+}
+
+// errRetryable marks webhook failures worth another attempt
+var errRetryable = errors.New("retryable")
+
+func isRetryable(err error) bool {
+	return errors.Is(err, errRetryable)
+}
+
+// webhookAttempt sends the request once, returning the response status and the start of its body
+func (env *Env) webhookAttempt(ctx context.Context, c *WebhookConfig, target string, headers map[string]string, body string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.Timeout))
+	defer cancel()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.Method, target, reader)
+	if err != nil {
+		return "", "", errors.Wrap(err, "creating request")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if body != "" && req.Header.Get("Content-Type") == "" && json.Valid([]byte(body)) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := env.httpClient().Do(req)
+	if err != nil {
+		// The caller names the request without its query string
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", "", errors.Mark(err, errRetryable)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+	response := truncate(strings.TrimSpace(string(data)), maxDetail)
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp.Status, response, nil
+	}
+
+	err = errors.Newf("server returned %s", resp.Status)
+	if response != "" {
+		err = errors.Newf("server returned %s: %s", resp.Status, response)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		err = errors.Mark(err, errRetryable)
+	}
+	return "", "", err
+}
+
+func parseExec(config json.RawMessage) (*ExecConfig, error) {
+	var c ExecConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Command) == 0 || c.Command[0] == "" {
+		return nil, errors.New("exec needs a command")
+	}
+	for key := range c.Env {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return nil, errors.Newf("invalid environment variable name %q", key)
+		}
+	}
+	if c.Timeout == 0 {
+		c.Timeout = Duration(defaultExecTimeout)
+	}
+
+	for i, arg := range c.Command {
+		if _, err := parseTemplate(fmt.Sprintf("argument %d", i), arg); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range c.Env {
+		if _, err := parseTemplate("env "+key, value); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// runCommand runs the command, returning its exit status and the end of its output
+func (env *Env) runCommand(ctx context.Context, c *ExecConfig) (string, error) {
+	args := make([]string, len(c.Command))
+	for i, arg := range c.Command {
+		var err error
+		if args[i], err = env.render(fmt.Sprintf("argument %d", i), arg); err != nil {
+			return "", err
+		}
+	}
+
+	environ := append(os.Environ(),
+		"LIMELIGHT_AUTOMATION="+env.Automation,
+		"LIMELIGHT_RUN_ID="+strconv.FormatInt(env.RunID, 10),
+	)
+	keys := make([]string, 0, len(c.Env))
+	for key := range c.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := env.render("env "+key, c.Env[key])
+		if err != nil {
+			return "", err
+		}
+		environ = append(environ, key+"="+value)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.Timeout))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = environ
+	cmd.Dir = c.Dir
+	// Don't wait forever on pipes held open by the command's children
+	cmd.WaitDelay = time.Second
+	output := &tailBuffer{max: maxOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
+	// The last lines, where errors usually are, are the ones worth keeping
+	out := truncateStart(strings.TrimSpace(output.String()), maxDetail)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = errors.Newf("timed out after %s", time.Duration(c.Timeout))
+		}
+		if out != "" {
+			return "", errors.Wrapf(err, "%s: %s", args[0], out)
+		}
+		return "", errors.Wrap(err, args[0])
+	}
+
+	detail := args[0] + " exited with status 0"
+	if out != "" {
+		detail += "\n" + out
+	}
+	return detail, nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
+
+func parseNotify(config json.RawMessage) (*NotifyConfig, error) {
+	var c NotifyConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.Message == "" {
+		return nil, errors.New("notify needs a message")
+	}
+	if c.Via == "" {
+		c.Via = "desktop"
+	}
+	if err := notify.ValidatePriority(c.Priority); err != nil {
+		return nil, err
+	}
+
+	switch c.Via {
+	case "desktop":
+		if c.URL != "" || c.Token != "" {
+			return nil, errors.New("desktop notifications don't take a url or token")
+		}
+	case "ntfy", "gotify":
+		if c.URL == "" {
+			return nil, errors.Newf("%s notifications need a url", c.Via)
+		}
+		if !strings.Contains(c.URL, "{{") {
+			if err := validateHTTPURL(c.URL); err != nil {
+				return nil, err
+			}
+		}
+		if c.Via == "gotify" && c.Token == "" {
+			return nil, errors.New("gotify notifications need an application token")
+		}
+	case "smtp":
+		if c.SMTP == nil || c.SMTP.Host == "" || c.SMTP.From == "" || len(c.SMTP.To) == 0 {
+			return nil, errors.New("smtp notifications need an smtp host, from and to")
+		}
+		if c.SMTP.Port < 0 || c.SMTP.Port > 65535 {
+			return nil, errors.Newf("invalid smtp port %d", c.SMTP.Port)
+		}
+	default:
+		return nil, errors.Newf("invalid via %q (expected desktop, ntfy, gotify or smtp)", c.Via)
+	}
+	if c.SMTP != nil && c.Via != "smtp" {
+		return nil, errors.Newf("%s notifications don't take smtp settings", c.Via)
+	}
+
+	templates := map[string]string{"title": c.Title, "message": c.Message, "url": c.URL, "token": c.Token}
+	if c.SMTP != nil {
+		templates["smtp password"] = c.SMTP.Password
+	}
+	for name, text := range templates {
+		if _, err := parseTemplate(name, text); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// notify sends the notification through the service the config names
+func (env *Env) notify(ctx context.Context, c *NotifyConfig) (string, error) {
+	var msg notify.Message
+	var err error
+	if msg.Title, err = env.render("title", c.Title); err != nil {
+		return "", err
+	}
+	if msg.Body, err = env.render("message", c.Message); err != nil {
+		return "", err
+	}
+	msg.Priority = c.Priority
+
+	target, err := env.render("url", c.URL)
+	if err != nil {
+		return "", err
+	}
+	token, err := env.render("token", c.Token)
+	if err != nil {
+		return "", err
+	}
+
+	var sender notify.Sender
+	switch c.Via {
+	case "desktop":
+		sender = env.Desktop
+		if sender == nil {
+			sender = notify.NewDesktop()
+		}
+	case "ntfy":
+		sender = &notify.Ntfy{URL: target, Token: token, HTTPClient: env.HTTPClient}
+	case "gotify":
+		sender = &notify.Gotify{URL: target, Token: token, HTTPClient: env.HTTPClient}
+	case "smtp":
+		password, err := env.render("smtp password", c.SMTP.Password)
+		if err != nil {
+			return "", err
+		}
+		sender = &notify.SMTP{
+			Host:     c.SMTP.Host,
+			Port:     c.SMTP.Port,
+			Username: c.SMTP.Username,
+			Password: password,
+			From:     c.SMTP.From,
+			To:       c.SMTP.To,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	return sender.Send(ctx, msg)
+}
+
+// truncate shortens s to at most n bytes, marking that it was cut
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
+
+// truncateStart shortens s to at most n bytes by cutting from the front,
+// marking that it was cut
+func truncateStart(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	i := len(s) - n
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return "…" + s[i:]
+}
@@ -0,0 +1,231 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/mithilarun/limelight/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookServer answers with the given statuses in turn, then 200, recording each request
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func startWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(body))
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"ok": true}`)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// runAction runs a single action, returning what it would record in the run history
+func runAction(t *testing.T, env *Env, actionType models.ActionType, config string) (string, error) {
+	engine := NewEngine()
+	require.NoError(t, engine.ValidateAction(actionType, json.RawMessage(config)), config)
+	return engine.actions[actionType].Run(context.Background(), env, json.RawMessage(config))
+}
+
+func TestWebhookAction(t *testing.T) {
+	t.Setenv("LIMELIGHT_TEST_TOKEN", "s3cret")
+	server := startWebhookServer(t)
+	env := envAt(t, "2026-10-19 20:00")
+	env.Automation, env.RunID = "Evening", 42
+
+	config := fmt.Sprintf(`{
+		"url": "%s/hooks/lights?key=abc",
+		"headers": {"Authorization": "Bearer {{env \"LIMELIGHT_TEST_TOKEN\"}}"},
+		"body": "{\"automation\": {{json .Automation}}, \"run\": {{.RunID}}, \"at\": \"{{.Now.Format \"15:04\"}}\"}"
+	}`, server.URL)
+	detail, err := runAction(t, env, models.ActionTypeWebhook, config)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("POST %s/hooks/lights: 200 OK\n{\"ok\": true}", server.URL), detail, "the query string is left out")
+
+	require.Equal(t, 1, server.count())
+	req := server.requests[0]
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "key=abc", req.URL.RawQuery)
+	assert.Equal(t, "Bearer s3cret", req.Header.Get("Authorization"))
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"automation": "Evening", "run": 42, "at": "20:00"}`, server.bodies[0])
+}
+
+func TestWebhookRetries(t *testing.T) {
+	env := envAt(t, "2026-10-19 20:00")
+
+	server := startWebhookServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	detail, err := runAction(t, env, models.ActionTypeWebhook,
+		fmt.Sprintf(`{"url": %q, "method": "put", "retries": 2, "retry_delay": "1ms"}`, server.URL))
+	require.NoError(t, err)
+	assert.Contains(t, detail, "PUT "+server.URL+": 200 OK after 3 attempts")
+	assert.Equal(t, 3, server.count())
+
+	// Client errors aren't retried
+	server = startWebhookServer(t, http.StatusNotFound)
+	_, err = runAction(t, env, models.ActionTypeWebhook,
+		fmt.Sprintf(`{"url": %q, "retries": 2, "retry_delay": "1ms"}`, server.URL))
+	assert.ErrorContains(t, err, "server returned 404 Not Found")
+	assert.Equal(t, 1, server.count())
+
+	server = startWebhookServer(t, 500, 500, 500)
+	_, err = runAction(t, env, models.ActionTypeWebhook,
+		fmt.Sprintf(`{"url": %q, "retries": 1, "retry_delay": "1ms"}`, server.URL))
+	assert.ErrorContains(t, err, "failed after 2 attempts: server returned 500 Internal Server Error")
+	assert.Equal(t, 2, server.count())
+
+	// Unreachable servers are retried too
+	server.Close()
+	_, err = runAction(t, env, models.ActionTypeWebhook,
+		fmt.Sprintf(`{"url": %q, "retries": 1, "retry_delay": "1ms", "timeout": "1s"}`, server.URL))
+	assert.ErrorContains(t, err, "failed after 2 attempts")
+}
+
+func TestExecAction(t *testing.T) {
+	env := envAt(t, "2026-10-19 20:00")
+	env.Automation, env.RunID = "Evening", 7
+
+	detail, err := runAction(t, env, models.ActionTypeExec, `{
+		"command": ["sh", "-c", "echo \"$GREETING $LIMELIGHT_AUTOMATION $LIMELIGHT_RUN_ID $1\"; pwd", "sh", "{{.Automation}}"],
+		"env": {"GREETING": "hello {{.RunID}}"},
+		"dir": "/"
+	}`)
+	require.NoError(t, err)
+	assert.Equal(t, "sh exited with status 0\nhello 7 Evening 7 Evening\n/", detail)
+
+	_, err = runAction(t, env, models.ActionTypeExec, `{"command": ["sh", "-c", "echo broken >&2; exit 3"]}`)
+	assert.ErrorContains(t, err, "sh: broken: exit status 3")
+
+	// Long output keeps its end, where errors usually are
+	detail, err = runAction(t, env, models.ActionTypeExec, `{"command": ["sh", "-c", "seq 1000; echo done"]}`)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(detail, "\n999\n1000\ndone"), detail)
+	assert.Contains(t, detail, "exited with status 0\n…")
+	assert.NotContains(t, detail, "\n1\n2\n")
+
+	start := time.Now()
+	_, err = runAction(t, env, models.ActionTypeExec, `{"command": ["sleep", "10"], "timeout": "50ms"}`)
+	assert.ErrorContains(t, err, "timed out after 50ms")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	_, err = runAction(t, env, models.ActionTypeExec, `{"command": ["limelight-no-such-command"]}`)
+	assert.Error(t, err)
+}
+
+// fakeDesktop records desktop notifications instead of showing them
+type fakeDesktop struct {
+	sent []notify.Message
+}
+
+func (f *fakeDesktop) Send(_ context.Context, msg notify.Message) (string, error) {
+	f.sent = append(f.sent, msg)
+	return "desktop notification 1", nil
+}
+
+func TestNotifyAction(t *testing.T) {
+	env := envAt(t, "2026-10-19 20:00")
+	env.Automation = "Evening"
+	desktop := &fakeDesktop{}
+	env.Desktop = desktop
+
+	detail, err := runAction(t, env, models.ActionTypeNotify, `{"title": "{{.Automation}}", "message": "Lights on at {{.Now.Format \"15:04\"}}", "priority": 2}`)
+	require.NoError(t, err)
+	assert.Equal(t, "desktop notification 1", detail)
+	assert.Equal(t, []notify.Message{{Title: "Evening", Body: "Lights on at 20:00", Priority: 2}}, desktop.sent)
+
+	var title string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title = r.Header.Get("Title")
+		fmt.Fprint(w, `{"id": "n1"}`)
+	}))
+	t.Cleanup(server.Close)
+	detail, err = runAction(t, env, models.ActionTypeNotify,
+		fmt.Sprintf(`{"via": "ntfy", "url": "%s/lights", "title": "{{.Automation}}", "message": "done"}`, server.URL))
+	require.NoError(t, err)
+	assert.Equal(t, "published to ntfy as n1", detail)
+	assert.Equal(t, "Evening", title)
+}
+
+func TestValidateExternalActions(t *testing.T) {
+	engine := NewEngine()
+
+	invalid := map[models.ActionType][]string{
+		models.ActionTypeWebhook: {
+			`{}`,
+			`{"url": "example.com/hook"}`,
+			`{"url": "ftp://example.com/hook"}`,
+			`{"url": "https://example.com", "retries": 11}`,
+			`{"url": "https://example.com", "body": "{{.Automation"}`,
+			`{"url": "https://example.com", "headers": {"X-Key": "{{nope}}"}}`,
+			`{"url": "https://example.com", "timeout": 10}`,
+		},
+		models.ActionTypeExec: {
+			`{}`,
+			`{"command": []}`,
+			`{"command": "echo hi"}`,
+			`{"command": ["echo", "{{"]}`,
+			`{"command": ["echo"], "env": {"A=B": "c"}}`,
+		},
+		models.ActionTypeNotify: {
+			`{}`,
+			`{"message": "hi", "via": "pager"}`,
+			`{"message": "hi", "priority": 9}`,
+			`{"message": "hi", "url": "https://ntfy.sh/lights"}`,
+			`{"message": "hi", "via": "ntfy"}`,
+			`{"message": "hi", "via": "gotify", "url": "https://gotify.example.com"}`,
+			`{"message": "hi", "via": "smtp", "smtp": {"host": "mail.example.com", "from": "a@example.com"}}`,
+			`{"message": "hi", "via": "ntfy", "url": "https://ntfy.sh/lights", "smtp": {"host": "mail.example.com"}}`,
+		},
+	}
+	for actionType, configs := range invalid {
+		for _, config := range configs {
+			assert.Error(t, engine.ValidateAction(actionType, json.RawMessage(config)), "%s %s", actionType, config)
+		}
+	}
+
+	valid := map[models.ActionType][]string{
+		models.ActionTypeWebhook: {`{"url": "https://{{env \"HOOK_HOST\"}}/hook"}`},
+		models.ActionTypeNotify: {
+			`{"message": "hi", "via": "gotify", "url": "https://gotify.example.com", "token": "{{env \"GOTIFY_TOKEN\"}}"}`,
+			`{"message": "hi", "via": "smtp", "smtp": {"host": "mail.example.com", "port": 465, "from": "a@example.com", "to": ["b@example.com"]}}`,
+		},
+	}
+	for actionType, configs := range valid {
+		for _, config := range configs {
+			assert.NoError(t, engine.ValidateAction(actionType, json.RawMessage(config)), "%s %s", actionType, config)
+		}
+	}
+}
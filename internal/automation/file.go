@@ -0,0 +1,157 @@
+package automation
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/db/models"
+)
+
+// File is an automation as written in files for import and export. A file holds
+// one automation or a list of them. Conditions are all required to hold; use an
+// any, all or not condition to combine them differently:
+//
+//	{
+//	  "name": "Lazy mornings",
+//	  "triggers": [{"type": "sunrise"}],
+//	  "conditions": [
+//	    {"type": "any", "config": {"conditions": [
+//	      {"type": "weekend"},
+//	      {"type": "date_range", "config": {"start": "12-24", "end": "12-26"}}
+//	    ]}}
+//	  ],
+//	  "actions": [{"type": "scene", "config": {"scene_id": "..."}}]
+//	}
+type File struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Enabled defaults to true
+	Enabled    *bool           `json:"enabled,omitempty"`
+	Triggers   []TriggerNode   `json:"triggers,omitempty"`
+	Conditions []ConditionNode `json:"conditions,omitempty"`
+	Actions    []ActionNode    `json:"actions,omitempty"`
+}
+
+// TriggerNode is a trigger in a File
+type TriggerNode struct {
+	Type   models.TriggerType `json:"type"`
+	Config json.RawMessage    `json:"config,omitempty"`
+}
+
+// ActionNode is an action in a File, run in the order listed
+type ActionNode struct {
+	Type   models.ActionType `json:"type"`
+	Config json.RawMessage   `json:"config,omitempty"`
+}
+
+// ParseFile reads one automation, or a JSON array of them, into specs ready to save
+func ParseFile(data []byte) ([]*models.AutomationSpec, error) {
+	var files []File
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := strictUnmarshal(trimmed, &files); err != nil {
+			return nil, err
+		}
+	} else {
+		var file File
+		if err := strictUnmarshal(trimmed, &file); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	specs := make([]*models.AutomationSpec, 0, len(files))
+	for _, file := range files {
+		specs = append(specs, file.Spec())
+	}
+	return specs, nil
+}
+
+// Spec converts the file into an automation spec without an ID
+func (f *File) Spec() *models.AutomationSpec {
+	spec := &models.AutomationSpec{
+		Automation: models.Automation{
+			Name:        f.Name,
+			Description: f.Description,
+			Enabled:     f.Enabled == nil || *f.Enabled,
+		},
+	}
+	for _, t := range f.Triggers {
+		spec.Triggers = append(spec.Triggers, &models.Trigger{Type: t.Type, Config: compactConfig(t.Config)})
+	}
+	for _, c := range f.Conditions {
+		spec.Conditions = append(spec.Conditions, &models.Condition{Type: c.Type, Config: compactConfig(c.Config)})
+	}
+	for _, a := range f.Actions {
+		spec.Actions = append(spec.Actions, &models.Action{Type: a.Type, Config: compactConfig(a.Config)})
+	}
+	return spec
+}
+
+// NewFile converts a stored automation into its file form
+func NewFile(spec *models.AutomationSpec) *File {
+	enabled := spec.Enabled
+	f := &File{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Enabled:     &enabled,
+	}
+	for _, t := range spec.Triggers {
+		f.Triggers = append(f.Triggers, TriggerNode{Type: t.Type, Config: emptyConfigOmitted(t.Config)})
+	}
+	for _, c := range spec.Conditions {
+		f.Conditions = append(f.Conditions, ConditionNode{Type: c.Type, Config: emptyConfigOmitted(c.Config)})
+	}
+	for _, a := range spec.Actions {
+		f.Actions = append(f.Actions, ActionNode{Type: a.Type, Config: emptyConfigOmitted(a.Config)})
+	}
+	return f
+}
+
+// MarshalFiles writes automations in file form, as a list if there is more than one
+func MarshalFiles(specs []*models.AutomationSpec) ([]byte, error) {
+	var value interface{}
+	if len(specs) == 1 {
+		value = NewFile(specs[0])
+	} else {
+		files := make([]*File, 0, len(specs))
+		for _, spec := range specs {
+			files = append(files, NewFile(spec))
+		}
+		value = files
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode automations")
+	}
+	return append(data, '\n'), nil
+}
+
+// compactConfig strips the file's formatting from a config before it is stored
+func compactConfig(config json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, config); err != nil {
+		return config
+	}
+	return buf.Bytes()
+}
+
+// emptyConfigOmitted drops configs that are empty objects, such as weekday's
+func emptyConfigOmitted(config json.RawMessage) json.RawMessage {
+	trimmed := bytes.TrimSpace(config)
+	if len(trimmed) == 0 || string(trimmed) == "{}" || string(trimmed) == "null" {
+		return nil
+	}
+	return config
+}
+
+func strictUnmarshal(data []byte, out interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return errors.Wrap(err, "invalid automation file")
+	}
+	return nil
+}
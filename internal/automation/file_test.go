@@ -0,0 +1,74 @@
+package automation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const holidayFile = `{
+  "name": "Lazy mornings",
+  "triggers": [{"type": "sunrise"}],
+  "conditions": [
+    {"type": "any", "config": {"conditions": [
+      {"type": "weekend"},
+      {"type": "date_range", "config": {"start": "12-24", "end": "12-26"}}
+    ]}}
+  ],
+  "actions": [
+    {"type": "scene", "config": {"scene_id": "relax"}},
+    {"type": "light", "config": {"light_id": "porch", "on": false}}
+  ]
+}`
+
+func TestParseFile(t *testing.T) {
+	specs, err := ParseFile([]byte(holidayFile))
+	require.NoError(t, err)
+	require.Len(t, specs, 1)
+
+	spec := specs[0]
+	assert.Equal(t, "Lazy mornings", spec.Name)
+	assert.True(t, spec.Enabled, "automations are enabled unless the file says otherwise")
+	require.Len(t, spec.Conditions, 1)
+	assert.Equal(t, models.ConditionTypeAny, spec.Conditions[0].Type)
+	assert.NotContains(t, string(spec.Conditions[0].Config), "\n", "configs are stored compact")
+	require.Len(t, spec.Actions, 2)
+	assert.Equal(t, models.ActionTypeLight, spec.Actions[1].Type)
+
+	require.NoError(t, NewEngine().ValidateSpec(spec))
+
+	specs, err = ParseFile([]byte(`[{"name": "One", "enabled": false}, {"name": "Two"}]`))
+	require.NoError(t, err)
+	require.Len(t, specs, 2)
+	assert.False(t, specs[0].Enabled)
+
+	_, err = ParseFile([]byte(`{"name": "Typo", "condition": []}`))
+	assert.Error(t, err, "unknown fields are rejected")
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := models.NewMemoryStore()
+
+	specs, err := ParseFile([]byte(holidayFile))
+	require.NoError(t, err)
+	require.NoError(t, store.SaveAutomationSpec(ctx, specs[0]))
+
+	saved, err := store.LoadAutomationSpec(ctx, specs[0].ID)
+	require.NoError(t, err)
+
+	data, err := MarshalFiles([]*models.AutomationSpec{saved})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"id"`)
+
+	again, err := ParseFile(data)
+	require.NoError(t, err)
+	require.Len(t, again, 1)
+	assert.Equal(t, saved.Name, again[0].Name)
+	require.Len(t, again[0].Conditions, 1)
+	assert.JSONEq(t, string(saved.Conditions[0].Config), string(again[0].Conditions[0].Config))
+	assert.Len(t, again[0].Actions, 2)
+}
@@ -0,0 +1,97 @@
+package automation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/db/models"
+)
+
+// runHistory records the steps of one run. Parallel branches record through
+// the same history, so it keeps the first error rather than failing the action.
+type runHistory struct {
+	ctx   context.Context
+	store models.Store
+	runID int64
+
+	mu       sync.Mutex
+	firstErr error
+}
+
+// RunAutomation runs an automation's actions as RunActions does and records the
+// run, with each bridge, webhook, exec and notify action it performed, in the
+// store's run history. The outcome is recorded even if ctx is
+// cancelled. The returned run is nil only if it couldn't be recorded at all.
+func (e *Engine) RunAutomation(ctx context.Context, env *Env, store models.Store, spec *models.AutomationSpec) (*models.Run, error) {
+	// Cancelling the run shouldn't also lose its history
+	recordCtx := context.WithoutCancel(ctx)
+
+	run, err := store.CreateRun(recordCtx, spec.ID, time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "recording run")
+	}
+
+	runEnv := *env
+	runEnv.Automation = spec.Name
+	runEnv.RunID = run.ID
+	runEnv.history = &runHistory{ctx: recordCtx, store: store, runID: run.ID}
+
+	runErr := e.RunActions(ctx, &runEnv, spec.Actions)
+
+	status, message := models.RunStatusSucceeded, ""
+	switch {
+	case errors.Is(runErr, ErrStopped):
+		status, message = models.RunStatusStopped, runErr.Error()
+	case runErr != nil:
+		status, message = models.RunStatusFailed, runErr.Error()
+	}
+	finishedAt := time.Now()
+	if err := store.FinishRun(recordCtx, run.ID, status, message, finishedAt); err != nil {
+		return run, errors.CombineErrors(runErr, errors.Wrap(err, "recording run"))
+	}
+	finishedAt = finishedAt.UTC()
+	run.Status, run.Error, run.FinishedAt = status, message, &finishedAt
+
+	if runErr != nil {
+		return run, runErr
+	}
+	return run, runEnv.history.err()
+}
+
+// recordStep adds a finished action to the run history, if the env has one
+func (env *Env) recordStep(actionType models.ActionType, started time.Time, detail string, err error) {
+	h := env.history
+	if h == nil {
+		return
+	}
+
+	step := &models.RunStep{
+		RunID:      h.runID,
+		ActionType: actionType,
+		Status:     models.RunStatusSucceeded,
+		Detail:     detail,
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+	}
+	if err != nil {
+		step.Status = models.RunStatusFailed
+		step.Error = err.Error()
+	}
+
+	if err := h.store.AddRunStep(h.ctx, step); err != nil {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.firstErr == nil {
+			h.firstErr = errors.Wrapf(err, "recording %s action", actionType)
+		}
+	}
+}
+
+// err returns the first error recording a step
+func (h *runHistory) err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.firstErr
+}
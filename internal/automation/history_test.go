@@ -0,0 +1,99 @@
+package automation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRunSpec stores an automation with the given actions
+func newRunSpec(t *testing.T, store models.Store, actions string) *models.AutomationSpec {
+	a, err := store.CreateAutomation(context.Background(), "Evening", "")
+	require.NoError(t, err)
+	return &models.AutomationSpec{Automation: *a, Actions: parseActions(t, actions)}
+}
+
+func TestRunAutomationRecordsHistory(t *testing.T) {
+	env, f := bridgeEnvWithFake(t)
+	server := startWebhookServer(t, http.StatusBadRequest)
+	store := models.NewMemoryStore()
+	ctx := context.Background()
+
+	spec := newRunSpec(t, store, fmt.Sprintf(`[
+		{"type": "parallel", "config": {"branches": [
+			[{"type": "scene", "config": {"scene_id": "s1"}}],
+			[{"type": "delay", "config": {"duration": "10ms"}}, {"type": "light", "config": {"light_id": "l1", "on": false}}]
+		]}},
+		{"type": "webhook", "config": {"url": "%s/run/{{.RunID}}"}},
+		{"type": "scene", "config": {"scene_id": "s2"}}
+	]`, server.URL))
+
+	run, err := NewEngine().RunAutomation(ctx, env, store, spec)
+	require.Error(t, err)
+	require.NotNil(t, run)
+	assert.Equal(t, models.RunStatusFailed, run.Status)
+	assert.Contains(t, run.Error, "server returned 400 Bad Request")
+	assert.Equal(t, fmt.Sprintf("/run/%d", run.ID), server.requests[0].URL.Path)
+	assert.Len(t, f.recorded(), 2, "the run ends at the failed webhook")
+
+	runs, err := store.ListRuns(ctx, spec.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, models.RunStatusFailed, runs[0].Status)
+	assert.NotNil(t, runs[0].FinishedAt)
+
+	steps, err := store.GetRunSteps(ctx, run.ID)
+	require.NoError(t, err)
+	require.Len(t, steps, 3, "flow actions aren't steps")
+	assert.Equal(t, models.ActionTypeScene, steps[0].ActionType)
+	assert.Equal(t, "activated scene s1", steps[0].Detail)
+	assert.Equal(t, models.ActionTypeLight, steps[1].ActionType)
+	assert.Equal(t, models.ActionTypeWebhook, steps[2].ActionType)
+	assert.Equal(t, models.RunStatusFailed, steps[2].Status)
+	assert.Contains(t, steps[2].Error, "400 Bad Request")
+}
+
+func TestRunAutomationStopped(t *testing.T) {
+	env, _ := bridgeEnvWithFake(t)
+	store := models.NewMemoryStore()
+	ctx := context.Background()
+
+	spec := newRunSpec(t, store, `[
+		{"type": "scene", "config": {"scene_id": "s1"}},
+		{"type": "stop_if", "config": {"conditions": [{"type": "light_state", "config": {"light": "Desk", "on": true}}]}},
+		{"type": "scene", "config": {"scene_id": "s2"}}
+	]`)
+	run, err := NewEngine().RunAutomation(ctx, env, store, spec)
+	assert.True(t, errors.Is(err, ErrStopped))
+	assert.Equal(t, models.RunStatusStopped, run.Status)
+
+	steps, err := store.GetRunSteps(ctx, run.ID)
+	require.NoError(t, err)
+	assert.Len(t, steps, 1)
+}
+
+func TestRunAutomationRecordsCancelledRuns(t *testing.T) {
+	env, _ := bridgeEnvWithFake(t)
+	store := models.NewMemoryStore()
+
+	spec := newRunSpec(t, store, `[
+		{"type": "scene", "config": {"scene_id": "s1"}},
+		{"type": "delay", "config": {"duration": "10s"}}
+	]`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	run, err := NewEngine().RunAutomation(ctx, env, store, spec)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	runs, err := store.ListRuns(context.Background(), spec.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, run.ID, runs[0].ID)
+	assert.Equal(t, models.RunStatusFailed, runs[0].Status)
+}
@@ -0,0 +1,374 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
+	"github.com/mithilarun/limelight/internal/db/models"
+)
+
+// ErrNoBridge is returned when a state condition or an action needs a bridge and none is configured
+var ErrNoBridge = errors.New("no bridge is configured")
+
+// BridgeState is the bridge state that state conditions read. *bridge.Client
+// satisfies it by asking the bridge on every call, and *bridge.StateCache by
+// reading its copy.
+type BridgeState interface {
+	GetLights(ctx context.Context) ([]bridge.Light, error)
+	GetRooms(ctx context.Context) ([]bridge.Room, error)
+	GetGroupedLights(ctx context.Context) ([]bridge.GroupedLight, error)
+	GetScenes(ctx context.Context) ([]bridge.Scene, error)
+	GetDevices(ctx context.Context) ([]bridge.Device, error)
+	GetLightLevels(ctx context.Context) ([]bridge.LightLevel, error)
+}
+
+var _ BridgeState = (*bridge.StateCache)(nil)
+
+// LightStateConfig is the config of light_state and room_state conditions,
+// which hold when the light, or the room's lights as a group, match every field
+// set. Lights that are off have a brightness of 0, and lights without dimming
+// that are on 100. At least one of the state fields is required.
+type LightStateConfig struct {
+	// Light is the ID or name of a light, for light_state
+	Light string `json:"light,omitempty"`
+	// Room is the ID or name of a room, for room_state
+	Room string `json:"room,omitempty"`
+	// Bridge is a profile name or bridge ID, the current profile's bridge if empty
+	Bridge string `json:"bridge,omitempty"`
+
+	On *bool `json:"on,omitempty"`
+	// BrightnessBelow and BrightnessAbove are percentages
+	BrightnessBelow *float64 `json:"brightness_below,omitempty"`
+	BrightnessAbove *float64 `json:"brightness_above,omitempty"`
+}
+
+// SceneActiveConfig is the config of scene_active conditions, which hold while
+// the scene is the one last recalled in its room or zone and hasn't been
+// changed since
+type SceneActiveConfig struct {
+	// Scene is the ID or name of a scene
+	Scene string `json:"scene"`
+	// Room narrows down scenes by name to those of one room or zone
+	Room   string `json:"room,omitempty"`
+	Bridge string `json:"bridge,omitempty"`
+}
+
+// SensorLuxConfig is the config of sensor_lux conditions, which hold while the
+// light level measured by a motion sensor is below Below and above Above lux.
+// At least one is required.
+type SensorLuxConfig struct {
+	// Sensor is the ID of a light_level resource, or the ID or name of its device
+	Sensor string   `json:"sensor"`
+	Bridge string   `json:"bridge,omitempty"`
+	Below  *float64 `json:"below,omitempty"`
+	Above  *float64 `json:"above,omitempty"`
+}
+
+func (e *Engine) registerStateConditions() {
+	e.RegisterCondition(models.ConditionTypeLightState, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseLightState(config, models.ConditionTypeLightState)
+			return err
+		},
+		Check: func(ctx context.Context, env *Env, config json.RawMessage) (bool, error) {
+			c, err := parseLightState(config, models.ConditionTypeLightState)
+			if err != nil {
+				return false, err
+			}
+			state, err := env.bridge(ctx, c.Bridge)
+			if err != nil {
+				return false, err
+			}
+			lights, err := state.GetLights(ctx)
+			if err != nil {
+				return false, err
+			}
+
+			light, err := findByIDOrName(lights, c.Light, "light", func(l *bridge.Light) (string, string) {
+				return l.ID, l.Metadata.Name
+			})
+			if err != nil {
+				return false, err
+			}
+			return c.matches(light.On.On, light.Dimming), nil
+		},
+	})
+
+	e.RegisterCondition(models.ConditionTypeRoomState, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseLightState(config, models.ConditionTypeRoomState)
+			return err
+		},
+		Check: func(ctx context.Context, env *Env, config json.RawMessage) (bool, error) {
+			c, err := parseLightState(config, models.ConditionTypeRoomState)
+			if err != nil {
+				return false, err
+			}
+			state, err := env.bridge(ctx, c.Bridge)
+			if err != nil {
+				return false, err
+			}
+			room, err := findRoom(ctx, state, c.Room)
+			if err != nil {
+				return false, err
+			}
+			groups, err := state.GetGroupedLights(ctx)
+			if err != nil {
+				return false, err
+			}
+
+			for _, group := range groups {
+				if group.Owner.ResourceID != room.ID {
+					continue
+				}
+				return c.matches(group.On.On, group.Dimming), nil
+			}
+			return false, errors.Newf("room %q has no grouped light", room.Metadata.Name)
+		},
+	})
+
+	e.RegisterCondition(models.ConditionTypeSceneActive, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseSceneActive(config)
+			return err
+		},
+		Check: func(ctx context.Context, env *Env, config json.RawMessage) (bool, error) {
+			c, err := parseSceneActive(config)
+			if err != nil {
+				return false, err
+			}
+			state, err := env.bridge(ctx, c.Bridge)
+			if err != nil {
+				return false, err
+			}
+			scenes, err := state.GetScenes(ctx)
+			if err != nil {
+				return false, err
+			}
+
+			if c.Room != "" {
+				room, err := findRoom(ctx, state, c.Room)
+				if err != nil {
+					return false, err
+				}
+				inRoom := scenes[:0:0]
+				for _, scene := range scenes {
+					if scene.Group.ResourceID == room.ID {
+						inRoom = append(inRoom, scene)
+					}
+				}
+				scenes = inRoom
+			}
+
+			scene, err := findByIDOrName(scenes, c.Scene, "scene", func(s *bridge.Scene) (string, string) {
+				return s.ID, s.Metadata.Name
+			})
+			if err != nil {
+				return false, err
+			}
+			return scene.IsActive(), nil
+		},
+	})
+
+	e.RegisterCondition(models.ConditionTypeSensorLux, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseSensorLux(config)
+			return err
+		},
+		Check: func(ctx context.Context, env *Env, config json.RawMessage) (bool, error) {
+			c, err := parseSensorLux(config)
+			if err != nil {
+				return false, err
+			}
+			state, err := env.bridge(ctx, c.Bridge)
+			if err != nil {
+				return false, err
+			}
+			sensor, err := findLightLevel(ctx, state, c.Sensor)
+			if err != nil {
+				return false, err
+			}
+			if !sensor.Enabled || (!sensor.Light.LightLevelValid && sensor.Light.Report == nil) {
+				return false, errors.Newf("light sensor %s has no valid reading", c.Sensor)
+			}
+
+			lux := sensor.Lux()
+			if c.Below != nil && lux >= *c.Below {
+				return false, nil
+			}
+			if c.Above != nil && lux <= *c.Above {
+				return false, nil
+			}
+			return true, nil
+		},
+	})
+}
+
+// bridge returns the named bridge, or the current profile's
+func (env *Env) bridge(ctx context.Context, profileOrBridgeID string) (Bridge, error) {
+	if env.Bridge == nil {
+		return nil, errors.WithHint(ErrNoBridge, "Pair one with 'limelight setup'.")
+	}
+	return env.Bridge(ctx, profileOrBridgeID)
+}
+
+func parseLightState(config json.RawMessage, conditionType models.ConditionType) (*LightStateConfig, error) {
+	var c LightStateConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+
+	target, other := c.Light, c.Room
+	field, otherField := "light", "room"
+	if conditionType == models.ConditionTypeRoomState {
+		target, other = c.Room, c.Light
+		field, otherField = "room", "light"
+	}
+	if target == "" {
+		return nil, errors.Newf("%s needs a %s", conditionType, field)
+	}
+	if other != "" {
+		return nil, errors.Newf("%s doesn't take a %s", conditionType, otherField)
+	}
+
+	if c.On == nil && c.BrightnessBelow == nil && c.BrightnessAbove == nil {
+		return nil, errors.Newf("%s needs on, brightness_below or brightness_above", conditionType)
+	}
+	for _, limit := range []*float64{c.BrightnessBelow, c.BrightnessAbove} {
+		if limit != nil && (*limit < 0 || *limit > 100) {
+			return nil, errors.Newf("brightness %g is not between 0 and 100", *limit)
+		}
+	}
+	if c.BrightnessBelow != nil && c.BrightnessAbove != nil && *c.BrightnessAbove >= *c.BrightnessBelow {
+		return nil, errors.Newf("brightness above %g and below %g never holds", *c.BrightnessAbove, *c.BrightnessBelow)
+	}
+	return &c, nil
+}
+
+func (c *LightStateConfig) matches(on bool, dimming *struct {
+	Brightness float64 `json:"brightness"`
+}) bool {
+	if c.On != nil && on != *c.On {
+		return false
+	}
+
+	brightness := 0.0
+	if on {
+		brightness = 100
+		if dimming != nil {
+			brightness = dimming.Brightness
+		}
+	}
+	if c.BrightnessBelow != nil && brightness >= *c.BrightnessBelow {
+		return false
+	}
+	if c.BrightnessAbove != nil && brightness <= *c.BrightnessAbove {
+		return false
+	}
+	return true
+}
+
+func parseSceneActive(config json.RawMessage) (*SceneActiveConfig, error) {
+	var c SceneActiveConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.Scene == "" {
+		return nil, errors.New("scene_active needs a scene")
+	}
+	return &c, nil
+}
+
+func parseSensorLux(config json.RawMessage) (*SensorLuxConfig, error) {
+	var c SensorLuxConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.Sensor == "" {
+		return nil, errors.New("sensor_lux needs a sensor")
+	}
+	if c.Below == nil && c.Above == nil {
+		return nil, errors.New("sensor_lux needs below, above or both")
+	}
+	for _, limit := range []*float64{c.Below, c.Above} {
+		if limit != nil && *limit < 0 {
+			return nil, errors.Newf("light level %g lux is negative", *limit)
+		}
+	}
+	if c.Below != nil && c.Above != nil && *c.Above >= *c.Below {
+		return nil, errors.Newf("light level above %g and below %g lux never holds", *c.Above, *c.Below)
+	}
+	return &c, nil
+}
+
+func findRoom(ctx context.Context, state BridgeState, ref string) (*bridge.Room, error) {
+	rooms, err := state.GetRooms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return findByIDOrName(rooms, ref, "room", func(r *bridge.Room) (string, string) {
+		return r.ID, r.Metadata.Name
+	})
+}
+
+// findLightLevel finds a light sensor by its own ID or by its device's ID or name
+func findLightLevel(ctx context.Context, state BridgeState, ref string) (*bridge.LightLevel, error) {
+	levels, err := state.GetLightLevels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range levels {
+		if levels[i].ID == ref {
+			return &levels[i], nil
+		}
+	}
+
+	devices, err := state.GetDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	device, err := findByIDOrName(devices, ref, "sensor", func(d *bridge.Device) (string, string) {
+		return d.ID, d.Metadata.Name
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range levels {
+		if levels[i].Owner.ResourceID == device.ID {
+			return &levels[i], nil
+		}
+	}
+	return nil, errors.Newf("device %q has no light sensor", device.Metadata.Name)
+}
+
+// findByIDOrName finds the resource with the given ID or, failing that, the only
+// one whose name matches case-insensitively
+func findByIDOrName[T any](resources []T, ref, kind string, key func(*T) (id, name string)) (*T, error) {
+	var match *T
+	matches := 0
+	for i := range resources {
+		id, name := key(&resources[i])
+		if id == ref {
+			return &resources[i], nil
+		}
+		if strings.EqualFold(name, ref) {
+			match = &resources[i]
+			matches++
+		}
+	}
+
+	switch matches {
+	case 0:
+		return nil, errors.Newf("no %s matches %q", kind, ref)
+	case 1:
+		return match, nil
+	default:
+		return nil, errors.WithHint(
+			errors.Newf("%d %ss are named %q", matches, kind, ref),
+			"Use the ID instead.",
+		)
+	}
+}
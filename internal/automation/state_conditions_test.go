@@ -0,0 +1,235 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/bridge"
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBridge serves fixed resources in the bridge's JSON form and records changes
+type fakeBridge struct {
+	lights      []bridge.Light
+	rooms       []bridge.Room
+	groups      []bridge.GroupedLight
+	scenes      []bridge.Scene
+	devices     []bridge.Device
+	lightLevels []bridge.LightLevel
+
+	mu      sync.Mutex
+	changes []string
+}
+
+func (f *fakeBridge) GetLights(context.Context) ([]bridge.Light, error) { return f.lights, nil }
+func (f *fakeBridge) GetRooms(context.Context) ([]bridge.Room, error)   { return f.rooms, nil }
+func (f *fakeBridge) GetGroupedLights(context.Context) ([]bridge.GroupedLight, error) {
+	return f.groups, nil
+}
+func (f *fakeBridge) GetScenes(context.Context) ([]bridge.Scene, error)   { return f.scenes, nil }
+func (f *fakeBridge) GetDevices(context.Context) ([]bridge.Device, error) { return f.devices, nil }
+func (f *fakeBridge) GetLightLevels(context.Context) ([]bridge.LightLevel, error) {
+	return f.lightLevels, nil
+}
+
+func (f *fakeBridge) UpdateLight(_ context.Context, lightID string, req bridge.LightUpdateRequest) error {
+	data, _ := json.Marshal(req)
+	f.record("light " + lightID + " " + string(data))
+	return nil
+}
+
+func (f *fakeBridge) UpdateGroupedLight(_ context.Context, groupedLightID string, req bridge.LightUpdateRequest) error {
+	data, _ := json.Marshal(req)
+	f.record("group " + groupedLightID + " " + string(data))
+	return nil
+}
+
+func (f *fakeBridge) ActivateScene(_ context.Context, sceneID string) error {
+	f.record("scene " + sceneID)
+	return nil
+}
+
+func (f *fakeBridge) IdentifyLight(_ context.Context, lightID string) error {
+	f.record("identify " + lightID)
+	return nil
+}
+
+func (f *fakeBridge) record(change string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.changes = append(f.changes, change)
+}
+
+func (f *fakeBridge) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.changes...)
+}
+
+func newFakeBridge(t *testing.T) *fakeBridge {
+	f := &fakeBridge{}
+	decode := func(data string, out interface{}) {
+		require.NoError(t, json.Unmarshal([]byte(data), out))
+	}
+
+	decode(`[
+		{"id": "l1", "metadata": {"name": "Desk"}, "on": {"on": true}, "dimming": {"brightness": 60},
+		 "effects": {"status": "no_effect", "effect_values": ["no_effect", "candle", "fire"]},
+		 "timed_effects": {"status": "no_effect", "effect_values": ["no_effect", "sunrise", "sunset"]}},
+		{"id": "l2", "metadata": {"name": "Hallway"}, "on": {"on": false}, "dimming": {"brightness": 80}},
+		{"id": "l3", "metadata": {"name": "Plug"}, "on": {"on": true}},
+		{"id": "l4", "metadata": {"name": "Lamp"}, "on": {"on": true},
+		 "gradient": {"points_capable": 3, "mode_values": ["interpolated_palette", "interpolated_palette_mirrored"]}},
+		{"id": "l5", "metadata": {"name": "lamp"}, "on": {"on": false}}
+	]`, &f.lights)
+	decode(`[
+		{"id": "r1", "metadata": {"name": "Office"}},
+		{"id": "r2", "metadata": {"name": "Living room"}}
+	]`, &f.rooms)
+	decode(`[
+		{"id": "g1", "owner": {"rid": "r1", "rtype": "room"}, "on": {"on": true}, "dimming": {"brightness": 25}},
+		{"id": "g2", "owner": {"rid": "r2", "rtype": "room"}, "on": {"on": false}, "dimming": {"brightness": 0}}
+	]`, &f.groups)
+	decode(`[
+		{"id": "s1", "metadata": {"name": "Relax"}, "group": {"rid": "r1", "rtype": "room"}, "status": {"active": "static"}},
+		{"id": "s2", "metadata": {"name": "Relax"}, "group": {"rid": "r2", "rtype": "room"}, "status": {"active": "inactive"}},
+		{"id": "s3", "metadata": {"name": "Focus"}, "group": {"rid": "r1", "rtype": "room"}, "status": {"active": "inactive"}}
+	]`, &f.scenes)
+	decode(`[
+		{"id": "d1", "metadata": {"name": "Hallway sensor"}},
+		{"id": "d2", "metadata": {"name": "Porch sensor"}}
+	]`, &f.devices)
+	decode(`[
+		{"id": "ll1", "owner": {"rid": "d1", "rtype": "device"}, "enabled": true,
+		 "light": {"light_level": 10001, "light_level_valid": true}},
+		{"id": "ll2", "owner": {"rid": "d2", "rtype": "device"}, "enabled": false,
+		 "light": {"light_level": 0, "light_level_valid": false}}
+	]`, &f.lightLevels)
+	return f
+}
+
+func bridgeEnv(t *testing.T) *Env {
+	env, _ := bridgeEnvWithFake(t)
+	return env
+}
+
+func bridgeEnvWithFake(t *testing.T) (*Env, *fakeBridge) {
+	f := newFakeBridge(t)
+	env := envAt(t, "2026-10-19 20:00")
+	env.Bridge = func(_ context.Context, profileOrBridgeID string) (Bridge, error) {
+		if profileOrBridgeID != "" && profileOrBridgeID != "home" {
+			return nil, errors.Newf("no profile or bridge ID matches %s", profileOrBridgeID)
+		}
+		return f, nil
+	}
+	return env, f
+}
+
+func TestWithState(t *testing.T) {
+	env, control := bridgeEnvWithFake(t)
+	state := newFakeBridge(t)
+	state.lights[1].On.On = true
+	env.Bridge = func(context.Context, string) (Bridge, error) {
+		return WithState(control, state), nil
+	}
+
+	assert.True(t, check(t, env, models.ConditionTypeLightState, `{"light": "Hallway", "on": true}`), "state is read from the cache")
+
+	actions := parseActions(t, `[{"type": "light", "config": {"light_id": "l2", "on": false}}]`)
+	require.NoError(t, NewEngine().RunActions(context.Background(), env, actions))
+	assert.Equal(t, []string{`light l2 {"on":{"on":false}}`}, control.recorded())
+	assert.Empty(t, state.recorded())
+}
+
+func TestLightStateCondition(t *testing.T) {
+	env := bridgeEnv(t)
+
+	tests := []struct {
+		name   string
+		config string
+		want   bool
+	}{
+		{"on by name", `{"light": "desk", "on": true}`, true},
+		{"on by ID", `{"light": "l2", "on": true}`, false},
+		{"off", `{"light": "Hallway", "on": false}`, true},
+		{"dimmed", `{"light": "Desk", "brightness_below": 75}`, true},
+		{"brighter than", `{"light": "Desk", "brightness_above": 60}`, false},
+		{"off light has no brightness", `{"light": "Hallway", "brightness_below": 1}`, true},
+		{"undimmable light is full", `{"light": "Plug", "brightness_above": 99}`, true},
+		{"named bridge", `{"light": "Desk", "bridge": "home", "on": true}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, check(t, env, models.ConditionTypeLightState, tt.config))
+		})
+	}
+
+	engine := NewEngine()
+	ctx := context.Background()
+	_, err := engine.CheckCondition(ctx, env, models.ConditionTypeLightState, json.RawMessage(`{"light": "lamp", "on": true}`))
+	assert.ErrorContains(t, err, `2 lights are named "lamp"`)
+	_, err = engine.CheckCondition(ctx, env, models.ConditionTypeLightState, json.RawMessage(`{"light": "Garage", "on": true}`))
+	assert.ErrorContains(t, err, `no light matches "Garage"`)
+	_, err = engine.CheckCondition(ctx, env, models.ConditionTypeLightState, json.RawMessage(`{"light": "Desk", "bridge": "office", "on": true}`))
+	assert.Error(t, err)
+
+	for _, config := range []string{
+		`{"light": "Desk"}`,
+		`{"on": true}`,
+		`{"light": "Desk", "room": "Office", "on": true}`,
+		`{"light": "Desk", "brightness_below": 120}`,
+		`{"light": "Desk", "brightness_above": 50, "brightness_below": 40}`,
+	} {
+		assert.Error(t, engine.ValidateCondition(models.ConditionTypeLightState, json.RawMessage(config)), config)
+	}
+}
+
+func TestRoomStateCondition(t *testing.T) {
+	env := bridgeEnv(t)
+
+	assert.True(t, check(t, env, models.ConditionTypeRoomState, `{"room": "office", "brightness_below": 30}`))
+	assert.False(t, check(t, env, models.ConditionTypeRoomState, `{"room": "Office", "brightness_below": 20}`))
+	assert.True(t, check(t, env, models.ConditionTypeRoomState, `{"room": "r2", "on": false}`))
+
+	assert.Error(t, NewEngine().ValidateCondition(models.ConditionTypeRoomState, json.RawMessage(`{"light": "Desk", "on": true}`)))
+}
+
+func TestSceneActiveCondition(t *testing.T) {
+	env := bridgeEnv(t)
+
+	assert.True(t, check(t, env, models.ConditionTypeSceneActive, `{"scene": "s1"}`))
+	assert.False(t, check(t, env, models.ConditionTypeSceneActive, `{"scene": "Focus"}`))
+	assert.True(t, check(t, env, models.ConditionTypeSceneActive, `{"scene": "Relax", "room": "Office"}`))
+	assert.False(t, check(t, env, models.ConditionTypeSceneActive, `{"scene": "relax", "room": "Living room"}`))
+
+	// Scene names repeat across rooms
+	_, err := NewEngine().CheckCondition(context.Background(), env, models.ConditionTypeSceneActive, json.RawMessage(`{"scene": "Relax"}`))
+	assert.ErrorContains(t, err, `2 scenes are named "Relax"`)
+}
+
+func TestSensorLuxCondition(t *testing.T) {
+	env := bridgeEnv(t)
+
+	// A light level of 10001 is 10 lux
+	assert.True(t, check(t, env, models.ConditionTypeSensorLux, `{"sensor": "Hallway sensor", "below": 20}`))
+	assert.False(t, check(t, env, models.ConditionTypeSensorLux, `{"sensor": "ll1", "below": 5}`))
+	assert.True(t, check(t, env, models.ConditionTypeSensorLux, `{"sensor": "d1", "above": 5, "below": 15}`))
+
+	_, err := NewEngine().CheckCondition(context.Background(), env, models.ConditionTypeSensorLux, json.RawMessage(`{"sensor": "Porch sensor", "below": 20}`))
+	assert.ErrorContains(t, err, "no valid reading")
+
+	for _, config := range []string{`{"sensor": "d1"}`, `{"below": 10}`, `{"sensor": "d1", "above": 20, "below": 10}`} {
+		assert.Error(t, NewEngine().ValidateCondition(models.ConditionTypeSensorLux, json.RawMessage(config)), config)
+	}
+}
+
+func TestStateConditionWithoutBridge(t *testing.T) {
+	_, err := NewEngine().CheckCondition(context.Background(), envAt(t, "2026-10-19 20:00"),
+		models.ConditionTypeLightState, json.RawMessage(`{"light": "Desk", "on": true}`))
+	assert.True(t, errors.Is(err, ErrNoBridge))
+}
@@ -0,0 +1,196 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/astro"
+	"github.com/mithilarun/limelight/internal/db/models"
+)
+
+// ErrNoHome is returned when a sun condition is evaluated without a home location
+var ErrNoHome = errors.New("no home location is set")
+
+// horizonElevation is the solar elevation at sunrise and sunset, matching astro
+const horizonElevation = -0.833
+
+const clockLayout = "15:04"
+
+// TimeWindowConfig is the config of time_window conditions. The window starts
+// at Start, inclusive, and ends at End, exclusive, both on the home's clock.
+// A window whose end is earlier than its start crosses midnight.
+type TimeWindowConfig struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// SunOffsetConfig is the config of after_sunset and before_sunrise conditions.
+// after_sunset holds from sunset, moved by the offset, until midnight, and
+// before_sunrise from midnight until sunrise, moved by the offset; use both in
+// an any group for "while it's dark". On days without a sunset or sunrise they
+// hold whenever the sun is below the horizon.
+type SunOffsetConfig struct {
+	// OffsetMinutes moves the event, negative for earlier
+	OffsetMinutes int `json:"offset_minutes,omitempty"`
+}
+
+// SunElevationConfig is the config of sun_elevation conditions, which hold while
+// the sun's elevation in degrees is above Above and below Below. At least one is required.
+type SunElevationConfig struct {
+	Above *float64 `json:"above,omitempty"`
+	Below *float64 `json:"below,omitempty"`
+}
+
+func (e *Engine) registerTimeConditions() {
+	e.RegisterCondition(models.ConditionTypeTimeWindow, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, _, err := parseTimeWindow(config)
+			return err
+		},
+		Check: func(_ context.Context, env *Env, config json.RawMessage) (bool, error) {
+			start, end, err := parseTimeWindow(config)
+			if err != nil {
+				return false, err
+			}
+			now := minuteOfDay(env.Now)
+			if start < end {
+				return now >= start && now < end, nil
+			}
+			return now >= start || now < end, nil
+		},
+	})
+
+	e.RegisterCondition(models.ConditionTypeAfterSunset, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			var c SunOffsetConfig
+			return decodeConfig(config, &c)
+		},
+		Check: func(_ context.Context, env *Env, config json.RawMessage) (bool, error) {
+			return checkSunOffset(env, config, astro.SunEventSunset)
+		},
+	})
+	e.RegisterCondition(models.ConditionTypeBeforeSunrise, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			var c SunOffsetConfig
+			return decodeConfig(config, &c)
+		},
+		Check: func(_ context.Context, env *Env, config json.RawMessage) (bool, error) {
+			return checkSunOffset(env, config, astro.SunEventSunrise)
+		},
+	})
+
+	e.RegisterCondition(models.ConditionTypeSunElevation, ConditionHandler{
+		Validate: func(config json.RawMessage) error {
+			_, err := parseSunElevation(config)
+			return err
+		},
+		Check: func(_ context.Context, env *Env, config json.RawMessage) (bool, error) {
+			c, err := parseSunElevation(config)
+			if err != nil {
+				return false, err
+			}
+			elevation, err := sunElevation(env)
+			if err != nil {
+				return false, err
+			}
+			if c.Above != nil && elevation <= *c.Above {
+				return false, nil
+			}
+			if c.Below != nil && elevation >= *c.Below {
+				return false, nil
+			}
+			return true, nil
+		},
+	})
+}
+
+func parseTimeWindow(config json.RawMessage) (start, end int, err error) {
+	var c TimeWindowConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return 0, 0, err
+	}
+
+	startTime, err := time.Parse(clockLayout, c.Start)
+	if err != nil {
+		return 0, 0, errors.Newf("invalid start time %q (expected HH:MM)", c.Start)
+	}
+	endTime, err := time.Parse(clockLayout, c.End)
+	if err != nil {
+		return 0, 0, errors.Newf("invalid end time %q (expected HH:MM)", c.End)
+	}
+
+	start, end = minuteOfDay(startTime), minuteOfDay(endTime)
+	if start == end {
+		return 0, 0, errors.Newf("time window %s to %s is empty", c.Start, c.End)
+	}
+	return start, end, nil
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+func checkSunOffset(env *Env, config json.RawMessage, event astro.SunEvent) (bool, error) {
+	var c SunOffsetConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return false, err
+	}
+	if env.Home == nil {
+		return false, noHome(event)
+	}
+
+	eventTime, err := env.Home.SunEvent(env.Now, event)
+	if err != nil {
+		// Polar day or night, so go by whether the sun is up at all
+		elevation, err := sunElevation(env)
+		if err != nil {
+			return false, err
+		}
+		return elevation < horizonElevation, nil
+	}
+
+	eventTime = eventTime.Add(time.Duration(c.OffsetMinutes) * time.Minute)
+	if event == astro.SunEventSunset {
+		return !env.Now.Before(eventTime), nil
+	}
+	return env.Now.Before(eventTime), nil
+}
+
+func parseSunElevation(config json.RawMessage) (*SunElevationConfig, error) {
+	var c SunElevationConfig
+	if err := decodeConfig(config, &c); err != nil {
+		return nil, err
+	}
+	if c.Above == nil && c.Below == nil {
+		return nil, errors.New("sun_elevation needs above, below or both")
+	}
+	for _, limit := range []*float64{c.Above, c.Below} {
+		if limit != nil && (*limit < -90 || *limit > 90) {
+			return nil, errors.Newf("sun elevation %g is not between -90 and 90", *limit)
+		}
+	}
+	if c.Above != nil && c.Below != nil && *c.Above >= *c.Below {
+		return nil, errors.Newf("sun elevation above %g and below %g never holds", *c.Above, *c.Below)
+	}
+	return &c, nil
+}
+
+func sunElevation(env *Env) (float64, error) {
+	if env.Home == nil {
+		return 0, noHome("sun elevation")
+	}
+	position, err := astro.CalculateSolarPosition(env.Home.Latitude, env.Home.Longitude, env.Now)
+	if err != nil {
+		return 0, err
+	}
+	return position.Elevation, nil
+}
+
+func noHome(what interface{}) error {
+	return errors.WithHint(
+		errors.Wrapf(ErrNoHome, "%v needs the home location", what),
+		"Set it with 'limelight location set'.",
+	)
+}
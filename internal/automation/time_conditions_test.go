@@ -0,0 +1,109 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/astro"
+	"github.com/mithilarun/limelight/internal/db/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testHome(t *testing.T, latitude, longitude float64, zone string) *astro.Home {
+	location, err := time.LoadLocation(zone)
+	require.NoError(t, err)
+	return &astro.Home{Latitude: latitude, Longitude: longitude, TimeZone: location}
+}
+
+func TestTimeWindowCondition(t *testing.T) {
+	tests := []struct {
+		name   string
+		now    string
+		config string
+		want   bool
+	}{
+		{"inside", "2026-10-19 12:30", `{"start": "09:00", "end": "17:00"}`, true},
+		{"start is inclusive", "2026-10-19 09:00", `{"start": "09:00", "end": "17:00"}`, true},
+		{"end is exclusive", "2026-10-19 17:00", `{"start": "09:00", "end": "17:00"}`, false},
+		{"late evening in overnight window", "2026-10-19 23:15", `{"start": "22:00", "end": "06:00"}`, true},
+		{"early morning in overnight window", "2026-10-19 05:59", `{"start": "22:00", "end": "06:00"}`, true},
+		{"afternoon outside overnight window", "2026-10-19 14:00", `{"start": "22:00", "end": "06:00"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, check(t, envAt(t, tt.now), models.ConditionTypeTimeWindow, tt.config))
+		})
+	}
+
+	engine := NewEngine()
+	for _, config := range []string{`{"start": "9am", "end": "17:00"}`, `{"start": "09:00", "end": "09:00"}`, `{"start": "09:00"}`} {
+		assert.Error(t, engine.ValidateCondition(models.ConditionTypeTimeWindow, json.RawMessage(config)), config)
+	}
+}
+
+func TestSunOffsetConditions(t *testing.T) {
+	home := testHome(t, 37.7749, -122.4194, "America/Los_Angeles")
+	day := home.Date(2026, time.June, 21)
+
+	sunset, err := home.SunEvent(day, astro.SunEventSunset)
+	require.NoError(t, err)
+	sunrise, err := home.SunEvent(day, astro.SunEventSunrise)
+	require.NoError(t, err)
+
+	at := func(tm time.Time) *Env { return &Env{Now: tm, Home: home} }
+
+	assert.False(t, check(t, at(sunset.Add(-time.Minute)), models.ConditionTypeAfterSunset, `{}`))
+	assert.True(t, check(t, at(sunset.Add(time.Minute)), models.ConditionTypeAfterSunset, `{}`))
+	assert.True(t, check(t, at(sunset.Add(-20*time.Minute)), models.ConditionTypeAfterSunset, `{"offset_minutes": -30}`))
+	assert.False(t, check(t, at(day.Add(2*time.Hour)), models.ConditionTypeAfterSunset, `{}`), "after sunset ends at midnight")
+
+	assert.True(t, check(t, at(sunrise.Add(-time.Minute)), models.ConditionTypeBeforeSunrise, `{}`))
+	assert.False(t, check(t, at(sunrise.Add(time.Minute)), models.ConditionTypeBeforeSunrise, `{}`))
+	assert.True(t, check(t, at(sunrise.Add(10*time.Minute)), models.ConditionTypeBeforeSunrise, `{"offset_minutes": 15}`))
+
+	dark := `{"conditions": [{"type": "after_sunset"}, {"type": "before_sunrise"}]}`
+	assert.True(t, check(t, at(day.Add(2*time.Hour)), models.ConditionTypeAny, dark))
+	assert.False(t, check(t, at(day.Add(12*time.Hour)), models.ConditionTypeAny, dark))
+}
+
+func TestSunOffsetConditionsAtPolarLatitudes(t *testing.T) {
+	tromso := testHome(t, 69.6492, 18.9553, "Europe/Oslo")
+
+	polarNight := &Env{Now: time.Date(2026, time.December, 21, 12, 0, 0, 0, tromso.TimeZone), Home: tromso}
+	assert.True(t, check(t, polarNight, models.ConditionTypeAfterSunset, `{}`))
+	assert.True(t, check(t, polarNight, models.ConditionTypeBeforeSunrise, `{}`))
+
+	midnightSun := &Env{Now: time.Date(2026, time.June, 21, 23, 30, 0, 0, tromso.TimeZone), Home: tromso}
+	assert.False(t, check(t, midnightSun, models.ConditionTypeAfterSunset, `{}`))
+}
+
+func TestSunElevationCondition(t *testing.T) {
+	home := testHome(t, 37.7749, -122.4194, "America/Los_Angeles")
+	noon := &Env{Now: time.Date(2026, time.June, 21, 13, 0, 0, 0, home.TimeZone), Home: home}
+	midnight := &Env{Now: time.Date(2026, time.June, 21, 0, 30, 0, 0, home.TimeZone), Home: home}
+
+	assert.True(t, check(t, noon, models.ConditionTypeSunElevation, `{"above": 30}`))
+	assert.False(t, check(t, midnight, models.ConditionTypeSunElevation, `{"above": 30}`))
+	assert.True(t, check(t, midnight, models.ConditionTypeSunElevation, `{"below": -6}`))
+	assert.False(t, check(t, noon, models.ConditionTypeSunElevation, `{"above": -6, "below": 10}`))
+
+	engine := NewEngine()
+	for _, config := range []string{`{}`, `{"above": 10, "below": 5}`, `{"below": 95}`} {
+		assert.Error(t, engine.ValidateCondition(models.ConditionTypeSunElevation, json.RawMessage(config)), config)
+	}
+}
+
+func TestSunConditionsNeedHome(t *testing.T) {
+	engine := NewEngine()
+	env := envAt(t, "2026-06-21 22:00")
+
+	_, err := engine.CheckCondition(context.Background(), env, models.ConditionTypeAfterSunset, nil)
+	assert.True(t, errors.Is(err, ErrNoHome))
+	_, err = engine.CheckCondition(context.Background(), env, models.ConditionTypeSunElevation, json.RawMessage(`{"below": 0}`))
+	assert.True(t, errors.Is(err, ErrNoHome))
+}
@@ -20,7 +20,8 @@ type authRequest struct {
 
 type authResponseItem struct {
 	Success *struct {
-		Username string `json:"username"`
+		Username  string `json:"username"`
+		ClientKey string `json:"clientkey"`
 	} `json:"success,omitempty"`
 	Error *struct {
 		Type        int    `json:"type"`
@@ -35,51 +36,70 @@ const (
 	authMaxRetries                = 30
 )
 
-func (c *Client) Authenticate(ctx context.Context, appName string) (string, error) {
+// ErrLinkButtonNotPressed marks pairing that gave up before the bridge's link button was pressed
+var ErrLinkButtonNotPressed = errors.New("link button not pressed")
+
+// AuthResult holds the credentials issued by the bridge when pairing
+type AuthResult struct {
+	// Username is the application key sent in the hue-application-key header
+	Username string
+	// ClientKey is the hex-encoded pre-shared key for entertainment streaming
+	ClientKey string
+}
+
+// Authenticate pairs with the bridge once its link button is pressed, requesting
+// both an application key and an entertainment client key
+func (c *Client) Authenticate(ctx context.Context, appName string) (*AuthResult, error) {
 	url := fmt.Sprintf("https://%s/api", c.bridgeIP)
 
 	reqBody := authRequest{
 		DeviceType:        appName,
-		GenerateClientKey: false,
+		GenerateClientKey: true,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", errors.Wrap(err, "marshaling auth request")
+		return nil, errors.Wrap(err, "marshaling auth request")
 	}
 
 	var lastError error
 	for i := 0; i < authMaxRetries; i++ {
 		select {
 		case <-ctx.Done():
-			return "", errors.Wrap(ctx.Err(), "authentication cancelled")
+			if lastError != nil {
+				return nil, linkButtonTimeout(lastError)
+			}
+			return nil, errors.Wrap(ctx.Err(), "authentication cancelled")
 		default:
 		}
 
 		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
 		if err != nil {
-			return "", errors.Wrap(err, "creating auth request")
+			return nil, errors.Wrap(err, "creating auth request")
 		}
 		req.Header.Set("Content-Type", "application/json")
 
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
-			return "", errors.Wrap(err, "executing auth request")
+			if lastError != nil && ctx.Err() != nil {
+				return nil, linkButtonTimeout(lastError)
+			}
+			return nil, errors.Mark(errors.Wrap(err, "executing auth request"), ErrBridgeUnreachable)
 		}
-		defer resp.Body.Close()
 
 		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
-			return "", errors.Wrap(err, "reading auth response")
+			return nil, errors.Wrap(err, "reading auth response")
 		}
 
 		var authResp []authResponseItem
 		if err := json.Unmarshal(respBody, &authResp); err != nil {
-			return "", errors.Wrap(err, "unmarshaling auth response")
+			return nil, errors.Wrap(err, "unmarshaling auth response")
 		}
 
 		if len(authResp) == 0 {
-			return "", errors.New("empty auth response")
+			return nil, errors.New("empty auth response")
 		}
 
 		item := authResp[0]
@@ -88,7 +108,10 @@ func (c *Client) Authenticate(ctx context.Context, appName string) (string, erro
 			c.logger.Info("authentication successful",
 				zap.String("username", item.Success.Username),
 			)
-			return item.Success.Username, nil
+			return &AuthResult{
+				Username:  item.Success.Username,
+				ClientKey: item.Success.ClientKey,
+			}, nil
 		}
 
 		if item.Error != nil {
@@ -100,21 +123,25 @@ func (c *Client) Authenticate(ctx context.Context, appName string) (string, erro
 				lastError = errors.Newf("link button not pressed: %s", item.Error.Description)
 				select {
 				case <-ctx.Done():
-					return "", errors.Wrap(ctx.Err(), "authentication cancelled")
+					return nil, linkButtonTimeout(lastError)
 				case <-time.After(authRetryInterval):
 				}
 				continue
 			}
-			return "", errors.Newf("hue auth error: type=%d, description=%s", item.Error.Type, item.Error.Description)
+			return nil, errors.Newf("hue auth error: type=%d, description=%s", item.Error.Type, item.Error.Description)
 		}
 
 		if item.Success == nil && item.Error == nil {
-			return "", errors.New("malformed auth response: both success and error are nil")
+			return nil, errors.New("malformed auth response: both success and error are nil")
 		}
 	}
 
 	if lastError != nil {
-		return "", errors.Wrap(lastError, "authentication timed out")
+		return nil, linkButtonTimeout(lastError)
 	}
-	return "", errors.New("authentication failed")
+	return nil, errors.New("authentication failed")
+}
+
+func linkButtonTimeout(lastError error) error {
+	return errors.Mark(errors.Wrap(lastError, "authentication timed out"), ErrLinkButtonNotPressed)
 }
@@ -0,0 +1,590 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// ChangeReload is the kind of change sent when the cache has reloaded
+	// every resource, after connecting to the event stream or when a
+	// subscriber missed changes
+	ChangeReload = "reload"
+
+	// subscriberBuffer is how many changes a subscriber can fall behind by
+	// before it misses some and is sent a reload instead
+	subscriberBuffer = 64
+
+	cacheMinRetry = time.Second
+	cacheMaxRetry = time.Minute
+)
+
+// ErrCacheNotLoaded is returned by a StateCache read before the first load
+var ErrCacheNotLoaded = errors.New("state cache not loaded")
+
+// Change is a resource the cache saw change
+type Change struct {
+	// Kind is EventAdd, EventUpdate, EventDelete or ChangeReload
+	Kind string
+	// Type and ID name the resource, such as "light"; both are empty for a reload
+	Type string
+	ID   string
+}
+
+// StateCache mirrors the state of a bridge's lights, rooms, grouped lights,
+// scenes, devices and light level sensors. Run loads everything once and
+// applies the bridge's events from then on, so reads don't ask the bridge.
+// Resources come back as copies that don't change with the cache.
+type StateCache struct {
+	client *Client
+	logger *zap.Logger
+
+	mu            sync.RWMutex
+	loaded        bool
+	lights        *resourceCache[Light]
+	rooms         *resourceCache[Room]
+	groupedLights *resourceCache[GroupedLight]
+	scenes        *resourceCache[Scene]
+	devices       *resourceCache[Device]
+	lightLevels   *resourceCache[LightLevel]
+
+	subMu       sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	ch chan Change
+	// missed is set when a change didn't fit, until a reload is sent
+	missed bool
+}
+
+// NewStateCache creates an empty cache of client's bridge; call Load or Run to fill it
+func NewStateCache(client *Client, logger *zap.Logger) *StateCache {
+	return &StateCache{
+		client:      client,
+		logger:      logger,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Load reads every cached resource from the bridge, replacing what the cache holds
+func (c *StateCache) Load(ctx context.Context) error {
+	lights, err := c.client.GetLights(ctx)
+	if err != nil {
+		return err
+	}
+	rooms, err := c.client.GetRooms(ctx)
+	if err != nil {
+		return err
+	}
+	groupedLights, err := c.client.GetGroupedLights(ctx)
+	if err != nil {
+		return err
+	}
+	scenes, err := c.client.GetScenes(ctx)
+	if err != nil {
+		return err
+	}
+	devices, err := c.client.GetDevices(ctx)
+	if err != nil {
+		return err
+	}
+	lightLevels, err := c.client.GetLightLevels(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.lights = newResourceCache(lights, func(l *Light) string { return l.ID })
+	c.rooms = newResourceCache(rooms, func(r *Room) string { return r.ID })
+	c.groupedLights = newResourceCache(groupedLights, func(g *GroupedLight) string { return g.ID })
+	c.scenes = newResourceCache(scenes, func(s *Scene) string { return s.ID })
+	c.devices = newResourceCache(devices, func(d *Device) string { return d.ID })
+	c.lightLevels = newResourceCache(lightLevels, func(l *LightLevel) string { return l.ID })
+	c.loaded = true
+	c.mu.Unlock()
+
+	c.logger.Debug("state cache loaded",
+		zap.Int("lights", len(lights)),
+		zap.Int("rooms", len(rooms)),
+		zap.Int("scenes", len(scenes)),
+	)
+	c.notify(Change{Kind: ChangeReload})
+	return nil
+}
+
+// Run keeps the cache current until ctx is done. It connects to the event
+// stream, loads everything, and applies events as they come; if the
+// connection drops it reconnects and loads everything again, waiting longer
+// after each failure.
+func (c *StateCache) Run(ctx context.Context) error {
+	retry := cacheMinRetry
+	for {
+		connected, err := c.follow(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if connected {
+			retry = cacheMinRetry
+		}
+		c.logger.Warn("state cache lost the bridge, reconnecting",
+			zap.Error(err),
+			zap.Duration("retry_in", retry),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, cacheMaxRetry)
+	}
+}
+
+// follow loads the cache and applies events until the stream fails. It
+// reports whether it got as far as loading.
+func (c *StateCache) follow(ctx context.Context) (bool, error) {
+	stream, err := c.client.OpenEventStream(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer stream.Close()
+
+	// Loading after connecting means a change made during the load is either
+	// in what was loaded or in an event still to be read
+	if err := c.Load(ctx); err != nil {
+		return false, err
+	}
+
+	for {
+		events, err := stream.Next()
+		if err != nil {
+			return true, err
+		}
+		c.Apply(events)
+	}
+}
+
+// Apply updates the cache from events of the bridge's event stream and
+// notifies subscribers of each resource that changed. Events about
+// resource types the cache doesn't hold are ignored.
+func (c *StateCache) Apply(events []Event) {
+	for _, event := range events {
+		if event.Type == EventError {
+			continue
+		}
+		for _, data := range event.Data {
+			ref, err := parseResourceRef(data)
+			if err != nil {
+				c.logger.Warn("skipping bridge event", zap.Error(err))
+				continue
+			}
+
+			changed, err := c.apply(event.Type, ref, data)
+			if err != nil {
+				c.logger.Warn("skipping bridge event",
+					zap.String("type", ref.Type),
+					zap.String("id", ref.ID),
+					zap.Error(err),
+				)
+				continue
+			}
+			if changed {
+				c.notify(Change{Kind: event.Type, Type: ref.Type, ID: ref.ID})
+			}
+		}
+	}
+}
+
+func (c *StateCache) apply(kind string, ref resourceRef, data json.RawMessage) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loaded {
+		return false, nil
+	}
+	switch ref.Type {
+	case "light":
+		return c.lights.apply(kind, ref.ID, data)
+	case "room":
+		return c.rooms.apply(kind, ref.ID, data)
+	case "grouped_light":
+		return c.groupedLights.apply(kind, ref.ID, data)
+	case "scene":
+		return c.scenes.apply(kind, ref.ID, data)
+	case "device":
+		return c.devices.apply(kind, ref.ID, data)
+	case "light_level":
+		return c.lightLevels.apply(kind, ref.ID, data)
+	}
+	return false, nil
+}
+
+// Subscribe returns a channel of the changes the cache applies from now on,
+// and a function that ends the subscription. A subscriber that falls too far
+// behind misses changes and is sent a ChangeReload once there is room, after
+// which it should read everything it depends on again.
+func (c *StateCache) Subscribe() (<-chan Change, func()) {
+	s := &subscriber{ch: make(chan Change, subscriberBuffer)}
+
+	c.subMu.Lock()
+	c.subscribers[s] = struct{}{}
+	c.subMu.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			c.subMu.Lock()
+			delete(c.subscribers, s)
+			c.subMu.Unlock()
+			close(s.ch)
+		})
+	}
+}
+
+func (c *StateCache) notify(change Change) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+
+	for s := range c.subscribers {
+		if s.missed && change.Kind != ChangeReload {
+			select {
+			case s.ch <- Change{Kind: ChangeReload}:
+				s.missed = false
+			default:
+				continue
+			}
+		}
+		select {
+		case s.ch <- change:
+			if change.Kind == ChangeReload {
+				s.missed = false
+			}
+		default:
+			s.missed = true
+		}
+	}
+}
+
+// Loaded reports whether the cache has loaded the bridge's resources
+func (c *StateCache) Loaded() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loaded
+}
+
+// Light returns a light by ID
+func (c *StateCache) Light(id string) (Light, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return Light{}, false
+	}
+	return c.lights.get(id)
+}
+
+// LightByName returns a light by name, ignoring case
+func (c *StateCache) LightByName(name string) (Light, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return Light{}, false
+	}
+	return c.lights.find(func(l *Light) bool { return strings.EqualFold(l.Metadata.Name, name) })
+}
+
+// Lights returns every light, in the bridge's order
+func (c *StateCache) Lights() []Light {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return nil
+	}
+	return c.lights.all()
+}
+
+// Room returns a room by ID
+func (c *StateCache) Room(id string) (Room, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return Room{}, false
+	}
+	return c.rooms.get(id)
+}
+
+// RoomByName returns a room by name, ignoring case
+func (c *StateCache) RoomByName(name string) (Room, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return Room{}, false
+	}
+	return c.rooms.find(func(r *Room) bool { return strings.EqualFold(r.Metadata.Name, name) })
+}
+
+// Rooms returns every room
+func (c *StateCache) Rooms() []Room {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return nil
+	}
+	return c.rooms.all()
+}
+
+// RoomLights returns the lights of a room's devices
+func (c *StateCache) RoomLights(roomID string) []Light {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return nil
+	}
+	room, ok := c.rooms.get(roomID)
+	if !ok {
+		return nil
+	}
+
+	var lights []Light
+	for _, light := range c.lights.all() {
+		for _, child := range room.Children {
+			if child.ResourceID == light.Owner.ResourceID || child.ResourceID == light.ID {
+				lights = append(lights, light)
+				break
+			}
+		}
+	}
+	return lights
+}
+
+// RoomGroupedLight returns the grouped light that controls a room's lights together
+func (c *StateCache) RoomGroupedLight(roomID string) (GroupedLight, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return GroupedLight{}, false
+	}
+	return c.groupedLights.find(func(g *GroupedLight) bool { return g.Owner.ResourceID == roomID })
+}
+
+// RoomScenes returns the scenes of a room
+func (c *StateCache) RoomScenes(roomID string) []Scene {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return nil
+	}
+	var scenes []Scene
+	for _, scene := range c.scenes.all() {
+		if scene.Group.ResourceID == roomID {
+			scenes = append(scenes, scene)
+		}
+	}
+	return scenes
+}
+
+// GroupedLight returns a grouped light by ID
+func (c *StateCache) GroupedLight(id string) (GroupedLight, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return GroupedLight{}, false
+	}
+	return c.groupedLights.get(id)
+}
+
+// Scene returns a scene by ID
+func (c *StateCache) Scene(id string) (Scene, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return Scene{}, false
+	}
+	return c.scenes.get(id)
+}
+
+// Device returns a device by ID
+func (c *StateCache) Device(id string) (Device, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return Device{}, false
+	}
+	return c.devices.get(id)
+}
+
+// LightLevel returns a light level sensor by ID
+func (c *StateCache) LightLevel(id string) (LightLevel, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return LightLevel{}, false
+	}
+	return c.lightLevels.get(id)
+}
+
+// The Get methods read the cache the way the Client methods of the same
+// names read the bridge, so a cache can stand in for a client's state
+
+func (c *StateCache) GetLights(context.Context) ([]Light, error) {
+	return cacheAll(c, func() []Light { return c.lights.all() })
+}
+
+func (c *StateCache) GetRooms(context.Context) ([]Room, error) {
+	return cacheAll(c, func() []Room { return c.rooms.all() })
+}
+
+func (c *StateCache) GetGroupedLights(context.Context) ([]GroupedLight, error) {
+	return cacheAll(c, func() []GroupedLight { return c.groupedLights.all() })
+}
+
+func (c *StateCache) GetScenes(context.Context) ([]Scene, error) {
+	return cacheAll(c, func() []Scene { return c.scenes.all() })
+}
+
+func (c *StateCache) GetDevices(context.Context) ([]Device, error) {
+	return cacheAll(c, func() []Device { return c.devices.all() })
+}
+
+func (c *StateCache) GetLightLevels(context.Context) ([]LightLevel, error) {
+	return cacheAll(c, func() []LightLevel { return c.lightLevels.all() })
+}
+
+func cacheAll[T any](c *StateCache, all func() []T) ([]T, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.loaded {
+		return nil, ErrCacheNotLoaded
+	}
+	return all(), nil
+}
+
+// resourceCache holds the resources of one type in the bridge's order. A
+// change replaces a resource rather than modifying it, so copies handed out
+// never change underneath their holders.
+type resourceCache[T any] struct {
+	ids  []string
+	byID map[string]*T
+}
+
+func newResourceCache[T any](items []T, id func(*T) string) *resourceCache[T] {
+	r := &resourceCache[T]{byID: make(map[string]*T, len(items))}
+	for i := range items {
+		item := &items[i]
+		r.ids = append(r.ids, id(item))
+		r.byID[id(item)] = item
+	}
+	return r
+}
+
+func (r *resourceCache[T]) get(id string) (T, bool) {
+	item, ok := r.byID[id]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return *item, true
+}
+
+func (r *resourceCache[T]) find(match func(*T) bool) (T, bool) {
+	for _, id := range r.ids {
+		if match(r.byID[id]) {
+			return *r.byID[id], true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func (r *resourceCache[T]) all() []T {
+	items := make([]T, len(r.ids))
+	for i, id := range r.ids {
+		items[i] = *r.byID[id]
+	}
+	return items
+}
+
+// apply adds, updates or deletes a resource from an event and reports
+// whether it changed. An update carries only the fields that changed, so it
+// is merged into the cached resource's JSON.
+func (r *resourceCache[T]) apply(kind, id string, data json.RawMessage) (bool, error) {
+	switch kind {
+	case EventAdd:
+		item := new(T)
+		if err := json.Unmarshal(data, item); err != nil {
+			return false, errors.Wrap(err, "unmarshaling added resource")
+		}
+		if _, ok := r.byID[id]; !ok {
+			r.ids = append(r.ids, id)
+		}
+		r.byID[id] = item
+		return true, nil
+
+	case EventUpdate:
+		old, ok := r.byID[id]
+		if !ok {
+			return false, nil
+		}
+		item, err := mergeResource(old, data)
+		if err != nil {
+			return false, err
+		}
+		r.byID[id] = item
+		return true, nil
+
+	case EventDelete:
+		if _, ok := r.byID[id]; !ok {
+			return false, nil
+		}
+		delete(r.byID, id)
+		r.ids = slices.DeleteFunc(r.ids, func(i string) bool { return i == id })
+		return true, nil
+	}
+	return false, nil
+}
+
+func mergeResource[T any](old *T, update json.RawMessage) (*T, error) {
+	oldJSON, err := json.Marshal(old)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling cached resource")
+	}
+	var base, patch map[string]any
+	if err := json.Unmarshal(oldJSON, &base); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling cached resource")
+	}
+	if err := json.Unmarshal(update, &patch); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling resource update")
+	}
+
+	merged, err := json.Marshal(mergeJSON(base, patch))
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling updated resource")
+	}
+	item := new(T)
+	if err := json.Unmarshal(merged, item); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling updated resource")
+	}
+	return item, nil
+}
+
+// mergeJSON sets each field of patch in base, merging objects field by field
+// and replacing anything else, such as lists, whole
+func mergeJSON(base, patch map[string]any) map[string]any {
+	for k, v := range patch {
+		if sub, ok := v.(map[string]any); ok {
+			if baseSub, ok := base[k].(map[string]any); ok {
+				base[k] = mergeJSON(baseSub, sub)
+				continue
+			}
+		}
+		base[k] = v
+	}
+	return base
+}
@@ -0,0 +1,216 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var cacheTestResources = map[string]string{
+	"light": `[
+  {"id": "l1", "type": "light", "metadata": {"name": "Desk"}, "owner": {"rid": "d1", "rtype": "device"}, "on": {"on": true}, "dimming": {"brightness": 80}, "color_temperature": {"mirek": 300}},
+  {"id": "l2", "type": "light", "metadata": {"name": "Ceiling"}, "owner": {"rid": "d2", "rtype": "device"}, "on": {"on": false}, "dimming": {"brightness": 50}}
+]`,
+	"room": `[
+  {"id": "r1", "type": "room", "metadata": {"name": "Office"}, "children": [{"rid": "d1", "rtype": "device"}]}
+]`,
+	"grouped_light": `[
+  {"id": "g1", "type": "grouped_light", "owner": {"rid": "r1", "rtype": "room"}, "on": {"on": true}}
+]`,
+	"scene": `[
+  {"id": "s1", "type": "scene", "metadata": {"name": "Focus"}, "group": {"rid": "r1", "rtype": "room"}},
+  {"id": "s2", "type": "scene", "metadata": {"name": "Relax"}, "group": {"rid": "r2", "rtype": "room"}}
+]`,
+	"device":      `[{"id": "d1", "type": "device", "metadata": {"name": "Desk lamp"}}]`,
+	"light_level": `[{"id": "ll1", "type": "light_level", "enabled": true, "light": {"light_level": 20000}}]`,
+}
+
+// newCacheTestBridge serves cacheTestResources and an event stream that sends
+// each message written to events, returning the bridge and how many times
+// the lights were loaded
+func newCacheTestBridge(t *testing.T, events <-chan string) (string, func() int) {
+	loads := make(chan struct{}, 100)
+	host := newTestBridge(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/eventstream/clip/v2" {
+			assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, ": hi\n\n")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case msg, ok := <-events:
+					if !ok {
+						return
+					}
+					fmt.Fprintf(w, "id: 1:0\ndata: %s\n\n", msg)
+					w.(http.Flusher).Flush()
+				}
+			}
+		}
+
+		resource := strings.TrimPrefix(r.URL.Path, "/clip/v2/resource/")
+		data, ok := cacheTestResources[resource]
+		if !assert.True(t, ok, "unexpected request for %s", r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		if resource == "light" {
+			loads <- struct{}{}
+		}
+		fmt.Fprintf(w, `{"errors": [], "data": %s}`, data)
+	})
+	return host, func() int { return len(loads) }
+}
+
+func TestStateCacheLoad(t *testing.T) {
+	host, _ := newCacheTestBridge(t, nil)
+	cache := NewStateCache(NewClient(host, "key", zap.NewNop()), zap.NewNop())
+
+	_, ok := cache.Light("l1")
+	assert.False(t, ok)
+	_, err := cache.GetLights(context.Background())
+	require.ErrorIs(t, err, ErrCacheNotLoaded)
+
+	require.NoError(t, cache.Load(context.Background()))
+	assert.True(t, cache.Loaded())
+
+	light, ok := cache.Light("l1")
+	require.True(t, ok)
+	assert.Equal(t, "Desk", light.Metadata.Name)
+	light, ok = cache.LightByName("ceiling")
+	require.True(t, ok)
+	assert.Equal(t, "l2", light.ID)
+	assert.Len(t, cache.Lights(), 2)
+
+	room, ok := cache.RoomByName("office")
+	require.True(t, ok)
+	lights := cache.RoomLights(room.ID)
+	require.Len(t, lights, 1)
+	assert.Equal(t, "l1", lights[0].ID)
+	group, ok := cache.RoomGroupedLight(room.ID)
+	require.True(t, ok)
+	assert.Equal(t, "g1", group.ID)
+	scenes := cache.RoomScenes(room.ID)
+	require.Len(t, scenes, 1)
+	assert.Equal(t, "Focus", scenes[0].Metadata.Name)
+
+	_, ok = cache.Device("d1")
+	assert.True(t, ok)
+	levels, err := cache.GetLightLevels(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, levels, 1)
+}
+
+func TestStateCacheApply(t *testing.T) {
+	host, _ := newCacheTestBridge(t, nil)
+	cache := NewStateCache(NewClient(host, "key", zap.NewNop()), zap.NewNop())
+	require.NoError(t, cache.Load(context.Background()))
+
+	before, _ := cache.Light("l1")
+	changes, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	cache.Apply([]Event{
+		{Type: EventUpdate, Data: []json.RawMessage{
+			json.RawMessage(`{"id": "l1", "type": "light", "dimming": {"brightness": 20}}`),
+			json.RawMessage(`{"id": "m1", "type": "motion", "motion": {"motion": true}}`),
+			json.RawMessage(`{"id": "unknown", "type": "light", "on": {"on": true}}`),
+		}},
+		{Type: EventAdd, Data: []json.RawMessage{
+			json.RawMessage(`{"id": "l3", "type": "light", "metadata": {"name": "Lamp"}, "owner": {"rid": "d1", "rtype": "device"}, "on": {"on": true}}`),
+		}},
+		{Type: EventDelete, Data: []json.RawMessage{
+			json.RawMessage(`{"id": "s2", "type": "scene"}`),
+		}},
+	})
+
+	light, ok := cache.Light("l1")
+	require.True(t, ok)
+	require.NotNil(t, light.Dimming)
+	assert.Equal(t, 20.0, light.Dimming.Brightness)
+	assert.True(t, light.On.On, "fields the update left out are kept")
+	assert.Equal(t, 300, light.ColorTemperature.Mirek)
+	assert.Equal(t, 80.0, before.Dimming.Brightness, "copies handed out don't change")
+
+	_, ok = cache.Light("unknown")
+	assert.False(t, ok, "updates to resources the cache doesn't hold are ignored")
+	assert.Equal(t, []string{"l1", "l2", "l3"}, lightIDs(cache.Lights()))
+	assert.Len(t, cache.RoomLights("r1"), 2)
+	_, ok = cache.Scene("s2")
+	assert.False(t, ok)
+
+	assert.Equal(t, Change{Kind: EventUpdate, Type: "light", ID: "l1"}, <-changes)
+	assert.Equal(t, Change{Kind: EventAdd, Type: "light", ID: "l3"}, <-changes)
+	assert.Equal(t, Change{Kind: EventDelete, Type: "scene", ID: "s2"}, <-changes)
+	assert.Empty(t, changes)
+}
+
+func lightIDs(lights []Light) []string {
+	var ids []string
+	for _, l := range lights {
+		ids = append(ids, l.ID)
+	}
+	return ids
+}
+
+func TestStateCacheSubscriberFallsBehind(t *testing.T) {
+	cache := NewStateCache(nil, zap.NewNop())
+	changes, unsubscribe := cache.Subscribe()
+
+	for range subscriberBuffer + 5 {
+		cache.notify(Change{Kind: EventUpdate, Type: "light", ID: "l1"})
+	}
+	for range subscriberBuffer {
+		<-changes
+	}
+
+	// The first change with room to spare is preceded by a reload for the missed ones
+	cache.notify(Change{Kind: EventUpdate, Type: "light", ID: "l2"})
+	assert.Equal(t, Change{Kind: ChangeReload}, <-changes)
+	assert.Equal(t, Change{Kind: EventUpdate, Type: "light", ID: "l2"}, <-changes)
+
+	unsubscribe()
+	unsubscribe()
+	_, open := <-changes
+	assert.False(t, open)
+}
+
+func TestStateCacheRun(t *testing.T) {
+	events := make(chan string)
+	host, loads := newCacheTestBridge(t, events)
+	cache := NewStateCache(NewClient(host, "key", zap.NewNop()), zap.NewNop())
+	changes, unsubscribe := cache.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- cache.Run(ctx) }()
+
+	assert.Equal(t, Change{Kind: ChangeReload}, <-changes)
+	events <- `[{"id": "e1", "type": "update", "creationtime": "2024-05-01T10:00:00Z", "data": [{"id": "l2", "type": "light", "on": {"on": true}}]}]`
+	assert.Equal(t, Change{Kind: EventUpdate, Type: "light", ID: "l2"}, <-changes)
+	light, _ := cache.Light("l2")
+	assert.True(t, light.On.On)
+	assert.Equal(t, 1, loads())
+
+	// Losing the stream reconnects and loads everything again
+	close(events)
+	assert.Equal(t, Change{Kind: ChangeReload}, <-changes)
+	assert.Equal(t, 2, loads())
+	light, _ = cache.Light("l2")
+	assert.False(t, light.On.On)
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -19,11 +19,16 @@ const (
 	httpTimeout = 10 * time.Second
 )
 
+// ErrBridgeUnreachable marks errors where the bridge could not be reached over the network
+var ErrBridgeUnreachable = errors.New("bridge unreachable")
+
 type Client struct {
 	bridgeIP   string
 	apiKey     string
 	httpClient *http.Client
 	logger     *zap.Logger
+	// streamPort overrides the entertainment stream port, for tests
+	streamPort int
 }
 
 func NewClient(bridgeIP, apiKey string, logger *zap.Logger) *Client {
@@ -75,7 +80,7 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, errors.Wrap(err, "executing http request")
+		return nil, errors.Mark(errors.Wrap(err, "executing http request"), ErrBridgeUnreachable)
 	}
 	defer resp.Body.Close()
 
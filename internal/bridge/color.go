@@ -0,0 +1,87 @@
+package bridge
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// XY is a color in CIE 1931 xy coordinates, the color space of the V2 API
+type XY struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type LightColorState struct {
+	XY XY `json:"xy"`
+}
+
+// whitePoint is D65, used for black, which has no chromaticity
+var whitePoint = XY{X: 0.3127, Y: 0.3290}
+
+// namedColors are the colors ParseColor accepts by name
+var namedColors = map[string][3]uint8{
+	"red":     {255, 0, 0},
+	"orange":  {255, 128, 0},
+	"amber":   {255, 191, 0},
+	"yellow":  {255, 255, 0},
+	"lime":    {128, 255, 0},
+	"green":   {0, 255, 0},
+	"teal":    {0, 128, 128},
+	"cyan":    {0, 255, 255},
+	"blue":    {0, 0, 255},
+	"indigo":  {75, 0, 130},
+	"purple":  {128, 0, 255},
+	"violet":  {238, 130, 238},
+	"magenta": {255, 0, 255},
+	"pink":    {255, 105, 180},
+	"white":   {255, 255, 255},
+	"warm":    {255, 180, 107},
+}
+
+// ParseColor reads a color name such as "orange" or a hex color such as
+// "#ff8800" and returns it in xy coordinates
+func ParseColor(s string) (XY, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if rgb, ok := namedColors[name]; ok {
+		return RGBToXY(rgb[0], rgb[1], rgb[2]), nil
+	}
+
+	hex := strings.TrimPrefix(name, "#")
+	if len(hex) == 6 {
+		if v, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return RGBToXY(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+		}
+	}
+	return XY{}, errors.Newf("invalid color %q (expected a name such as orange or a hex color such as #ff8800)", s)
+}
+
+// RGBToXY converts an sRGB color to xy with the wide gamut conversion Philips
+// documents for Hue. The bridge maps colors outside a light's gamut to the
+// nearest one it can show.
+func RGBToXY(r, g, b uint8) XY {
+	linear := func(c uint8) float64 {
+		v := float64(c) / 255
+		if v > 0.04045 {
+			return math.Pow((v+0.055)/1.055, 2.4)
+		}
+		return v / 12.92
+	}
+	rl, gl, bl := linear(r), linear(g), linear(b)
+
+	x := rl*0.664511 + gl*0.154324 + bl*0.162028
+	y := rl*0.283881 + gl*0.668433 + bl*0.047685
+	z := rl*0.000088 + gl*0.072310 + bl*0.986039
+
+	sum := x + y + z
+	if sum == 0 {
+		return whitePoint
+	}
+	return XY{X: round4(x / sum), Y: round4(y / sum)}
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,71 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// DefaultDiscoveryURL is the Hue cloud endpoint listing bridges on the caller's network
+const DefaultDiscoveryURL = "https://discovery.meethue.com"
+
+// DiscoveredBridge is a bridge reported by the discovery endpoint
+type DiscoveredBridge struct {
+	ID        string `json:"id"`
+	IPAddress string `json:"internalipaddress"`
+	Port      int    `json:"port"`
+}
+
+// Discover lists the bridges the discovery endpoint knows about on this network
+func Discover(ctx context.Context, discoveryURL string) ([]DiscoveredBridge, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", discoveryURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating discovery request")
+	}
+
+	resp, err := (&http.Client{Timeout: httpTimeout}).Do(req)
+	if err != nil {
+		return nil, errors.Mark(errors.Wrap(err, "executing discovery request"), ErrBridgeUnreachable)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading discovery response")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errors.Newf("discovery error: status=%d, body=%s", resp.StatusCode, string(respBody))
+	}
+
+	var bridges []DiscoveredBridge
+	if err := json.Unmarshal(respBody, &bridges); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling discovery response")
+	}
+
+	for i := range bridges {
+		bridges[i].ID = strings.ToLower(bridges[i].ID)
+	}
+
+	return bridges, nil
+}
+
+// DiscoverByID finds the address of the bridge with the given ID
+func DiscoverByID(ctx context.Context, discoveryURL, bridgeID string) (*DiscoveredBridge, error) {
+	bridges, err := Discover(ctx, discoveryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range bridges {
+		if strings.EqualFold(b.ID, bridgeID) {
+			return &b, nil
+		}
+	}
+
+	return nil, errors.Mark(errors.Newf("bridge %s not found on this network", bridgeID), ErrBridgeUnreachable)
+}
@@ -0,0 +1,128 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// EffectNone ends an effect or timed effect
+	EffectNone = "no_effect"
+
+	TimedEffectSunrise = "sunrise"
+	TimedEffectSunset  = "sunset"
+
+	// AlertBreathe makes a light or group breathe once, for a visible signal
+	AlertBreathe = "breathe"
+)
+
+// Effects are the light effects the bridge knows of; each light lists the ones
+// it supports in Light.Effects
+var Effects = []string{
+	"candle", "fire", "prism", "sparkle", "opal", "glisten", "underwater", "cosmos", "sunbeam", "enchant",
+}
+
+// LightEffectState starts an effect, such as candle, that runs until it is
+// ended with EffectNone or the light is changed
+type LightEffectState struct {
+	Effect string `json:"effect"`
+}
+
+// LightTimedEffectState starts an effect, such as sunrise, that runs for a time
+type LightTimedEffectState struct {
+	Effect string `json:"effect"`
+	// Duration is in milliseconds
+	Duration int `json:"duration,omitempty"`
+}
+
+type LightAlertState struct {
+	Action string `json:"action"`
+}
+
+// DeviceUpdateRequest changes a device, the hardware a light or sensor belongs to
+type DeviceUpdateRequest struct {
+	Identify *DeviceIdentifyState `json:"identify,omitempty"`
+}
+
+type DeviceIdentifyState struct {
+	Action string `json:"action"`
+}
+
+// SupportsEffect reports whether the light lists effect, or any effect at all for EffectNone
+func (l *Light) SupportsEffect(effect string) bool {
+	if l.Effects == nil {
+		return false
+	}
+	return effect == EffectNone || slices.Contains(l.Effects.EffectValues, effect)
+}
+
+// SupportsTimedEffect reports whether the light lists a timed effect, or any at all for EffectNone
+func (l *Light) SupportsTimedEffect(effect string) bool {
+	if l.TimedEffects == nil {
+		return false
+	}
+	return effect == EffectNone || slices.Contains(l.TimedEffects.EffectValues, effect)
+}
+
+// ActiveEffect returns the running effect or timed effect, "" if there is none
+func (l *Light) ActiveEffect() string {
+	if l.Effects != nil && l.Effects.Status != "" && l.Effects.Status != EffectNone {
+		return l.Effects.Status
+	}
+	if l.TimedEffects != nil && l.TimedEffects.Status != "" && l.TimedEffects.Status != EffectNone {
+		return l.TimedEffects.Status
+	}
+	return ""
+}
+
+func (c *Client) GetLight(ctx context.Context, lightID string) (*Light, error) {
+	respBody, err := c.doRequest(ctx, "GET", fmt.Sprintf("/resource/light/%s", lightID), nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting light %s", lightID)
+	}
+
+	var lightsResp LightsResponse
+	if err := json.Unmarshal(respBody, &lightsResp); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling light response")
+	}
+
+	if len(lightsResp.Errors) > 0 {
+		return nil, errors.Newf("hue api returned errors: %v", lightsResp.Errors)
+	}
+	if len(lightsResp.Data) == 0 {
+		return nil, errors.Newf("light %s not found", lightID)
+	}
+
+	return &lightsResp.Data[0], nil
+}
+
+// IdentifyLight makes a light blink so it can be found. Identification is a
+// feature of the light's device, so the light is looked up first.
+func (c *Client) IdentifyLight(ctx context.Context, lightID string) error {
+	light, err := c.GetLight(ctx, lightID)
+	if err != nil {
+		return err
+	}
+	if light.Owner.Type != "device" || light.Owner.ResourceID == "" {
+		return errors.Newf("light %s has no device to identify", lightID)
+	}
+
+	return c.IdentifyDevice(ctx, light.Owner.ResourceID)
+}
+
+// IdentifyDevice makes a device blink so it can be found
+func (c *Client) IdentifyDevice(ctx context.Context, deviceID string) error {
+	req := DeviceUpdateRequest{Identify: &DeviceIdentifyState{Action: "identify"}}
+	path := fmt.Sprintf("/resource/device/%s", deviceID)
+	if _, err := c.doRequest(ctx, "PUT", path, req); err != nil {
+		return errors.Wrapf(err, "identifying device %s", deviceID)
+	}
+
+	c.logger.Info("device identified", zap.String("device_id", deviceID))
+	return nil
+}
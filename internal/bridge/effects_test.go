@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const candleLight = `{"errors": [], "data": [{
+  "id": "l1",
+  "owner": {"rid": "d1", "rtype": "device"},
+  "metadata": {"name": "Lamp"},
+  "on": {"on": true},
+  "effects": {"status": "candle", "status_values": ["no_effect", "candle", "fire"], "effect_values": ["no_effect", "candle", "fire"]},
+  "timed_effects": {"effect": "no_effect", "status": "no_effect", "effect_values": ["no_effect", "sunrise", "sunset"]},
+  "alert": {"action_values": ["breathe"]}
+}]}`
+
+func TestIdentifyLight(t *testing.T) {
+	var requests []string
+	var body string
+	host := newTestBridge(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/clip/v2/resource/light/l1":
+			w.Write([]byte(candleLight))
+		default:
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+			w.Write([]byte(`{"errors": [], "data": [{"rid": "d1", "rtype": "device"}]}`))
+		}
+	})
+
+	require.NoError(t, NewClient(host, "key", zap.NewNop()).IdentifyLight(context.Background(), "l1"))
+	assert.Equal(t, []string{"GET /clip/v2/resource/light/l1", "PUT /clip/v2/resource/device/d1"}, requests)
+	assert.JSONEq(t, `{"identify": {"action": "identify"}}`, body)
+}
+
+func TestLightEffects(t *testing.T) {
+	var light LightsResponse
+	require.NoError(t, json.Unmarshal([]byte(candleLight), &light))
+	l := light.Data[0]
+
+	assert.Equal(t, "candle", l.ActiveEffect())
+	assert.True(t, l.SupportsEffect("fire"))
+	assert.False(t, l.SupportsEffect("sparkle"))
+	assert.True(t, l.SupportsTimedEffect(TimedEffectSunrise))
+	assert.True(t, l.SupportsTimedEffect(EffectNone))
+
+	var plain Light
+	require.NoError(t, json.Unmarshal([]byte(`{"id": "l2", "on": {"on": true}}`), &plain))
+	assert.False(t, plain.SupportsEffect(EffectNone), "lights without effects can't end one")
+	assert.Empty(t, plain.ActiveEffect())
+
+	data, err := json.Marshal(LightUpdateRequest{
+		On:           &LightOnState{On: true},
+		TimedEffects: &LightTimedEffectState{Effect: TimedEffectSunrise, Duration: 1800000},
+		Alert:        &LightAlertState{Action: AlertBreathe},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"on": {"on": true}, "timed_effects": {"effect": "sunrise", "duration": 1800000}, "alert": {"action": "breathe"}}`, string(data))
+}
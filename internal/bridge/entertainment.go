@@ -0,0 +1,164 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+// EntertainmentConfiguration is a set of lights, each in one or more
+// channels, that the Entertainment API streams colors to
+type EntertainmentConfiguration struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	// ConfigurationType is screen, monitor, music, 3dspace or other
+	ConfigurationType string `json:"configuration_type"`
+	// Status is "active" while a client is streaming
+	Status         string `json:"status"`
+	ActiveStreamer *struct {
+		ResourceID string `json:"rid"`
+		Type       string `json:"rtype"`
+	} `json:"active_streamer,omitempty"`
+	Channels      []EntertainmentChannel `json:"channels"`
+	LightServices []struct {
+		ResourceID string `json:"rid"`
+		Type       string `json:"rtype"`
+	} `json:"light_services"`
+}
+
+// EntertainmentChannel is one color of a stream frame. Its position runs
+// from -1 to 1 on each axis: left to right, back to front, floor to ceiling.
+type EntertainmentChannel struct {
+	ChannelID uint8 `json:"channel_id"`
+	Position  struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+		Z float64 `json:"z"`
+	} `json:"position"`
+	Members []struct {
+		Service struct {
+			ResourceID string `json:"rid"`
+			Type       string `json:"rtype"`
+		} `json:"service"`
+		Index int `json:"index"`
+	} `json:"members"`
+}
+
+// IsActive reports whether a client is streaming to the configuration
+func (e *EntertainmentConfiguration) IsActive() bool {
+	return e.Status == "active"
+}
+
+// ChannelIDs returns the IDs of the configuration's channels in order
+func (e *EntertainmentConfiguration) ChannelIDs() []uint8 {
+	ids := make([]uint8, len(e.Channels))
+	for i, ch := range e.Channels {
+		ids[i] = ch.ChannelID
+	}
+	return ids
+}
+
+type EntertainmentConfigurationsResponse struct {
+	Errors []struct {
+		Description string `json:"description"`
+	} `json:"errors"`
+	Data []EntertainmentConfiguration `json:"data"`
+}
+
+type entertainmentActionRequest struct {
+	Action string `json:"action"`
+}
+
+func (c *Client) GetEntertainmentConfigurations(ctx context.Context) ([]EntertainmentConfiguration, error) {
+	return c.getEntertainmentConfigurations(ctx, "/resource/entertainment_configuration")
+}
+
+func (c *Client) GetEntertainmentConfiguration(ctx context.Context, configID string) (*EntertainmentConfiguration, error) {
+	configs, err := c.getEntertainmentConfigurations(ctx, fmt.Sprintf("/resource/entertainment_configuration/%s", configID))
+	if err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return nil, errors.Newf("entertainment configuration %s not found", configID)
+	}
+	return &configs[0], nil
+}
+
+func (c *Client) getEntertainmentConfigurations(ctx context.Context, path string) ([]EntertainmentConfiguration, error) {
+	respBody, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting entertainment configurations")
+	}
+
+	var configsResp EntertainmentConfigurationsResponse
+	if err := json.Unmarshal(respBody, &configsResp); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling entertainment configurations response")
+	}
+
+	if len(configsResp.Errors) > 0 {
+		return nil, errors.Newf("hue api returned errors: %v", configsResp.Errors)
+	}
+
+	return configsResp.Data, nil
+}
+
+// StartStreaming makes the bridge accept a stream to the configuration. The
+// bridge ends streaming itself if no frame arrives for 10 seconds.
+func (c *Client) StartStreaming(ctx context.Context, configID string) error {
+	return c.setStreaming(ctx, configID, "start")
+}
+
+// StopStreaming ends streaming to the configuration, returning its lights to
+// the state they had before
+func (c *Client) StopStreaming(ctx context.Context, configID string) error {
+	return c.setStreaming(ctx, configID, "stop")
+}
+
+func (c *Client) setStreaming(ctx context.Context, configID, action string) error {
+	path := fmt.Sprintf("/resource/entertainment_configuration/%s", configID)
+	if _, err := c.doRequest(ctx, "PUT", path, entertainmentActionRequest{Action: action}); err != nil {
+		return errors.Wrapf(err, "%s streaming to %s", action, configID)
+	}
+
+	c.logger.Info("entertainment streaming changed",
+		zap.String("entertainment_configuration_id", configID),
+		zap.String("action", action),
+	)
+	return nil
+}
+
+// ApplicationID returns the bridge's ID for the application key, which is
+// the identity of its stream sessions
+func (c *Client) ApplicationID(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("https://%s/auth/v1", c.bridgeIP)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "creating http request")
+	}
+	req.Header.Set("hue-application-key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", errors.Mark(errors.Wrap(err, "executing http request"), ErrBridgeUnreachable)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", errors.Newf("hue api error: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+
+	id := resp.Header.Get("hue-application-id")
+	if id == "" {
+		return "", errors.New("bridge didn't return an application ID; it may need a firmware update for streaming")
+	}
+	return id, nil
+}
@@ -0,0 +1,125 @@
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Event kinds
+const (
+	EventAdd    = "add"
+	EventUpdate = "update"
+	EventDelete = "delete"
+	EventError  = "error"
+)
+
+// Event is a change the bridge reports on its event stream. Each item of Data
+// is a resource with its "id" and "type" and, for updates, only the fields
+// that changed.
+type Event struct {
+	ID           string            `json:"id"`
+	Type         string            `json:"type"`
+	CreationTime time.Time         `json:"creationtime"`
+	Data         []json.RawMessage `json:"data"`
+}
+
+// EventStream reads the bridge's server-sent events
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// OpenEventStream connects to the bridge's event stream. The bridge sends
+// events from the moment it connects, so state loaded after this returns
+// won't miss any.
+func (c *Client) OpenEventStream(ctx context.Context) (*EventStream, error) {
+	url := fmt.Sprintf("https://%s/eventstream/clip/%s", c.bridgeIP, apiVersion)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating http request")
+	}
+	req.Header.Set("hue-application-key", c.apiKey)
+	req.Header.Set("Accept", "text/event-stream")
+
+	// The stream stays open, so it can't share the client's request timeout
+	client := *c.httpClient
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Mark(errors.Wrap(err, "opening event stream"), ErrBridgeUnreachable)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, errors.Newf("hue api error: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+
+	c.logger.Debug("event stream opened")
+
+	scanner := bufio.NewScanner(resp.Body)
+	// A scene recall can report dozens of resources in one message
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	return &EventStream{body: resp.Body, scanner: scanner}, nil
+}
+
+// Next returns the events of the next message, blocking until one arrives.
+// It returns an error marked ErrBridgeUnreachable once the stream ends.
+func (s *EventStream) Next() ([]Event, error) {
+	var data bytes.Buffer
+	for s.scanner.Scan() {
+		line := s.scanner.Bytes()
+		switch {
+		case len(line) == 0:
+			if data.Len() == 0 {
+				continue
+			}
+			var events []Event
+			if err := json.Unmarshal(data.Bytes(), &events); err != nil {
+				return nil, errors.Wrap(err, "unmarshaling events")
+			}
+			return events, nil
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("data:")), []byte(" ")))
+		}
+		// Comments, such as the ": hi" the bridge opens with, and event IDs are skipped
+	}
+
+	err := s.scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	return nil, errors.Mark(errors.Wrap(err, "reading event stream"), ErrBridgeUnreachable)
+}
+
+// Close disconnects from the stream, ending a blocked Next
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
+
+// resourceRef is the part of an event's resource that says which one it is
+type resourceRef struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+func parseResourceRef(data json.RawMessage) (resourceRef, error) {
+	var ref resourceRef
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return ref, errors.Wrap(err, "unmarshaling event resource")
+	}
+	if ref.ID == "" || ref.Type == "" {
+		return ref, errors.Newf("event resource has no id or type: %s", data)
+	}
+	return ref, nil
+}
@@ -0,0 +1,168 @@
+package bridge
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// DefaultCyclePeriod is how long a ColorCycle takes around the color wheel by default
+const DefaultCyclePeriod = 10 * time.Second
+
+// ColorCycle turns every channel around the color wheel
+type ColorCycle struct {
+	// Period is how long a turn takes, DefaultCyclePeriod if 0
+	Period time.Duration
+	// Spread offsets the channels' colors evenly around the wheel so they
+	// chase each other rather than change together
+	Spread bool
+	// Brightness is from 0 to 1, full brightness if nil
+	Brightness *float64
+}
+
+func (c ColorCycle) Frame(elapsed time.Duration, channels []uint8) (Frame, error) {
+	period := c.Period
+	if period <= 0 {
+		period = DefaultCyclePeriod
+	}
+	brightness := 1.0
+	if c.Brightness != nil {
+		brightness = min(max(*c.Brightness, 0), 1)
+	}
+
+	turn := float64(elapsed%period) / float64(period)
+	frame := make(Frame, len(channels))
+	for i, ch := range channels {
+		hue := turn
+		if c.Spread {
+			hue += float64(i) / float64(len(channels))
+		}
+		frame[i] = ChannelColor{Channel: ch, Color: hsv(hue, 1, brightness)}
+	}
+	return frame, nil
+}
+
+const (
+	// audioChunkSamples is how many samples AudioLevel measures at once, 1/43 s at 44.1 kHz
+	audioChunkSamples = 1024
+	// audioDecay is how long a peak takes to fall to a third, so the lights
+	// don't flicker between beats
+	audioDecay = 200 * time.Millisecond
+)
+
+// AudioLevel shows the loudness of raw audio as a level meter: the channels
+// light in order from green to red as the sound gets louder. It reads signed
+// 16-bit little-endian mono samples, such as the output of
+// "parec --format=s16le --channels=1" or "arecord -f S16_LE -c 1".
+type AudioLevel struct {
+	// Gain scales the measured level, 1 if 0; raise it for quiet sources
+	Gain float64
+
+	mu    sync.Mutex
+	level float64
+	err   error
+
+	shown float64
+	last  time.Duration
+}
+
+// NewAudioLevel starts measuring the audio read from r. The source ends with
+// ErrSourceDone once r does.
+func NewAudioLevel(r io.Reader) *AudioLevel {
+	a := &AudioLevel{}
+	go a.read(r)
+	return a
+}
+
+func (a *AudioLevel) read(r io.Reader) {
+	buf := make([]byte, audioChunkSamples*2)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n >= 2 {
+			a.mu.Lock()
+			a.level = max(a.level, rms(buf[:n&^1]))
+			a.mu.Unlock()
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = ErrSourceDone
+			} else {
+				err = errors.Wrap(err, "reading audio")
+			}
+			a.mu.Lock()
+			a.err = err
+			a.mu.Unlock()
+			return
+		}
+	}
+}
+
+// rms returns the loudness of samples from 0 to 1
+func rms(samples []byte) float64 {
+	var sum float64
+	n := len(samples) / 2
+	for i := range n {
+		s := float64(int16(binary.LittleEndian.Uint16(samples[i*2:]))) / -math.MinInt16
+		sum += s * s
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+func (a *AudioLevel) Frame(elapsed time.Duration, channels []uint8) (Frame, error) {
+	a.mu.Lock()
+	level, err := a.level, a.err
+	a.level = 0
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	gain := a.Gain
+	if gain <= 0 {
+		gain = 1
+	}
+	// Speech and music rarely come near full scale, so the meter tops out at half
+	level = min(level*gain*2, 1)
+
+	// Rise at once and fall away smoothly
+	a.shown *= math.Exp(-float64(elapsed-a.last) / float64(audioDecay))
+	a.last = elapsed
+	a.shown = max(a.shown, level)
+
+	frame := make(Frame, len(channels))
+	for i, ch := range channels {
+		lit := min(max(a.shown*float64(len(channels))-float64(i), 0), 1)
+		// Green for the first channel through to red for the last
+		hue := 1.0 / 3
+		if len(channels) > 1 {
+			hue *= 1 - float64(i)/float64(len(channels)-1)
+		}
+		frame[i] = ChannelColor{Channel: ch, Color: hsv(hue, 1, lit)}
+	}
+	return frame, nil
+}
+
+// hsv converts a hue, in turns, saturation and value to RGB
+func hsv(h, s, v float64) RGB {
+	h = (h - math.Floor(h)) * 6
+	f := h - math.Floor(h)
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	switch int(h) {
+	case 0:
+		return RGB{v, t, p}
+	case 1:
+		return RGB{q, v, p}
+	case 2:
+		return RGB{p, v, t}
+	case 3:
+		return RGB{p, q, v}
+	case 4:
+		return RGB{t, p, v}
+	default:
+		return RGB{v, p, q}
+	}
+}
@@ -0,0 +1,91 @@
+package bridge
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// GradientModeInterpolated blends the points along the light, the default
+	GradientModeInterpolated = "interpolated_palette"
+	// GradientModeMirrored blends the points out from the middle
+	GradientModeMirrored = "interpolated_palette_mirrored"
+	// GradientModePixelated scatters the point colors along the light
+	GradientModePixelated = "random_pixelated"
+
+	// MinGradientPoints and MaxGradientPoints bound the points the API accepts;
+	// each light reports how many it can show in Gradient.PointsCapable
+	MinGradientPoints = 2
+	MaxGradientPoints = 5
+)
+
+// gradientModeAliases are the short mode names ParseGradient accepts
+var gradientModeAliases = map[string]string{
+	"interpolated": GradientModeInterpolated,
+	"mirrored":     GradientModeMirrored,
+	"pixelated":    GradientModePixelated,
+}
+
+// GradientPoint is one color of a gradient
+type GradientPoint struct {
+	Color LightColorState `json:"color"`
+}
+
+// LightGradientState sets the colors along a gradient light, in order from
+// its start
+type LightGradientState struct {
+	Points []GradientPoint `json:"points"`
+	Mode   string          `json:"mode,omitempty"`
+}
+
+// ParseGradient builds a gradient from color names or hex colors, in order,
+// and a mode: interpolated, mirrored or pixelated, the API's name for one, or
+// "" for the light's current mode
+func ParseGradient(colors []string, mode string) (*LightGradientState, error) {
+	if len(colors) < MinGradientPoints || len(colors) > MaxGradientPoints {
+		return nil, errors.Newf("a gradient needs %d to %d colors, got %d", MinGradientPoints, MaxGradientPoints, len(colors))
+	}
+
+	g := &LightGradientState{}
+	for _, c := range colors {
+		xy, err := ParseColor(c)
+		if err != nil {
+			return nil, err
+		}
+		g.Points = append(g.Points, GradientPoint{Color: LightColorState{XY: xy}})
+	}
+
+	if mode != "" {
+		m := strings.ToLower(mode)
+		if alias, ok := gradientModeAliases[m]; ok {
+			m = alias
+		}
+		if m != GradientModeInterpolated && m != GradientModeMirrored && m != GradientModePixelated {
+			return nil, errors.Newf("invalid gradient mode %q (expected interpolated, mirrored or pixelated)", mode)
+		}
+		g.Mode = m
+	}
+	return g, nil
+}
+
+// SupportsGradient reports whether the light is a gradient light
+func (l *Light) SupportsGradient() bool {
+	return l.Gradient != nil && l.Gradient.PointsCapable > 0
+}
+
+// CheckGradient returns an error if the light can't show g
+func (l *Light) CheckGradient(g *LightGradientState) error {
+	if !l.SupportsGradient() {
+		return errors.Newf("light %q isn't a gradient light", l.Metadata.Name)
+	}
+	if len(g.Points) > l.Gradient.PointsCapable {
+		return errors.Newf("light %q takes at most %d gradient colors, got %d", l.Metadata.Name, l.Gradient.PointsCapable, len(g.Points))
+	}
+	if g.Mode != "" && len(l.Gradient.ModeValues) > 0 && !slices.Contains(l.Gradient.ModeValues, g.Mode) {
+		return errors.Newf("light %q doesn't support the %s gradient mode (it supports %s)",
+			l.Metadata.Name, g.Mode, strings.Join(l.Gradient.ModeValues, ", "))
+	}
+	return nil
+}
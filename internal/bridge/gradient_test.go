@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColor(t *testing.T) {
+	red, err := ParseColor("Red")
+	require.NoError(t, err)
+	assert.Equal(t, XY{X: 0.7006, Y: 0.2993}, red)
+
+	hex, err := ParseColor("#FF0000")
+	require.NoError(t, err)
+	assert.Equal(t, red, hex)
+
+	blue, err := ParseColor("0000ff")
+	require.NoError(t, err)
+	assert.Equal(t, XY{X: 0.1355, Y: 0.0399}, blue)
+
+	black, err := ParseColor("#000000")
+	require.NoError(t, err)
+	assert.Equal(t, whitePoint, black)
+
+	for _, s := range []string{"", "chartreuse-ish", "#ff00", "#gg0000"} {
+		_, err := ParseColor(s)
+		assert.Error(t, err, s)
+	}
+}
+
+const gradientStrip = `{
+  "id": "l7",
+  "metadata": {"name": "TV strip"},
+  "on": {"on": true},
+  "gradient": {
+    "points": [{"color": {"xy": {"x": 0.7, "y": 0.3}}}, {"color": {"xy": {"x": 0.15, "y": 0.06}}}],
+    "mode": "interpolated_palette",
+    "points_capable": 5,
+    "mode_values": ["interpolated_palette", "interpolated_palette_mirrored"],
+    "pixel_count": 16
+  }
+}`
+
+func TestGradient(t *testing.T) {
+	var strip Light
+	require.NoError(t, json.Unmarshal([]byte(gradientStrip), &strip))
+	require.True(t, strip.SupportsGradient())
+	assert.Len(t, strip.Gradient.Points, 2)
+	assert.Equal(t, 16, strip.Gradient.PixelCount)
+
+	g, err := ParseGradient([]string{"red", "orange", "#ffff00"}, "mirrored")
+	require.NoError(t, err)
+	assert.Equal(t, GradientModeMirrored, g.Mode)
+	require.Len(t, g.Points, 3)
+	assert.NoError(t, strip.CheckGradient(g))
+
+	data, err := json.Marshal(LightUpdateRequest{Gradient: g})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"gradient": {"mode": "interpolated_palette_mirrored", "points": [
+		{"color": {"xy": {"x": 0.7006, "y": 0.2993}}},
+		{"color": {"xy": {"x": 0.6112, "y": 0.375}}},
+		{"color": {"xy": {"x": 0.4442, "y": 0.5166}}}
+	]}}`, string(data))
+
+	pixelated, err := ParseGradient([]string{"red", "blue"}, "random_pixelated")
+	require.NoError(t, err)
+	assert.ErrorContains(t, strip.CheckGradient(pixelated), "doesn't support the random_pixelated gradient mode")
+
+	var bulb Light
+	require.NoError(t, json.Unmarshal([]byte(`{"id": "l1", "metadata": {"name": "Desk"}}`), &bulb))
+	assert.False(t, bulb.SupportsGradient())
+	assert.ErrorContains(t, bulb.CheckGradient(pixelated), `"Desk" isn't a gradient light`)
+
+	for _, tc := range []struct {
+		colors []string
+		mode   string
+	}{
+		{[]string{"red"}, ""},
+		{[]string{"red", "orange", "yellow", "green", "blue", "purple"}, ""},
+		{[]string{"red", "blurple"}, ""},
+		{[]string{"red", "blue"}, "sideways"},
+	} {
+		_, err := ParseGradient(tc.colors, tc.mode)
+		assert.Error(t, err, "%v %s", tc.colors, tc.mode)
+	}
+}
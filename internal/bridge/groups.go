@@ -59,6 +59,9 @@ type GroupedLight struct {
 	On struct {
 		On bool `json:"on"`
 	} `json:"on"`
+	Dimming *struct {
+		Brightness float64 `json:"brightness"`
+	} `json:"dimming,omitempty"`
 }
 
 type GroupedLightsResponse struct {
@@ -97,6 +100,11 @@ func (c *Client) SetGroupedLightState(ctx context.Context, groupedLightID string
 		}
 	}
 
+	return c.UpdateGroupedLight(ctx, groupedLightID, req)
+}
+
+// UpdateGroupedLight applies the fields set in req to every light of a room or zone
+func (c *Client) UpdateGroupedLight(ctx context.Context, groupedLightID string, req LightUpdateRequest) error {
 	path := fmt.Sprintf("/resource/grouped_light/%s", groupedLightID)
 	_, err := c.doRequest(ctx, "PUT", path, req)
 	if err != nil {
@@ -105,7 +113,7 @@ func (c *Client) SetGroupedLightState(ctx context.Context, groupedLightID string
 
 	c.logger.Info("grouped light state updated",
 		zap.String("grouped_light_id", groupedLightID),
-		zap.Bool("on", on),
+		zap.Any("state", req),
 	)
 
 	return nil
@@ -0,0 +1,59 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// BridgeInfo is the public bridge configuration, readable without an API key
+type BridgeInfo struct {
+	Name       string `json:"name"`
+	BridgeID   string `json:"bridgeid"`
+	ModelID    string `json:"modelid"`
+	APIVersion string `json:"apiversion"`
+	SWVersion  string `json:"swversion"`
+	MAC        string `json:"mac"`
+}
+
+// GetBridgeInfo reads the bridge's public configuration, including its unique bridge ID
+func (c *Client) GetBridgeInfo(ctx context.Context) (*BridgeInfo, error) {
+	url := fmt.Sprintf("https://%s/api/0/config", c.bridgeIP)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating bridge config request")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Mark(errors.Wrap(err, "executing bridge config request"), ErrBridgeUnreachable)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading bridge config response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Newf("hue api error: status=%d, body=%s", resp.StatusCode, string(respBody))
+	}
+
+	var info BridgeInfo
+	if err := json.Unmarshal(respBody, &info); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling bridge config")
+	}
+
+	if info.BridgeID == "" {
+		return nil, errors.New("bridge config response has no bridge id")
+	}
+	info.BridgeID = strings.ToLower(info.BridgeID)
+
+	return &info, nil
+}
@@ -0,0 +1,157 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+// whitelistDateLayout is the format of dates in the V1 whitelist, in UTC
+const whitelistDateLayout = "2006-01-02T15:04:05"
+
+// ApplicationKey is an application key (whitelist entry) registered on the bridge
+type ApplicationKey struct {
+	Username    string
+	Name        string
+	CreateDate  time.Time
+	LastUseDate time.Time
+}
+
+type whitelistEntry struct {
+	Name        string `json:"name"`
+	CreateDate  string `json:"create date"`
+	LastUseDate string `json:"last use date"`
+}
+
+type v1Error struct {
+	Error *struct {
+		Type        int    `json:"type"`
+		Address     string `json:"address"`
+		Description string `json:"description"`
+	} `json:"error,omitempty"`
+}
+
+// APIKey returns the application key the client authenticates with
+func (c *Client) APIKey() string {
+	return c.apiKey
+}
+
+// ListApplicationKeys returns the application keys registered on the bridge,
+// most recently used first
+func (c *Client) ListApplicationKeys(ctx context.Context) ([]ApplicationKey, error) {
+	respBody, err := c.doV1Request(ctx, "GET", "/config", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting bridge config")
+	}
+
+	var config struct {
+		Whitelist map[string]whitelistEntry `json:"whitelist"`
+	}
+	if err := json.Unmarshal(respBody, &config); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling bridge config")
+	}
+
+	keys := make([]ApplicationKey, 0, len(config.Whitelist))
+	for username, entry := range config.Whitelist {
+		key := ApplicationKey{
+			Username: username,
+			Name:     entry.Name,
+		}
+		key.CreateDate, _ = time.Parse(whitelistDateLayout, entry.CreateDate)
+		key.LastUseDate, _ = time.Parse(whitelistDateLayout, entry.LastUseDate)
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].LastUseDate.Equal(keys[j].LastUseDate) {
+			return keys[i].LastUseDate.After(keys[j].LastUseDate)
+		}
+		return keys[i].Username < keys[j].Username
+	})
+
+	return keys, nil
+}
+
+// RevokeApplicationKey deletes an application key from the bridge.
+// Bridges on recent firmware may refuse this, in which case keys can only be
+// revoked from the Hue app or account.meethue.com.
+func (c *Client) RevokeApplicationKey(ctx context.Context, username string) error {
+	if username == "" {
+		return errors.New("application key cannot be empty")
+	}
+
+	path := fmt.Sprintf("/config/whitelist/%s", username)
+	if _, err := c.doV1Request(ctx, "DELETE", path, nil); err != nil {
+		return errors.Wrapf(err, "revoking application key %s", username)
+	}
+
+	c.logger.Info("application key revoked",
+		zap.String("name", username),
+	)
+
+	return nil
+}
+
+// doV1Request performs a request against the V1 API, which reports errors as a
+// successful response containing an array of error objects
+func (c *Client) doV1Request(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	if c.apiKey == "" {
+		return nil, errors.New("v1 api request requires an application key")
+	}
+
+	url := fmt.Sprintf("https://%s/api/%s%s", c.bridgeIP, c.apiKey, path)
+
+	var bodyReader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshaling request body")
+		}
+		bodyReader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating http request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	c.logger.Debug("hue v1 api request",
+		zap.String("method", method),
+		zap.String("path", path),
+	)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Mark(errors.Wrap(err, "executing http request"), ErrBridgeUnreachable)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading response body")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errors.Newf("hue api error: status=%d, body=%s", resp.StatusCode, string(respBody))
+	}
+
+	var results []v1Error
+	if json.Unmarshal(respBody, &results) == nil {
+		for _, result := range results {
+			if result.Error != nil {
+				return nil, errors.Newf("hue api error: type=%d, description=%s", result.Error.Type, result.Error.Description)
+			}
+		}
+	}
+
+	return respBody, nil
+}
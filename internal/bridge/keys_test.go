@@ -0,0 +1,126 @@
+package bridge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestBridge starts a TLS stand-in for the bridge and returns its host:port
+func newTestBridge(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "https://")
+}
+
+func TestAuthenticateRequestsClientKey(t *testing.T) {
+	attempts := 0
+	host := newTestBridge(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api", r.URL.Path)
+		attempts++
+		if attempts == 1 {
+			w.Write([]byte(`[{"error": {"type": 101, "address": "", "description": "link button not pressed"}}]`))
+			return
+		}
+		w.Write([]byte(`[{"success": {"username": "new-key", "clientkey": "0123456789ABCDEF"}}]`))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := NewClient(host, "", zap.NewNop()).Authenticate(ctx, "limelight#test")
+	require.NoError(t, err)
+	assert.Equal(t, "new-key", result.Username)
+	assert.Equal(t, "0123456789ABCDEF", result.ClientKey)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestListApplicationKeys(t *testing.T) {
+	host := newTestBridge(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/current-key/config", r.URL.Path)
+		w.Write([]byte(`{
+  "name": "Hue Bridge",
+  "whitelist": {
+    "old-key": {"name": "limelight#macOS", "create date": "2023-01-01T10:00:00", "last use date": "2023-06-01T10:00:00"},
+    "current-key": {"name": "limelight#linux", "create date": "2024-01-01T10:00:00", "last use date": "2024-06-01T10:00:00"}
+  }
+}`))
+	})
+
+	keys, err := NewClient(host, "current-key", zap.NewNop()).ListApplicationKeys(context.Background())
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	assert.Equal(t, "current-key", keys[0].Username)
+	assert.Equal(t, "limelight#linux", keys[0].Name)
+	assert.Equal(t, time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), keys[0].LastUseDate)
+	assert.Equal(t, "old-key", keys[1].Username)
+}
+
+func TestRevokeApplicationKey(t *testing.T) {
+	var deleted string
+	host := newTestBridge(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "DELETE", r.Method)
+		if strings.HasSuffix(r.URL.Path, "/protected-key") {
+			w.Write([]byte(`[{"error": {"type": 1, "address": "/config/whitelist/protected-key", "description": "unauthorized user"}}]`))
+			return
+		}
+		deleted = r.URL.Path
+		w.Write([]byte(`[{"success": "/config/whitelist/old-key deleted"}]`))
+	})
+
+	client := NewClient(host, "current-key", zap.NewNop())
+
+	require.NoError(t, client.RevokeApplicationKey(context.Background(), "old-key"))
+	assert.Equal(t, "/api/current-key/config/whitelist/old-key", deleted)
+
+	err := client.RevokeApplicationKey(context.Background(), "protected-key")
+	assert.ErrorContains(t, err, "unauthorized user")
+}
+
+func TestAuthenticateLinkButtonTimeout(t *testing.T) {
+	host := newTestBridge(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"error": {"type": 101, "address": "", "description": "link button not pressed"}}]`))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	_, err := NewClient(host, "", zap.NewNop()).Authenticate(ctx, "limelight#test")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrLinkButtonNotPressed))
+	assert.False(t, errors.Is(err, ErrBridgeUnreachable))
+}
+
+func TestAuthenticateUnreachable(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	host := strings.TrimPrefix(server.URL, "https://")
+	server.Close()
+
+	_, err := NewClient(host, "", zap.NewNop()).Authenticate(context.Background(), "limelight#test")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBridgeUnreachable))
+	assert.False(t, errors.Is(err, ErrLinkButtonNotPressed))
+}
+
+func TestDiscoverByID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": "001788FFFE123456", "internalipaddress": "192.168.1.20", "port": 443}]`))
+	}))
+	defer server.Close()
+
+	found, err := DiscoverByID(context.Background(), server.URL, "001788fffe123456")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.20", found.IPAddress)
+
+	_, err = DiscoverByID(context.Background(), server.URL, "001788fffe000000")
+	assert.True(t, errors.Is(err, ErrBridgeUnreachable))
+}
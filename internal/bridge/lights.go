@@ -17,6 +17,11 @@ type Light struct {
 		Name      string `json:"name"`
 		Archetype string `json:"archetype"`
 	} `json:"metadata"`
+	// Owner is the device the light belongs to
+	Owner struct {
+		ResourceID string `json:"rid"`
+		Type       string `json:"rtype"`
+	} `json:"owner"`
 	On struct {
 		On bool `json:"on"`
 	} `json:"on"`
@@ -32,6 +37,28 @@ type Light struct {
 			Y float64 `json:"y"`
 		} `json:"xy"`
 	} `json:"color,omitempty"`
+	// Gradient is set for gradient lights, such as gradient lightstrips, which
+	// show several colors along their length
+	Gradient *struct {
+		Points        []GradientPoint `json:"points"`
+		Mode          string          `json:"mode"`
+		PointsCapable int             `json:"points_capable"`
+		ModeValues    []string        `json:"mode_values"`
+		PixelCount    int             `json:"pixel_count"`
+	} `json:"gradient,omitempty"`
+	// Effects, TimedEffects and Alert are nil for lights without them
+	Effects *struct {
+		Status       string   `json:"status"`
+		EffectValues []string `json:"effect_values"`
+	} `json:"effects,omitempty"`
+	TimedEffects *struct {
+		Effect       string   `json:"effect"`
+		Status       string   `json:"status"`
+		EffectValues []string `json:"effect_values"`
+	} `json:"timed_effects,omitempty"`
+	Alert *struct {
+		ActionValues []string `json:"action_values"`
+	} `json:"alert,omitempty"`
 }
 
 type LightsResponse struct {
@@ -42,8 +69,13 @@ type LightsResponse struct {
 }
 
 type LightUpdateRequest struct {
-	On      *LightOnState      `json:"on,omitempty"`
-	Dimming *LightDimmingState `json:"dimming,omitempty"`
+	On           *LightOnState          `json:"on,omitempty"`
+	Dimming      *LightDimmingState     `json:"dimming,omitempty"`
+	Dynamics     *LightDynamicsState    `json:"dynamics,omitempty"`
+	Effects      *LightEffectState      `json:"effects,omitempty"`
+	TimedEffects *LightTimedEffectState `json:"timed_effects,omitempty"`
+	Alert        *LightAlertState       `json:"alert,omitempty"`
+	Gradient     *LightGradientState    `json:"gradient,omitempty"`
 }
 
 type LightOnState struct {
@@ -54,6 +86,12 @@ type LightDimmingState struct {
 	Brightness float64 `json:"brightness"`
 }
 
+// LightDynamicsState sets how long the light takes to reach the new state
+type LightDynamicsState struct {
+	// Duration is in milliseconds
+	Duration int `json:"duration"`
+}
+
 func (c *Client) GetLights(ctx context.Context) ([]Light, error) {
 	respBody, err := c.doRequest(ctx, "GET", "/resource/light", nil)
 	if err != nil {
@@ -83,6 +121,11 @@ func (c *Client) SetLightState(ctx context.Context, lightID string, on bool, bri
 		}
 	}
 
+	return c.UpdateLight(ctx, lightID, req)
+}
+
+// UpdateLight applies the fields set in req to a light
+func (c *Client) UpdateLight(ctx context.Context, lightID string, req LightUpdateRequest) error {
 	path := fmt.Sprintf("/resource/light/%s", lightID)
 	_, err := c.doRequest(ctx, "PUT", path, req)
 	if err != nil {
@@ -91,7 +134,7 @@ func (c *Client) SetLightState(ctx context.Context, lightID string, on bool, bri
 
 	c.logger.Info("light state updated",
 		zap.String("light_id", lightID),
-		zap.Bool("on", on),
+		zap.Any("state", req),
 	)
 
 	return nil
@@ -0,0 +1,123 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/credentials"
+	"go.uber.org/zap"
+)
+
+// ClientPool hands out authenticated clients for the bridges of each profile,
+// creating each client once. Automation actions use it to reach a resource on
+// a bridge other than the selected one.
+type ClientPool struct {
+	config *credentials.Config
+	opts   credentials.StoreOptions
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// NewClientPool creates a pool for the profiles in config.
+// opts is used to open each profile's credential store.
+func NewClientPool(config *credentials.Config, opts credentials.StoreOptions, logger *zap.Logger) *ClientPool {
+	return &ClientPool{
+		config:  config,
+		opts:    opts,
+		logger:  logger,
+		clients: make(map[string]*Client),
+	}
+}
+
+// Client returns a client for the bridge of a profile, found by profile name or
+// bridge ID. An empty name means the profile the config is viewed through.
+func (p *ClientPool) Client(ctx context.Context, profileOrBridgeID string) (*Client, error) {
+	name, err := p.profileName(profileOrBridgeID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, ok := p.clients[name]; ok {
+		return client, nil
+	}
+
+	config, store, err := p.profileStore(name)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey, err := store.Get(ctx, config.CredentialItem(), credentials.FieldAPIKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting api key from %s credential store", store.Name())
+	}
+
+	client := NewClient(config.BridgeIP, apiKey, p.logger.With(zap.String("profile", name)))
+	p.clients[name] = client
+
+	return client, nil
+}
+
+// ClientKey returns the entertainment streaming key of a profile's bridge,
+// found as Client finds the profile
+func (p *ClientPool) ClientKey(ctx context.Context, profileOrBridgeID string) (string, error) {
+	name, err := p.profileName(profileOrBridgeID)
+	if err != nil {
+		return "", err
+	}
+
+	config, store, err := p.profileStore(name)
+	if err != nil {
+		return "", err
+	}
+
+	clientKey, err := store.Get(ctx, config.CredentialItem(), credentials.FieldClientKey)
+	if errors.Is(err, credentials.ErrSecretNotFound) {
+		return "", errors.Newf("profile %s has no client key for streaming, pair again with 'limelight --profile %s setup'", name, name)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "getting client key from %s credential store", store.Name())
+	}
+	return clientKey, nil
+}
+
+// profileName finds a profile by name or bridge ID, the current one if empty
+func (p *ClientPool) profileName(profileOrBridgeID string) (string, error) {
+	if profileOrBridgeID == "" {
+		return p.config.ProfileName, nil
+	}
+	name, _, ok := p.config.FindProfile(profileOrBridgeID)
+	if !ok {
+		return "", errors.Newf("no profile or bridge ID matches %s", profileOrBridgeID)
+	}
+	return name, nil
+}
+
+// profileStore returns a profile's config and opens its credential store
+func (p *ClientPool) profileStore(name string) (*credentials.Config, credentials.Store, error) {
+	config := p.config
+	if name != p.config.ProfileName {
+		var err error
+		config, err = p.config.WithProfile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if config.BridgeIP == "" {
+		return nil, nil, errors.Newf("profile %s has no bridge configured, run 'limelight --profile %s setup'", name, name)
+	}
+
+	opts := p.opts
+	opts.Config = config
+	store, err := credentials.NewStoreFromConfig(config, opts)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "opening credential store for profile %s", name)
+	}
+	return config, store, nil
+}
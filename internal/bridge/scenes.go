@@ -30,10 +30,22 @@ type Scene struct {
 			Type       string `json:"rtype"`
 		} `json:"target"`
 		Action struct {
-			On      *LightOnState      `json:"on,omitempty"`
-			Dimming *LightDimmingState `json:"dimming,omitempty"`
+			On       *LightOnState       `json:"on,omitempty"`
+			Dimming  *LightDimmingState  `json:"dimming,omitempty"`
+			Color    *LightColorState    `json:"color,omitempty"`
+			Gradient *LightGradientState `json:"gradient,omitempty"`
 		} `json:"action"`
 	} `json:"actions"`
+	Status *struct {
+		// Active is "inactive", "static" or "dynamic_palette"
+		Active string `json:"active"`
+	} `json:"status,omitempty"`
+}
+
+// IsActive reports whether the scene is the one last recalled in its group
+// and hasn't been changed since
+func (s *Scene) IsActive() bool {
+	return s.Status != nil && s.Status.Active != "" && s.Status.Active != "inactive"
 }
 
 type ScenesResponse struct {
@@ -83,3 +95,80 @@ func (c *Client) ActivateScene(ctx context.Context, sceneID string) error {
 
 	return nil
 }
+
+// SetSceneGradient sets the gradient a scene recalls on one of its lights.
+// A PUT of a scene's actions replaces them all, so the actions are sent back
+// as the bridge returned them, with only that light's gradient changed.
+func (c *Client) SetSceneGradient(ctx context.Context, sceneID, lightID string, gradient *LightGradientState) error {
+	path := fmt.Sprintf("/resource/scene/%s", sceneID)
+	respBody, err := c.doRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return errors.Wrapf(err, "getting scene %s", sceneID)
+	}
+
+	var sceneResp struct {
+		Errors []struct {
+			Description string `json:"description"`
+		} `json:"errors"`
+		Data []struct {
+			Actions []map[string]json.RawMessage `json:"actions"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &sceneResp); err != nil {
+		return errors.Wrap(err, "unmarshaling scene response")
+	}
+	if len(sceneResp.Errors) > 0 {
+		return errors.Newf("hue api returned errors: %v", sceneResp.Errors)
+	}
+	if len(sceneResp.Data) == 0 {
+		return errors.Newf("scene %s not found", sceneID)
+	}
+
+	gradientJSON, err := json.Marshal(gradient)
+	if err != nil {
+		return errors.Wrap(err, "marshaling gradient")
+	}
+
+	actions := sceneResp.Data[0].Actions
+	found := false
+	for _, action := range actions {
+		var target struct {
+			ResourceID string `json:"rid"`
+		}
+		if err := json.Unmarshal(action["target"], &target); err != nil {
+			return errors.Wrap(err, "unmarshaling scene action target")
+		}
+		if target.ResourceID != lightID {
+			continue
+		}
+
+		var lightAction map[string]json.RawMessage
+		if err := json.Unmarshal(action["action"], &lightAction); err != nil {
+			return errors.Wrap(err, "unmarshaling scene action")
+		}
+		if lightAction == nil {
+			lightAction = make(map[string]json.RawMessage)
+		}
+		lightAction["gradient"] = gradientJSON
+
+		if action["action"], err = json.Marshal(lightAction); err != nil {
+			return errors.Wrap(err, "marshaling scene action")
+		}
+		found = true
+	}
+	if !found {
+		return errors.Newf("light %s isn't in scene %s", lightID, sceneID)
+	}
+
+	req := map[string]interface{}{"actions": actions}
+	if _, err := c.doRequest(ctx, "PUT", path, req); err != nil {
+		return errors.Wrapf(err, "updating scene %s", sceneID)
+	}
+
+	c.logger.Info("scene gradient set",
+		zap.String("scene_id", sceneID),
+		zap.String("light_id", lightID),
+	)
+
+	return nil
+}
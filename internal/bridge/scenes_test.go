@@ -0,0 +1,55 @@
+package bridge
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const eveningScene = `{"errors": [], "data": [{
+  "id": "s1",
+  "metadata": {"name": "Evening"},
+  "group": {"rid": "r1", "rtype": "room"},
+  "actions": [
+    {"target": {"rid": "l1", "rtype": "light"}, "action": {"on": {"on": true}, "color_temperature": {"mirek": 400}}},
+    {"target": {"rid": "l7", "rtype": "light"}, "action": {"on": {"on": true}, "dimming": {"brightness": 60}, "effects": {"effect": "candle"}}}
+  ],
+  "palette": {"color": [], "dimming": []},
+  "speed": 0.6
+}]}`
+
+func TestSetSceneGradient(t *testing.T) {
+	var requests []string
+	var body string
+	host := newTestBridge(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == "GET" {
+			w.Write([]byte(eveningScene))
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"errors": [], "data": [{"rid": "s1", "rtype": "scene"}]}`))
+	})
+	client := NewClient(host, "key", zap.NewNop())
+
+	g, err := ParseGradient([]string{"red", "blue"}, "mirrored")
+	require.NoError(t, err)
+	require.NoError(t, client.SetSceneGradient(context.Background(), "s1", "l7", g))
+
+	assert.Equal(t, []string{"GET /clip/v2/resource/scene/s1", "PUT /clip/v2/resource/scene/s1"}, requests)
+	assert.JSONEq(t, `{"actions": [
+	  {"target": {"rid": "l1", "rtype": "light"}, "action": {"on": {"on": true}, "color_temperature": {"mirek": 400}}},
+	  {"target": {"rid": "l7", "rtype": "light"}, "action": {"on": {"on": true}, "dimming": {"brightness": 60}, "effects": {"effect": "candle"},
+	    "gradient": {"points": [{"color": {"xy": {"x": 0.7006, "y": 0.2993}}}, {"color": {"xy": {"x": 0.1355, "y": 0.0399}}}],
+	      "mode": "interpolated_palette_mirrored"}}}
+	]}`, body, "only the light's gradient should change")
+
+	err = client.SetSceneGradient(context.Background(), "s1", "l9", g)
+	assert.ErrorContains(t, err, "isn't in scene")
+}
@@ -0,0 +1,105 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Device is a physical device, such as a bulb or a motion sensor, whose
+// services are the light, light_level and other resources it provides
+type Device struct {
+	ID       string `json:"id"`
+	IDV1     string `json:"id_v1"`
+	Type     string `json:"type"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Archetype string `json:"archetype"`
+	} `json:"metadata"`
+	Services []struct {
+		ResourceID string `json:"rid"`
+		Type       string `json:"rtype"`
+	} `json:"services"`
+}
+
+type DevicesResponse struct {
+	Errors []struct {
+		Description string `json:"description"`
+	} `json:"errors"`
+	Data []Device `json:"data"`
+}
+
+// LightLevel is the ambient light sensor of a motion sensor
+type LightLevel struct {
+	ID    string `json:"id"`
+	IDV1  string `json:"id_v1"`
+	Type  string `json:"type"`
+	Owner struct {
+		ResourceID string `json:"rid"`
+		Type       string `json:"rtype"`
+	} `json:"owner"`
+	Enabled bool `json:"enabled"`
+	Light   struct {
+		// LightLevel is 10000*log10(lux)+1, deprecated in favour of the report
+		LightLevel      int  `json:"light_level"`
+		LightLevelValid bool `json:"light_level_valid"`
+		Report          *struct {
+			LightLevel int    `json:"light_level"`
+			Changed    string `json:"changed"`
+		} `json:"light_level_report,omitempty"`
+	} `json:"light"`
+}
+
+type LightLevelsResponse struct {
+	Errors []struct {
+		Description string `json:"description"`
+	} `json:"errors"`
+	Data []LightLevel `json:"data"`
+}
+
+// Lux converts the sensor's logarithmic light level to lux
+func (l *LightLevel) Lux() float64 {
+	level := l.Light.LightLevel
+	if l.Light.Report != nil {
+		level = l.Light.Report.LightLevel
+	}
+	return math.Pow(10, float64(level-1)/10000)
+}
+
+func (c *Client) GetDevices(ctx context.Context) ([]Device, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/resource/device", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting devices")
+	}
+
+	var devicesResp DevicesResponse
+	if err := json.Unmarshal(respBody, &devicesResp); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling devices response")
+	}
+
+	if len(devicesResp.Errors) > 0 {
+		return nil, errors.Newf("hue api returned errors: %v", devicesResp.Errors)
+	}
+
+	return devicesResp.Data, nil
+}
+
+func (c *Client) GetLightLevels(ctx context.Context) ([]LightLevel, error) {
+	respBody, err := c.doRequest(ctx, "GET", "/resource/light_level", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting light levels")
+	}
+
+	var lightLevelsResp LightLevelsResponse
+	if err := json.Unmarshal(respBody, &lightLevelsResp); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling light levels response")
+	}
+
+	if len(lightLevelsResp.Errors) > 0 {
+		return nil, errors.Newf("hue api returned errors: %v", lightLevelsResp.Errors)
+	}
+
+	return lightLevelsResp.Data, nil
+}
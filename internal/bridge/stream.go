@@ -0,0 +1,250 @@
+package bridge
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/pion/dtls/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// entertainmentPort is the bridge's UDP port for stream sessions
+	entertainmentPort = 2100
+
+	// MaxStreamChannels is the most channels a frame can set
+	MaxStreamChannels = 20
+	// DefaultStreamRate is the frames per second Stream.Run sends by default.
+	// The bridge passes on about 25 a second and drops the rest, but sending
+	// faster hides lost packets.
+	DefaultStreamRate = 50
+	// MaxStreamRate is the most frames per second Stream.Run sends
+	MaxStreamRate = 2 * DefaultStreamRate
+
+	streamProtocol = "HueStream"
+	// streamIDLength is the length of the configuration ID in the header
+	streamIDLength      = 36
+	streamHeaderLength  = len(streamProtocol) + 7 + streamIDLength
+	streamColorSpaceRGB = 0x00
+
+	streamHandshakeTimeout = 10 * time.Second
+	streamStopTimeout      = 5 * time.Second
+)
+
+// ErrSourceDone is returned by a FrameSource that has no more frames
+var ErrSourceDone = errors.New("frame source done")
+
+// RGB is a color with each component from 0 to 1
+type RGB struct {
+	R, G, B float64
+}
+
+// ChannelColor sets the color of one entertainment channel
+type ChannelColor struct {
+	Channel uint8
+	Color   RGB
+}
+
+// Frame is the colors of a stream's channels at one moment. Channels left
+// out keep their last color.
+type Frame []ChannelColor
+
+// FrameSource produces the frames of a stream
+type FrameSource interface {
+	// Frame returns the colors of channels once elapsed has passed since the
+	// stream began. Returning ErrSourceDone ends the stream cleanly; any other
+	// error ends it with that error.
+	Frame(elapsed time.Duration, channels []uint8) (Frame, error)
+}
+
+// Stream sends frames to an entertainment configuration over a DTLS session
+type Stream struct {
+	conn     net.Conn
+	configID string
+	channels []uint8
+	// stop ends streaming on the bridge when the stream is closed, if set
+	stop   func(ctx context.Context) error
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	sequence uint8
+	buf      []byte
+}
+
+// OpenStream starts streaming to an entertainment configuration and opens a
+// session to send frames, using clientKey, the hex key issued when pairing.
+// Closing the stream ends streaming.
+func (c *Client) OpenStream(ctx context.Context, configID, clientKey string) (*Stream, error) {
+	psk, err := hex.DecodeString(clientKey)
+	if err != nil || len(psk) == 0 {
+		return nil, errors.New("invalid client key, pair again with 'limelight setup' to get one")
+	}
+
+	config, err := c.GetEntertainmentConfiguration(ctx, configID)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := c.ApplicationID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.StartStreaming(ctx, configID); err != nil {
+		return nil, err
+	}
+	stream, err := DialStream(ctx, c.streamAddr(), identity, psk, config.ID, config.ChannelIDs(), c.logger)
+	if err != nil {
+		stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), streamStopTimeout)
+		defer cancel()
+		return nil, errors.CombineErrors(err, c.StopStreaming(stopCtx, configID))
+	}
+	stream.stop = func(ctx context.Context) error { return c.StopStreaming(ctx, configID) }
+	return stream, nil
+}
+
+// streamAddr returns the bridge's stream address, on the host of its API
+func (c *Client) streamAddr() string {
+	host := c.bridgeIP
+	if h, _, err := net.SplitHostPort(c.bridgeIP); err == nil {
+		host = h
+	}
+	port := c.streamPort
+	if port == 0 {
+		port = entertainmentPort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// DialStream opens a session to a bridge whose configuration is already
+// streaming. identity is the application ID and psk the decoded client key.
+func DialStream(ctx context.Context, addr, identity string, psk []byte, configID string, channels []uint8, logger *zap.Logger) (*Stream, error) {
+	if len(configID) != streamIDLength {
+		return nil, errors.Newf("invalid entertainment configuration ID %q", configID)
+	}
+	if len(channels) > MaxStreamChannels {
+		return nil, errors.Newf("entertainment configuration has %d channels, streams take at most %d", len(channels), MaxStreamChannels)
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving %s", addr)
+	}
+
+	config := &dtls.Config{
+		PSK: func([]byte) ([]byte, error) {
+			return psk, nil
+		},
+		PSKIdentityHint: []byte(identity),
+		CipherSuites:    []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, streamHandshakeTimeout)
+	defer cancel()
+	conn, err := dtls.DialWithContext(dialCtx, "udp", raddr, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening stream session to %s", addr)
+	}
+
+	logger.Info("entertainment stream opened",
+		zap.String("entertainment_configuration_id", configID),
+		zap.Int("channels", len(channels)),
+	)
+
+	return &Stream{
+		conn:     conn,
+		configID: configID,
+		channels: channels,
+		logger:   logger,
+	}, nil
+}
+
+// Channels returns the IDs of the configuration's channels
+func (s *Stream) Channels() []uint8 {
+	return s.channels
+}
+
+// Send sends one frame
+func (s *Stream) Send(frame Frame) error {
+	if len(frame) > MaxStreamChannels {
+		return errors.Newf("frame sets %d channels, at most %d fit", len(frame), MaxStreamChannels)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf = encodeFrame(s.buf[:0], s.configID, s.sequence, frame)
+	s.sequence++
+	if _, err := s.conn.Write(s.buf); err != nil {
+		return errors.Wrap(err, "sending stream frame")
+	}
+	return nil
+}
+
+// Run sends frames from source at rate frames per second, DefaultStreamRate if
+// rate is 0 and at most MaxStreamRate, until ctx is done or the source ends
+func (s *Stream) Run(ctx context.Context, source FrameSource, rate int) error {
+	if rate <= 0 {
+		rate = DefaultStreamRate
+	}
+	rate = min(rate, MaxStreamRate)
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		frame, err := source.Frame(time.Since(start), s.channels)
+		if errors.Is(err, ErrSourceDone) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "producing stream frame")
+		}
+		if err := s.Send(frame); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close ends the session and, for a stream from OpenStream, streaming
+func (s *Stream) Close() error {
+	err := s.conn.Close()
+	if s.stop != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), streamStopTimeout)
+		defer cancel()
+		err = errors.CombineErrors(err, s.stop(ctx))
+	}
+	s.logger.Info("entertainment stream closed", zap.String("entertainment_configuration_id", s.configID))
+	return err
+}
+
+// encodeFrame appends a version 2 stream message setting frame's channels to
+// RGB colors with 16 bits per component
+func encodeFrame(buf []byte, configID string, sequence uint8, frame Frame) []byte {
+	buf = append(buf, streamProtocol...)
+	buf = append(buf, 0x02, 0x00, sequence, 0x00, 0x00, streamColorSpaceRGB, 0x00)
+	buf = append(buf, configID...)
+	for _, ch := range frame {
+		buf = append(buf, ch.Channel)
+		buf = binary.BigEndian.AppendUint16(buf, component(ch.Color.R))
+		buf = binary.BigEndian.AppendUint16(buf, component(ch.Color.G))
+		buf = binary.BigEndian.AppendUint16(buf, component(ch.Color.B))
+	}
+	return buf
+}
+
+func component(v float64) uint16 {
+	return uint16(math.Round(min(max(v, 0), 1) * math.MaxUint16))
+}
@@ -0,0 +1,301 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testConfigID  = "1a8d99cc-967b-44f2-9202-43f976c0fa6b"
+	testAppID     = "94ea2a4d-3a6c-4a1b-8f7b-bd7cd2a37d30"
+	testClientKey = "0123456789ABCDEF0123456789ABCDEF"
+)
+
+// newTestStreamServer starts a DTLS stand-in for the bridge's stream port. It
+// accepts sessions from testAppID with testClientKey and passes on each
+// message received.
+func newTestStreamServer(t *testing.T) (int, <-chan []byte) {
+	psk, err := hex.DecodeString(testClientKey)
+	require.NoError(t, err)
+
+	listener, err := dtls.Listen("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, &dtls.Config{
+		PSK: func(identity []byte) ([]byte, error) {
+			if string(identity) != testAppID {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return psk, nil
+		},
+		CipherSuites: []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	})
+	require.NoError(t, err)
+
+	messages := make(chan []byte, 100)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(messages)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			messages <- bytes.Clone(buf[:n])
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		wg.Wait()
+	})
+
+	return listener.Addr().(*net.UDPAddr).Port, messages
+}
+
+// decodeFrame reads a stream message back into its sequence number and frame
+func decodeFrame(t *testing.T, msg []byte) (uint8, Frame) {
+	require.GreaterOrEqual(t, len(msg), streamHeaderLength)
+	require.Equal(t, streamProtocol, string(msg[:9]))
+	require.Equal(t, []byte{0x02, 0x00}, msg[9:11], "version")
+	require.Equal(t, byte(streamColorSpaceRGB), msg[14])
+	require.Equal(t, testConfigID, string(msg[16:streamHeaderLength]))
+
+	var frame Frame
+	body := msg[streamHeaderLength:]
+	require.Zero(t, len(body)%7)
+	for i := 0; i < len(body); i += 7 {
+		c := func(j int) float64 {
+			return float64(binary.BigEndian.Uint16(body[i+j:])) / math.MaxUint16
+		}
+		frame = append(frame, ChannelColor{Channel: body[i], Color: RGB{c(1), c(3), c(5)}})
+	}
+	return msg[11], frame
+}
+
+func TestGetEntertainmentConfigurations(t *testing.T) {
+	host := newTestBridge(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/clip/v2/resource/entertainment_configuration", r.URL.Path)
+		w.Write([]byte(`{"errors": [], "data": [{
+  "id": "` + testConfigID + `",
+  "type": "entertainment_configuration",
+  "metadata": {"name": "TV"},
+  "configuration_type": "screen",
+  "status": "active",
+  "active_streamer": {"rid": "f5f2a2b4-6e8b-4f7d-9d59-2c1c7e4bd0a1", "rtype": "auth_v1"},
+  "channels": [
+    {"channel_id": 0, "position": {"x": -0.6, "y": 0.8, "z": 0}, "members": [{"service": {"rid": "l1", "rtype": "entertainment"}, "index": 0}]},
+    {"channel_id": 1, "position": {"x": 0.6, "y": 0.8, "z": 0}, "members": [{"service": {"rid": "l2", "rtype": "entertainment"}, "index": 0}]}
+  ],
+  "light_services": [{"rid": "l1", "rtype": "light"}, {"rid": "l2", "rtype": "light"}]
+}]}`))
+	})
+
+	configs, err := NewClient(host, "key", zap.NewNop()).GetEntertainmentConfigurations(context.Background())
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "TV", configs[0].Metadata.Name)
+	assert.Equal(t, "screen", configs[0].ConfigurationType)
+	assert.True(t, configs[0].IsActive())
+	assert.Equal(t, []uint8{0, 1}, configs[0].ChannelIDs())
+	assert.Equal(t, 0.6, configs[0].Channels[1].Position.X)
+	assert.Len(t, configs[0].LightServices, 2)
+}
+
+func TestOpenStream(t *testing.T) {
+	port, messages := newTestStreamServer(t)
+
+	var mu sync.Mutex
+	var actions []string
+	host := newTestBridge(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/auth/v1":
+			assert.Equal(t, "key", r.Header.Get("hue-application-key"))
+			w.Header().Set("hue-application-id", testAppID)
+		case r.Method == "GET":
+			assert.Equal(t, "/clip/v2/resource/entertainment_configuration/"+testConfigID, r.URL.Path)
+			w.Write([]byte(`{"errors": [], "data": [{"id": "` + testConfigID + `", "channels": [{"channel_id": 0}, {"channel_id": 1}, {"channel_id": 2}]}]}`))
+		case r.Method == "PUT":
+			var req entertainmentActionRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			mu.Lock()
+			actions = append(actions, req.Action)
+			mu.Unlock()
+			w.Write([]byte(`{"errors": [], "data": [{"rid": "` + testConfigID + `"}]}`))
+		}
+	})
+
+	client := NewClient(host, "key", zap.NewNop())
+	client.streamPort = port
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.OpenStream(ctx, testConfigID, testClientKey)
+	require.NoError(t, err)
+	assert.Equal(t, []uint8{0, 1, 2}, stream.Channels())
+
+	require.NoError(t, stream.Send(Frame{{Channel: 0, Color: RGB{1, 0, 0}}, {Channel: 2, Color: RGB{0, 0.5, 1}}}))
+	require.NoError(t, stream.Send(Frame{{Channel: 1, Color: RGB{0, 1, 0}}}))
+
+	seq, frame := decodeFrame(t, <-messages)
+	assert.Equal(t, uint8(0), seq)
+	require.Len(t, frame, 2)
+	assert.Equal(t, ChannelColor{Channel: 0, Color: RGB{1, 0, 0}}, frame[0])
+	assert.Equal(t, uint8(2), frame[1].Channel)
+	assert.InDelta(t, 0.5, frame[1].Color.G, 0.0001)
+
+	seq, frame = decodeFrame(t, <-messages)
+	assert.Equal(t, uint8(1), seq)
+	assert.Equal(t, Frame{{Channel: 1, Color: RGB{0, 1, 0}}}, frame)
+
+	require.NoError(t, stream.Close())
+	mu.Lock()
+	assert.Equal(t, []string{"start", "stop"}, actions)
+	mu.Unlock()
+}
+
+func TestOpenStreamRejectsBadClientKey(t *testing.T) {
+	client := NewClient("127.0.0.1:1", "key", zap.NewNop())
+	_, err := client.OpenStream(context.Background(), testConfigID, "not hex")
+	require.ErrorContains(t, err, "invalid client key")
+}
+
+// countingSource ends after a number of frames
+type countingSource struct {
+	frames int
+}
+
+func (c *countingSource) Frame(elapsed time.Duration, channels []uint8) (Frame, error) {
+	if c.frames == 0 {
+		return nil, ErrSourceDone
+	}
+	c.frames--
+	return ColorCycle{Spread: true}.Frame(elapsed, channels)
+}
+
+func TestStreamRun(t *testing.T) {
+	port, messages := newTestStreamServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := DialStream(ctx, net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), testAppID, mustHex(t, testClientKey), testConfigID, []uint8{3, 4}, zap.NewNop())
+	require.NoError(t, err)
+	defer stream.Close()
+
+	// Rates beyond MaxStreamRate are clamped rather than ticking at 0
+	require.NoError(t, stream.Run(ctx, &countingSource{frames: 3}, 2_000_000_000))
+	for i := range 3 {
+		seq, frame := decodeFrame(t, <-messages)
+		assert.Equal(t, uint8(i), seq)
+		require.Len(t, frame, 2)
+		assert.Equal(t, uint8(3), frame[0].Channel)
+		assert.Equal(t, uint8(4), frame[1].Channel)
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestColorCycle(t *testing.T) {
+	cycle := ColorCycle{Period: 6 * time.Second}
+
+	frame, err := cycle.Frame(0, []uint8{0, 1})
+	require.NoError(t, err)
+	assert.Equal(t, Frame{{Channel: 0, Color: RGB{1, 0, 0}}, {Channel: 1, Color: RGB{1, 0, 0}}}, frame)
+
+	frame, err = cycle.Frame(8*time.Second, []uint8{0})
+	require.NoError(t, err)
+	assert.Equal(t, Frame{{Channel: 0, Color: RGB{0, 1, 0}}}, frame, "a third of the way round")
+
+	cycle.Spread = true
+	half := 0.5
+	cycle.Brightness = &half
+	frame, err = cycle.Frame(0, []uint8{0, 1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, Frame{
+		{Channel: 0, Color: RGB{0.5, 0, 0}},
+		{Channel: 1, Color: RGB{0, 0.5, 0}},
+		{Channel: 2, Color: RGB{0, 0, 0.5}},
+	}, frame)
+
+	// Zero brightness is dark rather than unset
+	dark := 0.0
+	cycle.Brightness = &dark
+	frame, err = cycle.Frame(0, []uint8{0})
+	require.NoError(t, err)
+	assert.Equal(t, Frame{{Channel: 0, Color: RGB{}}}, frame)
+}
+
+// tone returns a chunk of S16LE samples alternating at amplitude
+func tone(amplitude int16) []byte {
+	var buf []byte
+	for i := range audioChunkSamples {
+		s := amplitude
+		if i%2 == 1 {
+			s = -amplitude
+		}
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(s))
+	}
+	return buf
+}
+
+func TestAudioLevel(t *testing.T) {
+	r, w := io.Pipe()
+	audio := NewAudioLevel(r)
+	channels := []uint8{0, 1, 2, 3}
+
+	// A quarter of full scale fills half the meter
+	_, err := w.Write(tone(math.MaxInt16 / 4))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		audio.mu.Lock()
+		defer audio.mu.Unlock()
+		return audio.level > 0
+	}, time.Second, time.Millisecond)
+
+	frame, err := audio.Frame(time.Second, channels)
+	require.NoError(t, err)
+	require.Len(t, frame, 4)
+	assert.InDelta(t, 0, frame[0].Color.R, 0.01, "first channel is green")
+	assert.InDelta(t, 1, frame[0].Color.G, 0.01)
+	assert.InDelta(t, 1, max(frame[1].Color.R, frame[1].Color.G), 0.01)
+	assert.Equal(t, RGB{}, frame[2].Color)
+	assert.Equal(t, RGB{}, frame[3].Color)
+
+	// Silence lets the meter fall away
+	frame, err = audio.Frame(time.Second+audioDecay, channels)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.74, frame[0].Color.G, 0.01)
+	assert.Equal(t, RGB{}, frame[1].Color)
+
+	w.Close()
+	require.Eventually(t, func() bool {
+		_, err := audio.Frame(2*time.Second, channels)
+		return err == ErrSourceDone
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,519 @@
+package calendar
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/teambition/rrule-go"
+)
+
+const (
+	dateLayout          = "20060102"
+	localDateTimeLayout = "20060102T150405"
+	utcDateTimeLayout   = "20060102T150405Z"
+)
+
+// Calendar is the events of an iCalendar (.ics) file
+type Calendar struct {
+	// Name is the calendar's display name, if the file has one
+	Name   string
+	Events []*Event
+}
+
+// Event is a VEVENT. Recurring events keep their rule and are expanded by
+// Calendar.Occurrences.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Categories  []string
+	// Status is TENTATIVE, CONFIRMED or CANCELLED, empty if not given
+	Status string
+	// AllDay events have dates rather than times and cover whole days in the
+	// time zone they are evaluated in
+	AllDay bool
+
+	start        dateTime
+	end          *dateTime
+	duration     time.Duration
+	days         int
+	rrule        string
+	rdates       []dateTime
+	exdates      []dateTime
+	recurrenceID *dateTime
+}
+
+// Occurrence is one instance of an event
+type Occurrence struct {
+	Event      *Event
+	Start, End time.Time
+}
+
+// dateTime is a DATE or DATE-TIME value. Floating values, which include dates
+// and times whose TZID isn't known, take the time zone they are evaluated in.
+type dateTime struct {
+	t        time.Time
+	floating bool
+}
+
+func (d dateTime) in(loc *time.Location) time.Time {
+	if !d.floating {
+		return d.t.In(loc)
+	}
+	return time.Date(d.t.Year(), d.t.Month(), d.t.Day(), d.t.Hour(), d.t.Minute(), d.t.Second(), 0, loc)
+}
+
+// property is an unfolded content line
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads an iCalendar file. Components other than events, and event
+// properties limelight doesn't use, are ignored.
+func Parse(r io.Reader) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &Calendar{}
+	var stack []string
+	var event *Event
+	seenCalendar := false
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		prop, err := parseProperty(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", i+1)
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			component := strings.ToUpper(prop.value)
+			if component == "VCALENDAR" {
+				seenCalendar = true
+			}
+			if component == "VEVENT" && len(stack) > 0 && stack[len(stack)-1] == "VCALENDAR" {
+				event = &Event{}
+			}
+			stack = append(stack, component)
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(prop.value) {
+				return nil, errors.Newf("line %d: unexpected END:%s", i+1, prop.value)
+			}
+			stack = stack[:len(stack)-1]
+			if strings.EqualFold(prop.value, "VEVENT") && event != nil {
+				if err := event.finish(); err != nil {
+					return nil, errors.Wrapf(err, "event %q", event.Summary)
+				}
+				cal.Events = append(cal.Events, event)
+				event = nil
+			}
+			continue
+		}
+
+		if len(stack) == 0 {
+			continue
+		}
+		switch stack[len(stack)-1] {
+		case "VCALENDAR":
+			if prop.name == "X-WR-CALNAME" {
+				cal.Name = unescapeText(prop.value)
+			}
+		case "VEVENT":
+			if event != nil {
+				if err := event.set(prop); err != nil {
+					return nil, errors.Wrapf(err, "line %d", i+1)
+				}
+			}
+		}
+	}
+
+	if !seenCalendar {
+		return nil, errors.New("not an iCalendar file: no BEGIN:VCALENDAR")
+	}
+	if len(stack) > 0 {
+		return nil, errors.Newf("missing END:%s", stack[len(stack)-1])
+	}
+	return cal, nil
+}
+
+// unfold joins content lines continued on lines starting with a space or tab
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading calendar")
+	}
+	return lines, nil
+}
+
+func parseProperty(line string) (*property, error) {
+	// The value starts at the first colon outside a quoted parameter value
+	colon := -1
+	quoted := false
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return nil, errors.Newf("invalid content line %q", line)
+	}
+
+	parts := splitOutsideQuotes(line[:colon], ';')
+	prop := &property{
+		name:   strings.ToUpper(parts[0]),
+		params: make(map[string]string),
+		value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return prop, nil
+}
+
+func splitOutsideQuotes(s string, sep rune) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, r := range s {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == sep && !quoted {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func (e *Event) set(prop *property) error {
+	var err error
+	switch prop.name {
+	case "UID":
+		e.UID = prop.value
+	case "SUMMARY":
+		e.Summary = unescapeText(prop.value)
+	case "DESCRIPTION":
+		e.Description = unescapeText(prop.value)
+	case "LOCATION":
+		e.Location = unescapeText(prop.value)
+	case "STATUS":
+		e.Status = strings.ToUpper(prop.value)
+	case "CATEGORIES":
+		for _, category := range splitText(prop.value) {
+			if category = strings.TrimSpace(category); category != "" {
+				e.Categories = append(e.Categories, category)
+			}
+		}
+	case "DTSTART":
+		e.start, e.AllDay, err = parseDateTime(prop.value, prop.params)
+	case "DTEND":
+		var end dateTime
+		end, _, err = parseDateTime(prop.value, prop.params)
+		e.end = &end
+	case "DURATION":
+		e.duration, err = parseDuration(prop.value)
+	case "RRULE":
+		e.rrule = prop.value
+	case "RDATE", "EXDATE":
+		for _, value := range strings.Split(prop.value, ",") {
+			// Periods in RDATE are reduced to their start
+			value, _, _ = strings.Cut(value, "/")
+			d, _, err := parseDateTime(value, prop.params)
+			if err != nil {
+				return errors.Wrapf(err, "%s", prop.name)
+			}
+			if prop.name == "RDATE" {
+				e.rdates = append(e.rdates, d)
+			} else {
+				e.exdates = append(e.exdates, d)
+			}
+		}
+	case "RECURRENCE-ID":
+		var id dateTime
+		id, _, err = parseDateTime(prop.value, prop.params)
+		e.recurrenceID = &id
+	}
+	return errors.Wrapf(err, "%s", prop.name)
+}
+
+// finish checks the event and works out how long it lasts
+func (e *Event) finish() error {
+	if e.start.t.IsZero() {
+		return errors.New("event has no DTSTART")
+	}
+
+	switch {
+	case e.end != nil && e.AllDay:
+		e.days = int(e.end.t.Sub(e.start.t).Hours()+12) / 24
+	case e.end != nil:
+		// Both ends are compared in the same zone, which only matters when floating
+		e.duration = e.end.in(time.UTC).Sub(e.start.in(time.UTC))
+	case e.AllDay && e.duration == 0:
+		e.days = 1
+	case e.AllDay:
+		e.days = int(e.duration.Hours()+12) / 24
+		e.duration = 0
+	}
+	if e.duration < 0 || e.days < 0 {
+		return errors.New("event ends before it starts")
+	}
+
+	if e.rrule != "" {
+		if _, err := rrule.StrToROption(e.rrule); err != nil {
+			return errors.Wrapf(err, "invalid RRULE %q", e.rrule)
+		}
+	}
+	return nil
+}
+
+// endOf returns the end of the occurrence starting at start
+func (e *Event) endOf(start time.Time) time.Time {
+	if e.AllDay {
+		return start.AddDate(0, 0, e.days)
+	}
+	return start.Add(e.duration)
+}
+
+// span is the longest an occurrence can last
+func (e *Event) span() time.Duration {
+	if e.AllDay {
+		return time.Duration(e.days) * 25 * time.Hour
+	}
+	return e.duration
+}
+
+// Occurrences returns the instances of events, recurring ones expanded, that
+// overlap the interval from from to to, sorted by start. Cancelled events and
+// instances are left out. Dates and floating times are taken to be in from's
+// time zone.
+func (c *Calendar) Occurrences(from, to time.Time) ([]Occurrence, error) {
+	loc := from.Location()
+
+	// Instances moved or cancelled by an event with a RECURRENCE-ID
+	overridden := make(map[string]map[int64]bool)
+	for _, e := range c.Events {
+		if e.recurrenceID == nil {
+			continue
+		}
+		if overridden[e.UID] == nil {
+			overridden[e.UID] = make(map[int64]bool)
+		}
+		overridden[e.UID][e.recurrenceID.in(loc).Unix()] = true
+	}
+
+	var occurrences []Occurrence
+	for _, e := range c.Events {
+		starts, err := e.starts(from.Add(-e.span()), to, loc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "expanding event %q", e.Summary)
+		}
+
+		for _, start := range starts {
+			if e.recurrenceID == nil && overridden[e.UID][start.Unix()] {
+				continue
+			}
+			if e.Status == "CANCELLED" {
+				continue
+			}
+			end := e.endOf(start)
+			if start.Before(to) && end.After(from) {
+				occurrences = append(occurrences, Occurrence{Event: e, Start: start, End: end})
+			}
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+	return occurrences, nil
+}
+
+// At returns the occurrences in progress at t
+func (c *Calendar) At(t time.Time) ([]Occurrence, error) {
+	return c.Occurrences(t, t.Add(time.Nanosecond))
+}
+
+// starts returns the start times of the event's instances between after and
+// before, in loc. Recurrences are expanded in the event's own time zone, so a
+// 09:00 New York meeting stays at 09:00 there when the clocks change at
+// different times in loc; only dates and floating times take loc's.
+func (e *Event) starts(after, before time.Time, loc *time.Location) ([]time.Time, error) {
+	zone := loc
+	if !e.start.floating {
+		zone = e.start.t.Location()
+	}
+	dtstart := e.start.in(zone)
+	if e.rrule == "" && len(e.rdates) == 0 {
+		if dtstart.Before(after) || dtstart.After(before) {
+			return nil, nil
+		}
+		return []time.Time{dtstart.In(loc)}, nil
+	}
+
+	set := &rrule.Set{}
+	if e.rrule != "" {
+		options, err := rrule.StrToROptionInLocation(e.rrule, zone)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid RRULE %q", e.rrule)
+		}
+		options.Dtstart = dtstart
+		rule, err := rrule.NewRRule(*options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid RRULE %q", e.rrule)
+		}
+		set.RRule(rule)
+	} else {
+		set.RDate(dtstart)
+	}
+	for _, d := range e.rdates {
+		set.RDate(d.in(zone))
+	}
+	for _, d := range e.exdates {
+		set.ExDate(d.in(zone))
+	}
+
+	starts := set.Between(after, before, true)
+	for i := range starts {
+		starts[i] = starts[i].In(loc)
+	}
+	return starts, nil
+}
+
+// parseDateTime parses a DATE or DATE-TIME value, reporting whether it is a date
+func parseDateTime(value string, params map[string]string) (dateTime, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return dateTime{}, false, errors.Newf("invalid date %q", value)
+		}
+		return dateTime{t: t, floating: true}, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcDateTimeLayout, value)
+		if err != nil {
+			return dateTime{}, false, errors.Newf("invalid date-time %q", value)
+		}
+		return dateTime{t: t}, false, nil
+	}
+
+	if tzid := params["TZID"]; tzid != "" {
+		// Zones outside the IANA database, such as Windows zone names, are
+		// treated as floating
+		if loc, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			t, err := time.ParseInLocation(localDateTimeLayout, value, loc)
+			if err != nil {
+				return dateTime{}, false, errors.Newf("invalid date-time %q", value)
+			}
+			return dateTime{t: t}, false, nil
+		}
+	}
+
+	t, err := time.Parse(localDateTimeLayout, value)
+	if err != nil {
+		return dateTime{}, false, errors.Newf("invalid date-time %q", value)
+	}
+	return dateTime{t: t, floating: true}, false, nil
+}
+
+// parseDuration parses an iCalendar duration such as P1D, PT1H30M or -PT15M
+func parseDuration(value string) (time.Duration, error) {
+	s := value
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+	}
+	s = strings.TrimLeft(s, "+-")
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, errors.Newf("invalid duration %q", value)
+	}
+	s = s[1:]
+
+	units := map[byte]time.Duration{
+		'W': 7 * 24 * time.Hour,
+		'D': 24 * time.Hour,
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+	}
+	var total time.Duration
+	inTime := false
+	number := ""
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 'T':
+			inTime = true
+		case c >= '0' && c <= '9':
+			number += string(c)
+		default:
+			unit, ok := units[c]
+			// M is months outside the time part, which durations can't have
+			if !ok || number == "" || (c == 'M' && !inTime) || (inTime && (c == 'W' || c == 'D')) {
+				return 0, errors.Newf("invalid duration %q", value)
+			}
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, errors.Newf("invalid duration %q", value)
+			}
+			total += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	if number != "" {
+		return 0, errors.Newf("invalid duration %q", value)
+	}
+	return sign * total, nil
+}
+
+// splitText splits a list of TEXT values on unescaped commas and unescapes them
+func splitText(value string) []string {
+	var parts []string
+	var current strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			current.WriteByte(value[i])
+			current.WriteByte(value[i+1])
+			i++
+			continue
+		}
+		if value[i] == ',' {
+			parts = append(parts, unescapeText(current.String()))
+			current.Reset()
+			continue
+		}
+		current.WriteByte(value[i])
+	}
+	return append(parts, unescapeText(current.String()))
+}
+
+func unescapeText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
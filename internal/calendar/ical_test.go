@@ -0,0 +1,236 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, lines ...string) *Calendar {
+	cal, err := Parse(strings.NewReader(strings.Join(lines, "\r\n")))
+	require.NoError(t, err)
+	return cal
+}
+
+func at(t *testing.T, value string, loc *time.Location) time.Time {
+	tm, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	require.NoError(t, err)
+	return tm
+}
+
+func summaries(occurrences []Occurrence) []string {
+	var names []string
+	for _, o := range occurrences {
+		names = append(names, o.Event.Summary)
+	}
+	return names
+}
+
+func TestParse(t *testing.T) {
+	cal := parse(t,
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"X-WR-CALNAME:Home",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Berlin",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:1",
+		"DTSTART;TZID=Europe/Berlin:20261019T090000",
+		"DTEND;TZID=Europe/Berlin:20261019T093000",
+		"SUMMARY:Stand-up\\, daily",
+		"DESCRIPTION:Line one\\nline two that is folded",
+		"  onto the next line",
+		"CATEGORIES:Work,Meeting",
+		"CATEGORIES:Recurring",
+		"BEGIN:VALARM",
+		"SUMMARY:Not the event",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+
+	assert.Equal(t, "Home", cal.Name)
+	require.Len(t, cal.Events, 1)
+	event := cal.Events[0]
+	assert.Equal(t, "Stand-up, daily", event.Summary)
+	assert.Equal(t, "Line one\nline two that is folded onto the next line", event.Description)
+	assert.Equal(t, []string{"Work", "Meeting", "Recurring"}, event.Categories)
+	assert.False(t, event.AllDay)
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	occurrences, err := cal.At(at(t, "2026-10-19 09:15", berlin).In(time.UTC))
+	require.NoError(t, err)
+	require.Len(t, occurrences, 1)
+	assert.True(t, occurrences[0].Start.Equal(at(t, "2026-10-19 09:00", berlin)))
+	assert.True(t, occurrences[0].End.Equal(at(t, "2026-10-19 09:30", berlin)))
+}
+
+func TestParseRejectsInvalidFiles(t *testing.T) {
+	for _, data := range []string{
+		"not a calendar",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:No start\nEND:VEVENT\nEND:VCALENDAR",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20261019T090000Z\nEND:VCALENDAR",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:2026-10-19\nEND:VEVENT\nEND:VCALENDAR",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20261019T090000Z\nRRULE:FREQ=SOMETIMES\nEND:VEVENT\nEND:VCALENDAR",
+	} {
+		_, err := Parse(strings.NewReader(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestAllDayEventsFollowTheEvaluationTimeZone(t *testing.T) {
+	cal := parse(t,
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20261225",
+		"SUMMARY:Christmas Day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20261231",
+		"DTEND;VALUE=DATE:20270102",
+		"SUMMARY:New Year break",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+
+	for _, zone := range []string{"Pacific/Auckland", "America/Los_Angeles"} {
+		loc, err := time.LoadLocation(zone)
+		require.NoError(t, err)
+
+		occurrences, err := cal.At(at(t, "2026-12-25 00:30", loc))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Christmas Day"}, summaries(occurrences), zone)
+
+		occurrences, err = cal.At(at(t, "2026-12-24 23:30", loc))
+		require.NoError(t, err)
+		assert.Empty(t, occurrences, zone)
+
+		occurrences, err = cal.At(at(t, "2027-01-01 18:00", loc))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"New Year break"}, summaries(occurrences), zone)
+	}
+}
+
+func TestRecurringEvents(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	cal := parse(t,
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:weekly",
+		"DTSTART;TZID=America/New_York:20261005T140000",
+		"DURATION:PT1H",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20261130T235959Z",
+		"EXDATE;TZID=America/New_York:20261012T140000",
+		"SUMMARY:Planning",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:weekly",
+		"RECURRENCE-ID;TZID=America/New_York:20261019T140000",
+		"DTSTART;TZID=America/New_York:20261019T160000",
+		"DURATION:PT1H",
+		"SUMMARY:Planning (moved)",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:weekly",
+		"RECURRENCE-ID;TZID=America/New_York:20261021T140000",
+		"DTSTART;TZID=America/New_York:20261021T140000",
+		"STATUS:CANCELLED",
+		"SUMMARY:Planning",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:birthday",
+		"DTSTART;VALUE=DATE:19900301",
+		"RRULE:FREQ=YEARLY",
+		"SUMMARY:Birthday",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+
+	tests := []struct {
+		now  string
+		want []string
+	}{
+		{"2026-10-05 14:30", []string{"Planning"}},
+		{"2026-10-07 14:59", []string{"Planning"}},
+		{"2026-10-07 15:00", nil},
+		{"2026-10-12 14:30", nil},
+		{"2026-10-19 14:30", nil},
+		{"2026-10-19 16:30", []string{"Planning (moved)"}},
+		{"2026-10-21 14:30", nil},
+		// Across the end of daylight saving time the meeting stays at 14:00
+		{"2026-11-02 14:30", []string{"Planning"}},
+		{"2026-12-02 14:30", nil},
+		{"2027-03-01 08:00", []string{"Birthday"}},
+	}
+	for _, tt := range tests {
+		occurrences, err := cal.At(at(t, tt.now, loc))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, summaries(occurrences), tt.now)
+	}
+
+	occurrences, err := cal.Occurrences(at(t, "2026-10-05 00:00", loc), at(t, "2026-10-12 00:00", loc))
+	require.NoError(t, err)
+	require.Len(t, occurrences, 2)
+	assert.Equal(t, time.Wednesday, occurrences[1].Start.Weekday())
+}
+
+func TestRecurringEventsKeepTheirTimeZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	cal := parse(t,
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"DTSTART;TZID=America/New_York:20260223T090000",
+		"DURATION:PT30M",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO",
+		"SUMMARY:Standup",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	)
+
+	// New York moves its clocks on March 8 and London not until March 29, so
+	// for those weeks the meeting is an hour earlier in London
+	occurrences, err := cal.Occurrences(at(t, "2026-03-09 00:00", london), at(t, "2026-03-10 00:00", london))
+	require.NoError(t, err)
+	require.Len(t, occurrences, 1)
+	assert.Equal(t, at(t, "2026-03-09 13:00", london), occurrences[0].Start)
+	assert.Equal(t, london, occurrences[0].Start.Location())
+
+	occurrences, err = cal.At(at(t, "2026-03-09 09:15", newYork).In(london))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Standup"}, summaries(occurrences))
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT15M":      15 * time.Minute,
+		"PT1H30M":    90 * time.Minute,
+		"P1D":        24 * time.Hour,
+		"P1W":        7 * 24 * time.Hour,
+		"P1DT2H":     26 * time.Hour,
+		"-PT10M":     -10 * time.Minute,
+		"+PT5M30S":   5*time.Minute + 30*time.Second,
+		"P2DT0H0M0S": 48 * time.Hour,
+	}
+	for value, want := range tests {
+		got, err := parseDuration(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+
+	for _, value := range []string{"", "P", "1H", "P1M", "PT1D", "PT1", "P1X"} {
+		_, err := parseDuration(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,212 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DefaultCacheTTL is how long a downloaded calendar is used before it is fetched again
+	DefaultCacheTTL = time.Hour
+
+	fetchTimeout = 30 * time.Second
+	// maxCalendarSize bounds how much of a calendar is read
+	maxCalendarSize = 10 << 20
+)
+
+// Loader reads calendars from files and URLs. Downloaded calendars are kept in
+// the calendar_cache table and, if a later download fails, the cached copy is
+// used however old it is.
+type Loader struct {
+	// HTTPClient is used for downloads, defaults to a client with a 30 second timeout
+	HTTPClient *http.Client
+
+	db  *sql.DB
+	ttl time.Duration
+
+	mu     sync.Mutex
+	parsed map[string]parsedCalendar
+}
+
+type parsedCalendar struct {
+	calendar *Calendar
+	loadedAt time.Time
+}
+
+// NewLoader creates a loader that caches downloads in db, or only in memory if db is nil
+func NewLoader(db *sql.DB, ttl time.Duration) *Loader {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	return &Loader{
+		HTTPClient: &http.Client{Timeout: fetchTimeout},
+		db:         db,
+		ttl:        ttl,
+		parsed:     make(map[string]parsedCalendar),
+	}
+}
+
+// ValidateSource checks that source is an http, https or webcal URL or an absolute path
+func ValidateSource(source string) error {
+	if source == "" {
+		return errors.New("calendar source cannot be empty")
+	}
+	if isURL(source) {
+		u, err := url.Parse(source)
+		if err != nil || u.Host == "" {
+			return errors.Newf("invalid calendar url: %s", source)
+		}
+		return nil
+	}
+	if !filepath.IsAbs(source) {
+		return errors.Newf("calendar must be a URL or an absolute path: %s", source)
+	}
+	return nil
+}
+
+// Load returns the calendar at source, a URL or an absolute path to an .ics file
+func (l *Loader) Load(ctx context.Context, source string) (*Calendar, error) {
+	if err := ValidateSource(source); err != nil {
+		return nil, err
+	}
+	if !isURL(source) {
+		return loadFile(source)
+	}
+
+	key := fetchURL(source)
+	l.mu.Lock()
+	cached, ok := l.parsed[key]
+	l.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < l.ttl {
+		return cached.calendar, nil
+	}
+
+	body, loadedAt, fresh := l.lookup(ctx, key)
+	if !fresh {
+		// Until the next attempt, a stale copy is as good as a fresh one
+		loadedAt = time.Now()
+		downloaded, err := l.fetch(ctx, key)
+		switch {
+		case err != nil && body == nil:
+			return nil, err
+		case err == nil && (body == nil || isCalendar(downloaded)):
+			// A broken download doesn't replace the last good copy
+			body = downloaded
+			l.store(ctx, key, body)
+		}
+	}
+
+	cal, err := Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing calendar %s", source)
+	}
+
+	l.mu.Lock()
+	l.parsed[key] = parsedCalendar{calendar: cal, loadedAt: loadedAt}
+	l.mu.Unlock()
+	return cal, nil
+}
+
+func loadFile(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening calendar")
+	}
+	defer f.Close()
+
+	cal, err := Parse(io.LimitReader(f, maxCalendarSize))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing calendar %s", path)
+	}
+	return cal, nil
+}
+
+func (l *Loader) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Accept", "text/calendar")
+
+	resp, err := l.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "downloading calendar %s", rawURL)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Newf("downloading calendar %s: %s", rawURL, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCalendarSize))
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading calendar %s", rawURL)
+	}
+	return body, nil
+}
+
+// lookup returns the cached copy of a calendar, if any, and whether it is still fresh
+func (l *Loader) lookup(ctx context.Context, key string) ([]byte, time.Time, bool) {
+	if l.db == nil {
+		return nil, time.Time{}, false
+	}
+
+	var body string
+	var fetchedAt time.Time
+	err := l.db.QueryRowContext(ctx,
+		"SELECT body, fetched_at FROM calendar_cache WHERE url = ?", key,
+	).Scan(&body, &fetchedAt)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return []byte(body), fetchedAt, time.Since(fetchedAt) < l.ttl
+}
+
+// store saves a downloaded calendar. Failures are not fatal; the calendar is
+// downloaded again next time.
+func (l *Loader) store(ctx context.Context, key string, body []byte) {
+	if l.db == nil {
+		return
+	}
+
+	_, _ = l.db.ExecContext(ctx, `
+		INSERT INTO calendar_cache (url, body) VALUES (?, ?)
+		ON CONFLICT (url) DO UPDATE SET body = excluded.body, fetched_at = CURRENT_TIMESTAMP
+	`, key, string(body))
+}
+
+func isCalendar(body []byte) bool {
+	_, err := Parse(bytes.NewReader(body))
+	return err == nil
+}
+
+func isURL(source string) bool {
+	lower := strings.ToLower(source)
+	for _, scheme := range []string{"http://", "https://", "webcal://"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchURL turns webcal:// links, as calendar apps publish them, into https
+func fetchURL(source string) string {
+	if strings.HasPrefix(strings.ToLower(source), "webcal://") {
+		return fmt.Sprintf("https://%s", source[len("webcal://"):])
+	}
+	return source
+}
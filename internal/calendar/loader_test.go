@@ -0,0 +1,91 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mithilarun/limelight/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const holidays = "BEGIN:VCALENDAR\r\n" +
+	"X-WR-CALNAME:Holidays\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20261225\r\n" +
+	"SUMMARY:Christmas Day\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestLoaderCachesDownloads(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "limelight.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		database.Close()
+	})
+	require.NoError(t, db.RunMigrations(database))
+
+	var requests int
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, holidays)
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	loader := NewLoader(database, time.Hour)
+	for i := 0; i < 2; i++ {
+		cal, err := loader.Load(ctx, server.URL+"/holidays.ics")
+		require.NoError(t, err)
+		assert.Equal(t, "Holidays", cal.Name)
+	}
+	assert.Equal(t, 1, requests)
+
+	// A new loader, as in the next run, reads the database instead
+	_, err = NewLoader(database, time.Hour).Load(ctx, server.URL+"/holidays.ics")
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	// Once the copy is stale a failed download falls back to it
+	_, err = database.Exec("UPDATE calendar_cache SET fetched_at = ?", time.Now().Add(-2*time.Hour).UTC())
+	require.NoError(t, err)
+	failing = true
+	cal, err := NewLoader(database, time.Hour).Load(ctx, server.URL+"/holidays.ics")
+	require.NoError(t, err)
+	assert.Equal(t, "Holidays", cal.Name)
+	assert.Equal(t, 2, requests)
+
+	_, err = NewLoader(database, time.Hour).Load(ctx, server.URL+"/other.ics")
+	assert.Error(t, err, "nothing is cached for other calendars")
+}
+
+func TestLoaderReadsFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.ics")
+	require.NoError(t, os.WriteFile(path, []byte(holidays), 0o600))
+
+	cal, err := NewLoader(nil, 0).Load(context.Background(), path)
+	require.NoError(t, err)
+	require.Len(t, cal.Events, 1)
+	assert.Equal(t, "Christmas Day", cal.Events[0].Summary)
+}
+
+func TestValidateSource(t *testing.T) {
+	for _, source := range []string{"https://example.com/a.ics", "webcal://example.com/a.ics", "/etc/holidays.ics"} {
+		assert.NoError(t, ValidateSource(source), source)
+	}
+	for _, source := range []string{"", "holidays.ics", "https://", "ftp://example.com/a.ics"} {
+		assert.Error(t, ValidateSource(source), source)
+	}
+	assert.Equal(t, "https://example.com/a.ics", fetchURL("webcal://example.com/a.ics"))
+}
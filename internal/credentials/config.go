@@ -4,31 +4,322 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/cockroachdb/errors"
+	"github.com/mithilarun/limelight/internal/paths"
 )
 
+const (
+	// DefaultProfile is the profile used when none is selected
+	DefaultProfile = "default"
+	// ProfileEnv selects a profile for a single invocation, like the --profile flag
+	ProfileEnv = "LIMELIGHT_PROFILE"
+	// ConfigPathEnv overrides the config file location, like the --config flag
+	ConfigPathEnv = "LIMELIGHT_CONFIG"
+
+	configFileName = "config.json"
+)
+
+var (
+	// selectedProfile overrides the current profile, set from the --profile flag
+	selectedProfile string
+	// configPathOverride replaces the config file location, set from the --config flag
+	configPathOverride string
+)
+
+// SetConfigPath makes LoadConfig and SaveConfig use another config file.
+// An empty path restores the default location.
+func SetConfigPath(path string) {
+	configPathOverride = path
+}
+
+// SelectProfile makes LoadConfig view the named profile instead of the current one.
+// An empty name restores the default selection.
+func SelectProfile(name string) {
+	selectedProfile = name
+}
+
+// Profile holds the settings for one bridge.
+// Latitude and Longitude are nil until set, since 0 is a valid coordinate.
+type Profile struct {
+	BridgeID            string   `json:"bridge_id,omitempty"`
+	BridgeIP            string   `json:"bridge_ip,omitempty"`
+	OnePasswordItemName string   `json:"onepassword_item_name,omitempty"`
+	OnePasswordVault    string   `json:"onepassword_vault,omitempty"`
+	CredentialStore     string   `json:"credential_store,omitempty"`
+	CredentialRef       string   `json:"credential_ref,omitempty"`
+	Latitude            *float64 `json:"latitude,omitempty"`
+	Longitude           *float64 `json:"longitude,omitempty"`
+	TimeZone            string   `json:"timezone,omitempty"`
+}
+
+// CredentialStoreName returns the selected credential store.
+// Configs written before stores were pluggable only set the 1Password item name.
+func (p Profile) CredentialStoreName() string {
+	if p.CredentialStore != "" {
+		return p.CredentialStore
+	}
+	if p.OnePasswordItemName != "" {
+		return StoreOnePassword
+	}
+	return ""
+}
+
+// Location returns the home coordinates, ok only when both are set
+func (p Profile) Location() (latitude, longitude float64, ok bool) {
+	if p.Latitude == nil || p.Longitude == nil {
+		return 0, 0, false
+	}
+	return *p.Latitude, *p.Longitude, true
+}
+
+// CredentialItem returns the ref under which the bridge secrets of the named profile are stored
+func (p Profile) CredentialItem(profileName string) string {
+	if p.CredentialRef != "" {
+		return p.CredentialRef
+	}
+	if p.OnePasswordItemName != "" {
+		return p.OnePasswordItemName
+	}
+	if profileName == "" || profileName == DefaultProfile {
+		return DefaultCredentialRef
+	}
+	return DefaultCredentialRef + "-" + profileName
+}
+
+// Config is the config file viewed through one profile.
+// The selected profile's settings are copied into the top-level fields on load
+// and written back on save, so code that doesn't deal with profiles reads and
+// writes the selected bridge.
 type Config struct {
-	BridgeIP            string  `json:"bridge_ip"`
-	OnePasswordItemName string  `json:"onepassword_item_name"`
-	Latitude            float64 `json:"latitude,omitempty"`
-	Longitude           float64 `json:"longitude,omitempty"`
+	BridgeID            string
+	BridgeIP            string
+	OnePasswordItemName string
+	OnePasswordVault    string
+	CredentialStore     string
+	CredentialRef       string
+	Latitude            *float64
+	Longitude           *float64
+	TimeZone            string
+
+	// ProfileName is the profile the fields above belong to
+	ProfileName string
+	// CurrentProfile is used when no profile is selected with --profile or LIMELIGHT_PROFILE
+	CurrentProfile string
+	// Profiles holds every profile as loaded; the selected one is only updated on save
+	Profiles map[string]Profile
+	// Secrets holds credentials by ref and field, only when the plaintext store is selected
+	Secrets map[string]map[string]string
 }
 
-func GetConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+// NewConfig returns an empty config viewing the active profile
+func NewConfig() *Config {
+	return &Config{
+		ProfileName: activeProfileName(""),
+		Profiles:    make(map[string]Profile),
+	}
+}
+
+// configFile is the on-disk layout of the config file at ConfigVersion
+type configFile struct {
+	Version        int                          `json:"version"`
+	CurrentProfile string                       `json:"current_profile,omitempty"`
+	Profiles       map[string]Profile           `json:"profiles,omitempty"`
+	Secrets        map[string]map[string]string `json:"secrets,omitempty"`
+}
+
+// Profile returns the settings of the selected profile
+func (c *Config) Profile() Profile {
+	return Profile{
+		BridgeID:            c.BridgeID,
+		BridgeIP:            c.BridgeIP,
+		OnePasswordItemName: c.OnePasswordItemName,
+		OnePasswordVault:    c.OnePasswordVault,
+		CredentialStore:     c.CredentialStore,
+		CredentialRef:       c.CredentialRef,
+		Latitude:            c.Latitude,
+		Longitude:           c.Longitude,
+		TimeZone:            c.TimeZone,
+	}
+}
+
+// viewProfile replaces the top-level fields with the settings of a profile
+func (c *Config) viewProfile(name string, p Profile) {
+	c.ProfileName = name
+	c.BridgeID = p.BridgeID
+	c.BridgeIP = p.BridgeIP
+	c.OnePasswordItemName = p.OnePasswordItemName
+	c.OnePasswordVault = p.OnePasswordVault
+	c.CredentialStore = p.CredentialStore
+	c.CredentialRef = p.CredentialRef
+	c.Latitude = p.Latitude
+	c.Longitude = p.Longitude
+	c.TimeZone = p.TimeZone
+}
+
+// CredentialStoreName returns the credential store of the selected profile
+func (c *Config) CredentialStoreName() string {
+	return c.Profile().CredentialStoreName()
+}
+
+// Location returns the home coordinates of the selected profile, ok only when both are set
+func (c *Config) Location() (latitude, longitude float64, ok bool) {
+	return c.Profile().Location()
+}
+
+// CredentialItem returns the credential ref of the selected profile
+func (c *Config) CredentialItem() string {
+	return c.Profile().CredentialItem(c.ProfileName)
+}
+
+// GetProfile returns a profile's settings, including unsaved changes to the selected one
+func (c *Config) GetProfile(name string) (Profile, bool) {
+	if name == c.ProfileName && c.Profile() != (Profile{}) {
+		return c.Profile(), true
+	}
+	p, ok := c.Profiles[name]
+	return p, ok
+}
+
+// ProfileNames returns the names of all profiles, sorted
+func (c *Config) ProfileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	if _, ok := c.Profiles[c.ProfileName]; !ok && c.ProfileName != "" && c.Profile() != (Profile{}) {
+		names = append(names, c.ProfileName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindProfile looks up a profile by name or by bridge ID
+func (c *Config) FindProfile(nameOrBridgeID string) (string, Profile, bool) {
+	if p, ok := c.GetProfile(nameOrBridgeID); ok {
+		return nameOrBridgeID, p, true
+	}
+
+	for _, name := range c.ProfileNames() {
+		p, _ := c.GetProfile(name)
+		if p.BridgeID != "" && strings.EqualFold(p.BridgeID, nameOrBridgeID) {
+			return name, p, true
+		}
+	}
+
+	return "", Profile{}, false
+}
+
+// WithProfile returns a copy of the config viewing another profile.
+// The copy shares Secrets with c.
+func (c *Config) WithProfile(name string) (*Config, error) {
+	p, ok := c.GetProfile(name)
+	if !ok {
+		return nil, errors.Newf("profile %s does not exist", name)
+	}
+
+	view := *c
+	view.viewProfile(name, p)
+	return &view, nil
+}
+
+// UseProfile makes an existing profile the current one
+func (c *Config) UseProfile(name string) error {
+	if _, ok := c.GetProfile(name); !ok {
+		return errors.Newf("profile %s does not exist", name)
+	}
+	c.CurrentProfile = name
+	return nil
+}
+
+// RemoveProfile deletes a profile. If it was current, the first remaining profile becomes current.
+func (c *Config) RemoveProfile(name string) error {
+	if _, ok := c.GetProfile(name); !ok {
+		return errors.Newf("profile %s does not exist", name)
+	}
+
+	delete(c.Profiles, name)
+	if c.ProfileName == name {
+		c.viewProfile("", Profile{})
+	}
+
+	if c.CurrentProfile == name {
+		c.CurrentProfile = ""
+		if names := c.ProfileNames(); len(names) > 0 {
+			c.CurrentProfile = names[0]
+		}
+	}
+
+	return nil
+}
+
+// activeProfileName resolves which profile to view: --profile, then LIMELIGHT_PROFILE,
+// then the current profile from the file
+func activeProfileName(current string) string {
+	if selectedProfile != "" {
+		return selectedProfile
+	}
+	if name := os.Getenv(ProfileEnv); name != "" {
+		return name
+	}
+	if current != "" {
+		return current
+	}
+	return DefaultProfile
+}
+
+// GetConfigDir returns the directory holding the config file, creating it if needed
+func GetConfigDir() (string, error) {
+	configPath, err := GetConfigPath()
 	if err != nil {
-		return "", errors.Wrap(err, "getting user home directory")
+		return "", err
 	}
+	return filepath.Dir(configPath), nil
+}
 
-	configDir := filepath.Join(homeDir, ".config", "limelight")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+// GetConfigPath returns the config file location: the --config flag, then
+// LIMELIGHT_CONFIG, then config.json in the XDG config directory. A config file
+// in ~/.config/limelight is still used if XDG_CONFIG_HOME points elsewhere and
+// has none. The directory is created if needed.
+func GetConfigPath() (string, error) {
+	configPath := configPathOverride
+	if configPath == "" {
+		configPath = os.Getenv(ConfigPathEnv)
+	}
+
+	if configPath == "" {
+		configDir, err := paths.ConfigDir()
+		if err != nil {
+			return "", err
+		}
+		configPath = filepath.Join(configDir, configFileName)
+
+		legacyDir, err := paths.LegacyDir()
+		if err != nil {
+			return "", err
+		}
+		if legacyPath := filepath.Join(legacyDir, configFileName); !fileExists(configPath) && fileExists(legacyPath) {
+			configPath = legacyPath
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
 		return "", errors.Wrap(err, "creating config directory")
 	}
 
-	return filepath.Join(configDir, "config.json"), nil
+	return configPath, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
+// LoadConfig reads the config file viewed through the active profile.
+// Files from older versions are migrated and rewritten, keeping a backup of the original.
+// It returns nil if there is no config file.
 func LoadConfig() (*Config, error) {
 	configPath, err := GetConfigPath()
 	if err != nil {
@@ -43,25 +334,78 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.Wrap(err, "reading config file")
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	version, migrated, err := migrateConfigFile(data)
+	if err != nil {
+		return nil, err
+	}
+	if version != ConfigVersion {
+		if err := backupConfigFile(configPath, version, data); err != nil {
+			return nil, err
+		}
+		if err := replaceConfigFile(configPath, migrated); err != nil {
+			return nil, err
+		}
+	}
+
+	var file configFile
+	if err := json.Unmarshal(migrated, &file); err != nil {
 		return nil, errors.Wrap(err, "unmarshaling config")
 	}
 
-	return &config, nil
+	config := &Config{
+		CurrentProfile: file.CurrentProfile,
+		Profiles:       file.Profiles,
+		Secrets:        file.Secrets,
+	}
+	if config.Profiles == nil {
+		config.Profiles = make(map[string]Profile)
+	}
+
+	name := activeProfileName(config.CurrentProfile)
+	config.viewProfile(name, config.Profiles[name])
+
+	return config, nil
 }
 
+// SaveConfig writes the config file, storing the top-level fields as the viewed profile
 func SaveConfig(config *Config) error {
 	configPath, err := GetConfigPath()
 	if err != nil {
 		return errors.Wrap(err, "getting config path")
 	}
 
-	data, err := json.MarshalIndent(config, "", "  ")
+	file := configFile{
+		Version:        ConfigVersion,
+		CurrentProfile: config.CurrentProfile,
+		Profiles:       make(map[string]Profile, len(config.Profiles)+1),
+		Secrets:        config.Secrets,
+	}
+	for name, p := range config.Profiles {
+		file.Profiles[name] = p
+	}
+
+	if view := config.Profile(); view != (Profile{}) {
+		name := config.ProfileName
+		if name == "" {
+			name = activeProfileName(config.CurrentProfile)
+		}
+		file.Profiles[name] = view
+
+		if file.CurrentProfile == "" {
+			file.CurrentProfile = name
+		}
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
 	if err != nil {
 		return errors.Wrap(err, "marshaling config")
 	}
 
+	return replaceConfigFile(configPath, data)
+}
+
+// replaceConfigFile replaces the config file atomically
+func replaceConfigFile(configPath string, data []byte) error {
 	tmpPath := configPath + ".tmp"
 	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
 		return errors.Wrap(err, "writing temp config file")
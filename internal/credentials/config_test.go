@@ -0,0 +1,202 @@
+package credentials
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, contents string) {
+	path, err := GetConfigPath()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+}
+
+func TestLoadConfigLegacyFlatFile(t *testing.T) {
+	setupTestEnv(t)
+
+	writeConfigFile(t, `{"bridge_ip": "192.168.1.100", "onepassword_item_name": "hue", "latitude": 37.7749, "longitude": -122.4194}`)
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+	require.NotNil(t, config)
+
+	assert.Equal(t, DefaultProfile, config.ProfileName)
+	assert.Equal(t, DefaultProfile, config.CurrentProfile)
+	assert.Equal(t, "192.168.1.100", config.BridgeIP)
+	assert.Equal(t, StoreOnePassword, config.CredentialStoreName())
+	assert.Equal(t, "hue", config.CredentialItem())
+
+	require.NoError(t, SaveConfig(config))
+
+	path, err := GetConfigPath()
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"profiles"`)
+
+	reloaded, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, config.Profile(), reloaded.Profile())
+	assert.Equal(t, []string{DefaultProfile}, reloaded.ProfileNames())
+}
+
+func TestProfileSelection(t *testing.T) {
+	setupTestEnv(t)
+	t.Cleanup(func() { SelectProfile("") })
+
+	writeConfigFile(t, `{
+  "current_profile": "home",
+  "profiles": {
+    "home": {"bridge_id": "001788fffe000001", "bridge_ip": "192.168.1.10", "credential_store": "pass"},
+    "office": {"bridge_id": "001788fffe000002", "bridge_ip": "10.0.0.10", "credential_store": "env"}
+  }
+}`)
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "home", config.ProfileName)
+	assert.Equal(t, "192.168.1.10", config.BridgeIP)
+
+	t.Setenv(ProfileEnv, "office")
+	config, err = LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "office", config.ProfileName)
+	assert.Equal(t, "10.0.0.10", config.BridgeIP)
+	assert.Equal(t, "limelight-hue-office", config.CredentialItem())
+
+	SelectProfile("home")
+	config, err = LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "home", config.ProfileName, "--profile should win over the environment")
+
+	SelectProfile("new")
+	config, err = LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "new", config.ProfileName)
+	assert.Empty(t, config.BridgeIP)
+
+	config.BridgeIP = "172.16.0.10"
+	require.NoError(t, SaveConfig(config))
+
+	SelectProfile("")
+	t.Setenv(ProfileEnv, "")
+	config, err = LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "home", config.ProfileName, "saving another profile should not change the current one")
+	assert.Equal(t, []string{"home", "new", "office"}, config.ProfileNames())
+}
+
+func TestFindProfile(t *testing.T) {
+	config := &Config{
+		ProfileName: "home",
+		BridgeID:    "001788fffe000001",
+		BridgeIP:    "192.168.1.10",
+		Profiles: map[string]Profile{
+			"office": {BridgeID: "001788fffe000002", BridgeIP: "10.0.0.10"},
+		},
+	}
+
+	name, profile, ok := config.FindProfile("office")
+	require.True(t, ok)
+	assert.Equal(t, "office", name)
+	assert.Equal(t, "10.0.0.10", profile.BridgeIP)
+
+	name, profile, ok = config.FindProfile("001788FFFE000001")
+	require.True(t, ok)
+	assert.Equal(t, "home", name)
+	assert.Equal(t, "192.168.1.10", profile.BridgeIP)
+
+	_, _, ok = config.FindProfile("cabin")
+	assert.False(t, ok)
+
+	view, err := config.WithProfile("office")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.10", view.BridgeIP)
+	assert.Equal(t, "192.168.1.10", config.BridgeIP, "the original view is unchanged")
+}
+
+func TestUseAndRemoveProfile(t *testing.T) {
+	config := &Config{
+		ProfileName:    "home",
+		CurrentProfile: "home",
+		BridgeIP:       "192.168.1.10",
+		Profiles: map[string]Profile{
+			"home":   {BridgeIP: "192.168.1.10"},
+			"office": {BridgeIP: "10.0.0.10"},
+		},
+	}
+
+	assert.Error(t, config.UseProfile("cabin"))
+	require.NoError(t, config.UseProfile("office"))
+	assert.Equal(t, "office", config.CurrentProfile)
+
+	require.NoError(t, config.UseProfile("home"))
+	require.NoError(t, config.RemoveProfile("home"))
+	assert.Equal(t, "office", config.CurrentProfile)
+	assert.Empty(t, config.ProfileName)
+	assert.Empty(t, config.BridgeIP)
+	assert.Equal(t, []string{"office"}, config.ProfileNames())
+
+	assert.Error(t, config.RemoveProfile("home"))
+}
+
+func TestLoadConfigMigratesFlatFile(t *testing.T) {
+	setupTestEnv(t)
+
+	legacy := `{"bridge_ip": "192.168.1.100", "credential_store": "plaintext", "secrets": {"limelight-hue": {"api_key": "secret"}}}`
+	writeConfigFile(t, legacy)
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.100", config.BridgeIP)
+	assert.Equal(t, "secret", config.Secrets["limelight-hue"]["api_key"])
+
+	path, err := GetConfigPath()
+	require.NoError(t, err)
+
+	backup, err := os.ReadFile(path + ".v1.bak")
+	require.NoError(t, err)
+	assert.Equal(t, legacy, string(backup))
+
+	var migrated map[string]interface{}
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &migrated))
+	assert.Equal(t, float64(ConfigVersion), migrated["version"])
+	assert.Equal(t, DefaultProfile, migrated["current_profile"])
+	assert.NotContains(t, migrated, "bridge_ip")
+}
+
+func TestLoadConfigUnversionedProfilesFile(t *testing.T) {
+	setupTestEnv(t)
+
+	writeConfigFile(t, `{"current_profile": "home", "profiles": {"home": {"bridge_ip": "192.168.1.10"}}}`)
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.10", config.BridgeIP)
+
+	path, err := GetConfigPath()
+	require.NoError(t, err)
+	_, err = os.Stat(path + ".v2.bak")
+	assert.True(t, os.IsNotExist(err), "current layout should not be migrated")
+
+	require.NoError(t, SaveConfig(config))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"version": 2`)
+}
+
+func TestLoadConfigRejectsNewerVersion(t *testing.T) {
+	setupTestEnv(t)
+
+	writeConfigFile(t, `{"version": 99, "profiles": {}}`)
+
+	_, err := LoadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "newer than this limelight supports")
+}
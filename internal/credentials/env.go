@@ -0,0 +1,84 @@
+package credentials
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+const envPrefix = "LIMELIGHT_"
+
+// EnvStore reads secrets from environment variables. The default profile reads
+// LIMELIGHT_<FIELD>, for example LIMELIGHT_API_KEY, and other profiles read
+// LIMELIGHT_<PROFILE>_<FIELD>, falling back to the default profile's variable.
+// It is read-only and ignores the ref.
+type EnvStore struct {
+	profile string
+}
+
+var _ Store = (*EnvStore)(nil)
+
+// NewEnvStore creates a store backed by environment variables for the named profile
+func NewEnvStore(profile string) *EnvStore {
+	return &EnvStore{profile: profile}
+}
+
+// EnvVarName returns the environment variable that holds field for the named profile
+func EnvVarName(profile, field string) string {
+	if profile == "" || profile == DefaultProfile {
+		return envPrefix + strings.ToUpper(field)
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, profile)
+	return envPrefix + strings.ToUpper(name) + "_" + strings.ToUpper(field)
+}
+
+// VarName returns the environment variable this store reads field from first
+func (s *EnvStore) VarName(field string) string {
+	return EnvVarName(s.profile, field)
+}
+
+// Name returns the store backend name
+func (s *EnvStore) Name() string {
+	return StoreEnv
+}
+
+// IsAvailable always returns true, the environment is always readable
+func (s *EnvStore) IsAvailable(ctx context.Context) bool {
+	return true
+}
+
+// Get returns the value of the field's environment variable for the profile,
+// or of the default profile's variable when that is unset
+func (s *EnvStore) Get(ctx context.Context, ref, field string) (string, error) {
+	name := s.VarName(field)
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+
+	fallback := EnvVarName(DefaultProfile, field)
+	if fallback == name {
+		return "", errors.Wrapf(ErrSecretNotFound, "environment variable %s is not set", name)
+	}
+	if value := os.Getenv(fallback); value != "" {
+		return value, nil
+	}
+	return "", errors.Wrapf(ErrSecretNotFound, "neither %s nor %s is set", name, fallback)
+}
+
+// Set always fails, the process environment can't be persisted
+func (s *EnvStore) Set(ctx context.Context, ref, field, value string) error {
+	return errors.Newf("environment credential store is read-only, set %s yourself", s.VarName(field))
+}
+
+// Delete always fails, the process environment can't be persisted
+func (s *EnvStore) Delete(ctx context.Context, ref, field string) error {
+	return errors.Newf("environment credential store is read-only, unset %s yourself", s.VarName(field))
+}
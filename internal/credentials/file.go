@@ -0,0 +1,229 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/cockroachdb/errors"
+)
+
+// PassphraseEnv is read for the encrypted file store passphrase before prompting
+const PassphraseEnv = "LIMELIGHT_PASSPHRASE"
+
+// FileStore keeps secrets in a passphrase-protected age file (https://age-encryption.org).
+// The plaintext is a JSON object of refs to fields to values, so the file can be
+// inspected with "age --decrypt".
+type FileStore struct {
+	path       string
+	passphrase func(newFile bool) (string, error)
+	// workFactor is the scrypt work factor for new files, zero uses the age default
+	workFactor int
+
+	mu     sync.Mutex
+	cached string
+}
+
+var _ Store = (*FileStore)(nil)
+
+// DefaultSecretsFilePath returns secrets.age in the config directory
+func DefaultSecretsFilePath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "secrets.age"), nil
+}
+
+// NewFileStore creates an encrypted file store at path.
+// The passphrase comes from LIMELIGHT_PASSPHRASE, or else the passphrase function.
+func NewFileStore(path string, passphrase func(newFile bool) (string, error)) *FileStore {
+	return &FileStore{
+		path:       path,
+		passphrase: passphrase,
+	}
+}
+
+// Name returns the store backend name
+func (s *FileStore) Name() string {
+	return StoreFile
+}
+
+// IsAvailable always returns true, the file is created on first use
+func (s *FileStore) IsAvailable(ctx context.Context) bool {
+	return true
+}
+
+// Get decrypts the file and returns a secret
+func (s *FileStore) Get(ctx context.Context, ref, field string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := secrets[ref][field]
+	if !ok {
+		return "", errors.Wrapf(ErrSecretNotFound, "%s field of %s in %s", field, ref, s.path)
+	}
+	return value, nil
+}
+
+// Set stores a secret, re-encrypting the whole file
+func (s *FileStore) Set(ctx context.Context, ref, field, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if secrets[ref] == nil {
+		secrets[ref] = make(map[string]string)
+	}
+	secrets[ref][field] = value
+
+	return s.save(secrets)
+}
+
+// Delete removes a secret, re-encrypting the whole file
+func (s *FileStore) Delete(ctx context.Context, ref, field string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secrets[ref][field]; !ok {
+		return nil
+	}
+
+	delete(secrets[ref], field)
+	if len(secrets[ref]) == 0 {
+		delete(secrets, ref)
+	}
+
+	return s.save(secrets)
+}
+
+// load decrypts the file, returning an empty set of secrets if it doesn't exist yet
+func (s *FileStore) load() (map[string]map[string]string, error) {
+	secrets := make(map[string]map[string]string)
+
+	ciphertext, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading secrets file")
+	}
+
+	passphrase, err := s.getPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating age identity")
+	}
+
+	reader, err := age.Decrypt(bytes.NewReader(ciphertext), identity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypting %s, is the passphrase correct?", s.path)
+	}
+
+	plaintext, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypting secrets file")
+	}
+
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling secrets")
+	}
+
+	return secrets, nil
+}
+
+// save encrypts secrets and atomically replaces the file
+func (s *FileStore) save(secrets map[string]map[string]string) error {
+	_, statErr := os.Stat(s.path)
+	passphrase, err := s.getPassphrase(os.IsNotExist(statErr))
+	if err != nil {
+		return err
+	}
+
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return errors.Wrap(err, "creating age recipient")
+	}
+	if s.workFactor > 0 {
+		recipient.SetWorkFactor(s.workFactor)
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return errors.Wrap(err, "marshaling secrets")
+	}
+
+	var ciphertext bytes.Buffer
+	writer, err := age.Encrypt(&ciphertext, recipient)
+	if err != nil {
+		return errors.Wrap(err, "encrypting secrets")
+	}
+	if _, err := writer.Write(plaintext); err != nil {
+		return errors.Wrap(err, "encrypting secrets")
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrap(err, "encrypting secrets")
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, ciphertext.Bytes(), 0600); err != nil {
+		return errors.Wrap(err, "writing temp secrets file")
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "moving secrets file")
+	}
+
+	return nil
+}
+
+// getPassphrase returns the passphrase, asking for it at most once per store
+func (s *FileStore) getPassphrase(newFile bool) (string, error) {
+	if s.cached != "" {
+		return s.cached, nil
+	}
+
+	passphrase := os.Getenv(PassphraseEnv)
+	if passphrase == "" {
+		if s.passphrase == nil {
+			return "", errors.Newf("no passphrase for %s, set %s", s.path, PassphraseEnv)
+		}
+
+		var err error
+		passphrase, err = s.passphrase(newFile)
+		if err != nil {
+			return "", errors.Wrap(err, "reading passphrase")
+		}
+	}
+
+	if passphrase == "" {
+		return "", errors.New("passphrase cannot be empty")
+	}
+
+	s.cached = passphrase
+	return passphrase, nil
+}
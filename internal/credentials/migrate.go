@@ -0,0 +1,139 @@
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ConfigVersion is the config file layout written by this version of limelight.
+//
+//	1: a single bridge's settings at the top level (unversioned)
+//	2: named profiles under "profiles", selected by "current_profile"
+const ConfigVersion = 2
+
+// configMigrations upgrade the raw config file from the version they are keyed by to the next one
+var configMigrations = map[int]func(raw map[string]json.RawMessage) error{
+	1: migrateFlatToProfiles,
+}
+
+// configFileVersion returns the layout version of a raw config file. Files written
+// before the version field existed are version 2 if they have profiles, else 1.
+func configFileVersion(raw map[string]json.RawMessage) (int, error) {
+	if data, ok := raw["version"]; ok {
+		var version int
+		if err := json.Unmarshal(data, &version); err != nil {
+			return 0, errors.Wrap(err, "parsing config version")
+		}
+		return version, nil
+	}
+
+	if _, ok := raw["profiles"]; ok {
+		return 2, nil
+	}
+	if _, ok := raw["current_profile"]; ok {
+		return 2, nil
+	}
+	return 1, nil
+}
+
+// migrateConfigFile upgrades raw config file data to ConfigVersion.
+// It returns the original version and the migrated data, which is unchanged
+// if the file was already current.
+func migrateConfigFile(data []byte) (int, []byte, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0, nil, errors.Wrap(err, "unmarshaling config")
+	}
+	if raw == nil {
+		raw = make(map[string]json.RawMessage)
+	}
+
+	original, err := configFileVersion(raw)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if original > ConfigVersion {
+		return 0, nil, errors.WithHint(
+			errors.Newf("config file version %d is newer than this limelight supports (%d)", original, ConfigVersion),
+			"upgrade limelight to read this config file",
+		)
+	}
+	if original == ConfigVersion {
+		return original, data, nil
+	}
+
+	for version := original; version < ConfigVersion; version++ {
+		migrate, ok := configMigrations[version]
+		if !ok {
+			return 0, nil, errors.Newf("no migration from config version %d", version)
+		}
+		if err := migrate(raw); err != nil {
+			return 0, nil, errors.Wrapf(err, "migrating config from version %d", version)
+		}
+	}
+
+	version, err := json.Marshal(ConfigVersion)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "marshaling config version")
+	}
+	raw["version"] = version
+
+	migrated, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "marshaling migrated config")
+	}
+
+	return original, migrated, nil
+}
+
+// migrateFlatToProfiles moves the top-level bridge settings of a version 1 file
+// into the default profile
+func migrateFlatToProfiles(raw map[string]json.RawMessage) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return errors.Wrap(err, "marshaling version 1 config")
+	}
+
+	var legacy Profile
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return errors.Wrap(err, "unmarshaling version 1 config")
+	}
+
+	secrets, hasSecrets := raw["secrets"]
+	for key := range raw {
+		delete(raw, key)
+	}
+	if hasSecrets {
+		raw["secrets"] = secrets
+	}
+
+	if legacy == (Profile{}) {
+		return nil
+	}
+
+	profiles, err := json.Marshal(map[string]Profile{DefaultProfile: legacy})
+	if err != nil {
+		return errors.Wrap(err, "marshaling profiles")
+	}
+	current, err := json.Marshal(DefaultProfile)
+	if err != nil {
+		return errors.Wrap(err, "marshaling current profile")
+	}
+
+	raw["profiles"] = profiles
+	raw["current_profile"] = current
+	return nil
+}
+
+// backupConfigFile keeps a copy of a config file before it is migrated, next to it
+func backupConfigFile(configPath string, version int, data []byte) error {
+	backupPath := fmt.Sprintf("%s.v%d.bak", configPath, version)
+	if err := os.WriteFile(backupPath, data, 0600); err != nil {
+		return errors.Wrap(err, "writing config backup")
+	}
+	return nil
+}
@@ -1,124 +1,252 @@
 package credentials
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"os/exec"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/cockroachdb/errors"
 	"go.uber.org/zap"
 )
 
 const (
-	onePasswordCLI = "op"
+	// Environment variables shared with the 1Password CLI and SDKs
+	OnePasswordConnectHostEnv    = "OP_CONNECT_HOST"
+	OnePasswordConnectTokenEnv   = "OP_CONNECT_TOKEN"
+	OnePasswordServiceAccountEnv = "OP_SERVICE_ACCOUNT_TOKEN"
+
+	onePasswordRefPrefix = "op://"
 )
 
-type Manager struct {
-	logger *zap.Logger
+// OnePasswordConfig configures a Manager
+type OnePasswordConfig struct {
+	// Vault is the vault name or ID used for refs that don't name one
+	Vault string
+	// ConnectHost and ConnectToken select a 1Password Connect server instead of the op CLI.
+	// They default to OP_CONNECT_HOST and OP_CONNECT_TOKEN.
+	ConnectHost  string
+	ConnectToken string
+	// HTTPClient is used for Connect requests
+	HTTPClient *http.Client
 }
 
-func NewManager(logger *zap.Logger) *Manager {
-	return &Manager{
-		logger: logger,
+// onePasswordField is a field of a 1Password item, as returned by both the CLI and Connect
+type onePasswordField struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Purpose string `json:"purpose,omitempty"`
+	Label   string `json:"label,omitempty"`
+	Value   string `json:"value,omitempty"`
+}
+
+// onePasswordItem is a 1Password item, as returned by both the CLI and Connect
+type onePasswordItem struct {
+	ID       string `json:"id,omitempty"`
+	Title    string `json:"title"`
+	Category string `json:"category,omitempty"`
+	Vault    struct {
+		ID string `json:"id"`
+	} `json:"vault"`
+	Fields []onePasswordField `json:"fields"`
+}
+
+// onePasswordBackend reads and writes 1Password items, through the op CLI or a Connect server
+type onePasswordBackend interface {
+	name() string
+	available(ctx context.Context) bool
+	getItem(ctx context.Context, vault, item string) (*onePasswordItem, error)
+	setField(ctx context.Context, vault, item, label, value string) error
+	deleteField(ctx context.Context, vault, item, label string) error
+}
+
+// onePasswordRef is a parsed credential ref: an item name, or a secret reference
+// op://<vault>/<item>[/<field>] naming the vault and, for the API key, the field label
+type onePasswordRef struct {
+	vault string
+	item  string
+	field string
+}
+
+// parseOnePasswordRef splits a credential ref, using defaultVault when it doesn't name one
+func parseOnePasswordRef(ref, defaultVault string) (onePasswordRef, error) {
+	if !strings.HasPrefix(ref, onePasswordRefPrefix) {
+		return onePasswordRef{vault: defaultVault, item: ref}, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(ref, onePasswordRefPrefix), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return onePasswordRef{}, errors.Newf("invalid 1password reference %s, expected op://<vault>/<item>[/<field>]", ref)
 	}
+
+	r := onePasswordRef{vault: parts[0], item: parts[1]}
+	if len(parts) == 3 {
+		r.field = parts[2]
+	}
+	return r, nil
 }
 
-type opItem struct {
-	ID     string `json:"id"`
-	Title  string `json:"title"`
-	Fields []struct {
-		ID    string `json:"id"`
-		Type  string `json:"type"`
-		Label string `json:"label"`
-		Value string `json:"value"`
-	} `json:"fields"`
+// label returns the item field label holding field
+func (r onePasswordRef) label(field string) string {
+	if field == FieldAPIKey && r.field != "" {
+		return r.field
+	}
+	return field
 }
 
-func (m *Manager) GetAPIKey(ctx context.Context, itemName string) (string, error) {
-	cmd := exec.CommandContext(ctx, onePasswordCLI, "item", "get", itemName, "--format", "json")
+// Manager is the 1Password credential store. It uses a Connect server when one
+// is configured and the op CLI otherwise, which picks up OP_SERVICE_ACCOUNT_TOKEN
+// for headless use. Secrets are cached in memory for the life of the Manager.
+type Manager struct {
+	logger  *zap.Logger
+	vault   string
+	backend onePasswordBackend
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	mu    sync.Mutex
+	cache map[onePasswordCacheKey]string
 
-	m.logger.Debug("executing 1password cli command",
-		zap.String("item", itemName),
-	)
+	availableOnce sync.Once
+	available     bool
+}
+
+type onePasswordCacheKey struct {
+	vault, item, label string
+}
+
+var _ Store = (*Manager)(nil)
+
+// NewManager creates a 1Password store configured from the environment
+func NewManager(logger *zap.Logger) *Manager {
+	return NewOnePasswordStore(logger, OnePasswordConfig{})
+}
+
+// NewOnePasswordStore creates a 1Password store
+func NewOnePasswordStore(logger *zap.Logger, config OnePasswordConfig) *Manager {
+	if config.ConnectHost == "" {
+		config.ConnectHost = os.Getenv(OnePasswordConnectHostEnv)
+	}
+	if config.ConnectToken == "" {
+		config.ConnectToken = os.Getenv(OnePasswordConnectTokenEnv)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", errors.Wrapf(err, "executing op cli command: stderr=%s", stderr.String())
+	var backend onePasswordBackend
+	if config.ConnectHost != "" && config.ConnectToken != "" {
+		backend = newOnePasswordConnect(config.ConnectHost, config.ConnectToken, config.HTTPClient)
+	} else {
+		backend = &onePasswordCLI{logger: logger}
 	}
 
-	var item opItem
-	if err := json.Unmarshal(stdout.Bytes(), &item); err != nil {
-		return "", errors.Wrap(err, "unmarshaling op item response")
+	return &Manager{
+		logger:  logger,
+		vault:   config.Vault,
+		backend: backend,
+		cache:   make(map[onePasswordCacheKey]string),
 	}
+}
 
-	for _, field := range item.Fields {
-		if field.Label == "api_key" || field.Label == "apikey" || field.Type == "CONCEALED" {
-			if field.Value != "" {
-				m.logger.Info("api key retrieved from 1password",
-					zap.String("item", itemName),
-				)
-				return field.Value, nil
-			}
+// Name returns the store backend name
+func (m *Manager) Name() string {
+	return StoreOnePassword
+}
+
+// IsAvailable reports whether the CLI is signed in or the Connect server accepts the token.
+// The check runs once per Manager.
+func (m *Manager) IsAvailable(ctx context.Context) bool {
+	m.availableOnce.Do(func() {
+		m.available = m.backend.available(ctx)
+		if !m.available {
+			m.logger.Debug("1password is not available", zap.String("backend", m.backend.name()))
 		}
-	}
+	})
+	return m.available
+}
 
-	return "", errors.Newf("api key field not found in 1password item %s", itemName)
+// GetAPIKey returns the bridge API key from a 1Password item
+func (m *Manager) GetAPIKey(ctx context.Context, itemName string) (string, error) {
+	return m.Get(ctx, itemName, FieldAPIKey)
 }
 
+// SaveAPIKey stores the bridge API key in a 1Password item, creating the item if needed
 func (m *Manager) SaveAPIKey(ctx context.Context, itemName, apiKey string) error {
-	getCmd := exec.CommandContext(ctx, onePasswordCLI, "item", "get", itemName)
-	if err := getCmd.Run(); err == nil {
-		editCmd := exec.CommandContext(ctx, onePasswordCLI, "item", "edit", itemName, fmt.Sprintf("api_key[concealed]=%s", apiKey))
+	return m.Set(ctx, itemName, FieldAPIKey, apiKey)
+}
 
-		var stderr bytes.Buffer
-		editCmd.Stderr = &stderr
+// Get returns the value of the item field whose label or ID matches field
+func (m *Manager) Get(ctx context.Context, ref, field string) (string, error) {
+	r, err := parseOnePasswordRef(ref, m.vault)
+	if err != nil {
+		return "", err
+	}
+	label := r.label(field)
+	key := onePasswordCacheKey{vault: r.vault, item: r.item, label: label}
 
-		if err := editCmd.Run(); err != nil {
-			return errors.Wrapf(err, "editing 1password item: stderr=%s", stderr.String())
-		}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-		m.logger.Info("api key updated in 1password",
-			zap.String("item", itemName),
-		)
-		return nil
+	if value, ok := m.cache[key]; ok {
+		return value, nil
 	}
 
-	createCmd := exec.CommandContext(ctx, onePasswordCLI, "item", "create",
-		"--category", "api_credential",
-		"--title", itemName,
-		fmt.Sprintf("api_key[concealed]=%s", apiKey),
-	)
+	item, err := m.backend.getItem(ctx, r.vault, r.item)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range item.Fields {
+		if (f.Label == label || f.ID == label) && f.Value != "" {
+			m.logger.Info("secret retrieved from 1password",
+				zap.String("item", r.item),
+				zap.String("field", label),
+			)
+			m.cache[key] = f.Value
+			return f.Value, nil
+		}
+	}
+
+	return "", errors.Wrapf(ErrSecretNotFound, "%s field in 1password item %s", label, r.item)
+}
 
-	var stderr bytes.Buffer
-	createCmd.Stderr = &stderr
+// Set stores value as a concealed field, creating the item if it does not exist
+func (m *Manager) Set(ctx context.Context, ref, field, value string) error {
+	r, err := parseOnePasswordRef(ref, m.vault)
+	if err != nil {
+		return err
+	}
+	label := r.label(field)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	if err := createCmd.Run(); err != nil {
-		return errors.Wrapf(err, "creating 1password item: stderr=%s", stderr.String())
+	if err := m.backend.setField(ctx, r.vault, r.item, label, value); err != nil {
+		return err
 	}
 
-	m.logger.Info("api key saved to 1password",
-		zap.String("item", itemName),
+	m.logger.Info("secret saved to 1password",
+		zap.String("item", r.item),
+		zap.String("field", label),
 	)
 
+	m.cache[onePasswordCacheKey{vault: r.vault, item: r.item, label: label}] = value
 	return nil
 }
 
-func (m *Manager) IsAvailable(ctx context.Context) bool {
-	cmd := exec.CommandContext(ctx, "which", onePasswordCLI)
-	if err := cmd.Run(); err != nil {
-		return false
+// Delete removes a field from a 1Password item
+func (m *Manager) Delete(ctx context.Context, ref, field string) error {
+	r, err := parseOnePasswordRef(ref, m.vault)
+	if err != nil {
+		return err
 	}
+	label := r.label(field)
 
-	cmd = exec.CommandContext(ctx, onePasswordCLI, "account", "list")
-	if err := cmd.Run(); err != nil {
-		m.logger.Warn("1password cli is installed but not signed in")
-		return false
-	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	return true
+	delete(m.cache, onePasswordCacheKey{vault: r.vault, item: r.item, label: label})
+
+	err = m.backend.deleteField(ctx, r.vault, r.item, label)
+	if errors.Is(err, ErrSecretNotFound) {
+		return nil
+	}
+	return err
 }
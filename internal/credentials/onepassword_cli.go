@@ -0,0 +1,117 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+const onePasswordCLIName = "op"
+
+// onePasswordCLI talks to 1Password through the op CLI, either in a signed-in
+// desktop session or with a service account token from OP_SERVICE_ACCOUNT_TOKEN
+type onePasswordCLI struct {
+	logger *zap.Logger
+}
+
+func (c *onePasswordCLI) name() string {
+	return "cli"
+}
+
+// available checks that op is installed and has an account to use,
+// without prompting to sign in
+func (c *onePasswordCLI) available(ctx context.Context) bool {
+	if _, err := exec.LookPath(onePasswordCLIName); err != nil {
+		return false
+	}
+
+	if os.Getenv(OnePasswordServiceAccountEnv) != "" {
+		return true
+	}
+
+	if err := c.run(ctx, nil, "whoami"); err != nil {
+		c.logger.Warn("1password cli is installed but not signed in")
+		return false
+	}
+
+	return true
+}
+
+func (c *onePasswordCLI) getItem(ctx context.Context, vault, item string) (*onePasswordItem, error) {
+	var stdout bytes.Buffer
+	if err := c.run(ctx, &stdout, withVault(vault, "item", "get", item, "--format", "json")...); err != nil {
+		return nil, errors.Wrapf(err, "1password item %s", item)
+	}
+
+	var result onePasswordItem
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return nil, errors.Wrap(err, "unmarshaling op item response")
+	}
+
+	return &result, nil
+}
+
+func (c *onePasswordCLI) setField(ctx context.Context, vault, item, label, value string) error {
+	assignment := fmt.Sprintf("%s[concealed]=%s", label, value)
+
+	err := c.run(ctx, nil, withVault(vault, "item", "get", item, "--format", "json")...)
+	switch {
+	case err == nil:
+		if err := c.run(ctx, nil, withVault(vault, "item", "edit", item, assignment)...); err != nil {
+			return errors.Wrap(err, "editing 1password item")
+		}
+	case errors.Is(err, ErrSecretNotFound):
+		args := withVault(vault, "item", "create", "--category", "api_credential", "--title", item, assignment)
+		if err := c.run(ctx, nil, args...); err != nil {
+			return errors.Wrap(err, "creating 1password item")
+		}
+	default:
+		return errors.Wrap(err, "looking up 1password item")
+	}
+
+	return nil
+}
+
+func (c *onePasswordCLI) deleteField(ctx context.Context, vault, item, label string) error {
+	if err := c.run(ctx, nil, withVault(vault, "item", "edit", item, fmt.Sprintf("%s[delete]", label))...); err != nil {
+		return errors.Wrap(err, "editing 1password item")
+	}
+	return nil
+}
+
+// run executes op, mapping missing items to ErrSecretNotFound
+func (c *onePasswordCLI) run(ctx context.Context, stdout *bytes.Buffer, args ...string) error {
+	cmd := exec.CommandContext(ctx, onePasswordCLIName, args...)
+
+	var stderr bytes.Buffer
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = &stderr
+
+	c.logger.Debug("executing 1password cli command", zap.Strings("args", args[:min(len(args), 3)]))
+
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "isn't an item") || strings.Contains(stderr.String(), "not found") {
+			return ErrSecretNotFound
+		}
+		return errors.Wrapf(err, "executing op cli command: stderr=%s", stderr.String())
+	}
+
+	return nil
+}
+
+// withVault appends --vault when a vault is selected
+func withVault(vault string, args ...string) []string {
+	if vault != "" {
+		args = append(args, "--vault", vault)
+	}
+	return args
+}
@@ -0,0 +1,240 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const connectTimeout = 10 * time.Second
+
+// onePasswordConnect talks to a 1Password Connect server over its REST API
+type onePasswordConnect struct {
+	host       string
+	token      string
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	vaultIDs map[string]string
+}
+
+type connectVault struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// connectPatchOp is one operation of a JSON Patch (RFC 6902) item update
+type connectPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func newOnePasswordConnect(host, token string, httpClient *http.Client) *onePasswordConnect {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: connectTimeout}
+	}
+	return &onePasswordConnect{
+		host:       strings.TrimRight(host, "/"),
+		token:      token,
+		httpClient: httpClient,
+		vaultIDs:   make(map[string]string),
+	}
+}
+
+func (c *onePasswordConnect) name() string {
+	return "connect"
+}
+
+// available checks that the server accepts the token
+func (c *onePasswordConnect) available(ctx context.Context) bool {
+	var vaults []connectVault
+	return c.do(ctx, "GET", "/v1/vaults", nil, &vaults) == nil
+}
+
+func (c *onePasswordConnect) getItem(ctx context.Context, vault, item string) (*onePasswordItem, error) {
+	vaultID, err := c.vaultID(ctx, vault)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []onePasswordItem
+	query := url.Values{"filter": {fmt.Sprintf("title eq %q", item)}}
+	if err := c.do(ctx, "GET", fmt.Sprintf("/v1/vaults/%s/items?%s", vaultID, query.Encode()), nil, &items); err != nil {
+		return nil, errors.Wrap(err, "searching 1password connect items")
+	}
+	if len(items) == 0 {
+		return nil, errors.Wrapf(ErrSecretNotFound, "1password item %s", item)
+	}
+
+	var result onePasswordItem
+	if err := c.do(ctx, "GET", fmt.Sprintf("/v1/vaults/%s/items/%s", vaultID, items[0].ID), nil, &result); err != nil {
+		return nil, errors.Wrap(err, "getting 1password connect item")
+	}
+
+	return &result, nil
+}
+
+func (c *onePasswordConnect) setField(ctx context.Context, vault, item, label, value string) error {
+	existing, err := c.getItem(ctx, vault, item)
+	if errors.Is(err, ErrSecretNotFound) {
+		vaultID, err := c.vaultID(ctx, vault)
+		if err != nil {
+			return err
+		}
+
+		created := onePasswordItem{
+			Title:    item,
+			Category: "API_CREDENTIAL",
+			Fields:   []onePasswordField{{Type: "CONCEALED", Label: label, Value: value}},
+		}
+		created.Vault.ID = vaultID
+
+		if err := c.do(ctx, "POST", fmt.Sprintf("/v1/vaults/%s/items", vaultID), created, nil); err != nil {
+			return errors.Wrap(err, "creating 1password connect item")
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var patch []connectPatchOp
+	for _, f := range existing.Fields {
+		if f.Label == label || f.ID == label {
+			patch = append(patch, connectPatchOp{Op: "replace", Path: "/fields/" + f.ID + "/value", Value: value})
+		}
+	}
+	if len(patch) == 0 {
+		patch = append(patch, connectPatchOp{
+			Op:    "add",
+			Path:  "/fields",
+			Value: onePasswordField{Type: "CONCEALED", Label: label, Value: value},
+		})
+	}
+
+	return c.patchItem(ctx, existing, patch)
+}
+
+func (c *onePasswordConnect) deleteField(ctx context.Context, vault, item, label string) error {
+	existing, err := c.getItem(ctx, vault, item)
+	if err != nil {
+		return err
+	}
+
+	var patch []connectPatchOp
+	for _, f := range existing.Fields {
+		if f.Label == label || f.ID == label {
+			patch = append(patch, connectPatchOp{Op: "remove", Path: "/fields/" + f.ID})
+		}
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+
+	return c.patchItem(ctx, existing, patch)
+}
+
+// patchItem applies a JSON Patch to an item. Unlike a PUT, which replaces the
+// whole item, this leaves sections, tags, URLs, notes and the attributes of
+// other fields as they are.
+func (c *onePasswordConnect) patchItem(ctx context.Context, item *onePasswordItem, patch []connectPatchOp) error {
+	path := fmt.Sprintf("/v1/vaults/%s/items/%s", item.Vault.ID, item.ID)
+	if err := c.do(ctx, "PATCH", path, patch, nil); err != nil {
+		return errors.Wrap(err, "updating 1password connect item")
+	}
+	return nil
+}
+
+// vaultID resolves a vault name or ID. With no vault selected, the token must
+// have access to exactly one vault.
+func (c *onePasswordConnect) vaultID(ctx context.Context, vault string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if id, ok := c.vaultIDs[vault]; ok {
+		return id, nil
+	}
+
+	var vaults []connectVault
+	if err := c.do(ctx, "GET", "/v1/vaults", nil, &vaults); err != nil {
+		return "", errors.Wrap(err, "listing 1password connect vaults")
+	}
+
+	var id string
+	if vault == "" {
+		if len(vaults) != 1 {
+			return "", errors.Newf("1password connect token can access %d vaults, set onepassword_vault to choose one", len(vaults))
+		}
+		id = vaults[0].ID
+	} else {
+		for _, v := range vaults {
+			if v.Name == vault || v.ID == vault {
+				id = v.ID
+				break
+			}
+		}
+		if id == "" {
+			return "", errors.Newf("1password vault %s not found", vault)
+		}
+	}
+
+	c.vaultIDs[vault] = id
+	return id, nil
+}
+
+// do sends an authenticated request and decodes the JSON response into out
+func (c *onePasswordConnect) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "marshaling request body")
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, bodyReader)
+	if err != nil {
+		return errors.Wrap(err, "creating 1password connect request")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "executing 1password connect request")
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "reading 1password connect response")
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.Wrapf(ErrSecretNotFound, "1password connect %s %s", method, path)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Newf("1password connect error: status=%d, body=%s", resp.StatusCode, string(respBody))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return errors.Wrap(err, "unmarshaling 1password connect response")
+		}
+	}
+
+	return nil
+}
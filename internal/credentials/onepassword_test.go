@@ -0,0 +1,327 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testConnectToken = "connect-token"
+
+// connectStub is a minimal 1Password Connect server holding items in memory.
+// Items are kept as raw JSON objects so that tests can check attributes the
+// client doesn't model survive an update.
+type connectStub struct {
+	t      *testing.T
+	vaults []connectVault
+
+	mu       sync.Mutex
+	items    map[string]map[string]interface{}
+	nextID   int
+	requests int
+}
+
+func newConnectStub(t *testing.T, vaults ...connectVault) (*connectStub, *httptest.Server) {
+	stub := &connectStub{t: t, vaults: vaults, items: make(map[string]map[string]interface{})}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return stub, server
+}
+
+func (s *connectStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	if r.Header.Get("Authorization") != "Bearer "+testConnectToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v1/vaults":
+		json.NewEncoder(w).Encode(s.vaults)
+	case r.Method == "GET" && len(parts) == 4 && parts[3] == "items":
+		var title string
+		fmt.Sscanf(r.URL.Query().Get("filter"), "title eq %q", &title)
+		matches := []map[string]interface{}{}
+		for id, item := range s.items {
+			if item["vault"].(map[string]interface{})["id"] == parts[2] && item["title"] == title {
+				matches = append(matches, map[string]interface{}{"id": id, "title": title})
+			}
+		}
+		json.NewEncoder(w).Encode(matches)
+	case r.Method == "POST" && len(parts) == 4 && parts[3] == "items":
+		var item map[string]interface{}
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(&item))
+		assert.Equal(s.t, parts[2], item["vault"].(map[string]interface{})["id"])
+		json.NewEncoder(w).Encode(s.store(item))
+	case len(parts) == 5 && parts[3] == "items":
+		item, ok := s.items[parts[4]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case "PUT":
+			s.t.Error("items should be patched, a PUT replaces the whole item")
+		case "PATCH":
+			var patch []connectPatchOp
+			require.NoError(s.t, json.NewDecoder(r.Body).Decode(&patch))
+			for _, op := range patch {
+				s.applyPatch(item, op)
+			}
+		}
+		json.NewEncoder(w).Encode(item)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// applyPatch applies the field operations the Connect API supports
+func (s *connectStub) applyPatch(item map[string]interface{}, op connectPatchOp) {
+	fields, _ := item["fields"].([]interface{})
+	path := strings.Split(strings.TrimPrefix(op.Path, "/"), "/")
+	require.Equal(s.t, "fields", path[0], "unexpected patch path %s", op.Path)
+
+	if op.Op == "add" && len(path) == 1 {
+		field := op.Value.(map[string]interface{})
+		s.nextID++
+		field["id"] = fmt.Sprintf("field%d", s.nextID)
+		item["fields"] = append(fields, field)
+		return
+	}
+
+	require.GreaterOrEqual(s.t, len(path), 2, "unexpected patch path %s", op.Path)
+	for i, f := range fields {
+		field := f.(map[string]interface{})
+		if field["id"] != path[1] {
+			continue
+		}
+		switch {
+		case op.Op == "remove" && len(path) == 2:
+			item["fields"] = append(fields[:i:i], fields[i+1:]...)
+		case op.Op == "replace" && len(path) == 3:
+			field[path[2]] = op.Value
+		default:
+			s.t.Errorf("unsupported patch %s %s", op.Op, op.Path)
+		}
+		return
+	}
+	s.t.Errorf("patch %s %s names a missing field", op.Op, op.Path)
+}
+
+// store gives an item and its fields IDs and keeps it
+func (s *connectStub) store(item map[string]interface{}) map[string]interface{} {
+	s.nextID++
+	item["id"] = fmt.Sprintf("item%d", s.nextID)
+	fields, _ := item["fields"].([]interface{})
+	for _, f := range fields {
+		field := f.(map[string]interface{})
+		if field["id"] == nil || field["id"] == "" {
+			s.nextID++
+			field["id"] = fmt.Sprintf("field%d", s.nextID)
+		}
+	}
+	s.items[item["id"].(string)] = item
+	return item
+}
+
+func (s *connectStub) addItem(vaultID, title string, fields ...onePasswordField) {
+	item := onePasswordItem{Title: title, Fields: fields}
+	item.Vault.ID = vaultID
+	data, err := json.Marshal(item)
+	require.NoError(s.t, err)
+	s.addRawItem(string(data))
+}
+
+// addRawItem stores an item given as Connect JSON
+func (s *connectStub) addRawItem(data string) string {
+	var item map[string]interface{}
+	require.NoError(s.t, json.Unmarshal([]byte(data), &item))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store(item)["id"].(string)
+}
+
+// rawItem returns a stored item as Connect JSON
+func (s *connectStub) rawItem(id string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.items[id]
+}
+
+func (s *connectStub) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func newConnectStore(server *httptest.Server, vault string) *Manager {
+	return NewOnePasswordStore(zap.NewNop(), OnePasswordConfig{
+		Vault:        vault,
+		ConnectHost:  server.URL,
+		ConnectToken: testConnectToken,
+	})
+}
+
+func TestOnePasswordConnectStore(t *testing.T) {
+	_, server := newConnectStub(t, connectVault{ID: "vault1", Name: "Home"})
+
+	store := newConnectStore(server, "")
+	assert.True(t, store.IsAvailable(context.Background()))
+
+	testStoreRoundTrip(t, store)
+}
+
+func TestOnePasswordConnectUnauthorized(t *testing.T) {
+	_, server := newConnectStub(t, connectVault{ID: "vault1", Name: "Home"})
+
+	store := NewOnePasswordStore(zap.NewNop(), OnePasswordConfig{ConnectHost: server.URL, ConnectToken: "wrong"})
+	assert.False(t, store.IsAvailable(context.Background()))
+}
+
+func TestOnePasswordConnectVaultSelection(t *testing.T) {
+	ctx := context.Background()
+	stub, server := newConnectStub(t,
+		connectVault{ID: "vault1", Name: "Home"},
+		connectVault{ID: "vault2", Name: "Office"},
+	)
+	stub.addItem("vault1", "hue", onePasswordField{Label: FieldAPIKey, Type: "CONCEALED", Value: "home-key"})
+	stub.addItem("vault2", "hue", onePasswordField{Label: FieldAPIKey, Type: "CONCEALED", Value: "office-key"})
+
+	_, err := newConnectStore(server, "").Get(ctx, "hue", FieldAPIKey)
+	assert.ErrorContains(t, err, "onepassword_vault")
+
+	value, err := newConnectStore(server, "Office").Get(ctx, "hue", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "office-key", value)
+
+	value, err = newConnectStore(server, "Office").Get(ctx, "op://Home/hue", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "home-key", value, "a secret reference overrides the configured vault")
+
+	_, err = newConnectStore(server, "Cabin").Get(ctx, "hue", FieldAPIKey)
+	assert.ErrorContains(t, err, "vault Cabin not found")
+}
+
+func TestOnePasswordFieldReference(t *testing.T) {
+	ctx := context.Background()
+	stub, server := newConnectStub(t, connectVault{ID: "vault1", Name: "Home"})
+	stub.addItem("vault1", "hue",
+		onePasswordField{Label: "password", Type: "CONCEALED", Value: "not-the-key"},
+		onePasswordField{Label: "application key", Type: "CONCEALED", Value: "the-key"},
+	)
+
+	store := newConnectStore(server, "")
+
+	_, err := store.Get(ctx, "hue", FieldAPIKey)
+	assert.Error(t, err, "only a field labelled api_key matches without a field reference")
+
+	value, err := store.Get(ctx, "op://Home/hue/application key", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "the-key", value)
+}
+
+func TestOnePasswordCachesSecrets(t *testing.T) {
+	ctx := context.Background()
+	stub, server := newConnectStub(t, connectVault{ID: "vault1", Name: "Home"})
+	stub.addItem("vault1", "hue", onePasswordField{Label: FieldAPIKey, Type: "CONCEALED", Value: "cached-key"})
+
+	store := newConnectStore(server, "")
+
+	value, err := store.Get(ctx, "hue", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "cached-key", value)
+
+	requests := stub.requestCount()
+	for i := 0; i < 3; i++ {
+		value, err = store.Get(ctx, "hue", FieldAPIKey)
+		require.NoError(t, err)
+		assert.Equal(t, "cached-key", value)
+	}
+	assert.Equal(t, requests, stub.requestCount(), "cached secrets should not hit the server")
+
+	require.NoError(t, store.Set(ctx, "hue", FieldAPIKey, "new-key"))
+	value, err = store.Get(ctx, "hue", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "new-key", value)
+}
+
+func TestOnePasswordConnectKeepsUnmodelledData(t *testing.T) {
+	ctx := context.Background()
+	stub, server := newConnectStub(t, connectVault{ID: "vault1", Name: "Home"})
+	id := stub.addRawItem(`{
+		"title": "hue",
+		"category": "API_CREDENTIAL",
+		"vault": {"id": "vault1"},
+		"tags": ["home", "lighting"],
+		"urls": [{"primary": true, "href": "https://192.168.1.2"}],
+		"sections": [{"id": "bridge", "label": "Bridge"}],
+		"fields": [
+			{"id": "notesPlain", "type": "STRING", "purpose": "NOTES", "label": "notesPlain", "value": "Living room bridge"},
+			{"id": "serial", "type": "STRING", "label": "serial", "value": "ABC123", "section": {"id": "bridge"}},
+			{"id": "password", "type": "CONCEALED", "purpose": "PASSWORD", "label": "password", "value": "x", "generate": true}
+		]
+	}`)
+
+	store := newConnectStore(server, "")
+	require.NoError(t, store.Set(ctx, "hue", FieldAPIKey, "first-key"))
+	require.NoError(t, store.Set(ctx, "hue", FieldAPIKey, "second-key"))
+	require.NoError(t, store.Set(ctx, "hue", FieldClientKey, "client-key"))
+	require.NoError(t, store.Delete(ctx, "hue", FieldClientKey))
+
+	value, err := newConnectStore(server, "").Get(ctx, "hue", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "second-key", value)
+
+	item := stub.rawItem(id)
+	assert.Equal(t, []interface{}{"home", "lighting"}, item["tags"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"primary": true, "href": "https://192.168.1.2"}}, item["urls"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": "bridge", "label": "Bridge"}}, item["sections"])
+
+	fields := item["fields"].([]interface{})
+	require.Len(t, fields, 4, "the client key field should be gone and the api key added once")
+	assert.Equal(t, "Living room bridge", fields[0].(map[string]interface{})["value"])
+	assert.Equal(t, map[string]interface{}{"id": "bridge"}, fields[1].(map[string]interface{})["section"])
+	assert.Equal(t, true, fields[2].(map[string]interface{})["generate"])
+	assert.Equal(t, FieldAPIKey, fields[3].(map[string]interface{})["label"])
+}
+
+func TestParseOnePasswordRef(t *testing.T) {
+	tests := []struct {
+		ref         string
+		expected    onePasswordRef
+		expectError bool
+	}{
+		{ref: "limelight-hue", expected: onePasswordRef{vault: "Default", item: "limelight-hue"}},
+		{ref: "op://Home/limelight-hue", expected: onePasswordRef{vault: "Home", item: "limelight-hue"}},
+		{ref: "op://Home/limelight-hue/key", expected: onePasswordRef{vault: "Home", item: "limelight-hue", field: "key"}},
+		{ref: "op://Home", expectError: true},
+		{ref: "op:///item", expectError: true},
+		{ref: "op://Home/item/section/field", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			r, err := parseOnePasswordRef(tt.ref, "Default")
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, r)
+		})
+	}
+}
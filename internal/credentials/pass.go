@@ -0,0 +1,103 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+const passCLI = "pass"
+
+// PassStore keeps secrets in pass (https://www.passwordstore.org/),
+// one entry per field at <ref>/<field>
+type PassStore struct {
+	logger *zap.Logger
+}
+
+var _ Store = (*PassStore)(nil)
+
+// NewPassStore creates a store backed by the pass CLI
+func NewPassStore(logger *zap.Logger) *PassStore {
+	return &PassStore{logger: logger}
+}
+
+// Name returns the store backend name
+func (s *PassStore) Name() string {
+	return StorePass
+}
+
+// IsAvailable reports whether pass is installed
+func (s *PassStore) IsAvailable(ctx context.Context) bool {
+	_, err := exec.LookPath(passCLI)
+	return err == nil
+}
+
+// Get returns the first line of the pass entry for field
+func (s *PassStore) Get(ctx context.Context, ref, field string) (string, error) {
+	entry := path.Join(ref, field)
+
+	var stdout bytes.Buffer
+	if err := s.run(ctx, nil, &stdout, "show", entry); err != nil {
+		if errors.Is(err, ErrSecretNotFound) {
+			return "", errors.Wrapf(err, "pass entry %s", entry)
+		}
+		return "", err
+	}
+
+	value, _, _ := strings.Cut(stdout.String(), "\n")
+	if value == "" {
+		return "", errors.Wrapf(ErrSecretNotFound, "pass entry %s is empty", entry)
+	}
+
+	s.logger.Debug("secret retrieved from pass", zap.String("entry", entry))
+	return value, nil
+}
+
+// Set creates or overwrites the pass entry for field
+func (s *PassStore) Set(ctx context.Context, ref, field, value string) error {
+	entry := path.Join(ref, field)
+
+	if err := s.run(ctx, strings.NewReader(value+"\n"), nil, "insert", "--multiline", "--force", entry); err != nil {
+		return err
+	}
+
+	s.logger.Info("secret saved to pass", zap.String("entry", entry))
+	return nil
+}
+
+// Delete removes the pass entry for field
+func (s *PassStore) Delete(ctx context.Context, ref, field string) error {
+	err := s.run(ctx, nil, nil, "rm", "--force", path.Join(ref, field))
+	if errors.Is(err, ErrSecretNotFound) {
+		return nil
+	}
+	return err
+}
+
+// run executes pass, mapping missing entries to ErrSecretNotFound
+func (s *PassStore) run(ctx context.Context, stdin *strings.Reader, stdout *bytes.Buffer, args ...string) error {
+	cmd := exec.CommandContext(ctx, passCLI, args...)
+
+	var stderr bytes.Buffer
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "is not in the password store") {
+			return ErrSecretNotFound
+		}
+		return errors.Wrapf(err, "executing pass %s: stderr=%s", args[0], stderr.String())
+	}
+
+	return nil
+}
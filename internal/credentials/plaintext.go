@@ -0,0 +1,72 @@
+package credentials
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+)
+
+// PlaintextStore keeps secrets unencrypted in the config file.
+// It is only used when explicitly selected.
+type PlaintextStore struct {
+	config *Config
+}
+
+var _ Store = (*PlaintextStore)(nil)
+
+// NewPlaintextStore creates a store that saves secrets into config
+func NewPlaintextStore(config *Config) *PlaintextStore {
+	return &PlaintextStore{config: config}
+}
+
+// Name returns the store backend name
+func (s *PlaintextStore) Name() string {
+	return StorePlaintext
+}
+
+// IsAvailable always returns true
+func (s *PlaintextStore) IsAvailable(ctx context.Context) bool {
+	return true
+}
+
+// Get returns a secret from the config
+func (s *PlaintextStore) Get(ctx context.Context, ref, field string) (string, error) {
+	value, ok := s.config.Secrets[ref][field]
+	if !ok || value == "" {
+		return "", errors.Wrapf(ErrSecretNotFound, "%s field of %s in config file", field, ref)
+	}
+	return value, nil
+}
+
+// Set stores a secret in the config and saves it
+func (s *PlaintextStore) Set(ctx context.Context, ref, field, value string) error {
+	if s.config.Secrets == nil {
+		s.config.Secrets = make(map[string]map[string]string)
+	}
+	if s.config.Secrets[ref] == nil {
+		s.config.Secrets[ref] = make(map[string]string)
+	}
+	s.config.Secrets[ref][field] = value
+
+	if err := SaveConfig(s.config); err != nil {
+		return errors.Wrap(err, "saving config")
+	}
+	return nil
+}
+
+// Delete removes a secret from the config and saves it
+func (s *PlaintextStore) Delete(ctx context.Context, ref, field string) error {
+	if _, ok := s.config.Secrets[ref][field]; !ok {
+		return nil
+	}
+
+	delete(s.config.Secrets[ref], field)
+	if len(s.config.Secrets[ref]) == 0 {
+		delete(s.config.Secrets, ref)
+	}
+
+	if err := SaveConfig(s.config); err != nil {
+		return errors.Wrap(err, "saving config")
+	}
+	return nil
+}
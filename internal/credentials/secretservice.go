@@ -0,0 +1,316 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/godbus/dbus/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	secretServiceName   = "org.freedesktop.secrets"
+	secretServicePath   = dbus.ObjectPath("/org/freedesktop/secrets")
+	serviceInterface    = "org.freedesktop.Secret.Service"
+	collectionInterface = "org.freedesktop.Secret.Collection"
+	itemInterface       = "org.freedesktop.Secret.Item"
+	sessionInterface    = "org.freedesktop.Secret.Session"
+	promptInterface     = "org.freedesktop.Secret.Prompt"
+
+	// noPrompt is returned in place of a prompt path when no user interaction is needed
+	noPrompt = dbus.ObjectPath("/")
+
+	secretServiceApplication = "limelight"
+)
+
+// secretServiceSecret is the Secret struct from the Secret Service API, (oayays) on the wire
+type secretServiceSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// SecretServiceStore keeps secrets in the freedesktop Secret Service
+// (GNOME Keyring, KWallet, KeePassXC) over the D-Bus session bus.
+// Items are found by their application, ref and field attributes.
+type SecretServiceStore struct {
+	logger  *zap.Logger
+	connect func() (*dbus.Conn, error)
+}
+
+var _ Store = (*SecretServiceStore)(nil)
+
+// NewSecretServiceStore creates a store using the D-Bus session bus
+func NewSecretServiceStore(logger *zap.Logger) *SecretServiceStore {
+	return newSecretServiceStore(logger, connectSessionBus)
+}
+
+func newSecretServiceStore(logger *zap.Logger, connect func() (*dbus.Conn, error)) *SecretServiceStore {
+	return &SecretServiceStore{
+		logger:  logger,
+		connect: connect,
+	}
+}
+
+// connectSessionBus connects to an already running session bus.
+// Unlike dbus.ConnectSessionBus it never launches a bus daemon.
+func connectSessionBus() (*dbus.Conn, error) {
+	conn, err := dbus.SessionBusPrivateNoAutoStartup()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// Name returns the store backend name
+func (s *SecretServiceStore) Name() string {
+	return StoreSecretService
+}
+
+// IsAvailable reports whether a Secret Service provider answers on the session bus,
+// starting it if it is D-Bus activatable
+func (s *SecretServiceStore) IsAvailable(ctx context.Context) bool {
+	conn, err := s.connect()
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	call := conn.Object(secretServiceName, secretServicePath).CallWithContext(ctx, "org.freedesktop.DBus.Peer.Ping", 0)
+	return call.Err == nil
+}
+
+// Get returns the secret of the item matching ref and field
+func (s *SecretServiceStore) Get(ctx context.Context, ref, field string) (string, error) {
+	session, err := s.openSession(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer session.close()
+
+	item, err := session.findItem(ctx, ref, field)
+	if err != nil {
+		return "", err
+	}
+	if item == "" {
+		return "", errors.Wrapf(ErrSecretNotFound, "%s field of %s in secret service", field, ref)
+	}
+
+	var secret secretServiceSecret
+	call := session.conn.Object(secretServiceName, item).CallWithContext(ctx, itemInterface+".GetSecret", 0, session.path)
+	if err := call.Store(&secret); err != nil {
+		return "", errors.Wrap(err, "getting secret from secret service")
+	}
+
+	s.logger.Debug("secret retrieved from secret service",
+		zap.String("ref", ref),
+		zap.String("field", field),
+	)
+
+	return string(secret.Value), nil
+}
+
+// Set creates or replaces the item for ref and field in the default collection
+func (s *SecretServiceStore) Set(ctx context.Context, ref, field, value string) error {
+	session, err := s.openSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	var collectionPath dbus.ObjectPath
+	call := session.service.CallWithContext(ctx, serviceInterface+".ReadAlias", 0, "default")
+	if err := call.Store(&collectionPath); err != nil {
+		return errors.Wrap(err, "reading default secret service collection")
+	}
+	if collectionPath == noPrompt {
+		return errors.New("secret service has no default collection, create a keyring first")
+	}
+
+	if err := session.unlock(ctx, collectionPath); err != nil {
+		return err
+	}
+
+	properties := map[string]dbus.Variant{
+		itemInterface + ".Label":      dbus.MakeVariant(fmt.Sprintf("limelight %s (%s)", ref, field)),
+		itemInterface + ".Attributes": dbus.MakeVariant(secretServiceAttributes(ref, field)),
+	}
+	secret := secretServiceSecret{
+		Session:     session.path,
+		Parameters:  []byte{},
+		Value:       []byte(value),
+		ContentType: "text/plain",
+	}
+
+	var item, prompt dbus.ObjectPath
+	call = session.conn.Object(secretServiceName, collectionPath).CallWithContext(ctx, collectionInterface+".CreateItem", 0, properties, secret, true)
+	if err := call.Store(&item, &prompt); err != nil {
+		return errors.Wrap(err, "creating secret service item")
+	}
+
+	if err := session.prompt(ctx, prompt); err != nil {
+		return err
+	}
+
+	s.logger.Info("secret saved to secret service",
+		zap.String("ref", ref),
+		zap.String("field", field),
+	)
+
+	return nil
+}
+
+// Delete removes the item for ref and field
+func (s *SecretServiceStore) Delete(ctx context.Context, ref, field string) error {
+	session, err := s.openSession(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	item, err := session.findItem(ctx, ref, field)
+	if err != nil || item == "" {
+		return err
+	}
+
+	var prompt dbus.ObjectPath
+	call := session.conn.Object(secretServiceName, item).CallWithContext(ctx, itemInterface+".Delete", 0)
+	if err := call.Store(&prompt); err != nil {
+		return errors.Wrap(err, "deleting secret service item")
+	}
+
+	return session.prompt(ctx, prompt)
+}
+
+// secretServiceAttributes returns the lookup attributes for an item
+func secretServiceAttributes(ref, field string) map[string]string {
+	return map[string]string{
+		"application": secretServiceApplication,
+		"ref":         ref,
+		"field":       field,
+	}
+}
+
+// secretSession is an open Secret Service session on its own bus connection
+type secretSession struct {
+	conn    *dbus.Conn
+	service dbus.BusObject
+	path    dbus.ObjectPath
+}
+
+// openSession connects to the bus and opens an unencrypted session.
+// Secrets only travel over the local session bus, so the plain algorithm is used.
+func (s *SecretServiceStore) openSession(ctx context.Context) (*secretSession, error) {
+	conn, err := s.connect()
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to d-bus session bus")
+	}
+
+	service := conn.Object(secretServiceName, secretServicePath)
+
+	var output dbus.Variant
+	var path dbus.ObjectPath
+	call := service.CallWithContext(ctx, serviceInterface+".OpenSession", 0, "plain", dbus.MakeVariant(""))
+	if err := call.Store(&output, &path); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "opening secret service session")
+	}
+
+	return &secretSession{
+		conn:    conn,
+		service: service,
+		path:    path,
+	}, nil
+}
+
+// close closes the session and its bus connection
+func (ss *secretSession) close() {
+	ss.conn.Object(secretServiceName, ss.path).Call(sessionInterface+".Close", 0)
+	ss.conn.Close()
+}
+
+// findItem returns the unlocked item for ref and field, or "" if there is none
+func (ss *secretSession) findItem(ctx context.Context, ref, field string) (dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	call := ss.service.CallWithContext(ctx, serviceInterface+".SearchItems", 0, secretServiceAttributes(ref, field))
+	if err := call.Store(&unlocked, &locked); err != nil {
+		return "", errors.Wrap(err, "searching secret service items")
+	}
+
+	if len(unlocked) > 0 {
+		return unlocked[0], nil
+	}
+	if len(locked) > 0 {
+		if err := ss.unlock(ctx, locked[0]); err != nil {
+			return "", err
+		}
+		return locked[0], nil
+	}
+
+	return "", nil
+}
+
+// unlock unlocks an item or collection, prompting the user if the provider requires it
+func (ss *secretSession) unlock(ctx context.Context, object dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	call := ss.service.CallWithContext(ctx, serviceInterface+".Unlock", 0, []dbus.ObjectPath{object})
+	if err := call.Store(&unlocked, &prompt); err != nil {
+		return errors.Wrap(err, "unlocking secret service keyring")
+	}
+
+	return ss.prompt(ctx, prompt)
+}
+
+// prompt runs a Secret Service prompt and waits for the user to complete it
+func (ss *secretSession) prompt(ctx context.Context, prompt dbus.ObjectPath) error {
+	if prompt == noPrompt || prompt == "" {
+		return nil
+	}
+
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface(promptInterface),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := ss.conn.AddMatchSignalContext(ctx, match...); err != nil {
+		return errors.Wrap(err, "subscribing to secret service prompt")
+	}
+	defer ss.conn.RemoveMatchSignal(match...)
+
+	signals := make(chan *dbus.Signal, 1)
+	ss.conn.Signal(signals)
+	defer ss.conn.RemoveSignal(signals)
+
+	call := ss.conn.Object(secretServiceName, prompt).CallWithContext(ctx, promptInterface+".Prompt", 0, "")
+	if call.Err != nil {
+		return errors.Wrap(call.Err, "showing secret service prompt")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "waiting for secret service prompt")
+		case signal := <-signals:
+			if signal.Path != prompt || signal.Name != promptInterface+".Completed" || len(signal.Body) == 0 {
+				continue
+			}
+			if dismissed, _ := signal.Body[0].(bool); dismissed {
+				return errors.New("secret service prompt was dismissed")
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/mithilarun/limelight/internal/testutil/dbustest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	fakeCollectionPath = dbus.ObjectPath("/org/freedesktop/secrets/collection/login")
+	fakePromptPath     = dbus.ObjectPath("/org/freedesktop/secrets/prompt/unlock")
+)
+
+// fakeSecretService implements the parts of the Secret Service API the store uses.
+// The collection starts locked so unlocking goes through a prompt.
+type fakeSecretService struct {
+	conn *dbus.Conn
+
+	mu       sync.Mutex
+	locked   bool
+	nextItem int
+	items    map[dbus.ObjectPath]*fakeSecretItem
+}
+
+type fakeSecretItem struct {
+	service    *fakeSecretService
+	path       dbus.ObjectPath
+	attributes map[string]string
+	secret     []byte
+}
+
+func (s *fakeSecretService) OpenSession(algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if algorithm != "plain" {
+		return dbus.Variant{}, "", dbus.MakeFailedError(fmt.Errorf("unsupported algorithm %s", algorithm))
+	}
+	return dbus.MakeVariant(""), "/org/freedesktop/secrets/session/1", nil
+}
+
+func (s *fakeSecretService) SearchItems(attributes map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := []dbus.ObjectPath{}
+	for path, item := range s.items {
+		if item.matches(attributes) {
+			matches = append(matches, path)
+		}
+	}
+
+	if s.locked {
+		return []dbus.ObjectPath{}, matches, nil
+	}
+	return matches, []dbus.ObjectPath{}, nil
+}
+
+func (s *fakeSecretService) Unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locked {
+		return []dbus.ObjectPath{}, fakePromptPath, nil
+	}
+	return objects, noPrompt, nil
+}
+
+func (s *fakeSecretService) ReadAlias(name string) (dbus.ObjectPath, *dbus.Error) {
+	if name == "default" {
+		return fakeCollectionPath, nil
+	}
+	return noPrompt, nil
+}
+
+// Prompt unlocks the collection, as if the user had typed their keyring password
+func (s *fakeSecretService) Prompt(windowID string) *dbus.Error {
+	s.mu.Lock()
+	s.locked = false
+	s.mu.Unlock()
+
+	if err := s.conn.Emit(fakePromptPath, promptInterface+".Completed", false, dbus.MakeVariant([]dbus.ObjectPath{fakeCollectionPath})); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (s *fakeSecretService) CreateItem(properties map[string]dbus.Variant, secret secretServiceSecret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locked {
+		return "", "", dbus.MakeFailedError(fmt.Errorf("collection is locked"))
+	}
+
+	var attributes map[string]string
+	if err := properties[itemInterface+".Attributes"].Store(&attributes); err != nil {
+		return "", "", dbus.MakeFailedError(err)
+	}
+
+	if replace {
+		for _, item := range s.items {
+			if item.matches(attributes) {
+				item.secret = secret.Value
+				return item.path, noPrompt, nil
+			}
+		}
+	}
+
+	s.nextItem++
+	item := &fakeSecretItem{
+		service:    s,
+		path:       dbus.ObjectPath(fmt.Sprintf("%s/%d", fakeCollectionPath, s.nextItem)),
+		attributes: attributes,
+		secret:     secret.Value,
+	}
+	s.items[item.path] = item
+
+	if err := s.conn.Export(item, item.path, itemInterface); err != nil {
+		return "", "", dbus.MakeFailedError(err)
+	}
+	return item.path, noPrompt, nil
+}
+
+func (s *fakeSecretService) Close() *dbus.Error {
+	return nil
+}
+
+func (i *fakeSecretItem) GetSecret(session dbus.ObjectPath) (secretServiceSecret, *dbus.Error) {
+	i.service.mu.Lock()
+	defer i.service.mu.Unlock()
+
+	return secretServiceSecret{Session: session, Parameters: []byte{}, Value: i.secret, ContentType: "text/plain"}, nil
+}
+
+func (i *fakeSecretItem) Delete() (dbus.ObjectPath, *dbus.Error) {
+	i.service.mu.Lock()
+	defer i.service.mu.Unlock()
+
+	delete(i.service.items, i.path)
+	i.service.conn.Export(nil, i.path, itemInterface)
+	return noPrompt, nil
+}
+
+func (i *fakeSecretItem) matches(attributes map[string]string) bool {
+	for key, value := range attributes {
+		if i.attributes[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSecretServiceStore(t *testing.T) {
+	address := dbustest.StartSessionBus(t)
+	connect := dbustest.Connector(address)
+
+	store := newSecretServiceStore(zap.NewNop(), connect)
+	assert.False(t, store.IsAvailable(context.Background()), "no provider is running yet")
+
+	conn, err := connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	service := &fakeSecretService{
+		conn:   conn,
+		locked: true,
+		items:  make(map[dbus.ObjectPath]*fakeSecretItem),
+	}
+	require.NoError(t, conn.Export(service, secretServicePath, serviceInterface))
+	require.NoError(t, conn.Export(service, fakeCollectionPath, collectionInterface))
+	require.NoError(t, conn.Export(service, fakePromptPath, promptInterface))
+	require.NoError(t, conn.Export(service, "/org/freedesktop/secrets/session/1", sessionInterface))
+
+	reply, err := conn.RequestName(secretServiceName, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+
+	assert.True(t, store.IsAvailable(context.Background()))
+
+	testStoreRoundTrip(t, store)
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	assert.False(t, service.locked, "collection should have been unlocked through the prompt")
+	require.Len(t, service.items, 1)
+	for _, item := range service.items {
+		assert.Equal(t, secretServiceAttributes("limelight-test", "client_key"), item.attributes)
+	}
+}
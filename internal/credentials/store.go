@@ -0,0 +1,140 @@
+package credentials
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+// Credential store backend names, as used for credential_store in the config file
+const (
+	StoreOnePassword   = "1password"
+	StoreSecretService = "secret-service"
+	StorePass          = "pass"
+	StoreFile          = "file"
+	StoreEnv           = "env"
+	StorePlaintext     = "plaintext"
+)
+
+// Secret field names
+const (
+	// FieldAPIKey holds the bridge application key
+	FieldAPIKey = "api_key"
+	// FieldClientKey holds the entertainment streaming pre-shared key
+	FieldClientKey = "client_key"
+)
+
+// DefaultCredentialRef is the item name used when none is configured
+const DefaultCredentialRef = "limelight-hue"
+
+// ErrSecretNotFound is returned when a store has no value for a field
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Store reads and writes bridge secrets.
+// A ref names a group of secrets (a 1Password item, a pass directory, an
+// encrypted file entry) and a field names one secret within it.
+type Store interface {
+	// Name returns the backend name, one of the Store* constants
+	Name() string
+	// IsAvailable reports whether the backend can be used on this machine
+	IsAvailable(ctx context.Context) bool
+	// Get returns the value of field in ref, or an error wrapping ErrSecretNotFound
+	Get(ctx context.Context, ref, field string) (string, error)
+	// Set creates or replaces the value of field in ref
+	Set(ctx context.Context, ref, field, value string) error
+	// Delete removes field from ref. Deleting a missing field is not an error.
+	Delete(ctx context.Context, ref, field string) error
+}
+
+// StoreInfo describes a credential store backend
+type StoreInfo struct {
+	Name        string
+	Description string
+	// Secure is false for backends that keep secrets unencrypted on disk or in the environment
+	Secure bool
+}
+
+// Stores lists the credential store backends in order of preference
+func Stores() []StoreInfo {
+	return []StoreInfo{
+		{Name: StoreOnePassword, Description: "1Password, via the op CLI or a Connect server", Secure: true},
+		{Name: StoreSecretService, Description: "Secret Service keyring (GNOME Keyring, KWallet)", Secure: true},
+		{Name: StorePass, Description: "pass, the standard unix password manager", Secure: true},
+		{Name: StoreFile, Description: "age-encrypted file protected by a passphrase", Secure: true},
+		{Name: StoreEnv, Description: "environment variables (read-only)", Secure: false},
+		{Name: StorePlaintext, Description: "plaintext in the config file", Secure: false},
+	}
+}
+
+// StoreOptions carries the dependencies backends may need
+type StoreOptions struct {
+	Logger *zap.Logger
+	// Config is where the plaintext store keeps secrets. It is saved on every change.
+	Config *Config
+	// Profile selects the environment store's variables, defaults to the profile Config views
+	Profile string
+	// OnePasswordVault is the vault used for 1Password refs that don't name one
+	OnePasswordVault string
+	// FilePath is the encrypted file store location, defaults to secrets.age in the config directory
+	FilePath string
+	// Passphrase supplies the encrypted file store passphrase when LIMELIGHT_PASSPHRASE is unset.
+	// newFile is true when the passphrase will be used to create the file.
+	Passphrase func(newFile bool) (string, error)
+}
+
+// NewStore creates the credential store backend with the given name
+func NewStore(name string, opts StoreOptions) (Store, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	switch name {
+	case StoreOnePassword:
+		return NewOnePasswordStore(logger, OnePasswordConfig{Vault: opts.OnePasswordVault}), nil
+	case StoreSecretService:
+		return NewSecretServiceStore(logger), nil
+	case StorePass:
+		return NewPassStore(logger), nil
+	case StoreFile:
+		path := opts.FilePath
+		if path == "" {
+			var err error
+			path, err = DefaultSecretsFilePath()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return NewFileStore(path, opts.Passphrase), nil
+	case StoreEnv:
+		profile := opts.Profile
+		if profile == "" && opts.Config != nil {
+			profile = opts.Config.ProfileName
+		}
+		return NewEnvStore(profile), nil
+	case StorePlaintext:
+		if opts.Config == nil {
+			return nil, errors.New("plaintext credential store requires a config")
+		}
+		return NewPlaintextStore(opts.Config), nil
+	case "":
+		return nil, errors.New("no credential store configured, run 'limelight setup' first")
+	default:
+		return nil, errors.Newf("unknown credential store: %s", name)
+	}
+}
+
+// NewStoreFromConfig creates the credential store selected in config
+func NewStoreFromConfig(config *Config, opts StoreOptions) (Store, error) {
+	if opts.Config == nil {
+		opts.Config = config
+	}
+	if opts.Profile == "" {
+		opts.Profile = config.ProfileName
+	}
+	if opts.OnePasswordVault == "" {
+		opts.OnePasswordVault = config.OnePasswordVault
+	}
+	return NewStore(config.CredentialStoreName(), opts)
+}
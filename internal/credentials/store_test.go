@@ -0,0 +1,232 @@
+package credentials
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTestEnv(t *testing.T) {
+	t.Setenv(ConfigPathEnv, filepath.Join(t.TempDir(), "config.json"))
+}
+
+// fakePass is a stand-in for the pass CLI that keeps entries as plain files in FAKE_PASS_DIR
+const fakePass = `#!/bin/sh
+case "$1" in
+show)
+	[ -f "$FAKE_PASS_DIR/$2" ] || { echo "Error: $2 is not in the password store." >&2; exit 1; }
+	cat "$FAKE_PASS_DIR/$2" ;;
+insert)
+	mkdir -p "$(dirname "$FAKE_PASS_DIR/$4")"
+	cat > "$FAKE_PASS_DIR/$4" ;;
+rm)
+	[ -f "$FAKE_PASS_DIR/$3" ] || { echo "Error: $3 is not in the password store." >&2; exit 1; }
+	rm "$FAKE_PASS_DIR/$3" ;;
+esac
+`
+
+// testStoreRoundTrip checks the behaviour every writable store must share
+func testStoreRoundTrip(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	_, err := store.Get(ctx, "limelight-test", FieldAPIKey)
+	assert.True(t, errors.Is(err, ErrSecretNotFound), "expected ErrSecretNotFound, got %v", err)
+
+	require.NoError(t, store.Set(ctx, "limelight-test", FieldAPIKey, "first-key"))
+	require.NoError(t, store.Set(ctx, "limelight-test", "client_key", "client"))
+	require.NoError(t, store.Set(ctx, "limelight-test", FieldAPIKey, "second-key"))
+
+	value, err := store.Get(ctx, "limelight-test", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "second-key", value)
+
+	value, err = store.Get(ctx, "limelight-test", "client_key")
+	require.NoError(t, err)
+	assert.Equal(t, "client", value)
+
+	_, err = store.Get(ctx, "other-item", FieldAPIKey)
+	assert.True(t, errors.Is(err, ErrSecretNotFound), "expected ErrSecretNotFound, got %v", err)
+
+	require.NoError(t, store.Delete(ctx, "limelight-test", FieldAPIKey))
+	require.NoError(t, store.Delete(ctx, "limelight-test", FieldAPIKey))
+
+	_, err = store.Get(ctx, "limelight-test", FieldAPIKey)
+	assert.True(t, errors.Is(err, ErrSecretNotFound), "expected ErrSecretNotFound, got %v", err)
+
+	value, err = store.Get(ctx, "limelight-test", "client_key")
+	require.NoError(t, err)
+	assert.Equal(t, "client", value)
+}
+
+func TestPlaintextStore(t *testing.T) {
+	setupTestEnv(t)
+
+	config := &Config{BridgeIP: "192.168.1.100", CredentialStore: StorePlaintext}
+	testStoreRoundTrip(t, NewPlaintextStore(config))
+
+	loaded, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "client", loaded.Secrets["limelight-test"]["client_key"])
+	assert.Equal(t, "192.168.1.100", loaded.BridgeIP)
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.age")
+
+	prompts := 0
+	store := NewFileStore(path, func(newFile bool) (string, error) {
+		prompts++
+		assert.True(t, newFile)
+		return "correct horse", nil
+	})
+	store.workFactor = 10
+
+	testStoreRoundTrip(t, store)
+	assert.Equal(t, 1, prompts, "passphrase should be asked for once")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "client")
+
+	t.Run("reopen with env passphrase", func(t *testing.T) {
+		t.Setenv(PassphraseEnv, "correct horse")
+
+		value, err := NewFileStore(path, nil).Get(context.Background(), "limelight-test", "client_key")
+		require.NoError(t, err)
+		assert.Equal(t, "client", value)
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		t.Setenv(PassphraseEnv, "battery staple")
+
+		_, err := NewFileStore(path, nil).Get(context.Background(), "limelight-test", "client_key")
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrSecretNotFound))
+	})
+
+	t.Run("no passphrase", func(t *testing.T) {
+		_, err := NewFileStore(path, nil).Get(context.Background(), "limelight-test", "client_key")
+		assert.ErrorContains(t, err, PassphraseEnv)
+	})
+}
+
+func TestPassStore(t *testing.T) {
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "pass"), []byte(fakePass), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_PASS_DIR", t.TempDir())
+
+	store := NewPassStore(zap.NewNop())
+	assert.True(t, store.IsAvailable(context.Background()))
+
+	testStoreRoundTrip(t, store)
+
+	data, err := os.ReadFile(filepath.Join(os.Getenv("FAKE_PASS_DIR"), "limelight-test", "client_key"))
+	require.NoError(t, err)
+	assert.Equal(t, "client\n", string(data))
+}
+
+func TestEnvStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewEnvStore(DefaultProfile)
+
+	t.Setenv("LIMELIGHT_API_KEY", "")
+	_, err := store.Get(ctx, "ignored", FieldAPIKey)
+	assert.True(t, errors.Is(err, ErrSecretNotFound))
+
+	t.Setenv("LIMELIGHT_API_KEY", "from-env")
+	value, err := store.Get(ctx, "ignored", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", value)
+
+	assert.Error(t, store.Set(ctx, "ignored", FieldAPIKey, "value"))
+	assert.Error(t, store.Delete(ctx, "ignored", FieldAPIKey))
+}
+
+func TestEnvStoreProfile(t *testing.T) {
+	ctx := context.Background()
+	store := NewEnvStore("upstairs-2")
+	assert.Equal(t, "LIMELIGHT_UPSTAIRS_2_API_KEY", store.VarName(FieldAPIKey))
+
+	t.Setenv("LIMELIGHT_API_KEY", "")
+	t.Setenv("LIMELIGHT_UPSTAIRS_2_API_KEY", "")
+	_, err := store.Get(ctx, "ignored", FieldAPIKey)
+	assert.True(t, errors.Is(err, ErrSecretNotFound))
+
+	t.Setenv("LIMELIGHT_API_KEY", "shared")
+	value, err := store.Get(ctx, "ignored", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "shared", value, "falls back to the default profile's variable")
+
+	t.Setenv("LIMELIGHT_UPSTAIRS_2_API_KEY", "upstairs")
+	value, err = store.Get(ctx, "ignored", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "upstairs", value)
+
+	value, err = NewEnvStore(DefaultProfile).Get(ctx, "ignored", FieldAPIKey)
+	require.NoError(t, err)
+	assert.Equal(t, "shared", value)
+}
+
+func TestNewStore(t *testing.T) {
+	setupTestEnv(t)
+
+	for _, info := range Stores() {
+		store, err := NewStore(info.Name, StoreOptions{Config: &Config{}})
+		require.NoError(t, err, info.Name)
+		assert.Equal(t, info.Name, store.Name())
+	}
+
+	_, err := NewStore("keychain", StoreOptions{})
+	assert.ErrorContains(t, err, "unknown credential store")
+
+	_, err = NewStore("", StoreOptions{})
+	assert.ErrorContains(t, err, "no credential store configured")
+
+	_, err = NewStore(StorePlaintext, StoreOptions{})
+	assert.Error(t, err)
+}
+
+func TestConfigCredentialStore(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		expectedStore string
+		expectedRef   string
+	}{
+		{
+			name:          "unconfigured",
+			expectedStore: "",
+			expectedRef:   DefaultCredentialRef,
+		},
+		{
+			name:          "legacy 1password item",
+			config:        Config{OnePasswordItemName: "hue"},
+			expectedStore: StoreOnePassword,
+			expectedRef:   "hue",
+		},
+		{
+			name:          "explicit store",
+			config:        Config{CredentialStore: StorePass, CredentialRef: "home/hue"},
+			expectedStore: StorePass,
+			expectedRef:   "home/hue",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedStore, tt.config.CredentialStoreName())
+			assert.Equal(t, tt.expectedRef, tt.config.CredentialItem())
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mattn/go-sqlite3"
+	"github.com/mithilarun/limelight/internal/paths"
+)
+
+const (
+	// backupPagesPerStep is how many pages are copied while holding the read lock
+	backupPagesPerStep = 256
+	// backupBusyWait is how long to wait when another connection holds a lock
+	backupBusyWait = 50 * time.Millisecond
+
+	rotatingBackupPrefix = "limelight-"
+	rotatingBackupSuffix = ".db"
+	rotatingBackupLayout = "20060102-150405"
+)
+
+// Backup copies the database to dest with SQLite's online backup API, which
+// takes a consistent snapshot including changes still in the write-ahead log
+// while other connections keep using the database. The copy is written next
+// to dest and renamed into place, so dest is never left half-written.
+func Backup(ctx context.Context, db *sql.DB, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return errors.Wrap(err, "failed to create backup directory")
+	}
+
+	tmp := dest + ".tmp"
+	os.Remove(tmp)
+
+	destDB, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return errors.Wrap(err, "failed to create backup file")
+	}
+
+	err = copyDatabase(ctx, destDB, db)
+	if closeErr := destDB.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "failed to back up database to %s", dest)
+	}
+
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "failed to move backup into place")
+	}
+
+	return nil
+}
+
+// Restore replaces the contents of db with the backup at src. The backup is
+// checked first, and the current database is copied to <path>.pre-restore.bak
+// unless it is in memory. Run migrations afterwards to bring an older backup
+// up to date.
+func Restore(ctx context.Context, db *sql.DB, src string) (string, error) {
+	if _, err := os.Stat(src); err != nil {
+		return "", errors.Wrap(err, "failed to read backup")
+	}
+
+	srcDB, err := sql.Open("sqlite3", "file:"+src+"?mode=ro")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open backup")
+	}
+	defer srcDB.Close()
+
+	problems, err := Check(ctx, srcDB)
+	if err != nil {
+		return "", errors.Wrapf(err, "%s is not a usable database", src)
+	}
+	if len(problems) > 0 {
+		return "", errors.Newf("backup %s is damaged: %s", src, strings.Join(problems, "; "))
+	}
+
+	var count int
+	err = srcDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&count)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to inspect backup")
+	}
+	if count == 0 {
+		return "", errors.Newf("%s is not a limelight database", src)
+	}
+
+	safety, err := backupBeforeChange(db, "pre-restore")
+	if err != nil {
+		return "", err
+	}
+
+	if err := copyDatabase(ctx, db, srcDB); err != nil {
+		return safety, errors.Wrapf(err, "failed to restore database from %s", src)
+	}
+
+	return safety, nil
+}
+
+// Check runs SQLite's integrity and foreign key checks and returns the problems
+// found, or nothing for a healthy database
+func Check(ctx context.Context, db *sql.DB) ([]string, error) {
+	var problems []string
+
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run integrity check")
+	}
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			rows.Close()
+			return nil, errors.Wrap(err, "failed to scan integrity check")
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating integrity check")
+	}
+
+	rows, err = db.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to run foreign key check")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, parent string
+		var rowID sql.NullInt64
+		var fkID int
+		if err := rows.Scan(&table, &rowID, &parent, &fkID); err != nil {
+			return nil, errors.Wrap(err, "failed to scan foreign key check")
+		}
+		problems = append(problems, fmt.Sprintf("%s row %d references a missing %s row", table, rowID.Int64, parent))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating foreign key check")
+	}
+
+	return problems, nil
+}
+
+// DefaultBackupDir returns where rotating backups go unless configured otherwise,
+// the backups directory under the XDG state directory
+func DefaultBackupDir() (string, error) {
+	stateDir, err := paths.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "backups"), nil
+}
+
+// RotateBackup writes a timestamped backup to dir and deletes the oldest ones
+// so that at most keep remain. It returns the path of the new backup.
+func RotateBackup(ctx context.Context, db *sql.DB, dir string, keep int, now time.Time) (string, error) {
+	if keep < 1 {
+		return "", errors.Newf("must keep at least 1 backup, got %d", keep)
+	}
+
+	dest := filepath.Join(dir, rotatingBackupPrefix+now.UTC().Format(rotatingBackupLayout)+rotatingBackupSuffix)
+	if err := Backup(ctx, db, dest); err != nil {
+		return "", err
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return dest, err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return dest, errors.Wrap(err, "failed to remove old backup")
+		}
+		backups = backups[1:]
+	}
+
+	return dest, nil
+}
+
+// ListBackups returns the rotating backups in dir, oldest first
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read backup directory")
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, rotatingBackupPrefix) || !strings.HasSuffix(name, rotatingBackupSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, rotatingBackupPrefix), rotatingBackupSuffix)
+		if _, err := time.Parse(rotatingBackupLayout, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	// The timestamp layout sorts chronologically
+	sort.Strings(backups)
+
+	return backups, nil
+}
+
+// backupBeforeChange copies a file-backed database to <path>.<label>.bak,
+// replacing an earlier copy, and returns the backup path. In-memory databases
+// aren't backed up.
+func backupBeforeChange(db *sql.DB, label string) (string, error) {
+	path, err := mainDatabaseFile(db)
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	backup := fmt.Sprintf("%s.%s.bak", path, label)
+	if err := Backup(context.Background(), db, backup); err != nil {
+		return "", err
+	}
+
+	return backup, nil
+}
+
+// copyDatabase copies every page of src's main database into dest's
+func copyDatabase(ctx context.Context, dest, src *sql.DB) error {
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to destination")
+	}
+	defer destConn.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to source")
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			destSQLite, ok := destDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("destination is not a SQLite connection")
+			}
+			srcSQLite, ok := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("source is not a SQLite connection")
+			}
+
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return errors.Wrap(err, "failed to start backup")
+			}
+
+			remaining := -1
+			for {
+				done, err := backup.Step(backupPagesPerStep)
+				if err != nil {
+					backup.Close()
+					return errors.Wrap(err, "failed to copy pages")
+				}
+				if done {
+					break
+				}
+
+				// No progress means another connection holds a lock
+				var wait time.Duration
+				if backup.Remaining() == remaining {
+					wait = backupBusyWait
+				}
+				remaining = backup.Remaining()
+
+				select {
+				case <-ctx.Done():
+					backup.Close()
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+
+			return errors.Wrap(backup.Finish(), "failed to finish backup")
+		})
+	})
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMigratedDB(t *testing.T) *sql.DB {
+	db := setupTestDB(t)
+	require.NoError(t, RunMigrations(db))
+	return db
+}
+
+func countAutomations(t *testing.T, db *sql.DB) int {
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM automations").Scan(&count))
+	return count
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	db := setupMigratedDB(t)
+
+	_, err := db.Exec("INSERT INTO automations (name, description) VALUES ('evening', '')")
+	require.NoError(t, err)
+
+	dest := filepath.Join(t.TempDir(), "nested", "backup.db")
+	require.NoError(t, Backup(ctx, db, dest))
+
+	// The backup stands alone, without a write-ahead log next to it
+	for _, suffix := range []string{"-wal", "-shm", ".tmp"} {
+		_, err := os.Stat(dest + suffix)
+		assert.True(t, os.IsNotExist(err), suffix)
+	}
+
+	backup, err := Open(dest)
+	require.NoError(t, err)
+	assert.Equal(t, 1, countAutomations(t, backup))
+	backup.Close()
+
+	_, err = db.Exec("DELETE FROM automations")
+	require.NoError(t, err)
+
+	safety, err := Restore(ctx, db, dest)
+	require.NoError(t, err)
+	assert.FileExists(t, safety)
+	assert.Equal(t, 1, countAutomations(t, db))
+
+	saved, err := Open(safety)
+	require.NoError(t, err)
+	defer saved.Close()
+	assert.Equal(t, 0, countAutomations(t, saved), "the safety copy holds the database as it was")
+}
+
+func TestRestoreRejectsOtherFiles(t *testing.T) {
+	ctx := context.Background()
+	db := setupMigratedDB(t)
+	dir := t.TempDir()
+
+	_, err := Restore(ctx, db, filepath.Join(dir, "missing.db"))
+	assert.Error(t, err)
+
+	garbage := filepath.Join(dir, "garbage.db")
+	require.NoError(t, os.WriteFile(garbage, []byte("not a database at all, just some text"), 0600))
+	_, err = Restore(ctx, db, garbage)
+	assert.Error(t, err)
+
+	other, err := Open(filepath.Join(dir, "other.db"))
+	require.NoError(t, err)
+	_, err = other.Exec("CREATE TABLE notes (body TEXT)")
+	require.NoError(t, err)
+	other.Close()
+	_, err = Restore(ctx, db, filepath.Join(dir, "other.db"))
+	assert.ErrorContains(t, err, "not a limelight database")
+
+	tables := 0
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'notes'").Scan(&tables))
+	assert.Zero(t, tables)
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	db := setupMigratedDB(t)
+
+	problems, err := Check(ctx, db)
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+
+	_, err = db.Exec("PRAGMA foreign_keys = OFF")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO triggers (automation_id, type, config) VALUES (42, 'time', '{}')")
+	require.NoError(t, err)
+
+	problems, err = Check(ctx, db)
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0], "triggers row")
+	assert.Contains(t, problems[0], "automations")
+}
+
+func TestRotateBackup(t *testing.T) {
+	ctx := context.Background()
+	db := setupMigratedDB(t)
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600))
+
+	start := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	var latest string
+	for i := 0; i < 5; i++ {
+		var err error
+		latest, err = RotateBackup(ctx, db, dir, 3, start.Add(time.Duration(i)*24*time.Hour))
+		require.NoError(t, err)
+	}
+
+	backups, err := ListBackups(dir)
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+	assert.Equal(t, filepath.Join(dir, "limelight-20260103-030000.db"), backups[0])
+	assert.Equal(t, latest, backups[2])
+	assert.FileExists(t, filepath.Join(dir, "notes.txt"), "other files are left alone")
+
+	_, err = RotateBackup(ctx, db, dir, 0, start)
+	assert.Error(t, err)
+}
+
+func TestDefaultBackupDir(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+
+	dir, err := DefaultBackupDir()
+	require.NoError(t, err)
+	assert.Equal(t, "/xdg/state/limelight/backups", dir)
+}
@@ -4,20 +4,50 @@ import (
 	"database/sql"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/cockroachdb/errors"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/mithilarun/limelight/internal/paths"
 )
 
-// Open opens a connection to the SQLite database.
-// The database file is created in ~/.config/limelight/limelight.db
-func Open() (*sql.DB, error) {
-	dbPath, err := getDatabasePath()
+const (
+	// PathEnv overrides the database location, like the --db flag
+	PathEnv = "LIMELIGHT_DB"
+
+	databaseFile = "limelight.db"
+	// defaultParams are added to file paths; DSNs only get foreign keys turned on
+	defaultParams = "_foreign_keys=on&_journal_mode=WAL"
+)
+
+// pathOverride replaces the default database location, set from the --db flag
+var pathOverride string
+
+// SetPath makes Open("") use the given database path or DSN.
+// An empty path restores the default location.
+func SetPath(path string) {
+	pathOverride = path
+}
+
+// Open opens a connection to the SQLite database at pathOrDSN, which is either a
+// file path or a go-sqlite3 DSN such as "file:test.db?cache=shared" or ":memory:".
+// An empty pathOrDSN opens the --db or LIMELIGHT_DB database, else limelight.db in
+// the XDG data directory (~/.local/share/limelight).
+func Open(pathOrDSN string) (*sql.DB, error) {
+	if pathOrDSN == "" {
+		var err error
+		pathOrDSN, err = defaultDatabase()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get database path")
+		}
+	}
+
+	dsn, err := dataSourceName(pathOrDSN)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get database path")
+		return nil, err
 	}
 
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on&_journal_mode=WAL")
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to open database")
 	}
@@ -35,17 +65,82 @@ func Open() (*sql.DB, error) {
 	return db, nil
 }
 
-// getDatabasePath returns the path to the database file
-func getDatabasePath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+// DefaultPath returns the database location Open("") uses
+func DefaultPath() (string, error) {
+	if pathOverride != "" {
+		return pathOverride, nil
+	}
+	if path := os.Getenv(PathEnv); path != "" {
+		return path, nil
+	}
+
+	dataDir, err := paths.DataDir()
 	if err != nil {
-		return "", errors.Wrap(err, "failed to get home directory")
+		return "", err
 	}
+	return filepath.Join(dataDir, databaseFile), nil
+}
 
-	configDir := filepath.Join(homeDir, ".config", "limelight")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return "", errors.Wrap(err, "failed to create config directory")
+// defaultDatabase resolves the default database, moving one left in the config
+// directory by earlier versions to the data directory. If it can't be moved,
+// it is used where it is.
+func defaultDatabase() (string, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	if pathOverride != "" || os.Getenv(PathEnv) != "" {
+		return path, nil
 	}
 
-	return filepath.Join(configDir, "limelight.db"), nil
+	legacyDir, err := paths.LegacyDir()
+	if err != nil {
+		return "", err
+	}
+	legacyPath := filepath.Join(legacyDir, databaseFile)
+
+	if legacyPath == path || !fileExists(legacyPath) || fileExists(path) {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return legacyPath, nil
+	}
+	if err := os.Rename(legacyPath, path); err != nil {
+		return legacyPath, nil
+	}
+	// The write-ahead log holds committed changes not yet in the main file
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if fileExists(legacyPath + suffix) {
+			os.Rename(legacyPath+suffix, path+suffix)
+		}
+	}
+
+	return path, nil
+}
+
+// dataSourceName turns a path into a DSN with the default parameters, creating
+// its directory. DSNs are passed through with foreign keys turned on.
+func dataSourceName(pathOrDSN string) (string, error) {
+	if pathOrDSN == ":memory:" || strings.HasPrefix(pathOrDSN, "file:") || strings.Contains(pathOrDSN, "?") {
+		if strings.Contains(pathOrDSN, "_foreign_keys=") || strings.Contains(pathOrDSN, "_fk=") {
+			return pathOrDSN, nil
+		}
+		separator := "?"
+		if strings.Contains(pathOrDSN, "?") {
+			separator = "&"
+		}
+		return pathOrDSN + separator + "_foreign_keys=on", nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pathOrDSN), 0755); err != nil {
+		return "", errors.Wrap(err, "failed to create database directory")
+	}
+
+	return pathOrDSN + "?" + defaultParams, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
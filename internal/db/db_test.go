@@ -10,12 +10,9 @@ import (
 )
 
 func TestOpen(t *testing.T) {
-	tmpDir := t.TempDir()
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
+	dbPath := filepath.Join(t.TempDir(), "limelight.db")
 
-	db, err := Open()
+	db, err := Open(dbPath)
 	require.NoError(t, err)
 	require.NotNil(t, db)
 	defer db.Close()
@@ -23,25 +20,91 @@ func TestOpen(t *testing.T) {
 	err = db.Ping()
 	assert.NoError(t, err)
 
-	dbPath := filepath.Join(tmpDir, ".config", "limelight", "limelight.db")
 	_, err = os.Stat(dbPath)
 	assert.NoError(t, err)
+
+	var foreignKeys int
+	require.NoError(t, db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys))
+	assert.Equal(t, 1, foreignKeys)
 }
 
 func TestOpenCreatesDirectory(t *testing.T) {
-	tmpDir := t.TempDir()
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	defer os.Setenv("HOME", oldHome)
-
-	configDir := filepath.Join(tmpDir, ".config", "limelight")
-	_, err := os.Stat(configDir)
+	dataDir := filepath.Join(t.TempDir(), "nested", "limelight")
+	_, err := os.Stat(dataDir)
 	assert.True(t, os.IsNotExist(err))
 
-	db, err := Open()
+	db, err := Open(filepath.Join(dataDir, "limelight.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = os.Stat(dataDir)
+	assert.NoError(t, err)
+}
+
+func TestOpenDSN(t *testing.T) {
+	db, err := Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, RunMigrations(db))
+
+	var foreignKeys int
+	require.NoError(t, db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys))
+	assert.Equal(t, 1, foreignKeys)
+}
+
+func TestOpenDefaultPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	t.Setenv(PathEnv, "")
+
+	db, err := Open("")
+	require.NoError(t, err)
+	db.Close()
+
+	_, err = os.Stat(filepath.Join(home, "data", "limelight", "limelight.db"))
+	assert.NoError(t, err)
+
+	override := filepath.Join(home, "override.db")
+	t.Setenv(PathEnv, override)
+
+	path, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, override, path)
+
+	SetPath(filepath.Join(home, "flag.db"))
+	t.Cleanup(func() { SetPath("") })
+
+	path, err = DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "flag.db"), path)
+}
+
+func TestOpenMovesLegacyDatabase(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv(PathEnv, "")
+
+	legacyPath := filepath.Join(home, ".config", "limelight", "limelight.db")
+	legacy, err := Open(legacyPath)
+	require.NoError(t, err)
+	require.NoError(t, RunMigrations(legacy))
+	_, err = legacy.Exec("INSERT INTO config (key, value) VALUES ('moved', 'yes')")
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	db, err := Open("")
 	require.NoError(t, err)
 	defer db.Close()
 
-	_, err = os.Stat(configDir)
+	var value string
+	require.NoError(t, db.QueryRow("SELECT value FROM config WHERE key = 'moved'").Scan(&value))
+	assert.Equal(t, "yes", value)
+
+	_, err = os.Stat(filepath.Join(home, ".local", "share", "limelight", "limelight.db"))
 	assert.NoError(t, err)
+	_, err = os.Stat(legacyPath)
+	assert.True(t, os.IsNotExist(err))
 }
@@ -1,10 +1,13 @@
 package db
 
 import (
+	"crypto/sha256"
 	"database/sql"
 	"embed"
-	"fmt"
+	"encoding/hex"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 )
@@ -12,69 +15,358 @@ import (
 //go:embed migrations/*.sql
 var migrationFiles embed.FS
 
+const (
+	upSuffix   = ".up.sql"
+	downSuffix = ".down.sql"
+)
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration was edited afterwards
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	// ErrUnknownMigration is returned when the database has a migration this build doesn't know
+	ErrUnknownMigration = errors.New("unknown migration")
+)
+
+// Migration is a pair of up and down SQL scripts, embedded as
+// migrations/<version>.up.sql and migrations/<version>.down.sql
+type Migration struct {
+	Version string
+	Up      string
+	Down    string
+	// Checksum is the SHA-256 of the up script, recorded when it is applied
+	Checksum string
+}
+
+// MigrationStatus describes a migration and whether it has been applied
+type MigrationStatus struct {
+	Version   string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is set when the up script changed since it was applied
+	Modified bool
+	// Unknown is set for applied migrations this build doesn't have
+	Unknown bool
+}
+
+// MigrationReport lists what Migrate or Rollback did
+type MigrationReport struct {
+	Applied    []string
+	RolledBack []string
+	// Backup is the copy of the database taken beforehand, if any
+	Backup string
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	version   string
+	checksum  string
+	appliedAt time.Time
+}
+
 // RunMigrations applies all pending database migrations
 func RunMigrations(db *sql.DB) error {
-	if err := createMigrationsTable(db); err != nil {
-		return errors.Wrap(err, "failed to create migrations table")
+	_, err := Migrate(db, "")
+	return err
+}
+
+// Migrate applies pending migrations up to and including target, or all of them
+// if target is empty. A file-backed database that already has migrations applied
+// is backed up first.
+func Migrate(db *sql.DB, target string) (*MigrationReport, error) {
+	migrations, applied, err := loadMigrationState(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksums(migrations, applied); err != nil {
+		return nil, err
 	}
 
-	applied, err := getAppliedMigrations(db)
+	if target != "" && findMigration(migrations, target) == nil {
+		return nil, errors.Newf("no migration named %s", target)
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+		if m.Version == target {
+			break
+		}
+	}
+
+	report := &MigrationReport{}
+	if len(pending) == 0 {
+		return report, nil
+	}
+
+	if len(applied) > 0 {
+		report.Backup, err = backupBeforeChange(db, "pre-"+pending[0].Version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range pending {
+		if err := applyMigration(db, m); err != nil {
+			return report, errors.Wrapf(err, "failed to apply migration %s", m.Version)
+		}
+		report.Applied = append(report.Applied, m.Version)
+	}
+
+	return report, nil
+}
+
+// Rollback reverts the most recently applied migrations, steps of them,
+// after backing up a file-backed database
+func Rollback(db *sql.DB, steps int) (*MigrationReport, error) {
+	if steps < 1 {
+		return nil, errors.Newf("steps must be at least 1, got %d", steps)
+	}
+
+	migrations, applied, err := loadMigrationState(db)
 	if err != nil {
-		return errors.Wrap(err, "failed to get applied migrations")
+		return nil, err
+	}
+	if err := verifyChecksums(migrations, applied); err != nil {
+		return nil, err
 	}
 
-	entries, err := migrationFiles.ReadDir("migrations")
+	versions := make([]string, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+	if steps > len(versions) {
+		return nil, errors.Newf("only %d migrations are applied, can't roll back %d", len(versions), steps)
+	}
+	versions = versions[:steps]
+
+	var targets []*Migration
+	for _, version := range versions {
+		m := findMigration(migrations, version)
+		if m == nil {
+			return nil, errors.WithHint(
+				errors.Mark(errors.Newf("migration %s is not part of this build", version), ErrUnknownMigration),
+				"It was applied by a newer version of limelight, which can roll it back.",
+			)
+		}
+		targets = append(targets, m)
+	}
+
+	report := &MigrationReport{}
+	report.Backup, err = backupBeforeChange(db, "pre-rollback-"+versions[0])
 	if err != nil {
-		return errors.Wrap(err, "failed to read migrations directory")
+		return nil, err
 	}
 
-	var migrationNames []string
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			migrationNames = append(migrationNames, entry.Name())
+	for _, m := range targets {
+		if err := revertMigration(db, *m); err != nil {
+			return report, errors.Wrapf(err, "failed to roll back migration %s", m.Version)
+		}
+		report.RolledBack = append(report.RolledBack, m.Version)
+	}
+
+	return report, nil
+}
+
+// Status lists every known migration in order, followed by any applied
+// migrations this build doesn't know. It only reads the database: legacy
+// migration records are reported as adopted but left for Migrate to update.
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := readAppliedMigrations(db)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get applied migrations")
+	}
+	adoptLegacyRecords(migrations, applied)
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version}
+		if row, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = row.appliedAt
+			status.Modified = row.checksum != m.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+
+	var unknown []string
+	for version := range applied {
+		if findMigration(migrations, version) == nil {
+			unknown = append(unknown, version)
 		}
 	}
-	sort.Strings(migrationNames)
+	sort.Strings(unknown)
+	for _, version := range unknown {
+		statuses = append(statuses, MigrationStatus{
+			Version:   version,
+			Applied:   true,
+			AppliedAt: applied[version].appliedAt,
+			Unknown:   true,
+		})
+	}
 
-	for _, name := range migrationNames {
-		if applied[name] {
+	return statuses, nil
+}
+
+// Migrations returns the embedded migrations sorted by version
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read migrations directory")
+	}
+
+	byVersion := make(map[string]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
 			continue
 		}
+		name := entry.Name()
+
+		var version string
+		switch {
+		case strings.HasSuffix(name, upSuffix):
+			version = strings.TrimSuffix(name, upSuffix)
+		case strings.HasSuffix(name, downSuffix):
+			version = strings.TrimSuffix(name, downSuffix)
+		default:
+			return nil, errors.Newf("migration %s must end in %s or %s", name, upSuffix, downSuffix)
+		}
 
-		if err := applyMigration(db, name); err != nil {
-			return errors.Wrapf(err, "failed to apply migration %s", name)
+		content, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read migration %s", name)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+		if strings.HasSuffix(name, upSuffix) {
+			m.Up = string(content)
+			m.Checksum = checksum(content)
+		} else {
+			m.Down = string(content)
 		}
 	}
 
-	return nil
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, errors.Newf("migration %s needs both %s and %s files", m.Version, upSuffix, downSuffix)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// loadMigrationState returns the embedded migrations and the applied ones by version
+func loadMigrationState(db *sql.DB) ([]Migration, map[string]appliedMigration, error) {
+	if err := createMigrationsTable(db); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to create migrations table")
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied, err := getAppliedMigrations(db)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get applied migrations")
+	}
+
+	if err := adoptLegacyMigrations(db, migrations, applied); err != nil {
+		return nil, nil, err
+	}
+
+	return migrations, applied, nil
 }
 
-// createMigrationsTable creates the schema_migrations table if it doesn't exist
+// createMigrationsTable creates the schema_migrations table if it doesn't exist,
+// and adds the checksum column to tables created before it was recorded
 func createMigrationsTable(db *sql.DB) error {
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS schema_migrations (
 			version TEXT PRIMARY KEY,
-			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			checksum TEXT NOT NULL DEFAULT ''
 		)
 	`)
-	return errors.Wrap(err, "failed to create schema_migrations table")
+	if err != nil {
+		return errors.Wrap(err, "failed to create schema_migrations table")
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('schema_migrations') WHERE name = 'checksum'").Scan(&count)
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect schema_migrations table")
+	}
+	if count == 0 {
+		if _, err := db.Exec("ALTER TABLE schema_migrations ADD COLUMN checksum TEXT NOT NULL DEFAULT ''"); err != nil {
+			return errors.Wrap(err, "failed to add checksum column")
+		}
+	}
+
+	return nil
 }
 
-// getAppliedMigrations returns a set of already applied migration versions
-func getAppliedMigrations(db *sql.DB) (map[string]bool, error) {
-	rows, err := db.Query("SELECT version FROM schema_migrations")
+// getAppliedMigrations returns the applied migrations by version
+func getAppliedMigrations(db *sql.DB) (map[string]appliedMigration, error) {
+	return queryAppliedMigrations(db, "SELECT version, checksum, applied_at FROM schema_migrations")
+}
+
+// readAppliedMigrations returns the applied migrations by version without
+// creating or upgrading schema_migrations. A database without the table has
+// none applied, and rows from before checksums were recorded have none.
+func readAppliedMigrations(db *sql.DB) (map[string]appliedMigration, error) {
+	var tables int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&tables)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look for schema_migrations table")
+	}
+	if tables == 0 {
+		return make(map[string]appliedMigration), nil
+	}
+
+	var columns int
+	err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('schema_migrations') WHERE name = 'checksum'").Scan(&columns)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to inspect schema_migrations table")
+	}
+	if columns == 0 {
+		return queryAppliedMigrations(db, "SELECT version, '', applied_at FROM schema_migrations")
+	}
+	return getAppliedMigrations(db)
+}
+
+// queryAppliedMigrations runs a query returning version, checksum and applied_at
+// rows and collects them by version
+func queryAppliedMigrations(db *sql.DB, query string) (map[string]appliedMigration, error) {
+	rows, err := db.Query(query)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to query schema_migrations")
 	}
 	defer rows.Close()
 
-	applied := make(map[string]bool)
+	applied := make(map[string]appliedMigration)
 	for rows.Next() {
-		var version string
-		if err := rows.Scan(&version); err != nil {
+		var row appliedMigration
+		if err := rows.Scan(&row.version, &row.checksum, &row.appliedAt); err != nil {
 			return nil, errors.Wrap(err, "failed to scan migration version")
 		}
-		applied[version] = true
+		applied[row.version] = row
 	}
 
 	if err := rows.Err(); err != nil {
@@ -84,24 +376,78 @@ func getAppliedMigrations(db *sql.DB) (map[string]bool, error) {
 	return applied, nil
 }
 
-// applyMigration applies a single migration within a transaction
-func applyMigration(db *sql.DB, name string) error {
-	content, err := migrationFiles.ReadFile(fmt.Sprintf("migrations/%s", name))
-	if err != nil {
-		return errors.Wrap(err, "failed to read migration file")
+// adoptLegacyMigrations updates rows recorded before migrations had down scripts,
+// which used the file name as the version and had no checksum. Their checksum is
+// taken to be that of the current up script.
+func adoptLegacyMigrations(db *sql.DB, migrations []Migration, applied map[string]appliedMigration) error {
+	for oldVersion, row := range adoptLegacyRecords(migrations, applied) {
+		if _, err := db.Exec(
+			"UPDATE schema_migrations SET version = ?, checksum = ? WHERE version = ?",
+			row.version, row.checksum, oldVersion,
+		); err != nil {
+			return errors.Wrapf(err, "failed to update migration record %s", oldVersion)
+		}
+	}
+
+	return nil
+}
+
+// adoptLegacyRecords rewrites legacy rows in applied under their current version
+// and checksum, returning the rewritten rows by the version they were recorded as
+func adoptLegacyRecords(migrations []Migration, applied map[string]appliedMigration) map[string]appliedMigration {
+	adopted := make(map[string]appliedMigration)
+	for version, row := range applied {
+		newVersion := strings.TrimSuffix(version, ".sql")
+		if newVersion == version && row.checksum != "" {
+			continue
+		}
+
+		m := findMigration(migrations, newVersion)
+		if m == nil {
+			continue
+		}
+
+		row.version = newVersion
+		row.checksum = m.Checksum
+		adopted[version] = row
+	}
+
+	for version, row := range adopted {
+		delete(applied, version)
+		applied[row.version] = row
+	}
+
+	return adopted
+}
+
+// verifyChecksums fails if an applied migration's up script has changed since
+func verifyChecksums(migrations []Migration, applied map[string]appliedMigration) error {
+	for _, m := range migrations {
+		row, ok := applied[m.Version]
+		if !ok || row.checksum == m.Checksum {
+			continue
+		}
+		return errors.WithHint(
+			errors.Mark(errors.Newf("migration %s was changed after it was applied", m.Version), ErrChecksumMismatch),
+			"Restore the database from a backup taken before the upgrade, or reinstall the previous version of limelight.",
+		)
 	}
+	return nil
+}
 
+// applyMigration applies a single migration within a transaction
+func applyMigration(db *sql.DB, m Migration) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return errors.Wrap(err, "failed to begin transaction")
 	}
 	defer tx.Rollback()
 
-	if _, err := tx.Exec(string(content)); err != nil {
+	if _, err := tx.Exec(m.Up); err != nil {
 		return errors.Wrap(err, "failed to execute migration SQL")
 	}
 
-	if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", name); err != nil {
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, checksum) VALUES (?, ?)", m.Version, m.Checksum); err != nil {
 		return errors.Wrap(err, "failed to record migration")
 	}
 
@@ -111,3 +457,62 @@ func applyMigration(db *sql.DB, name string) error {
 
 	return nil
 }
+
+// revertMigration runs a migration's down script within a transaction
+func revertMigration(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.Down); err != nil {
+		return errors.Wrap(err, "failed to execute rollback SQL")
+	}
+
+	if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+		return errors.Wrap(err, "failed to remove migration record")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit rollback transaction")
+	}
+
+	return nil
+}
+
+// mainDatabaseFile returns the file behind the main database, or "" for an in-memory one
+func mainDatabaseFile(db *sql.DB) (string, error) {
+	rows, err := db.Query("PRAGMA database_list")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list databases")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return "", errors.Wrap(err, "failed to scan database list")
+		}
+		if name == "main" {
+			return file, nil
+		}
+	}
+
+	return "", errors.Wrap(rows.Err(), "error iterating database list")
+}
+
+func findMigration(migrations []Migration, version string) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,6 @@
+-- Drop the initial schema, children before the automations they reference
+DROP TABLE IF EXISTS actions;
+DROP TABLE IF EXISTS conditions;
+DROP TABLE IF EXISTS triggers;
+DROP TABLE IF EXISTS automations;
+DROP TABLE IF EXISTS config;
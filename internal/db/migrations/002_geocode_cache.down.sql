@@ -0,0 +1,2 @@
+-- Drop the geocode_cache table
+DROP TABLE IF EXISTS geocode_cache;
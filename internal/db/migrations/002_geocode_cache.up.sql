@@ -0,0 +1,8 @@
+-- Create geocode_cache table for past geocoding lookups
+CREATE TABLE geocode_cache (
+    kind TEXT NOT NULL,
+    query TEXT NOT NULL,
+    results TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (kind, query)
+);
@@ -0,0 +1,2 @@
+-- Drop the calendar_cache table
+DROP TABLE IF EXISTS calendar_cache;
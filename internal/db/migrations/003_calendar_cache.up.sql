@@ -0,0 +1,6 @@
+-- Create calendar_cache table for downloaded iCalendar feeds
+CREATE TABLE calendar_cache (
+    url TEXT PRIMARY KEY,
+    body TEXT NOT NULL,
+    fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- Drop the run history tables
+DROP TABLE IF EXISTS run_steps;
+DROP TABLE IF EXISTS runs;
@@ -2,22 +2,17 @@ package db
 
 import (
 	"database/sql"
-	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
-	tmpDir := t.TempDir()
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	t.Cleanup(func() {
-		os.Setenv("HOME", oldHome)
-	})
-
-	db, err := Open()
+	db, err := Open(filepath.Join(t.TempDir(), "limelight.db"))
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		db.Close()
@@ -35,12 +30,12 @@ func TestRunMigrations(t *testing.T) {
 	var count int
 	err = db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&count)
 	require.NoError(t, err)
-	assert.Equal(t, 1, count)
+	assert.Equal(t, 4, count)
 
 	var version string
-	err = db.QueryRow("SELECT version FROM schema_migrations").Scan(&version)
+	err = db.QueryRow("SELECT version FROM schema_migrations ORDER BY version LIMIT 1").Scan(&version)
 	require.NoError(t, err)
-	assert.Equal(t, "001_initial_schema.sql", version)
+	assert.Equal(t, "001_initial_schema", version)
 }
 
 func TestRunMigrationsIdempotent(t *testing.T) {
@@ -55,7 +50,7 @@ func TestRunMigrationsIdempotent(t *testing.T) {
 	var count int
 	err = db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&count)
 	require.NoError(t, err)
-	assert.Equal(t, 1, count)
+	assert.Equal(t, 4, count)
 }
 
 func TestMigrationsCreateTables(t *testing.T) {
@@ -64,7 +59,7 @@ func TestMigrationsCreateTables(t *testing.T) {
 	err := RunMigrations(db)
 	require.NoError(t, err)
 
-	tables := []string{"automations", "triggers", "conditions", "actions", "config"}
+	tables := []string{"automations", "triggers", "conditions", "actions", "config", "geocode_cache", "calendar_cache", "runs", "run_steps"}
 	for _, table := range tables {
 		var name string
 		err := db.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name=?", table).Scan(&name)
@@ -85,3 +80,186 @@ func TestMigrationsForeignKeys(t *testing.T) {
 	_, err = db.Exec("INSERT INTO triggers (automation_id, type, config) VALUES (?, ?, ?)", 999, "time", "{}")
 	assert.Error(t, err)
 }
+
+func TestMigrationsHaveDownScripts(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 4)
+
+	for _, m := range migrations {
+		assert.NotEmpty(t, m.Up, m.Version)
+		assert.NotEmpty(t, m.Down, m.Version)
+		assert.Len(t, m.Checksum, 64, m.Version)
+	}
+}
+
+func TestMigrateToTarget(t *testing.T) {
+	db := setupTestDB(t)
+
+	report, err := Migrate(db, "001_initial_schema")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"001_initial_schema"}, report.Applied)
+	assert.Empty(t, report.Backup, "a new database isn't backed up")
+
+	statuses, err := Status(db)
+	require.NoError(t, err)
+	require.Len(t, statuses, 4)
+	assert.True(t, statuses[0].Applied)
+	for _, status := range statuses[1:] {
+		assert.False(t, status.Applied, status.Version)
+	}
+
+	_, err = Migrate(db, "999_missing")
+	assert.Error(t, err)
+}
+
+func TestMigrateBacksUpExistingDatabase(t *testing.T) {
+	db := setupTestDB(t)
+
+	_, err := Migrate(db, "001_initial_schema")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO automations (name, description) VALUES ('kept', '')")
+	require.NoError(t, err)
+
+	report, err := Migrate(db, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"002_geocode_cache", "003_calendar_cache", "004_run_history"}, report.Applied)
+	require.NotEmpty(t, report.Backup)
+	assert.True(t, strings.HasSuffix(report.Backup, "limelight.db.pre-002_geocode_cache.bak"))
+
+	backup, err := Open(report.Backup)
+	require.NoError(t, err)
+	defer backup.Close()
+
+	var name string
+	require.NoError(t, backup.QueryRow("SELECT name FROM automations").Scan(&name))
+	assert.Equal(t, "kept", name)
+
+	var count int
+	require.NoError(t, backup.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'geocode_cache'").Scan(&count))
+	assert.Zero(t, count, "the backup is taken before migrating")
+}
+
+func TestRollback(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, RunMigrations(db))
+
+	report, err := Rollback(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"004_run_history"}, report.RolledBack)
+	assert.NotEmpty(t, report.Backup)
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'runs'").Scan(&count))
+	assert.Zero(t, count)
+
+	_, err = Rollback(db, 4)
+	assert.Error(t, err, "only three migrations are left")
+
+	report, err = Rollback(db, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"003_calendar_cache", "002_geocode_cache", "001_initial_schema"}, report.RolledBack)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'automations'").Scan(&count))
+	assert.Zero(t, count)
+
+	require.NoError(t, RunMigrations(db))
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&count))
+	assert.Equal(t, 4, count)
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, RunMigrations(db))
+
+	_, err := db.Exec("UPDATE schema_migrations SET checksum = 'edited' WHERE version = '001_initial_schema'")
+	require.NoError(t, err)
+
+	statuses, err := Status(db)
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Modified)
+
+	_, err = Migrate(db, "")
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+	_, err = Rollback(db, 1)
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+}
+
+func TestLegacyMigrationRecordsAreAdopted(t *testing.T) {
+	db := setupTestDB(t)
+
+	_, err := db.Exec(`CREATE TABLE schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	require.NoError(t, err)
+
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	_, err = db.Exec(migrations[0].Up)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO schema_migrations (version) VALUES ('001_initial_schema.sql')")
+	require.NoError(t, err)
+
+	report, err := Migrate(db, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"002_geocode_cache", "003_calendar_cache", "004_run_history"}, report.Applied)
+
+	statuses, err := Status(db)
+	require.NoError(t, err)
+	require.Len(t, statuses, 4)
+	for _, status := range statuses {
+		assert.True(t, status.Applied, status.Version)
+		assert.False(t, status.Modified, status.Version)
+		assert.False(t, status.Unknown, status.Version)
+	}
+}
+
+func TestStatusIsReadOnly(t *testing.T) {
+	db := setupTestDB(t)
+
+	statuses, err := Status(db)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.False(t, status.Applied, status.Version)
+	}
+	var tables int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_migrations'").Scan(&tables))
+	assert.Zero(t, tables, "status shouldn't create the migrations table")
+
+	_, err = db.Exec(`CREATE TABLE schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO schema_migrations (version) VALUES ('001_initial_schema.sql')")
+	require.NoError(t, err)
+
+	statuses, err = Status(db)
+	require.NoError(t, err)
+	assert.True(t, statuses[0].Applied, "legacy records are reported under their current version")
+	assert.False(t, statuses[0].Modified)
+	assert.False(t, statuses[1].Applied)
+
+	var version string
+	require.NoError(t, db.QueryRow("SELECT version FROM schema_migrations").Scan(&version))
+	assert.Equal(t, "001_initial_schema.sql", version, "status shouldn't adopt legacy records")
+	var columns int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('schema_migrations') WHERE name = 'checksum'").Scan(&columns))
+	assert.Zero(t, columns, "status shouldn't upgrade the migrations table")
+}
+
+func TestStatusReportsUnknownMigrations(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, RunMigrations(db))
+
+	_, err := db.Exec("INSERT INTO schema_migrations (version, checksum) VALUES ('900_from_the_future', 'x')")
+	require.NoError(t, err)
+
+	statuses, err := Status(db)
+	require.NoError(t, err)
+	require.Len(t, statuses, 5)
+	assert.True(t, statuses[4].Unknown)
+
+	_, err = Rollback(db, 1)
+	assert.True(t, errors.Is(err, ErrUnknownMigration))
+}
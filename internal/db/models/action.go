@@ -1,7 +1,7 @@
 package models
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 
 	"github.com/cockroachdb/errors"
@@ -14,6 +14,24 @@ const (
 	ActionTypeLight ActionType = "light"
 	ActionTypeScene ActionType = "scene"
 	ActionTypeGroup ActionType = "group"
+
+	// Effect actions run the bridge's built-in effects and signals
+	ActionTypeEffect      ActionType = "effect"
+	ActionTypeTimedEffect ActionType = "timed_effect"
+	ActionTypeAlert       ActionType = "alert"
+	ActionTypeIdentify    ActionType = "identify"
+
+	// Flow actions control when and how other actions run
+	ActionTypeDelay    ActionType = "delay"
+	ActionTypeWaitFor  ActionType = "wait_for"
+	ActionTypeRepeat   ActionType = "repeat"
+	ActionTypeParallel ActionType = "parallel"
+	ActionTypeStopIf   ActionType = "stop_if"
+
+	// External actions reach outside the bridge
+	ActionTypeWebhook ActionType = "webhook"
+	ActionTypeExec    ActionType = "exec"
+	ActionTypeNotify  ActionType = "notify"
 )
 
 // Action represents an action for an automation
@@ -25,8 +43,24 @@ type Action struct {
 	Config       json.RawMessage `json:"config"`
 }
 
+// ActionTarget selects the bridge a light, scene or group action applies to,
+// read from the "bridge" key of the action config
+type ActionTarget struct {
+	// Bridge is a profile name or bridge ID, empty for the selected profile
+	Bridge string `json:"bridge,omitempty"`
+}
+
+// Target returns the bridge the action applies to
+func (a *Action) Target() (*ActionTarget, error) {
+	var target ActionTarget
+	if err := json.Unmarshal(a.Config, &target); err != nil {
+		return nil, errors.Wrap(err, "failed to parse action target")
+	}
+	return &target, nil
+}
+
 // CreateAction creates a new action
-func CreateAction(db *sql.DB, automationID int64, actionType ActionType, config interface{}, orderIndex int) (*Action, error) {
+func CreateAction(ctx context.Context, db DBTX, automationID int64, actionType ActionType, config interface{}, orderIndex int) (*Action, error) {
 	if err := validateActionType(actionType); err != nil {
 		return nil, err
 	}
@@ -40,7 +74,7 @@ func CreateAction(db *sql.DB, automationID int64, actionType ActionType, config
 		return nil, errors.Wrap(err, "failed to marshal action config")
 	}
 
-	result, err := db.Exec(
+	result, err := db.ExecContext(ctx,
 		"INSERT INTO actions (automation_id, type, config, order_index) VALUES (?, ?, ?, ?)",
 		automationID, actionType, string(configJSON), orderIndex,
 	)
@@ -63,8 +97,8 @@ func CreateAction(db *sql.DB, automationID int64, actionType ActionType, config
 }
 
 // GetActions retrieves all actions for an automation, ordered by order_index
-func GetActions(db *sql.DB, automationID int64) ([]*Action, error) {
-	rows, err := db.Query(
+func GetActions(ctx context.Context, db DBTX, automationID int64) ([]*Action, error) {
+	rows, err := db.QueryContext(ctx,
 		"SELECT id, automation_id, order_index, type, config FROM actions WHERE automation_id = ? ORDER BY order_index",
 		automationID,
 	)
@@ -92,8 +126,8 @@ func GetActions(db *sql.DB, automationID int64) ([]*Action, error) {
 }
 
 // DeleteAction deletes an action
-func DeleteAction(db *sql.DB, id int64) error {
-	result, err := db.Exec("DELETE FROM actions WHERE id = ?", id)
+func DeleteAction(ctx context.Context, db DBTX, id int64) error {
+	result, err := db.ExecContext(ctx, "DELETE FROM actions WHERE id = ?", id)
 	if err != nil {
 		return errors.Wrap(err, "failed to delete action")
 	}
@@ -104,7 +138,7 @@ func DeleteAction(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.Newf("action with id %d not found", id)
+		return notFound("action", id)
 	}
 
 	return nil
@@ -113,7 +147,10 @@ func DeleteAction(db *sql.DB, id int64) error {
 // validateActionType validates that the action type is valid
 func validateActionType(t ActionType) error {
 	switch t {
-	case ActionTypeLight, ActionTypeScene, ActionTypeGroup:
+	case ActionTypeLight, ActionTypeScene, ActionTypeGroup,
+		ActionTypeEffect, ActionTypeTimedEffect, ActionTypeAlert, ActionTypeIdentify,
+		ActionTypeDelay, ActionTypeWaitFor, ActionTypeRepeat, ActionTypeParallel, ActionTypeStopIf,
+		ActionTypeWebhook, ActionTypeExec, ActionTypeNotify:
 		return nil
 	default:
 		return errors.Newf("invalid action type: %s", t)
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -10,15 +11,16 @@ import (
 
 func TestCreateAction(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
 	config := map[string]interface{}{
 		"scene_id": "abc123",
 	}
 
-	action, err := CreateAction(database, automation.ID, ActionTypeScene, config, 0)
+	action, err := CreateAction(ctx, database, automation.ID, ActionTypeScene, config, 0)
 	require.NoError(t, err)
 	require.NotNil(t, action)
 
@@ -35,27 +37,29 @@ func TestCreateAction(t *testing.T) {
 
 func TestCreateActionInvalidType(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	_, err = CreateAction(database, automation.ID, ActionType("invalid"), map[string]interface{}{}, 0)
+	_, err = CreateAction(ctx, database, automation.ID, ActionType("invalid"), map[string]interface{}{}, 0)
 	assert.Error(t, err)
 }
 
 func TestGetActions(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	_, err = CreateAction(database, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "first"}, 1)
+	_, err = CreateAction(ctx, database, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "first"}, 1)
 	require.NoError(t, err)
 
-	_, err = CreateAction(database, automation.ID, ActionTypeLight, map[string]interface{}{"light_id": "second"}, 0)
+	_, err = CreateAction(ctx, database, automation.ID, ActionTypeLight, map[string]interface{}{"light_id": "second"}, 0)
 	require.NoError(t, err)
 
-	actions, err := GetActions(database, automation.ID)
+	actions, err := GetActions(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, actions, 2)
 
@@ -68,46 +72,50 @@ func TestGetActions(t *testing.T) {
 
 func TestGetActionsEmpty(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	actions, err := GetActions(database, automation.ID)
+	actions, err := GetActions(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, actions, 0)
 }
 
 func TestDeleteAction(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	action, err := CreateAction(database, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "abc"}, 0)
+	action, err := CreateAction(ctx, database, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "abc"}, 0)
 	require.NoError(t, err)
 
-	err = DeleteAction(database, action.ID)
+	err = DeleteAction(ctx, database, action.ID)
 	require.NoError(t, err)
 
-	actions, err := GetActions(database, automation.ID)
+	actions, err := GetActions(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, actions, 0)
 }
 
 func TestDeleteActionNotFound(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	err := DeleteAction(database, 999)
+	err := DeleteAction(ctx, database, 999)
 	assert.Error(t, err)
 }
 
 func TestCreateActionNegativeOrderIndex(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	_, err = CreateAction(database, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "abc"}, -1)
+	_, err = CreateAction(ctx, database, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "abc"}, -1)
 	assert.Error(t, err)
 }
 
@@ -150,3 +158,28 @@ func TestValidateActionType(t *testing.T) {
 		})
 	}
 }
+
+func TestActionTarget(t *testing.T) {
+	database := setupTestDB(t)
+	ctx := context.Background()
+
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
+	require.NoError(t, err)
+
+	action, err := CreateAction(ctx, database, automation.ID, ActionTypeLight, map[string]interface{}{
+		"light_id": "abc123",
+		"bridge":   "office",
+	}, 0)
+	require.NoError(t, err)
+
+	target, err := action.Target()
+	require.NoError(t, err)
+	assert.Equal(t, "office", target.Bridge)
+
+	action, err = CreateAction(ctx, database, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "def456"}, 1)
+	require.NoError(t, err)
+
+	target, err = action.Target()
+	require.NoError(t, err)
+	assert.Empty(t, target.Bridge)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -18,12 +19,12 @@ type Automation struct {
 }
 
 // CreateAutomation creates a new automation
-func CreateAutomation(db *sql.DB, name, description string) (*Automation, error) {
+func CreateAutomation(ctx context.Context, db DBTX, name, description string) (*Automation, error) {
 	if name == "" {
 		return nil, errors.New("automation name cannot be empty")
 	}
 
-	result, err := db.Exec(
+	result, err := db.ExecContext(ctx,
 		"INSERT INTO automations (name, description) VALUES (?, ?)",
 		name, description,
 	)
@@ -36,19 +37,19 @@ func CreateAutomation(db *sql.DB, name, description string) (*Automation, error)
 		return nil, errors.Wrap(err, "failed to get last insert id")
 	}
 
-	return GetAutomation(db, id)
+	return GetAutomation(ctx, db, id)
 }
 
 // GetAutomation retrieves an automation by ID
-func GetAutomation(db *sql.DB, id int64) (*Automation, error) {
+func GetAutomation(ctx context.Context, db DBTX, id int64) (*Automation, error) {
 	var a Automation
-	err := db.QueryRow(
+	err := db.QueryRowContext(ctx,
 		"SELECT id, name, description, enabled, created_at, updated_at FROM automations WHERE id = ?",
 		id,
 	).Scan(&a.ID, &a.Name, &a.Description, &a.Enabled, &a.CreatedAt, &a.UpdatedAt)
 
-	if err == sql.ErrNoRows {
-		return nil, errors.Newf("automation with id %d not found", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notFound("automation", id)
 	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to query automation")
@@ -58,8 +59,8 @@ func GetAutomation(db *sql.DB, id int64) (*Automation, error) {
 }
 
 // ListAutomations retrieves all automations
-func ListAutomations(db *sql.DB) ([]*Automation, error) {
-	rows, err := db.Query(
+func ListAutomations(ctx context.Context, db DBTX) ([]*Automation, error) {
+	rows, err := db.QueryContext(ctx,
 		"SELECT id, name, description, enabled, created_at, updated_at FROM automations ORDER BY created_at DESC",
 	)
 	if err != nil {
@@ -84,8 +85,8 @@ func ListAutomations(db *sql.DB) ([]*Automation, error) {
 }
 
 // ListEnabledAutomations retrieves all enabled automations
-func ListEnabledAutomations(db *sql.DB) ([]*Automation, error) {
-	rows, err := db.Query(
+func ListEnabledAutomations(ctx context.Context, db DBTX) ([]*Automation, error) {
+	rows, err := db.QueryContext(ctx,
 		"SELECT id, name, description, enabled, created_at, updated_at FROM automations WHERE enabled = 1 ORDER BY created_at DESC",
 	)
 	if err != nil {
@@ -110,12 +111,12 @@ func ListEnabledAutomations(db *sql.DB) ([]*Automation, error) {
 }
 
 // UpdateAutomation updates an automation's name and description
-func UpdateAutomation(db *sql.DB, id int64, name, description string) error {
+func UpdateAutomation(ctx context.Context, db DBTX, id int64, name, description string) error {
 	if name == "" {
 		return errors.New("automation name cannot be empty")
 	}
 
-	result, err := db.Exec(
+	result, err := db.ExecContext(ctx,
 		"UPDATE automations SET name = ?, description = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
 		name, description, id,
 	)
@@ -129,15 +130,15 @@ func UpdateAutomation(db *sql.DB, id int64, name, description string) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.Newf("automation with id %d not found", id)
+		return notFound("automation", id)
 	}
 
 	return nil
 }
 
 // SetEnabled enables or disables an automation
-func SetEnabled(db *sql.DB, id int64, enabled bool) error {
-	result, err := db.Exec(
+func SetEnabled(ctx context.Context, db DBTX, id int64, enabled bool) error {
+	result, err := db.ExecContext(ctx,
 		"UPDATE automations SET enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
 		enabled, id,
 	)
@@ -151,7 +152,7 @@ func SetEnabled(db *sql.DB, id int64, enabled bool) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.Newf("automation with id %d not found", id)
+		return notFound("automation", id)
 	}
 
 	return nil
@@ -160,8 +161,8 @@ func SetEnabled(db *sql.DB, id int64, enabled bool) error {
 // DeleteAutomation deletes an automation and all associated triggers, conditions, and actions.
 // Due to CASCADE DELETE foreign key constraints, all related triggers, conditions,
 // and actions are automatically deleted when the automation is deleted.
-func DeleteAutomation(db *sql.DB, id int64) error {
-	result, err := db.Exec("DELETE FROM automations WHERE id = ?", id)
+func DeleteAutomation(ctx context.Context, db DBTX, id int64) error {
+	result, err := db.ExecContext(ctx, "DELETE FROM automations WHERE id = ?", id)
 	if err != nil {
 		return errors.Wrap(err, "failed to delete automation")
 	}
@@ -172,7 +173,7 @@ func DeleteAutomation(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.Newf("automation with id %d not found", id)
+		return notFound("automation", id)
 	}
 
 	return nil
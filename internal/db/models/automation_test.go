@@ -1,8 +1,9 @@
 package models
 
 import (
+	"context"
 	"database/sql"
-	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/mithilarun/limelight/internal/db"
@@ -11,14 +12,7 @@ import (
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
-	tmpDir := t.TempDir()
-	oldHome := os.Getenv("HOME")
-	os.Setenv("HOME", tmpDir)
-	t.Cleanup(func() {
-		os.Setenv("HOME", oldHome)
-	})
-
-	database, err := db.Open()
+	database, err := db.Open(filepath.Join(t.TempDir(), "limelight.db"))
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		database.Close()
@@ -32,8 +26,9 @@ func setupTestDB(t *testing.T) *sql.DB {
 
 func TestCreateAutomation(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Morning Lights", "Turn on lights in the morning")
+	automation, err := CreateAutomation(ctx, database, "Morning Lights", "Turn on lights in the morning")
 	require.NoError(t, err)
 	require.NotNil(t, automation)
 
@@ -47,28 +42,31 @@ func TestCreateAutomation(t *testing.T) {
 
 func TestCreateAutomationEmptyName(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	_, err := CreateAutomation(database, "", "No name")
+	_, err := CreateAutomation(ctx, database, "", "No name")
 	assert.Error(t, err)
 }
 
 func TestCreateAutomationDuplicateName(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	_, err := CreateAutomation(database, "Morning Lights", "First")
+	_, err := CreateAutomation(ctx, database, "Morning Lights", "First")
 	require.NoError(t, err)
 
-	_, err = CreateAutomation(database, "Morning Lights", "Second")
+	_, err = CreateAutomation(ctx, database, "Morning Lights", "Second")
 	assert.Error(t, err)
 }
 
 func TestGetAutomation(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	created, err := CreateAutomation(database, "Test Automation", "Test description")
+	created, err := CreateAutomation(ctx, database, "Test Automation", "Test description")
 	require.NoError(t, err)
 
-	retrieved, err := GetAutomation(database, created.ID)
+	retrieved, err := GetAutomation(ctx, database, created.ID)
 	require.NoError(t, err)
 	assert.Equal(t, created.ID, retrieved.ID)
 	assert.Equal(t, created.Name, retrieved.Name)
@@ -77,38 +75,41 @@ func TestGetAutomation(t *testing.T) {
 
 func TestGetAutomationNotFound(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	_, err := GetAutomation(database, 999)
+	_, err := GetAutomation(ctx, database, 999)
 	assert.Error(t, err)
 }
 
 func TestListAutomations(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	_, err := CreateAutomation(database, "First", "First automation")
+	_, err := CreateAutomation(ctx, database, "First", "First automation")
 	require.NoError(t, err)
 
-	_, err = CreateAutomation(database, "Second", "Second automation")
+	_, err = CreateAutomation(ctx, database, "Second", "Second automation")
 	require.NoError(t, err)
 
-	automations, err := ListAutomations(database)
+	automations, err := ListAutomations(ctx, database)
 	require.NoError(t, err)
 	assert.Len(t, automations, 2)
 }
 
 func TestListEnabledAutomations(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	first, err := CreateAutomation(database, "First", "First automation")
+	first, err := CreateAutomation(ctx, database, "First", "First automation")
 	require.NoError(t, err)
 
-	_, err = CreateAutomation(database, "Second", "Second automation")
+	_, err = CreateAutomation(ctx, database, "Second", "Second automation")
 	require.NoError(t, err)
 
-	err = SetEnabled(database, first.ID, false)
+	err = SetEnabled(ctx, database, first.ID, false)
 	require.NoError(t, err)
 
-	automations, err := ListEnabledAutomations(database)
+	automations, err := ListEnabledAutomations(ctx, database)
 	require.NoError(t, err)
 	assert.Len(t, automations, 1)
 	assert.Equal(t, "Second", automations[0].Name)
@@ -116,14 +117,15 @@ func TestListEnabledAutomations(t *testing.T) {
 
 func TestUpdateAutomation(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Original", "Original description")
+	automation, err := CreateAutomation(ctx, database, "Original", "Original description")
 	require.NoError(t, err)
 
-	err = UpdateAutomation(database, automation.ID, "Updated", "Updated description")
+	err = UpdateAutomation(ctx, database, automation.ID, "Updated", "Updated description")
 	require.NoError(t, err)
 
-	updated, err := GetAutomation(database, automation.ID)
+	updated, err := GetAutomation(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Equal(t, "Updated", updated.Name)
 	assert.Equal(t, "Updated description", updated.Description)
@@ -131,97 +133,104 @@ func TestUpdateAutomation(t *testing.T) {
 
 func TestUpdateAutomationEmptyName(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Original", "Original description")
+	automation, err := CreateAutomation(ctx, database, "Original", "Original description")
 	require.NoError(t, err)
 
-	err = UpdateAutomation(database, automation.ID, "", "Updated description")
+	err = UpdateAutomation(ctx, database, automation.ID, "", "Updated description")
 	assert.Error(t, err)
 }
 
 func TestUpdateAutomationNotFound(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	err := UpdateAutomation(database, 999, "Updated", "Updated description")
+	err := UpdateAutomation(ctx, database, 999, "Updated", "Updated description")
 	assert.Error(t, err)
 }
 
 func TestSetEnabled(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 	assert.True(t, automation.Enabled)
 
-	err = SetEnabled(database, automation.ID, false)
+	err = SetEnabled(ctx, database, automation.ID, false)
 	require.NoError(t, err)
 
-	updated, err := GetAutomation(database, automation.ID)
+	updated, err := GetAutomation(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.False(t, updated.Enabled)
 
-	err = SetEnabled(database, automation.ID, true)
+	err = SetEnabled(ctx, database, automation.ID, true)
 	require.NoError(t, err)
 
-	updated, err = GetAutomation(database, automation.ID)
+	updated, err = GetAutomation(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.True(t, updated.Enabled)
 }
 
 func TestSetEnabledNotFound(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	err := SetEnabled(database, 999, false)
+	err := SetEnabled(ctx, database, 999, false)
 	assert.Error(t, err)
 }
 
 func TestDeleteAutomation(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	err = DeleteAutomation(database, automation.ID)
+	err = DeleteAutomation(ctx, database, automation.ID)
 	require.NoError(t, err)
 
-	_, err = GetAutomation(database, automation.ID)
+	_, err = GetAutomation(ctx, database, automation.ID)
 	assert.Error(t, err)
 }
 
 func TestDeleteAutomationNotFound(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	err := DeleteAutomation(database, 999)
+	err := DeleteAutomation(ctx, database, 999)
 	assert.Error(t, err)
 }
 
 func TestDeleteAutomationCascade(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	_, err = CreateTrigger(database, automation.ID, TriggerTypeTime, map[string]interface{}{"hour": 9, "minute": 0})
+	_, err = CreateTrigger(ctx, database, automation.ID, TriggerTypeTime, map[string]interface{}{"hour": 9, "minute": 0})
 	require.NoError(t, err)
 
-	_, err = CreateCondition(database, automation.ID, ConditionTypeWeekday, map[string]interface{}{})
+	_, err = CreateCondition(ctx, database, automation.ID, ConditionTypeWeekday, map[string]interface{}{})
 	require.NoError(t, err)
 
-	_, err = CreateAction(database, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "abc"}, 0)
+	_, err = CreateAction(ctx, database, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "abc"}, 0)
 	require.NoError(t, err)
 
-	err = DeleteAutomation(database, automation.ID)
+	err = DeleteAutomation(ctx, database, automation.ID)
 	require.NoError(t, err)
 
-	triggers, err := GetTriggers(database, automation.ID)
+	triggers, err := GetTriggers(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, triggers, 0)
 
-	conditions, err := GetConditions(database, automation.ID)
+	conditions, err := GetConditions(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, conditions, 0)
 
-	actions, err := GetActions(database, automation.ID)
+	actions, err := GetActions(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, actions, 0)
 }
@@ -1,7 +1,7 @@
 package models
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 
 	"github.com/cockroachdb/errors"
@@ -15,6 +15,25 @@ const (
 	ConditionTypeWeekend   ConditionType = "weekend"
 	ConditionTypeDayOfWeek ConditionType = "day_of_week"
 	ConditionTypeDateRange ConditionType = "date_range"
+
+	ConditionTypeTimeWindow    ConditionType = "time_window"
+	ConditionTypeAfterSunset   ConditionType = "after_sunset"
+	ConditionTypeBeforeSunrise ConditionType = "before_sunrise"
+	ConditionTypeSunElevation  ConditionType = "sun_elevation"
+
+	// State conditions query the bridge when they are evaluated
+	ConditionTypeLightState  ConditionType = "light_state"
+	ConditionTypeRoomState   ConditionType = "room_state"
+	ConditionTypeSceneActive ConditionType = "scene_active"
+	ConditionTypeSensorLux   ConditionType = "sensor_lux"
+
+	// ConditionTypeCalendarEvent checks an iCalendar file or feed
+	ConditionTypeCalendarEvent ConditionType = "calendar_event"
+
+	// Groups combine the conditions nested in their config
+	ConditionTypeAll ConditionType = "all"
+	ConditionTypeAny ConditionType = "any"
+	ConditionTypeNot ConditionType = "not"
 )
 
 // Condition represents a condition for an automation
@@ -26,7 +45,7 @@ type Condition struct {
 }
 
 // CreateCondition creates a new condition
-func CreateCondition(db *sql.DB, automationID int64, conditionType ConditionType, config interface{}) (*Condition, error) {
+func CreateCondition(ctx context.Context, db DBTX, automationID int64, conditionType ConditionType, config interface{}) (*Condition, error) {
 	if err := validateConditionType(conditionType); err != nil {
 		return nil, err
 	}
@@ -36,7 +55,7 @@ func CreateCondition(db *sql.DB, automationID int64, conditionType ConditionType
 		return nil, errors.Wrap(err, "failed to marshal condition config")
 	}
 
-	result, err := db.Exec(
+	result, err := db.ExecContext(ctx,
 		"INSERT INTO conditions (automation_id, type, config) VALUES (?, ?, ?)",
 		automationID, conditionType, string(configJSON),
 	)
@@ -58,8 +77,8 @@ func CreateCondition(db *sql.DB, automationID int64, conditionType ConditionType
 }
 
 // GetConditions retrieves all conditions for an automation
-func GetConditions(db *sql.DB, automationID int64) ([]*Condition, error) {
-	rows, err := db.Query(
+func GetConditions(ctx context.Context, db DBTX, automationID int64) ([]*Condition, error) {
+	rows, err := db.QueryContext(ctx,
 		"SELECT id, automation_id, type, config FROM conditions WHERE automation_id = ?",
 		automationID,
 	)
@@ -87,8 +106,8 @@ func GetConditions(db *sql.DB, automationID int64) ([]*Condition, error) {
 }
 
 // DeleteCondition deletes a condition
-func DeleteCondition(db *sql.DB, id int64) error {
-	result, err := db.Exec("DELETE FROM conditions WHERE id = ?", id)
+func DeleteCondition(ctx context.Context, db DBTX, id int64) error {
+	result, err := db.ExecContext(ctx, "DELETE FROM conditions WHERE id = ?", id)
 	if err != nil {
 		return errors.Wrap(err, "failed to delete condition")
 	}
@@ -99,7 +118,7 @@ func DeleteCondition(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.Newf("condition with id %d not found", id)
+		return notFound("condition", id)
 	}
 
 	return nil
@@ -108,7 +127,11 @@ func DeleteCondition(db *sql.DB, id int64) error {
 // validateConditionType validates that the condition type is valid
 func validateConditionType(t ConditionType) error {
 	switch t {
-	case ConditionTypeWeekday, ConditionTypeWeekend, ConditionTypeDayOfWeek, ConditionTypeDateRange:
+	case ConditionTypeWeekday, ConditionTypeWeekend, ConditionTypeDayOfWeek, ConditionTypeDateRange,
+		ConditionTypeTimeWindow, ConditionTypeAfterSunset, ConditionTypeBeforeSunrise, ConditionTypeSunElevation,
+		ConditionTypeLightState, ConditionTypeRoomState, ConditionTypeSceneActive, ConditionTypeSensorLux,
+		ConditionTypeCalendarEvent,
+		ConditionTypeAll, ConditionTypeAny, ConditionTypeNot:
 		return nil
 	default:
 		return errors.Newf("invalid condition type: %s", t)
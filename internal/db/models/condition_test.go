@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -10,15 +11,16 @@ import (
 
 func TestCreateCondition(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
 	config := map[string]interface{}{
 		"days": []int{1, 2, 3, 4, 5},
 	}
 
-	condition, err := CreateCondition(database, automation.ID, ConditionTypeDayOfWeek, config)
+	condition, err := CreateCondition(ctx, database, automation.ID, ConditionTypeDayOfWeek, config)
 	require.NoError(t, err)
 	require.NotNil(t, condition)
 
@@ -34,63 +36,68 @@ func TestCreateCondition(t *testing.T) {
 
 func TestCreateConditionInvalidType(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	_, err = CreateCondition(database, automation.ID, ConditionType("invalid"), map[string]interface{}{})
+	_, err = CreateCondition(ctx, database, automation.ID, ConditionType("invalid"), map[string]interface{}{})
 	assert.Error(t, err)
 }
 
 func TestGetConditions(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	_, err = CreateCondition(database, automation.ID, ConditionTypeWeekday, map[string]interface{}{})
+	_, err = CreateCondition(ctx, database, automation.ID, ConditionTypeWeekday, map[string]interface{}{})
 	require.NoError(t, err)
 
-	_, err = CreateCondition(database, automation.ID, ConditionTypeWeekend, map[string]interface{}{})
+	_, err = CreateCondition(ctx, database, automation.ID, ConditionTypeWeekend, map[string]interface{}{})
 	require.NoError(t, err)
 
-	conditions, err := GetConditions(database, automation.ID)
+	conditions, err := GetConditions(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, conditions, 2)
 }
 
 func TestGetConditionsEmpty(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	conditions, err := GetConditions(database, automation.ID)
+	conditions, err := GetConditions(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, conditions, 0)
 }
 
 func TestDeleteCondition(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	condition, err := CreateCondition(database, automation.ID, ConditionTypeWeekday, map[string]interface{}{})
+	condition, err := CreateCondition(ctx, database, automation.ID, ConditionTypeWeekday, map[string]interface{}{})
 	require.NoError(t, err)
 
-	err = DeleteCondition(database, condition.ID)
+	err = DeleteCondition(ctx, database, condition.ID)
 	require.NoError(t, err)
 
-	conditions, err := GetConditions(database, automation.ID)
+	conditions, err := GetConditions(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, conditions, 0)
 }
 
 func TestDeleteConditionNotFound(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	err := DeleteCondition(database, 999)
+	err := DeleteCondition(ctx, database, 999)
 	assert.Error(t, err)
 }
 
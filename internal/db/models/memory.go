@@ -0,0 +1,650 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// MemoryStore is a Store that keeps everything in memory, for engine tests.
+// It applies the same validation, ordering and cascading deletes as SQLStore.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data *memoryData
+	now  func() time.Time
+	// inTx is set on the store passed to a WithTx callback
+	inTx bool
+}
+
+type memoryData struct {
+	nextID      int64
+	automations map[int64]Automation
+	triggers    map[int64]Trigger
+	conditions  map[int64]Condition
+	actions     map[int64]Action
+	runs        map[int64]Run
+	runSteps    map[int64]RunStep
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: &memoryData{
+			automations: make(map[int64]Automation),
+			triggers:    make(map[int64]Trigger),
+			conditions:  make(map[int64]Condition),
+			actions:     make(map[int64]Action),
+			runs:        make(map[int64]Run),
+			runSteps:    make(map[int64]RunStep),
+		},
+		now: time.Now,
+	}
+}
+
+// clone returns a deep copy, used as the working set of a transaction
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		nextID:      d.nextID,
+		automations: make(map[int64]Automation, len(d.automations)),
+		triggers:    make(map[int64]Trigger, len(d.triggers)),
+		conditions:  make(map[int64]Condition, len(d.conditions)),
+		actions:     make(map[int64]Action, len(d.actions)),
+		runs:        make(map[int64]Run, len(d.runs)),
+		runSteps:    make(map[int64]RunStep, len(d.runSteps)),
+	}
+	for id, a := range d.automations {
+		c.automations[id] = a
+	}
+	for id, t := range d.triggers {
+		c.triggers[id] = t.clone()
+	}
+	for id, cond := range d.conditions {
+		c.conditions[id] = cond.clone()
+	}
+	for id, a := range d.actions {
+		c.actions[id] = a.clone()
+	}
+	for id, r := range d.runs {
+		c.runs[id] = r.clone()
+	}
+	for id, step := range d.runSteps {
+		c.runSteps[id] = step
+	}
+	return c
+}
+
+// cloneConfig copies config so stored and returned records don't share a backing array
+func cloneConfig(config json.RawMessage) json.RawMessage {
+	if config == nil {
+		return nil
+	}
+	return append(json.RawMessage(nil), config...)
+}
+
+func (t Trigger) clone() Trigger {
+	t.Config = cloneConfig(t.Config)
+	return t
+}
+
+func (c Condition) clone() Condition {
+	c.Config = cloneConfig(c.Config)
+	return c
+}
+
+func (a Action) clone() Action {
+	a.Config = cloneConfig(a.Config)
+	return a
+}
+
+func (r Run) clone() Run {
+	if r.FinishedAt != nil {
+		finishedAt := *r.FinishedAt
+		r.FinishedAt = &finishedAt
+	}
+	return r
+}
+
+func (d *memoryData) newID() int64 {
+	d.nextID++
+	return d.nextID
+}
+
+// timestamp matches the second precision of SQLite's CURRENT_TIMESTAMP
+func (s *MemoryStore) timestamp() time.Time {
+	return s.now().UTC().Truncate(time.Second)
+}
+
+func (s *MemoryStore) CreateAutomation(ctx context.Context, name, description string) (*Automation, error) {
+	if name == "" {
+		return nil, errors.New("automation name cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkNameUnique(0, name); err != nil {
+		return nil, errors.Wrap(err, "failed to insert automation")
+	}
+
+	now := s.timestamp()
+	a := Automation{
+		ID:          s.data.newID(),
+		Name:        name,
+		Description: description,
+		Enabled:     true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.data.automations[a.ID] = a
+
+	return &a, nil
+}
+
+// checkNameUnique mirrors the UNIQUE constraint on automation names
+func (s *MemoryStore) checkNameUnique(id int64, name string) error {
+	for _, a := range s.data.automations {
+		if a.Name == name && a.ID != id {
+			return errors.Newf("automation named %s already exists", name)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetAutomation(ctx context.Context, id int64) (*Automation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.data.automations[id]
+	if !ok {
+		return nil, notFound("automation", id)
+	}
+	return &a, nil
+}
+
+func (s *MemoryStore) ListAutomations(ctx context.Context) ([]*Automation, error) {
+	return s.listAutomations(func(Automation) bool { return true }), nil
+}
+
+func (s *MemoryStore) ListEnabledAutomations(ctx context.Context) ([]*Automation, error) {
+	return s.listAutomations(func(a Automation) bool { return a.Enabled }), nil
+}
+
+// listAutomations returns matching automations, newest first
+func (s *MemoryStore) listAutomations(match func(Automation) bool) []*Automation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var automations []*Automation
+	for _, a := range s.data.automations {
+		if match(a) {
+			a := a
+			automations = append(automations, &a)
+		}
+	}
+
+	sort.Slice(automations, func(i, j int) bool {
+		if !automations[i].CreatedAt.Equal(automations[j].CreatedAt) {
+			return automations[i].CreatedAt.After(automations[j].CreatedAt)
+		}
+		return automations[i].ID > automations[j].ID
+	})
+
+	return automations
+}
+
+func (s *MemoryStore) UpdateAutomation(ctx context.Context, id int64, name, description string) error {
+	if name == "" {
+		return errors.New("automation name cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.data.automations[id]
+	if !ok {
+		return notFound("automation", id)
+	}
+	if err := s.checkNameUnique(id, name); err != nil {
+		return errors.Wrap(err, "failed to update automation")
+	}
+	a.Name = name
+	a.Description = description
+	a.UpdatedAt = s.timestamp()
+	s.data.automations[id] = a
+
+	return nil
+}
+
+func (s *MemoryStore) SetEnabled(ctx context.Context, id int64, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.data.automations[id]
+	if !ok {
+		return notFound("automation", id)
+	}
+	a.Enabled = enabled
+	a.UpdatedAt = s.timestamp()
+	s.data.automations[id] = a
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteAutomation(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.automations[id]; !ok {
+		return notFound("automation", id)
+	}
+	delete(s.data.automations, id)
+
+	for childID, t := range s.data.triggers {
+		if t.AutomationID == id {
+			delete(s.data.triggers, childID)
+		}
+	}
+	for childID, c := range s.data.conditions {
+		if c.AutomationID == id {
+			delete(s.data.conditions, childID)
+		}
+	}
+	for childID, a := range s.data.actions {
+		if a.AutomationID == id {
+			delete(s.data.actions, childID)
+		}
+	}
+	for runID, r := range s.data.runs {
+		if r.AutomationID != id {
+			continue
+		}
+		delete(s.data.runs, runID)
+		for stepID, step := range s.data.runSteps {
+			if step.RunID == runID {
+				delete(s.data.runSteps, stepID)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) CreateTrigger(ctx context.Context, automationID int64, triggerType TriggerType, config interface{}) (*Trigger, error) {
+	if err := validateTriggerType(triggerType); err != nil {
+		return nil, err
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal trigger config")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.automations[automationID]; !ok {
+		return nil, errors.Wrap(notFound("automation", automationID), "failed to insert trigger")
+	}
+
+	t := Trigger{
+		ID:           s.data.newID(),
+		AutomationID: automationID,
+		Type:         triggerType,
+		Config:       configJSON,
+	}
+	s.data.triggers[t.ID] = t.clone()
+
+	return &t, nil
+}
+
+func (s *MemoryStore) GetTriggers(ctx context.Context, automationID int64) ([]*Trigger, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var triggers []*Trigger
+	for _, t := range s.data.triggers {
+		if t.AutomationID == automationID {
+			t := t.clone()
+			triggers = append(triggers, &t)
+		}
+	}
+	sort.Slice(triggers, func(i, j int) bool { return triggers[i].ID < triggers[j].ID })
+
+	return triggers, nil
+}
+
+func (s *MemoryStore) DeleteTrigger(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.triggers[id]; !ok {
+		return notFound("trigger", id)
+	}
+	delete(s.data.triggers, id)
+	return nil
+}
+
+func (s *MemoryStore) CreateCondition(ctx context.Context, automationID int64, conditionType ConditionType, config interface{}) (*Condition, error) {
+	if err := validateConditionType(conditionType); err != nil {
+		return nil, err
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal condition config")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.automations[automationID]; !ok {
+		return nil, errors.Wrap(notFound("automation", automationID), "failed to insert condition")
+	}
+
+	c := Condition{
+		ID:           s.data.newID(),
+		AutomationID: automationID,
+		Type:         conditionType,
+		Config:       configJSON,
+	}
+	s.data.conditions[c.ID] = c.clone()
+
+	return &c, nil
+}
+
+func (s *MemoryStore) GetConditions(ctx context.Context, automationID int64) ([]*Condition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var conditions []*Condition
+	for _, c := range s.data.conditions {
+		if c.AutomationID == automationID {
+			c := c.clone()
+			conditions = append(conditions, &c)
+		}
+	}
+	sort.Slice(conditions, func(i, j int) bool { return conditions[i].ID < conditions[j].ID })
+
+	return conditions, nil
+}
+
+func (s *MemoryStore) DeleteCondition(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.conditions[id]; !ok {
+		return notFound("condition", id)
+	}
+	delete(s.data.conditions, id)
+	return nil
+}
+
+func (s *MemoryStore) CreateAction(ctx context.Context, automationID int64, actionType ActionType, config interface{}, orderIndex int) (*Action, error) {
+	if err := validateActionType(actionType); err != nil {
+		return nil, err
+	}
+
+	if orderIndex < 0 {
+		return nil, errors.Newf("order_index must be non-negative, got %d", orderIndex)
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal action config")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.automations[automationID]; !ok {
+		return nil, errors.Wrap(notFound("automation", automationID), "failed to insert action")
+	}
+
+	a := Action{
+		ID:           s.data.newID(),
+		AutomationID: automationID,
+		OrderIndex:   orderIndex,
+		Type:         actionType,
+		Config:       configJSON,
+	}
+	s.data.actions[a.ID] = a.clone()
+
+	return &a, nil
+}
+
+func (s *MemoryStore) GetActions(ctx context.Context, automationID int64) ([]*Action, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var actions []*Action
+	for _, a := range s.data.actions {
+		if a.AutomationID == automationID {
+			a := a.clone()
+			actions = append(actions, &a)
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].OrderIndex != actions[j].OrderIndex {
+			return actions[i].OrderIndex < actions[j].OrderIndex
+		}
+		return actions[i].ID < actions[j].ID
+	})
+
+	return actions, nil
+}
+
+func (s *MemoryStore) DeleteAction(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.actions[id]; !ok {
+		return notFound("action", id)
+	}
+	delete(s.data.actions, id)
+	return nil
+}
+
+func (s *MemoryStore) CreateRun(ctx context.Context, automationID int64, startedAt time.Time) (*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.automations[automationID]; !ok {
+		return nil, errors.Wrap(notFound("automation", automationID), "failed to insert run")
+	}
+
+	r := Run{
+		ID:           s.data.newID(),
+		AutomationID: automationID,
+		Status:       RunStatusRunning,
+		StartedAt:    startedAt.UTC(),
+	}
+	s.data.runs[r.ID] = r
+
+	return &r, nil
+}
+
+func (s *MemoryStore) FinishRun(ctx context.Context, id int64, status RunStatus, runErr string, finishedAt time.Time) error {
+	if err := validateRunStatus(status); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.data.runs[id]
+	if !ok {
+		return notFound("run", id)
+	}
+	finishedAt = finishedAt.UTC()
+	r.Status = status
+	r.Error = runErr
+	r.FinishedAt = &finishedAt
+	s.data.runs[id] = r
+
+	return nil
+}
+
+func (s *MemoryStore) ListRuns(ctx context.Context, automationID int64, limit int) ([]*Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var runs []*Run
+	for _, r := range s.data.runs {
+		if automationID == 0 || r.AutomationID == automationID {
+			r := r.clone()
+			runs = append(runs, &r)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].StartedAt.After(runs[j].StartedAt)
+		}
+		return runs[i].ID > runs[j].ID
+	})
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+
+	return runs, nil
+}
+
+func (s *MemoryStore) AddRunStep(ctx context.Context, step *RunStep) error {
+	if err := validateRunStatus(step.Status); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.runs[step.RunID]; !ok {
+		return errors.Wrap(notFound("run", step.RunID), "failed to insert run step")
+	}
+
+	step.ID = s.data.newID()
+	stored := *step
+	stored.StartedAt = stored.StartedAt.UTC()
+	stored.FinishedAt = stored.FinishedAt.UTC()
+	s.data.runSteps[step.ID] = stored
+
+	return nil
+}
+
+func (s *MemoryStore) GetRunSteps(ctx context.Context, runID int64) ([]*RunStep, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var steps []*RunStep
+	for _, step := range s.data.runSteps {
+		if step.RunID == runID {
+			step := step
+			steps = append(steps, &step)
+		}
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].ID < steps[j].ID })
+
+	return steps, nil
+}
+
+// LoadAutomationSpec reads the automation and its children from one snapshot
+func (s *MemoryStore) LoadAutomationSpec(ctx context.Context, id int64) (*AutomationSpec, error) {
+	var spec *AutomationSpec
+	err := s.WithTx(ctx, func(tx Store) error {
+		automation, err := tx.GetAutomation(ctx, id)
+		if err != nil {
+			return err
+		}
+		spec, err = loadChildren(ctx, tx, automation)
+		return err
+	})
+	return spec, err
+}
+
+func (s *MemoryStore) LoadAllEnabledSpecs(ctx context.Context) ([]*AutomationSpec, error) {
+	var specs []*AutomationSpec
+	err := s.WithTx(ctx, func(tx Store) error {
+		automations, err := tx.ListEnabledAutomations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, automation := range automations {
+			spec, err := loadChildren(ctx, tx, automation)
+			if err != nil {
+				return err
+			}
+			specs = append(specs, spec)
+		}
+		return nil
+	})
+	return specs, err
+}
+
+func (s *MemoryStore) SaveAutomationSpec(ctx context.Context, spec *AutomationSpec) error {
+	var saved *AutomationSpec
+	err := s.WithTx(ctx, func(tx Store) error {
+		var err error
+		saved, err = saveAutomationSpec(ctx, tx, spec)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	*spec = *saved
+	return nil
+}
+
+// loadChildren builds a spec from an automation and its children
+func loadChildren(ctx context.Context, store Store, automation *Automation) (*AutomationSpec, error) {
+	spec := &AutomationSpec{Automation: *automation}
+
+	var err error
+	if spec.Triggers, err = store.GetTriggers(ctx, automation.ID); err != nil {
+		return nil, err
+	}
+	if spec.Conditions, err = store.GetConditions(ctx, automation.ID); err != nil {
+		return nil, err
+	}
+	if spec.Actions, err = store.GetActions(ctx, automation.ID); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// WithTx runs fn against a copy of the data, which replaces the store's data if
+// fn succeeds. Other callers wait until the transaction finishes, as with SQLite's
+// single writer.
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	tx := &MemoryStore{
+		data: s.data.clone(),
+		now:  s.now,
+		inTx: true,
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+
+	s.data = tx.data
+	return nil
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// RunStatus is how a run, or one step of it, ended
+type RunStatus string
+
+const (
+	// RunStatusRunning marks a run that hasn't finished, or whose process exited before it did
+	RunStatusRunning   RunStatus = "running"
+	RunStatusSucceeded RunStatus = "succeeded"
+	// RunStatusStopped marks a run ended early by a stop_if or wait_for action
+	RunStatusStopped RunStatus = "stopped"
+	RunStatusFailed  RunStatus = "failed"
+)
+
+// Run records one time an automation's actions ran
+type Run struct {
+	ID           int64      `json:"id"`
+	AutomationID int64      `json:"automation_id"`
+	Status       RunStatus  `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// RunStep records one action a run performed, such as a light change or a webhook
+type RunStep struct {
+	ID         int64      `json:"id"`
+	RunID      int64      `json:"run_id"`
+	ActionType ActionType `json:"action_type"`
+	Status     RunStatus  `json:"status"`
+	// Detail describes what the action did, such as the response to a webhook
+	Detail     string    `json:"detail,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// CreateRun records the start of a run of an automation's actions
+func CreateRun(ctx context.Context, db DBTX, automationID int64, startedAt time.Time) (*Run, error) {
+	startedAt = startedAt.UTC()
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO runs (automation_id, status, started_at) VALUES (?, ?, ?)",
+		automationID, RunStatusRunning, startedAt,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to insert run")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get last insert id")
+	}
+
+	return &Run{
+		ID:           id,
+		AutomationID: automationID,
+		Status:       RunStatusRunning,
+		StartedAt:    startedAt,
+	}, nil
+}
+
+// FinishRun records how a run ended
+func FinishRun(ctx context.Context, db DBTX, id int64, status RunStatus, runErr string, finishedAt time.Time) error {
+	if err := validateRunStatus(status); err != nil {
+		return err
+	}
+
+	result, err := db.ExecContext(ctx,
+		"UPDATE runs SET status = ?, error = ?, finished_at = ? WHERE id = ?",
+		status, runErr, finishedAt.UTC(), id,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to update run")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to get rows affected")
+	}
+
+	if rowsAffected == 0 {
+		return notFound("run", id)
+	}
+
+	return nil
+}
+
+// ListRuns retrieves an automation's most recent runs, newest first, or every
+// automation's if automationID is 0. A limit of 0 returns them all.
+func ListRuns(ctx context.Context, db DBTX, automationID int64, limit int) ([]*Run, error) {
+	query := "SELECT id, automation_id, status, error, started_at, finished_at FROM runs"
+	var args []interface{}
+	if automationID != 0 {
+		query += " WHERE automation_id = ?"
+		args = append(args, automationID)
+	}
+	query += " ORDER BY started_at DESC, id DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query runs")
+	}
+	defer rows.Close()
+
+	var runs []*Run
+	for rows.Next() {
+		var r Run
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.AutomationID, &r.Status, &r.Error, &r.StartedAt, &finishedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan run")
+		}
+		if finishedAt.Valid {
+			r.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, &r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating runs")
+	}
+
+	return runs, nil
+}
+
+// AddRunStep records a step of a run, setting its ID
+func AddRunStep(ctx context.Context, db DBTX, step *RunStep) error {
+	if err := validateRunStatus(step.Status); err != nil {
+		return err
+	}
+
+	result, err := db.ExecContext(ctx,
+		`INSERT INTO run_steps (run_id, action_type, status, detail, error, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		step.RunID, step.ActionType, step.Status, step.Detail, step.Error, step.StartedAt.UTC(), step.FinishedAt.UTC(),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert run step")
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return errors.Wrap(err, "failed to get last insert id")
+	}
+
+	step.ID = id
+	return nil
+}
+
+// GetRunSteps retrieves the steps of a run in the order they finished
+func GetRunSteps(ctx context.Context, db DBTX, runID int64) ([]*RunStep, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, run_id, action_type, status, detail, error, started_at, finished_at
+		FROM run_steps WHERE run_id = ? ORDER BY id`,
+		runID,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query run steps")
+	}
+	defer rows.Close()
+
+	var steps []*RunStep
+	for rows.Next() {
+		var s RunStep
+		if err := rows.Scan(&s.ID, &s.RunID, &s.ActionType, &s.Status, &s.Detail, &s.Error, &s.StartedAt, &s.FinishedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan run step")
+		}
+		steps = append(steps, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating run steps")
+	}
+
+	return steps, nil
+}
+
+func validateRunStatus(status RunStatus) error {
+	switch status {
+	case RunStatusRunning, RunStatusSucceeded, RunStatusStopped, RunStatusFailed:
+		return nil
+	default:
+		return errors.Newf("invalid run status: %s", status)
+	}
+}
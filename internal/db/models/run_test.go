@@ -0,0 +1,82 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHistory(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		start := time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)
+
+		evening, err := store.CreateAutomation(ctx, "Evening", "")
+		require.NoError(t, err)
+		morning, err := store.CreateAutomation(ctx, "Morning", "")
+		require.NoError(t, err)
+
+		first, err := store.CreateRun(ctx, evening.ID, start)
+		require.NoError(t, err)
+		assert.Equal(t, RunStatusRunning, first.Status)
+		require.NoError(t, store.FinishRun(ctx, first.ID, RunStatusFailed, "webhook: 503 Service Unavailable", start.Add(time.Minute)))
+
+		second, err := store.CreateRun(ctx, evening.ID, start.Add(24*time.Hour))
+		require.NoError(t, err)
+		_, err = store.CreateRun(ctx, morning.ID, start.Add(12*time.Hour))
+		require.NoError(t, err)
+
+		_, err = store.CreateRun(ctx, evening.ID+1000, start)
+		assert.Error(t, err, "runs need an existing automation")
+		assert.True(t, errors.Is(store.FinishRun(ctx, 9999, RunStatusSucceeded, "", start), ErrNotFound))
+		assert.Error(t, store.FinishRun(ctx, second.ID, "exploded", "", start))
+
+		runs, err := store.ListRuns(ctx, evening.ID, 0)
+		require.NoError(t, err)
+		require.Len(t, runs, 2)
+		assert.Equal(t, second.ID, runs[0].ID, "newest first")
+		assert.Nil(t, runs[0].FinishedAt)
+		assert.Equal(t, RunStatusFailed, runs[1].Status)
+		assert.Equal(t, "webhook: 503 Service Unavailable", runs[1].Error)
+		require.NotNil(t, runs[1].FinishedAt)
+		assert.True(t, runs[1].FinishedAt.Equal(start.Add(time.Minute)))
+
+		runs, err = store.ListRuns(ctx, 0, 2)
+		require.NoError(t, err)
+		require.Len(t, runs, 2)
+		assert.Equal(t, morning.ID, runs[1].AutomationID)
+
+		for i, actionType := range []ActionType{ActionTypeScene, ActionTypeWebhook} {
+			step := &RunStep{
+				RunID:      first.ID,
+				ActionType: actionType,
+				Status:     RunStatusSucceeded,
+				Detail:     "ok",
+				StartedAt:  start.Add(time.Duration(i) * time.Second),
+				FinishedAt: start.Add(time.Duration(i+1) * time.Second),
+			}
+			require.NoError(t, store.AddRunStep(ctx, step))
+			assert.NotZero(t, step.ID)
+		}
+		assert.Error(t, store.AddRunStep(ctx, &RunStep{RunID: 9999, ActionType: ActionTypeScene, Status: RunStatusSucceeded}))
+
+		steps, err := store.GetRunSteps(ctx, first.ID)
+		require.NoError(t, err)
+		require.Len(t, steps, 2)
+		assert.Equal(t, ActionTypeWebhook, steps[1].ActionType)
+		assert.True(t, steps[1].FinishedAt.Equal(start.Add(2*time.Second)))
+
+		// History goes with the automation
+		require.NoError(t, store.DeleteAutomation(ctx, evening.ID))
+		runs, err = store.ListRuns(ctx, 0, 0)
+		require.NoError(t, err)
+		assert.Len(t, runs, 1)
+		steps, err = store.GetRunSteps(ctx, first.ID)
+		require.NoError(t, err)
+		assert.Empty(t, steps)
+	})
+}
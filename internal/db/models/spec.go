@@ -0,0 +1,221 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cockroachdb/errors"
+)
+
+// AutomationSpec is an automation together with its triggers, conditions and actions
+type AutomationSpec struct {
+	Automation
+	Triggers   []*Trigger   `json:"triggers"`
+	Conditions []*Condition `json:"conditions"`
+	Actions    []*Action    `json:"actions"`
+}
+
+// LoadAutomationSpec loads an automation and all its children in four queries
+func LoadAutomationSpec(ctx context.Context, db DBTX, id int64) (*AutomationSpec, error) {
+	specs, err := loadSpecs(ctx, db, "a.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(specs) == 0 {
+		return nil, notFound("automation", id)
+	}
+	return specs[0], nil
+}
+
+// LoadAllEnabledSpecs loads every enabled automation and its children in four queries,
+// newest first like ListEnabledAutomations
+func LoadAllEnabledSpecs(ctx context.Context, db DBTX) ([]*AutomationSpec, error) {
+	return loadSpecs(ctx, db, "a.enabled = 1")
+}
+
+// loadSpecs loads the automations matching where, a condition on automations aliased as a,
+// and fetches the children of all of them with one query per table
+func loadSpecs(ctx context.Context, db DBTX, where string, args ...interface{}) ([]*AutomationSpec, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT a.id, a.name, a.description, a.enabled, a.created_at, a.updated_at FROM automations a WHERE "+where+" ORDER BY a.created_at DESC",
+		args...,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query automations")
+	}
+
+	var specs []*AutomationSpec
+	byID := make(map[int64]*AutomationSpec)
+	for rows.Next() {
+		spec := &AutomationSpec{}
+		a := &spec.Automation
+		if err := rows.Scan(&a.ID, &a.Name, &a.Description, &a.Enabled, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, errors.Wrap(err, "failed to scan automation")
+		}
+		specs = append(specs, spec)
+		byID[a.ID] = spec
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating automations")
+	}
+
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	rows, err = db.QueryContext(ctx,
+		"SELECT t.id, t.automation_id, t.type, t.config FROM triggers t JOIN automations a ON a.id = t.automation_id WHERE "+where+" ORDER BY t.id",
+		args...,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query triggers")
+	}
+	for rows.Next() {
+		var t Trigger
+		var configStr string
+		if err := rows.Scan(&t.ID, &t.AutomationID, &t.Type, &configStr); err != nil {
+			rows.Close()
+			return nil, errors.Wrap(err, "failed to scan trigger")
+		}
+		t.Config = json.RawMessage(configStr)
+		byID[t.AutomationID].Triggers = append(byID[t.AutomationID].Triggers, &t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating triggers")
+	}
+
+	rows, err = db.QueryContext(ctx,
+		"SELECT c.id, c.automation_id, c.type, c.config FROM conditions c JOIN automations a ON a.id = c.automation_id WHERE "+where+" ORDER BY c.id",
+		args...,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query conditions")
+	}
+	for rows.Next() {
+		var c Condition
+		var configStr string
+		if err := rows.Scan(&c.ID, &c.AutomationID, &c.Type, &configStr); err != nil {
+			rows.Close()
+			return nil, errors.Wrap(err, "failed to scan condition")
+		}
+		c.Config = json.RawMessage(configStr)
+		byID[c.AutomationID].Conditions = append(byID[c.AutomationID].Conditions, &c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating conditions")
+	}
+
+	rows, err = db.QueryContext(ctx,
+		"SELECT x.id, x.automation_id, x.order_index, x.type, x.config FROM actions x JOIN automations a ON a.id = x.automation_id WHERE "+where+" ORDER BY x.automation_id, x.order_index, x.id",
+		args...,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query actions")
+	}
+	for rows.Next() {
+		var x Action
+		var configStr string
+		if err := rows.Scan(&x.ID, &x.AutomationID, &x.OrderIndex, &x.Type, &configStr); err != nil {
+			rows.Close()
+			return nil, errors.Wrap(err, "failed to scan action")
+		}
+		x.Config = json.RawMessage(configStr)
+		byID[x.AutomationID].Actions = append(byID[x.AutomationID].Actions, &x)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating actions")
+	}
+
+	return specs, nil
+}
+
+// saveAutomationSpec creates the automation if spec.ID is zero, or else updates it,
+// and replaces its children with those in spec. Actions are renumbered in slice order.
+// It must be called inside a transaction, and returns the spec as stored, with new IDs.
+func saveAutomationSpec(ctx context.Context, tx Store, spec *AutomationSpec) (*AutomationSpec, error) {
+	id := spec.ID
+	if id == 0 {
+		automation, err := tx.CreateAutomation(ctx, spec.Name, spec.Description)
+		if err != nil {
+			return nil, err
+		}
+		id = automation.ID
+	} else {
+		if err := tx.UpdateAutomation(ctx, id, spec.Name, spec.Description); err != nil {
+			return nil, err
+		}
+		if err := deleteChildren(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.SetEnabled(ctx, id, spec.Enabled); err != nil {
+		return nil, err
+	}
+
+	for _, t := range spec.Triggers {
+		if _, err := tx.CreateTrigger(ctx, id, t.Type, configOrEmpty(t.Config)); err != nil {
+			return nil, errors.Wrapf(err, "saving %s trigger", t.Type)
+		}
+	}
+	for _, c := range spec.Conditions {
+		if _, err := tx.CreateCondition(ctx, id, c.Type, configOrEmpty(c.Config)); err != nil {
+			return nil, errors.Wrapf(err, "saving %s condition", c.Type)
+		}
+	}
+	for i, a := range spec.Actions {
+		if _, err := tx.CreateAction(ctx, id, a.Type, configOrEmpty(a.Config), i); err != nil {
+			return nil, errors.Wrapf(err, "saving %s action", a.Type)
+		}
+	}
+
+	return tx.LoadAutomationSpec(ctx, id)
+}
+
+// deleteChildren removes every trigger, condition and action of an automation
+func deleteChildren(ctx context.Context, tx Store, id int64) error {
+	triggers, err := tx.GetTriggers(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, t := range triggers {
+		if err := tx.DeleteTrigger(ctx, t.ID); err != nil {
+			return err
+		}
+	}
+
+	conditions, err := tx.GetConditions(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, c := range conditions {
+		if err := tx.DeleteCondition(ctx, c.ID); err != nil {
+			return err
+		}
+	}
+
+	actions, err := tx.GetActions(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, a := range actions {
+		if err := tx.DeleteAction(ctx, a.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// configOrEmpty stores a missing config as an empty object rather than null
+func configOrEmpty(config json.RawMessage) json.RawMessage {
+	if len(config) == 0 {
+		return json.RawMessage("{}")
+	}
+	return config
+}
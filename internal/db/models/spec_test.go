@@ -0,0 +1,137 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSpec(name string) *AutomationSpec {
+	return &AutomationSpec{
+		Automation: Automation{Name: name, Description: "test", Enabled: true},
+		Triggers: []*Trigger{
+			{Type: TriggerTypeSunset, Config: json.RawMessage(`{"offset_minutes": -10}`)},
+		},
+		Conditions: []*Condition{
+			{Type: ConditionTypeWeekday},
+		},
+		Actions: []*Action{
+			{Type: ActionTypeScene, Config: json.RawMessage(`{"scene_id": "relax"}`)},
+			{Type: ActionTypeLight, Config: json.RawMessage(`{"light_id": "porch", "on": true}`)},
+		},
+	}
+}
+
+func TestSaveAndLoadAutomationSpec(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		spec := newTestSpec("Evening")
+		require.NoError(t, store.SaveAutomationSpec(ctx, spec))
+		require.NotZero(t, spec.ID)
+
+		loaded, err := store.LoadAutomationSpec(ctx, spec.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Evening", loaded.Name)
+		assert.True(t, loaded.Enabled)
+		require.Len(t, loaded.Triggers, 1)
+		assert.JSONEq(t, `{"offset_minutes": -10}`, string(loaded.Triggers[0].Config))
+		require.Len(t, loaded.Conditions, 1)
+		assert.JSONEq(t, `{}`, string(loaded.Conditions[0].Config))
+		require.Len(t, loaded.Actions, 2)
+		assert.Equal(t, ActionTypeScene, loaded.Actions[0].Type)
+		assert.Equal(t, 0, loaded.Actions[0].OrderIndex)
+		assert.Equal(t, 1, loaded.Actions[1].OrderIndex)
+
+		_, err = store.LoadAutomationSpec(ctx, spec.ID+1000)
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+}
+
+func TestSaveAutomationSpecReplacesChildren(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		spec := newTestSpec("Evening")
+		require.NoError(t, store.SaveAutomationSpec(ctx, spec))
+		id := spec.ID
+
+		spec.Description = "updated"
+		spec.Triggers = []*Trigger{{Type: TriggerTypeTime, Config: json.RawMessage(`{"hour": 20}`)}}
+		spec.Conditions = nil
+		spec.Actions = spec.Actions[1:]
+		require.NoError(t, store.SaveAutomationSpec(ctx, spec))
+		assert.Equal(t, id, spec.ID)
+
+		loaded, err := store.LoadAutomationSpec(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "updated", loaded.Description)
+		require.Len(t, loaded.Triggers, 1)
+		assert.Equal(t, TriggerTypeTime, loaded.Triggers[0].Type)
+		assert.Empty(t, loaded.Conditions)
+		require.Len(t, loaded.Actions, 1)
+		assert.Equal(t, ActionTypeLight, loaded.Actions[0].Type)
+		assert.Equal(t, 0, loaded.Actions[0].OrderIndex)
+	})
+}
+
+func TestSaveAutomationSpecIsAtomic(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		spec := newTestSpec("Evening")
+		require.NoError(t, store.SaveAutomationSpec(ctx, spec))
+
+		broken := *spec
+		broken.Description = "broken"
+		broken.Triggers = nil
+		broken.Actions = []*Action{{Type: ActionType("invalid")}}
+		require.Error(t, store.SaveAutomationSpec(ctx, &broken))
+
+		loaded, err := store.LoadAutomationSpec(ctx, spec.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "test", loaded.Description)
+		assert.Len(t, loaded.Triggers, 1)
+		assert.Len(t, loaded.Actions, 2)
+
+		require.Error(t, store.SaveAutomationSpec(ctx, &AutomationSpec{
+			Automation: Automation{Name: "New"},
+			Triggers:   []*Trigger{{Type: TriggerType("invalid")}},
+		}))
+		automations, err := store.ListAutomations(ctx)
+		require.NoError(t, err)
+		assert.Len(t, automations, 1)
+	})
+}
+
+func TestLoadAllEnabledSpecs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		for _, name := range []string{"One", "Two", "Three"} {
+			require.NoError(t, store.SaveAutomationSpec(ctx, newTestSpec(name)))
+		}
+
+		disabled := newTestSpec("Disabled")
+		disabled.Enabled = false
+		require.NoError(t, store.SaveAutomationSpec(ctx, disabled))
+
+		specs, err := store.LoadAllEnabledSpecs(ctx)
+		require.NoError(t, err)
+		require.Len(t, specs, 3)
+
+		for _, spec := range specs {
+			assert.NotEqual(t, "Disabled", spec.Name)
+			assert.Len(t, spec.Triggers, 1)
+			assert.Len(t, spec.Conditions, 1)
+			assert.Len(t, spec.Actions, 2)
+			for _, action := range spec.Actions {
+				assert.Equal(t, spec.ID, action.AutomationID)
+			}
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ErrNotFound is returned, wrapped, when a record doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// DBTX is the subset of *sql.DB and *sql.Tx the model functions use,
+// so they can run inside or outside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Store persists automations and their triggers, conditions and actions
+type Store interface {
+	CreateAutomation(ctx context.Context, name, description string) (*Automation, error)
+	GetAutomation(ctx context.Context, id int64) (*Automation, error)
+	ListAutomations(ctx context.Context) ([]*Automation, error)
+	ListEnabledAutomations(ctx context.Context) ([]*Automation, error)
+	UpdateAutomation(ctx context.Context, id int64, name, description string) error
+	SetEnabled(ctx context.Context, id int64, enabled bool) error
+	// DeleteAutomation deletes an automation along with its triggers, conditions and actions
+	DeleteAutomation(ctx context.Context, id int64) error
+
+	CreateTrigger(ctx context.Context, automationID int64, triggerType TriggerType, config interface{}) (*Trigger, error)
+	GetTriggers(ctx context.Context, automationID int64) ([]*Trigger, error)
+	DeleteTrigger(ctx context.Context, id int64) error
+
+	CreateCondition(ctx context.Context, automationID int64, conditionType ConditionType, config interface{}) (*Condition, error)
+	GetConditions(ctx context.Context, automationID int64) ([]*Condition, error)
+	DeleteCondition(ctx context.Context, id int64) error
+
+	CreateAction(ctx context.Context, automationID int64, actionType ActionType, config interface{}, orderIndex int) (*Action, error)
+	GetActions(ctx context.Context, automationID int64) ([]*Action, error)
+	DeleteAction(ctx context.Context, id int64) error
+
+	// CreateRun records the start of a run of an automation's actions
+	CreateRun(ctx context.Context, automationID int64, startedAt time.Time) (*Run, error)
+	FinishRun(ctx context.Context, id int64, status RunStatus, runErr string, finishedAt time.Time) error
+	// ListRuns returns an automation's most recent runs, or every automation's if
+	// automationID is 0, newest first. A limit of 0 returns them all.
+	ListRuns(ctx context.Context, automationID int64, limit int) ([]*Run, error)
+	AddRunStep(ctx context.Context, step *RunStep) error
+	GetRunSteps(ctx context.Context, runID int64) ([]*RunStep, error)
+
+	// LoadAutomationSpec returns an automation with all its children
+	LoadAutomationSpec(ctx context.Context, id int64) (*AutomationSpec, error)
+	// LoadAllEnabledSpecs returns every enabled automation with its children
+	LoadAllEnabledSpecs(ctx context.Context) ([]*AutomationSpec, error)
+	// SaveAutomationSpec creates or updates an automation and replaces its children
+	// atomically, then reloads spec with the stored IDs
+	SaveAutomationSpec(ctx context.Context, spec *AutomationSpec) error
+
+	// WithTx runs fn with a store whose changes are all committed if fn returns nil,
+	// and all discarded otherwise. Calling WithTx on that store joins the same transaction.
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// SQLStore is a Store backed by the SQLite database
+type SQLStore struct {
+	db DBTX
+	// sqlDB is nil once the store is bound to a transaction
+	sqlDB *sql.DB
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// NewSQLStore creates a store over an open, migrated database
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db, sqlDB: db}
+}
+
+func (s *SQLStore) CreateAutomation(ctx context.Context, name, description string) (*Automation, error) {
+	return CreateAutomation(ctx, s.db, name, description)
+}
+
+func (s *SQLStore) GetAutomation(ctx context.Context, id int64) (*Automation, error) {
+	return GetAutomation(ctx, s.db, id)
+}
+
+func (s *SQLStore) ListAutomations(ctx context.Context) ([]*Automation, error) {
+	return ListAutomations(ctx, s.db)
+}
+
+func (s *SQLStore) ListEnabledAutomations(ctx context.Context) ([]*Automation, error) {
+	return ListEnabledAutomations(ctx, s.db)
+}
+
+func (s *SQLStore) UpdateAutomation(ctx context.Context, id int64, name, description string) error {
+	return UpdateAutomation(ctx, s.db, id, name, description)
+}
+
+func (s *SQLStore) SetEnabled(ctx context.Context, id int64, enabled bool) error {
+	return SetEnabled(ctx, s.db, id, enabled)
+}
+
+func (s *SQLStore) DeleteAutomation(ctx context.Context, id int64) error {
+	return DeleteAutomation(ctx, s.db, id)
+}
+
+func (s *SQLStore) CreateTrigger(ctx context.Context, automationID int64, triggerType TriggerType, config interface{}) (*Trigger, error) {
+	return CreateTrigger(ctx, s.db, automationID, triggerType, config)
+}
+
+func (s *SQLStore) GetTriggers(ctx context.Context, automationID int64) ([]*Trigger, error) {
+	return GetTriggers(ctx, s.db, automationID)
+}
+
+func (s *SQLStore) DeleteTrigger(ctx context.Context, id int64) error {
+	return DeleteTrigger(ctx, s.db, id)
+}
+
+func (s *SQLStore) CreateCondition(ctx context.Context, automationID int64, conditionType ConditionType, config interface{}) (*Condition, error) {
+	return CreateCondition(ctx, s.db, automationID, conditionType, config)
+}
+
+func (s *SQLStore) GetConditions(ctx context.Context, automationID int64) ([]*Condition, error) {
+	return GetConditions(ctx, s.db, automationID)
+}
+
+func (s *SQLStore) DeleteCondition(ctx context.Context, id int64) error {
+	return DeleteCondition(ctx, s.db, id)
+}
+
+func (s *SQLStore) CreateAction(ctx context.Context, automationID int64, actionType ActionType, config interface{}, orderIndex int) (*Action, error) {
+	return CreateAction(ctx, s.db, automationID, actionType, config, orderIndex)
+}
+
+func (s *SQLStore) GetActions(ctx context.Context, automationID int64) ([]*Action, error) {
+	return GetActions(ctx, s.db, automationID)
+}
+
+func (s *SQLStore) DeleteAction(ctx context.Context, id int64) error {
+	return DeleteAction(ctx, s.db, id)
+}
+
+func (s *SQLStore) CreateRun(ctx context.Context, automationID int64, startedAt time.Time) (*Run, error) {
+	return CreateRun(ctx, s.db, automationID, startedAt)
+}
+
+func (s *SQLStore) FinishRun(ctx context.Context, id int64, status RunStatus, runErr string, finishedAt time.Time) error {
+	return FinishRun(ctx, s.db, id, status, runErr, finishedAt)
+}
+
+func (s *SQLStore) ListRuns(ctx context.Context, automationID int64, limit int) ([]*Run, error) {
+	return ListRuns(ctx, s.db, automationID, limit)
+}
+
+func (s *SQLStore) AddRunStep(ctx context.Context, step *RunStep) error {
+	return AddRunStep(ctx, s.db, step)
+}
+
+func (s *SQLStore) GetRunSteps(ctx context.Context, runID int64) ([]*RunStep, error) {
+	return GetRunSteps(ctx, s.db, runID)
+}
+
+func (s *SQLStore) LoadAutomationSpec(ctx context.Context, id int64) (*AutomationSpec, error) {
+	return LoadAutomationSpec(ctx, s.db, id)
+}
+
+func (s *SQLStore) LoadAllEnabledSpecs(ctx context.Context) ([]*AutomationSpec, error) {
+	return LoadAllEnabledSpecs(ctx, s.db)
+}
+
+func (s *SQLStore) SaveAutomationSpec(ctx context.Context, spec *AutomationSpec) error {
+	var saved *AutomationSpec
+	err := s.WithTx(ctx, func(tx Store) error {
+		var err error
+		saved, err = saveAutomationSpec(ctx, tx, spec)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	*spec = *saved
+	return nil
+}
+
+// WithTx runs fn in a database transaction
+func (s *SQLStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.sqlDB == nil {
+		return fn(s)
+	}
+
+	tx, err := s.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLStore{db: tx}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+
+	return nil
+}
+
+// notFound returns an error wrapping ErrNotFound for a missing record
+func notFound(kind string, id int64) error {
+	return errors.Mark(errors.Newf("%s with id %d not found", kind, id), ErrNotFound)
+}
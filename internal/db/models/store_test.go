@@ -0,0 +1,190 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// forEachStore runs a test against the SQLite and in-memory stores
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("sql", func(t *testing.T) {
+		test(t, NewSQLStore(setupTestDB(t)))
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+}
+
+func TestStoreLifecycle(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		automation, err := store.CreateAutomation(ctx, "Evening", "Lights at dusk")
+		require.NoError(t, err)
+		assert.True(t, automation.Enabled)
+
+		_, err = store.CreateAutomation(ctx, "Evening", "Duplicate")
+		assert.Error(t, err)
+
+		_, err = store.CreateTrigger(ctx, automation.ID, TriggerTypeSunset, map[string]interface{}{"offset_minutes": -15})
+		require.NoError(t, err)
+		_, err = store.CreateCondition(ctx, automation.ID, ConditionTypeWeekday, map[string]interface{}{})
+		require.NoError(t, err)
+		_, err = store.CreateAction(ctx, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "b"}, 1)
+		require.NoError(t, err)
+		_, err = store.CreateAction(ctx, automation.ID, ActionTypeLight, map[string]interface{}{"light_id": "a"}, 0)
+		require.NoError(t, err)
+
+		actions, err := store.GetActions(ctx, automation.ID)
+		require.NoError(t, err)
+		require.Len(t, actions, 2)
+		assert.Equal(t, ActionTypeLight, actions[0].Type)
+
+		_, err = store.CreateTrigger(ctx, automation.ID+1000, TriggerTypeTime, map[string]interface{}{})
+		assert.Error(t, err, "children need an existing automation")
+
+		require.NoError(t, store.SetEnabled(ctx, automation.ID, false))
+		enabled, err := store.ListEnabledAutomations(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, enabled)
+
+		require.NoError(t, store.DeleteAutomation(ctx, automation.ID))
+
+		_, err = store.GetAutomation(ctx, automation.ID)
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		triggers, err := store.GetTriggers(ctx, automation.ID)
+		require.NoError(t, err)
+		assert.Empty(t, triggers, "children are deleted with the automation")
+		conditions, err := store.GetConditions(ctx, automation.ID)
+		require.NoError(t, err)
+		assert.Empty(t, conditions)
+
+		err = store.DeleteTrigger(ctx, 9999)
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+}
+
+func TestStoreWithTxCommits(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		var id int64
+		err := store.WithTx(ctx, func(tx Store) error {
+			automation, err := tx.CreateAutomation(ctx, "Morning", "")
+			if err != nil {
+				return err
+			}
+			id = automation.ID
+
+			_, err = tx.CreateTrigger(ctx, automation.ID, TriggerTypeTime, map[string]interface{}{"hour": 7})
+			if err != nil {
+				return err
+			}
+
+			return tx.WithTx(ctx, func(nested Store) error {
+				_, err := nested.CreateAction(ctx, automation.ID, ActionTypeScene, map[string]interface{}{"scene_id": "x"}, 0)
+				return err
+			})
+		})
+		require.NoError(t, err)
+
+		automation, err := store.GetAutomation(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Morning", automation.Name)
+
+		triggers, err := store.GetTriggers(ctx, id)
+		require.NoError(t, err)
+		assert.Len(t, triggers, 1)
+		actions, err := store.GetActions(ctx, id)
+		require.NoError(t, err)
+		assert.Len(t, actions, 1)
+	})
+}
+
+func TestStoreWithTxRollsBack(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		err := store.WithTx(ctx, func(tx Store) error {
+			automation, err := tx.CreateAutomation(ctx, "Broken", "")
+			if err != nil {
+				return err
+			}
+			_, err = tx.CreateTrigger(ctx, automation.ID, TriggerType("invalid"), map[string]interface{}{})
+			return err
+		})
+		require.Error(t, err)
+
+		automations, err := store.ListAutomations(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, automations, "a failed transaction leaves nothing behind")
+
+		_, err = store.CreateAutomation(ctx, "Broken", "")
+		assert.NoError(t, err)
+	})
+}
+
+func TestStoreWithTxCancelled(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		called := false
+		err := store.WithTx(ctx, func(tx Store) error {
+			called = true
+			return nil
+		})
+		assert.Error(t, err)
+		assert.False(t, called)
+	})
+}
+
+func TestStoreReturnsCopies(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+
+		automation, err := store.CreateAutomation(ctx, "Evening", "")
+		require.NoError(t, err)
+		trigger, err := store.CreateTrigger(ctx, automation.ID, TriggerTypeTime, map[string]interface{}{"hour": 7})
+		require.NoError(t, err)
+		trigger.Config[2] = 'X'
+
+		triggers, err := store.GetTriggers(ctx, automation.ID)
+		require.NoError(t, err)
+		require.Len(t, triggers, 1)
+		assert.JSONEq(t, `{"hour": 7}`, string(triggers[0].Config))
+		triggers[0].Config[2] = 'X'
+
+		err = store.WithTx(ctx, func(tx Store) error {
+			triggers, err := tx.GetTriggers(ctx, automation.ID)
+			require.NoError(t, err)
+			triggers[0].Config[2] = 'X'
+			return errors.New("roll back")
+		})
+		require.Error(t, err)
+
+		triggers, err = store.GetTriggers(ctx, automation.ID)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"hour": 7}`, string(triggers[0].Config))
+
+		run, err := store.CreateRun(ctx, automation.ID, time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		finishedAt := time.Date(2026, 1, 1, 7, 0, 1, 0, time.UTC)
+		require.NoError(t, store.FinishRun(ctx, run.ID, RunStatusSucceeded, "", finishedAt))
+
+		runs, err := store.ListRuns(ctx, automation.ID, 0)
+		require.NoError(t, err)
+		require.Len(t, runs, 1)
+		*runs[0].FinishedAt = finishedAt.Add(time.Hour)
+
+		runs, err = store.ListRuns(ctx, automation.ID, 0)
+		require.NoError(t, err)
+		assert.True(t, finishedAt.Equal(*runs[0].FinishedAt))
+	})
+}
@@ -1,7 +1,7 @@
 package models
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 
 	"github.com/cockroachdb/errors"
@@ -15,6 +15,8 @@ const (
 	TriggerTypeSunrise  TriggerType = "sunrise"
 	TriggerTypeSunset   TriggerType = "sunset"
 	TriggerTypePresence TriggerType = "presence"
+	// TriggerTypeSunEvent fires at any astro.SunEvent, e.g. {"event": "civil_dusk", "offset_minutes": -10}
+	TriggerTypeSunEvent TriggerType = "sun_event"
 )
 
 // Trigger represents a trigger for an automation
@@ -26,7 +28,7 @@ type Trigger struct {
 }
 
 // CreateTrigger creates a new trigger
-func CreateTrigger(db *sql.DB, automationID int64, triggerType TriggerType, config interface{}) (*Trigger, error) {
+func CreateTrigger(ctx context.Context, db DBTX, automationID int64, triggerType TriggerType, config interface{}) (*Trigger, error) {
 	if err := validateTriggerType(triggerType); err != nil {
 		return nil, err
 	}
@@ -36,7 +38,7 @@ func CreateTrigger(db *sql.DB, automationID int64, triggerType TriggerType, conf
 		return nil, errors.Wrap(err, "failed to marshal trigger config")
 	}
 
-	result, err := db.Exec(
+	result, err := db.ExecContext(ctx,
 		"INSERT INTO triggers (automation_id, type, config) VALUES (?, ?, ?)",
 		automationID, triggerType, string(configJSON),
 	)
@@ -58,8 +60,8 @@ func CreateTrigger(db *sql.DB, automationID int64, triggerType TriggerType, conf
 }
 
 // GetTriggers retrieves all triggers for an automation
-func GetTriggers(db *sql.DB, automationID int64) ([]*Trigger, error) {
-	rows, err := db.Query(
+func GetTriggers(ctx context.Context, db DBTX, automationID int64) ([]*Trigger, error) {
+	rows, err := db.QueryContext(ctx,
 		"SELECT id, automation_id, type, config FROM triggers WHERE automation_id = ?",
 		automationID,
 	)
@@ -87,8 +89,8 @@ func GetTriggers(db *sql.DB, automationID int64) ([]*Trigger, error) {
 }
 
 // DeleteTrigger deletes a trigger
-func DeleteTrigger(db *sql.DB, id int64) error {
-	result, err := db.Exec("DELETE FROM triggers WHERE id = ?", id)
+func DeleteTrigger(ctx context.Context, db DBTX, id int64) error {
+	result, err := db.ExecContext(ctx, "DELETE FROM triggers WHERE id = ?", id)
 	if err != nil {
 		return errors.Wrap(err, "failed to delete trigger")
 	}
@@ -99,7 +101,7 @@ func DeleteTrigger(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.Newf("trigger with id %d not found", id)
+		return notFound("trigger", id)
 	}
 
 	return nil
@@ -108,7 +110,7 @@ func DeleteTrigger(db *sql.DB, id int64) error {
 // validateTriggerType validates that the trigger type is valid
 func validateTriggerType(t TriggerType) error {
 	switch t {
-	case TriggerTypeTime, TriggerTypeSunrise, TriggerTypeSunset, TriggerTypePresence:
+	case TriggerTypeTime, TriggerTypeSunrise, TriggerTypeSunset, TriggerTypePresence, TriggerTypeSunEvent:
 		return nil
 	default:
 		return errors.Newf("invalid trigger type: %s", t)
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -10,8 +11,9 @@ import (
 
 func TestCreateTrigger(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
 	config := map[string]interface{}{
@@ -19,7 +21,7 @@ func TestCreateTrigger(t *testing.T) {
 		"minute": 0,
 	}
 
-	trigger, err := CreateTrigger(database, automation.ID, TriggerTypeTime, config)
+	trigger, err := CreateTrigger(ctx, database, automation.ID, TriggerTypeTime, config)
 	require.NoError(t, err)
 	require.NotNil(t, trigger)
 
@@ -36,63 +38,68 @@ func TestCreateTrigger(t *testing.T) {
 
 func TestCreateTriggerInvalidType(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	_, err = CreateTrigger(database, automation.ID, TriggerType("invalid"), map[string]interface{}{})
+	_, err = CreateTrigger(ctx, database, automation.ID, TriggerType("invalid"), map[string]interface{}{})
 	assert.Error(t, err)
 }
 
 func TestGetTriggers(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	_, err = CreateTrigger(database, automation.ID, TriggerTypeTime, map[string]interface{}{"hour": 9})
+	_, err = CreateTrigger(ctx, database, automation.ID, TriggerTypeTime, map[string]interface{}{"hour": 9})
 	require.NoError(t, err)
 
-	_, err = CreateTrigger(database, automation.ID, TriggerTypeSunrise, map[string]interface{}{"offset_minutes": 0})
+	_, err = CreateTrigger(ctx, database, automation.ID, TriggerTypeSunrise, map[string]interface{}{"offset_minutes": 0})
 	require.NoError(t, err)
 
-	triggers, err := GetTriggers(database, automation.ID)
+	triggers, err := GetTriggers(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, triggers, 2)
 }
 
 func TestGetTriggersEmpty(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	triggers, err := GetTriggers(database, automation.ID)
+	triggers, err := GetTriggers(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, triggers, 0)
 }
 
 func TestDeleteTrigger(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	automation, err := CreateAutomation(database, "Test", "Test automation")
+	automation, err := CreateAutomation(ctx, database, "Test", "Test automation")
 	require.NoError(t, err)
 
-	trigger, err := CreateTrigger(database, automation.ID, TriggerTypeTime, map[string]interface{}{"hour": 9})
+	trigger, err := CreateTrigger(ctx, database, automation.ID, TriggerTypeTime, map[string]interface{}{"hour": 9})
 	require.NoError(t, err)
 
-	err = DeleteTrigger(database, trigger.ID)
+	err = DeleteTrigger(ctx, database, trigger.ID)
 	require.NoError(t, err)
 
-	triggers, err := GetTriggers(database, automation.ID)
+	triggers, err := GetTriggers(ctx, database, automation.ID)
 	require.NoError(t, err)
 	assert.Len(t, triggers, 0)
 }
 
 func TestDeleteTriggerNotFound(t *testing.T) {
 	database := setupTestDB(t)
+	ctx := context.Background()
 
-	err := DeleteTrigger(database, 999)
+	err := DeleteTrigger(ctx, database, 999)
 	assert.Error(t, err)
 }
 
@@ -122,6 +129,11 @@ func TestValidateTriggerType(t *testing.T) {
 			triggerType: TriggerTypePresence,
 			expectError: false,
 		},
+		{
+			name:        "valid sun_event trigger",
+			triggerType: TriggerTypeSunEvent,
+			expectError: false,
+		},
 		{
 			name:        "invalid trigger type",
 			triggerType: TriggerType("invalid"),
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Settings reads and writes machine-wide settings in the config table.
+// Per-bridge settings live in the config file instead, since they are needed
+// before the database is opened and differ between profiles.
+type Settings struct {
+	db *sql.DB
+}
+
+// Setting is a stored setting value
+type Setting struct {
+	Key       string
+	Value     string
+	UpdatedAt time.Time
+}
+
+// NewSettings creates a settings store over the config table
+func NewSettings(db *sql.DB) *Settings {
+	return &Settings{db: db}
+}
+
+// Get returns the raw value of a setting and whether it is set
+func (s *Settings) Get(ctx context.Context, key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM config WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.Wrapf(err, "failed to get setting %s", key)
+	}
+	return value, true, nil
+}
+
+// GetString returns a setting, or fallback if it is unset
+func (s *Settings) GetString(ctx context.Context, key, fallback string) (string, error) {
+	value, ok, err := s.Get(ctx, key)
+	if err != nil || !ok {
+		return fallback, err
+	}
+	return value, nil
+}
+
+// GetBool returns a boolean setting, or fallback if it is unset
+func (s *Settings) GetBool(ctx context.Context, key string, fallback bool) (bool, error) {
+	value, ok, err := s.Get(ctx, key)
+	if err != nil || !ok {
+		return fallback, err
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback, errors.Wrapf(err, "setting %s is not a boolean", key)
+	}
+	return parsed, nil
+}
+
+// GetInt returns an integer setting, or fallback if it is unset
+func (s *Settings) GetInt(ctx context.Context, key string, fallback int) (int, error) {
+	value, ok, err := s.Get(ctx, key)
+	if err != nil || !ok {
+		return fallback, err
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback, errors.Wrapf(err, "setting %s is not an integer", key)
+	}
+	return parsed, nil
+}
+
+// GetDuration returns a duration setting such as "720h", or fallback if it is unset
+func (s *Settings) GetDuration(ctx context.Context, key string, fallback time.Duration) (time.Duration, error) {
+	value, ok, err := s.Get(ctx, key)
+	if err != nil || !ok {
+		return fallback, err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback, errors.Wrapf(err, "setting %s is not a duration", key)
+	}
+	return parsed, nil
+}
+
+// Set stores a setting's raw value, replacing any previous value
+func (s *Settings) Set(ctx context.Context, key, value string) error {
+	if key == "" {
+		return errors.New("setting key cannot be empty")
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO config (key, value, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, key, value)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set setting %s", key)
+	}
+	return nil
+}
+
+// SetBool stores a boolean setting
+func (s *Settings) SetBool(ctx context.Context, key string, value bool) error {
+	return s.Set(ctx, key, strconv.FormatBool(value))
+}
+
+// SetInt stores an integer setting
+func (s *Settings) SetInt(ctx context.Context, key string, value int) error {
+	return s.Set(ctx, key, strconv.Itoa(value))
+}
+
+// SetDuration stores a duration setting
+func (s *Settings) SetDuration(ctx context.Context, key string, value time.Duration) error {
+	return s.Set(ctx, key, value.String())
+}
+
+// Delete removes a setting so its default applies. Deleting an unset setting is not an error.
+func (s *Settings) Delete(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM config WHERE key = ?", key); err != nil {
+		return errors.Wrapf(err, "failed to delete setting %s", key)
+	}
+	return nil
+}
+
+// List returns every stored setting, sorted by key
+func (s *Settings) List(ctx context.Context) ([]Setting, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT key, value, updated_at FROM config ORDER BY key")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list settings")
+	}
+	defer rows.Close()
+
+	var settings []Setting
+	for rows.Next() {
+		var setting Setting
+		if err := rows.Scan(&setting.Key, &setting.Value, &setting.UpdatedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan setting")
+		}
+		settings = append(settings, setting)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error iterating setting rows")
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettings(t *testing.T) {
+	db := setupTestDB(t)
+	require.NoError(t, RunMigrations(db))
+
+	ctx := context.Background()
+	settings := NewSettings(db)
+
+	_, ok, err := settings.Get(ctx, "geocoder.user_agent")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	value, err := settings.GetString(ctx, "geocoder.user_agent", "fallback")
+	require.NoError(t, err)
+	assert.Equal(t, "fallback", value)
+
+	require.NoError(t, settings.Set(ctx, "geocoder.user_agent", "limelight-test"))
+	require.NoError(t, settings.Set(ctx, "geocoder.user_agent", "limelight-test/2"))
+	value, err = settings.GetString(ctx, "geocoder.user_agent", "fallback")
+	require.NoError(t, err)
+	assert.Equal(t, "limelight-test/2", value)
+
+	require.NoError(t, settings.SetDuration(ctx, "geocoder.cache_ttl", 48*time.Hour))
+	ttl, err := settings.GetDuration(ctx, "geocoder.cache_ttl", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 48*time.Hour, ttl)
+
+	require.NoError(t, settings.SetBool(ctx, "feature.enabled", true))
+	enabled, err := settings.GetBool(ctx, "feature.enabled", false)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	require.NoError(t, settings.SetInt(ctx, "retries", 3))
+	retries, err := settings.GetInt(ctx, "retries", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, retries)
+
+	_, err = settings.GetInt(ctx, "geocoder.user_agent", 0)
+	assert.Error(t, err)
+
+	list, err := settings.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	assert.Equal(t, "feature.enabled", list[0].Key)
+	assert.False(t, list[0].UpdatedAt.IsZero())
+
+	require.NoError(t, settings.Delete(ctx, "retries"))
+	require.NoError(t, settings.Delete(ctx, "retries"))
+	retries, err = settings.GetInt(ctx, "retries", 5)
+	require.NoError(t, err)
+	assert.Equal(t, 5, retries)
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/errors"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	notificationsName      = "org.freedesktop.Notifications"
+	notificationsPath      = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notificationsInterface = "org.freedesktop.Notifications"
+
+	appName = "limelight"
+)
+
+// Desktop shows notifications through the desktop's notification server on
+// the D-Bus session bus
+type Desktop struct {
+	connect func() (*dbus.Conn, error)
+}
+
+// NewDesktop creates a sender for the session bus's notification server
+func NewDesktop() *Desktop {
+	return newDesktop(connectSessionBus)
+}
+
+func newDesktop(connect func() (*dbus.Conn, error)) *Desktop {
+	return &Desktop{connect: connect}
+}
+
+// connectSessionBus connects to an already running session bus
+func connectSessionBus() (*dbus.Conn, error) {
+	conn, err := dbus.SessionBusPrivateNoAutoStartup()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (d *Desktop) Send(ctx context.Context, msg Message) (string, error) {
+	conn, err := d.connect()
+	if err != nil {
+		return "", errors.Wrap(err, "connecting to d-bus session bus")
+	}
+	defer conn.Close()
+
+	hints := map[string]dbus.Variant{
+		"urgency": dbus.MakeVariant(urgency(msg.priority())),
+	}
+
+	var id uint32
+	err = conn.Object(notificationsName, notificationsPath).CallWithContext(ctx,
+		notificationsInterface+".Notify", 0,
+		appName, uint32(0), "", msg.Title, msg.Body, []string{}, hints, int32(-1),
+	).Store(&id)
+	if err != nil {
+		return "", errors.WithHint(
+			errors.Wrap(err, "showing desktop notification"),
+			"Desktop notifications need a notification server on the session bus, such as the one your desktop environment runs.",
+		)
+	}
+	return fmt.Sprintf("desktop notification %d", id), nil
+}
+
+// urgency maps a priority onto the notification spec's low (0), normal (1) and critical (2)
+func urgency(priority int) byte {
+	switch {
+	case priority <= 2:
+		return 0
+	case priority == 3:
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/mithilarun/limelight/internal/testutil/dbustest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNotifications is a notification server that records what it is asked to show
+type fakeNotifications struct {
+	mu    sync.Mutex
+	shown []shownNotification
+}
+
+type shownNotification struct {
+	app, summary, body string
+	urgency            byte
+}
+
+func (f *fakeNotifications) Notify(app string, replacesID uint32, icon, summary, body string,
+	actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var urgency byte
+	if v, ok := hints["urgency"]; ok {
+		urgency, _ = v.Value().(byte)
+	}
+	f.shown = append(f.shown, shownNotification{app: app, summary: summary, body: body, urgency: urgency})
+	return uint32(len(f.shown)), nil
+}
+
+func TestDesktop(t *testing.T) {
+	connect := dbustest.Connector(dbustest.StartSessionBus(t))
+
+	desktop := newDesktop(connect)
+	_, err := desktop.Send(context.Background(), Message{Title: "Lights", Body: "All off"})
+	assert.Error(t, err, "no notification server is running yet")
+
+	conn, err := connect()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	server := &fakeNotifications{}
+	require.NoError(t, conn.Export(server, notificationsPath, notificationsInterface))
+	reply, err := conn.RequestName(notificationsName, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+
+	detail, err := desktop.Send(context.Background(), Message{Title: "Lights", Body: "All off", Priority: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "desktop notification 1", detail)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, []shownNotification{{app: "limelight", summary: "Lights", body: "All off", urgency: 2}}, server.shown)
+}
@@ -0,0 +1,158 @@
+// Package notify sends notifications to the desktop, ntfy, Gotify or an email address.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// DefaultPriority is the priority of a message that doesn't set one
+	DefaultPriority = 3
+	// maxResponseSize bounds how much of a server's reply is read
+	maxResponseSize = 64 << 10
+)
+
+// Message is a notification. Priority runs from 1, the lowest, to 5, and
+// 0 means DefaultPriority; each service maps it to its own scale.
+type Message struct {
+	Title    string
+	Body     string
+	Priority int
+}
+
+// Sender delivers notifications through one service. Send returns a short
+// description of the delivery, such as the ID the service assigned.
+type Sender interface {
+	Send(ctx context.Context, msg Message) (string, error)
+}
+
+// ValidatePriority checks that a priority is 0 or between 1 and 5
+func ValidatePriority(priority int) error {
+	if priority < 0 || priority > 5 {
+		return errors.Newf("priority %d is not between 1 and 5", priority)
+	}
+	return nil
+}
+
+func (m Message) priority() int {
+	if m.Priority == 0 {
+		return DefaultPriority
+	}
+	return m.Priority
+}
+
+// Ntfy publishes to an ntfy topic, such as https://ntfy.sh/my-lights
+type Ntfy struct {
+	// URL is the topic's URL
+	URL string
+	// Token is an access token, sent as a bearer token if set
+	Token string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+func (n *Ntfy) Send(ctx context.Context, msg Message) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(msg.Body))
+	if err != nil {
+		return "", errors.Wrap(err, "creating ntfy request")
+	}
+	if msg.Title != "" {
+		req.Header.Set("Title", msg.Title)
+	}
+	// ntfy's priorities run from 1 to 5 as well
+	req.Header.Set("Priority", strconv.Itoa(msg.priority()))
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	var reply struct {
+		ID string `json:"id"`
+	}
+	if err := do(httpClient(n.HTTPClient), req, "ntfy", &reply); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("published to ntfy as %s", reply.ID), nil
+}
+
+// Gotify posts a message to a Gotify server
+type Gotify struct {
+	// URL is the server's base URL, such as https://gotify.example.com
+	URL string
+	// Token is an application token
+	Token string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+func (g *Gotify) Send(ctx context.Context, msg Message) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    msg.Title,
+		"message":  msg.Body,
+		"priority": gotifyPriority(msg.priority()),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "encoding gotify message")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(g.URL, "/")+"/message", bytes.NewReader(body))
+	if err != nil {
+		return "", errors.Wrap(err, "creating gotify request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", g.Token)
+
+	var reply struct {
+		ID int64 `json:"id"`
+	}
+	if err := do(httpClient(g.HTTPClient), req, "gotify", &reply); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("posted to gotify as message %d", reply.ID), nil
+}
+
+// gotifyPriority maps 1-5 onto Gotify's 0-10, where clients notify from 4 and
+// alert from 8
+func gotifyPriority(priority int) int {
+	return []int{0, 2, 5, 8, 10}[priority-1]
+}
+
+func httpClient(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
+
+// do sends req and decodes a JSON reply into out, treating any status but 2xx as an error
+func do(client *http.Client, req *http.Request, service string, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "sending to %s", service)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return errors.Wrapf(err, "reading %s response", service)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Newf("%s returned %s: %s", service, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	// Some proxies answer with an empty body; the message was still accepted
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return errors.Wrapf(err, "parsing %s response", service)
+		}
+	}
+	return nil
+}
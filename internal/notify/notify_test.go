@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNtfy(t *testing.T) {
+	var got *http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		got, body = r, string(data)
+		fmt.Fprint(w, `{"id": "abc123", "topic": "lights"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	n := &Ntfy{URL: server.URL + "/lights", Token: "tk_secret"}
+	detail, err := n.Send(context.Background(), Message{Title: "Front door", Body: "Porch light on", Priority: 4})
+	require.NoError(t, err)
+	assert.Equal(t, "published to ntfy as abc123", detail)
+
+	assert.Equal(t, "/lights", got.URL.Path)
+	assert.Equal(t, "Front door", got.Header.Get("Title"))
+	assert.Equal(t, "4", got.Header.Get("Priority"))
+	assert.Equal(t, "Bearer tk_secret", got.Header.Get("Authorization"))
+	assert.Equal(t, "Porch light on", body)
+}
+
+func TestGotify(t *testing.T) {
+	var got map[string]interface{}
+	var key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/message" {
+			http.NotFound(w, r)
+			return
+		}
+		key = r.Header.Get("X-Gotify-Key")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		fmt.Fprint(w, `{"id": 25}`)
+	}))
+	t.Cleanup(server.Close)
+
+	g := &Gotify{URL: server.URL + "/", Token: "app-token"}
+	detail, err := g.Send(context.Background(), Message{Title: "Lights", Body: "All off"})
+	require.NoError(t, err)
+	assert.Equal(t, "posted to gotify as message 25", detail)
+	assert.Equal(t, "app-token", key)
+	assert.Equal(t, map[string]interface{}{"title": "Lights", "message": "All off", "priority": float64(5)}, got)
+}
+
+func TestHTTPSenderErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := (&Ntfy{URL: server.URL}).Send(context.Background(), Message{Body: "hi"})
+	assert.ErrorContains(t, err, "ntfy returned 401 Unauthorized")
+	_, err = (&Gotify{URL: server.URL}).Send(context.Background(), Message{Body: "hi"})
+	assert.ErrorContains(t, err, "gotify returned 401 Unauthorized")
+}
+
+func TestValidatePriority(t *testing.T) {
+	for _, p := range []int{0, 1, 5} {
+		assert.NoError(t, ValidatePriority(p))
+	}
+	for _, p := range []int{-1, 6} {
+		assert.Error(t, ValidatePriority(p))
+	}
+	assert.Equal(t, []int{0, 2, 5, 8, 10}, []int{gotifyPriority(1), gotifyPriority(2), gotifyPriority(3), gotifyPriority(4), gotifyPriority(5)})
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// implicitTLSPort is the submissions port, where TLS starts before SMTP does
+const implicitTLSPort = 465
+
+// SMTP emails notifications. On port 465 the connection is TLS from the start;
+// on other ports it is upgraded with STARTTLS when the server offers it.
+type SMTP struct {
+	Host string
+	// Port defaults to 587
+	Port int
+	// Username and Password, if set, are sent with PLAIN authentication, which
+	// net/smtp only allows over TLS or to localhost
+	Username string
+	Password string
+	From     string
+	To       []string
+	// TLSConfig overrides the TLS settings, for servers with private certificates
+	TLSConfig *tls.Config
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) (string, error) {
+	if len(s.To) == 0 {
+		return "", errors.New("smtp needs at least one recipient")
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(port))
+	tlsConfig := s.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: s.Host}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", errors.Wrapf(err, "connecting to %s", addr)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// net/smtp doesn't take a context; closing the connection ends a cancelled send
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if port == implicitTLSPort {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return "", errors.Wrapf(err, "starting smtp session with %s", addr)
+	}
+	defer client.Close()
+
+	if port != implicitTLSPort {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return "", errors.Wrap(err, "starting tls")
+			}
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return "", errors.Wrap(err, "authenticating")
+		}
+	}
+
+	if err := client.Mail(s.From); err != nil {
+		return "", errors.Wrapf(err, "sender %s", s.From)
+	}
+	for _, to := range s.To {
+		if err := client.Rcpt(to); err != nil {
+			return "", errors.Wrapf(err, "recipient %s", to)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return "", errors.Wrap(err, "starting message")
+	}
+	if _, err := w.Write(s.message(msg, time.Now())); err != nil {
+		return "", errors.Wrap(err, "writing message")
+	}
+	if err := w.Close(); err != nil {
+		return "", errors.Wrap(err, "sending message")
+	}
+	if err := client.Quit(); err != nil {
+		return "", errors.Wrap(err, "ending smtp session")
+	}
+
+	return fmt.Sprintf("emailed %s", strings.Join(s.To, ", ")), nil
+}
+
+// message formats msg as a plain text email
+func (s *SMTP) message(msg Message, now time.Time) []byte {
+	var b strings.Builder
+	header := func(key, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", key, value)
+	}
+	header("From", s.From)
+	header("To", strings.Join(s.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Title))
+	header("Date", now.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	if msg.priority() >= 4 {
+		header("X-Priority", "1")
+		header("Importance", "high")
+	}
+	b.WriteString("\r\n")
+
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	for _, line := range strings.Split(body, "\n") {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTPServer accepts one message per connection and records the session
+type fakeSMTPServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	commands []string
+	data     string
+}
+
+func startSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+
+		switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			reply("235 2.7.0 Authentication successful")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	server := startSMTPServer(t)
+
+	s := &SMTP{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Username: "lights",
+		Password: "secret",
+		From:     "limelight@example.com",
+		To:       []string{"me@example.com", "you@example.com"},
+	}
+	detail, err := s.Send(context.Background(), Message{Title: "Lights on", Body: "Porch\nand hallway", Priority: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "emailed me@example.com, you@example.com", detail)
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Contains(t, server.commands, "AUTH PLAIN AGxpZ2h0cwBzZWNyZXQ=")
+	assert.Contains(t, server.commands, "MAIL FROM:<limelight@example.com>")
+	assert.Contains(t, server.commands, "RCPT TO:<you@example.com>")
+	assert.Contains(t, server.data, "Subject: Lights on\r\n")
+	assert.Contains(t, server.data, "X-Priority: 1\r\n")
+	assert.True(t, strings.HasSuffix(server.data, "\r\n\r\nPorch\r\nand hallway\r\n"))
+}
+
+func TestSMTPMessage(t *testing.T) {
+	s := &SMTP{From: "a@example.com", To: []string{"b@example.com"}}
+	msg := string(s.message(Message{Title: "Lumière", Body: "hi"}, time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)))
+	assert.Contains(t, msg, "Subject: =?utf-8?q?Lumi=C3=A8re?=\r\n")
+	assert.Contains(t, msg, "Date: Mon, 19 Oct 2026 20:00:00 +0000\r\n")
+	assert.NotContains(t, msg, "X-Priority")
+}
+
+func TestSMTPUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	s := &SMTP{Host: "127.0.0.1", Port: port, From: "a@example.com", To: []string{"b@example.com"}}
+	_, err = s.Send(context.Background(), Message{Body: "hi"})
+	assert.ErrorContains(t, err, "connecting to 127.0.0.1:"+strconv.Itoa(port))
+
+	_, err = (&SMTP{Host: "127.0.0.1"}).Send(context.Background(), Message{Body: "hi"})
+	assert.ErrorContains(t, err, "at least one recipient")
+}
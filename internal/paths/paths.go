@@ -0,0 +1,54 @@
+// Package paths locates limelight's files following the XDG base directory specification.
+//
+// Configuration goes in $XDG_CONFIG_HOME/limelight (~/.config/limelight), the
+// database in $XDG_DATA_HOME/limelight (~/.local/share/limelight), and state
+// such as rotating backups in $XDG_STATE_HOME/limelight (~/.local/state/limelight).
+package paths
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+)
+
+const appName = "limelight"
+
+// ConfigDir returns the limelight config directory, without creating it
+func ConfigDir() (string, error) {
+	return appDir("XDG_CONFIG_HOME", ".config")
+}
+
+// DataDir returns the limelight data directory, without creating it
+func DataDir() (string, error) {
+	return appDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// StateDir returns the limelight state directory, without creating it
+func StateDir() (string, error) {
+	return appDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// LegacyDir returns ~/.config/limelight, where every file lived before XDG
+// directories were supported, regardless of XDG_CONFIG_HOME
+func LegacyDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "getting user home directory")
+	}
+	return filepath.Join(homeDir, ".config", appName), nil
+}
+
+// appDir returns the limelight directory under the base named by env, or under
+// fallback in the home directory. Relative values are ignored, as the spec requires.
+func appDir(env, fallback string) (string, error) {
+	if base := os.Getenv(env); base != "" && filepath.IsAbs(base) {
+		return filepath.Join(base, appName), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "getting user home directory")
+	}
+	return filepath.Join(homeDir, fallback, appName), nil
+}
@@ -0,0 +1,60 @@
+package paths
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirsDefaultToHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+
+	tests := []struct {
+		name     string
+		dir      func() (string, error)
+		expected string
+	}{
+		{"config", ConfigDir, filepath.Join(home, ".config", "limelight")},
+		{"data", DataDir, filepath.Join(home, ".local", "share", "limelight")},
+		{"state", StateDir, filepath.Join(home, ".local", "state", "limelight")},
+		{"legacy", LegacyDir, filepath.Join(home, ".config", "limelight")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := tt.dir()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, dir)
+		})
+	}
+}
+
+func TestDirsFollowXDG(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+	t.Setenv("XDG_STATE_HOME", "relative/state")
+
+	dir, err := ConfigDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/xdg/config", "limelight"), dir)
+
+	dir, err = DataDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/xdg/data", "limelight"), dir)
+
+	dir, err = StateDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".local", "state", "limelight"), dir, "relative XDG paths are ignored")
+
+	dir, err = LegacyDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".config", "limelight"), dir)
+}
@@ -0,0 +1,61 @@
+// Package dbustest runs a private D-Bus session bus for tests of code that talks
+// to desktop services.
+package dbustest
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// StartSessionBus runs a private dbus-daemon for the test and returns its address.
+// The test is skipped when dbus-daemon isn't installed.
+func StartSessionBus(t testing.TB) string {
+	t.Helper()
+
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+
+	dir := t.TempDir()
+	config := fmt.Sprintf(`<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>`, filepath.Join(dir, "bus"))
+	configPath := filepath.Join(dir, "bus.conf")
+	require.NoError(t, os.WriteFile(configPath, []byte(config), 0600))
+
+	cmd := exec.Command(daemon, "--config-file", configPath, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	return strings.TrimSpace(address)
+}
+
+// Connector returns a function that opens a new connection to the bus at address
+func Connector(address string) func() (*dbus.Conn, error) {
+	return func() (*dbus.Conn, error) {
+		return dbus.Connect(address)
+	}
+}